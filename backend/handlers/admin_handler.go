@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"lambda-runner-server/models"
+	"lambda-runner-server/services"
+)
+
+type AdminHandler struct {
+	rateLimits *services.RateLimitService
+	retention  *services.RetentionService
+}
+
+func NewAdminHandler(rateLimits *services.RateLimitService, retention *services.RetentionService) *AdminHandler {
+	return &AdminHandler{rateLimits: rateLimits, retention: retention}
+}
+
+// ListRateLimits godoc
+// @Summary List rate limits and quotas
+// @Tags admin
+// @Produce json
+// @Success 200 {array} models.RateLimit
+// @Router /admin/rate-limits [get]
+func (h *AdminHandler) ListRateLimits(c *fiber.Ctx) error {
+	limits, err := h.rateLimits.ListRateLimits(c.UserContext())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(limits)
+}
+
+// UpsertRateLimit godoc
+// @Summary Create or replace a rate limit
+// @Description Set the rate limit and quotas of a function or caller ("*" sets the scope default)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param limit body models.UpsertRateLimitRequest true "Rate limit"
+// @Success 200 {object} models.RateLimit
+// @Failure 400 {object} map[string]string
+// @Router /admin/rate-limits [put]
+func (h *AdminHandler) UpsertRateLimit(c *fiber.Ctx) error {
+	var req models.UpsertRateLimitRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	limit, err := h.rateLimits.UpsertRateLimit(c.UserContext(), &req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(limit)
+}
+
+// DeleteRateLimit godoc
+// @Summary Delete a rate limit
+// @Tags admin
+// @Param limitId path int true "Rate limit ID"
+// @Success 204
+// @Failure 404 {object} map[string]string
+// @Router /admin/rate-limits/{limitId} [delete]
+func (h *AdminHandler) DeleteRateLimit(c *fiber.Ctx) error {
+	limitID, err := strconv.ParseInt(c.Params("limitId"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid rate limit ID"})
+	}
+
+	if err := h.rateLimits.DeleteRateLimit(c.UserContext(), limitID); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// GetQuotaUsage godoc
+// @Summary Get quota usage
+// @Description Get the current daily and monthly usage of a function or caller
+// @Tags admin
+// @Produce json
+// @Param scope path string true "Scope (function or caller)"
+// @Param subject path string true "Function ID or caller"
+// @Success 200 {object} models.QuotaUsage
+// @Failure 400 {object} map[string]string
+// @Router /admin/quotas/{scope}/{subject} [get]
+func (h *AdminHandler) GetQuotaUsage(c *fiber.Ctx) error {
+	usage, err := h.rateLimits.GetUsage(c.UserContext(), c.Params("scope"), c.Params("subject"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(usage)
+}
+
+// GetRetentionStatus godoc
+// @Summary Get table sizes and retention status
+// @Description Get the disk usage of every table, the retention policies and the results of the invocation purges run by this instance
+// @Tags admin
+// @Produce json
+// @Success 200 {object} models.RetentionStatus
+// @Router /admin/retention [get]
+func (h *AdminHandler) GetRetentionStatus(c *fiber.Ctx) error {
+	status, err := h.retention.Status(c.UserContext())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(status)
+}
+
+// ListRetentionPolicies godoc
+// @Summary List invocation retention policies
+// @Tags admin
+// @Produce json
+// @Success 200 {array} models.RetentionPolicy
+// @Router /admin/retention/policies [get]
+func (h *AdminHandler) ListRetentionPolicies(c *fiber.Ctx) error {
+	policies, err := h.retention.ListPolicies(c.UserContext())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(policies)
+}
+
+// UpsertRetentionPolicy godoc
+// @Summary Create or replace a retention policy
+// @Description Set how long finished invocations of a function are kept, by age and/or count; without function_id it sets the global policy
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param policy body models.UpsertRetentionPolicyRequest true "Retention policy"
+// @Success 200 {object} models.RetentionPolicy
+// @Failure 400 {object} map[string]string
+// @Router /admin/retention/policies [put]
+func (h *AdminHandler) UpsertRetentionPolicy(c *fiber.Ctx) error {
+	var req models.UpsertRetentionPolicyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	policy, err := h.retention.UpsertPolicy(c.UserContext(), &req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(policy)
+}
+
+// DeleteRetentionPolicy godoc
+// @Summary Delete a retention policy
+// @Tags admin
+// @Param policyId path int true "Retention policy ID"
+// @Success 204
+// @Failure 404 {object} map[string]string
+// @Router /admin/retention/policies/{policyId} [delete]
+func (h *AdminHandler) DeleteRetentionPolicy(c *fiber.Ctx) error {
+	policyID, err := strconv.ParseInt(c.Params("policyId"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid retention policy ID"})
+	}
+
+	if err := h.retention.DeletePolicy(c.UserContext(), policyID); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
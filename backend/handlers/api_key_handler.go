@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"lambda-runner-server/middleware"
+	"lambda-runner-server/models"
+	"lambda-runner-server/services"
+)
+
+type APIKeyHandler struct {
+	service *services.APIKeyService
+}
+
+func NewAPIKeyHandler(service *services.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{service: service}
+}
+
+// CreateAPIKey godoc
+// @Summary Create an API key
+// @Description Issue an API key with the given scopes (read, write, invoke, admin). The key is only returned in this response.
+// @Tags api-keys
+// @Accept json
+// @Produce json
+// @Param request body models.CreateAPIKeyRequest true "API key"
+// @Success 201 {object} models.CreateAPIKeyResponse
+// @Failure 400 {object} map[string]string
+// @Router /api-keys [post]
+func (h *APIKeyHandler) CreateAPIKey(c *fiber.Ctx) error {
+	var req models.CreateAPIKeyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	key, err := h.service.CreateKey(c.UserContext(), &req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusCreated).JSON(key)
+}
+
+// ListAPIKeys godoc
+// @Summary List API keys
+// @Description List all API keys, including revoked and expired ones. Secrets are never returned.
+// @Tags api-keys
+// @Produce json
+// @Success 200 {array} models.APIKey
+// @Router /api-keys [get]
+func (h *APIKeyHandler) ListAPIKeys(c *fiber.Ctx) error {
+	keys, err := h.service.ListKeys(c.UserContext())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(keys)
+}
+
+// GetCurrentAPIKey godoc
+// @Summary Get the calling API key
+// @Description Return the API key used for this request, e.g. to check its scopes
+// @Tags api-keys
+// @Produce json
+// @Success 200 {object} models.APIKey
+// @Router /api-keys/me [get]
+func (h *APIKeyHandler) GetCurrentAPIKey(c *fiber.Ctx) error {
+	return c.JSON(middleware.APIKeyFromContext(c.UserContext()))
+}
+
+// RevokeAPIKey godoc
+// @Summary Revoke an API key
+// @Tags api-keys
+// @Produce json
+// @Param keyId path int true "API key ID"
+// @Success 200 {object} models.APIKey
+// @Failure 404 {object} map[string]string
+// @Router /api-keys/{keyId} [delete]
+func (h *APIKeyHandler) RevokeAPIKey(c *fiber.Ctx) error {
+	keyID, err := strconv.ParseInt(c.Params("keyId"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid API key ID"})
+	}
+
+	key, err := h.service.RevokeKey(c.UserContext(), keyID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(key)
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"bufio"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"lambda-runner-server/services"
+)
+
+const ndjsonContentType = "application/x-ndjson"
+
+type AuditHandler struct {
+	service *services.AuditService
+}
+
+func NewAuditHandler(service *services.AuditService) *AuditHandler {
+	return &AuditHandler{service: service}
+}
+
+// ListAuditEvents godoc
+// @Summary List audit events
+// @Description List changes to functions, schedules, triggers, workflows, event rules, API keys, namespaces and rate limits, newest first. With format=ndjson (or Accept: application/x-ndjson) every matching event is exported as newline-delimited JSON, oldest first, and limit/before_id are ignored.
+// @Tags audit
+// @Produce json
+// @Produce application/x-ndjson
+// @Param namespace query string false "Filter by namespace name"
+// @Param actor query string false "Filter by actor (key:<id>, user:<name> or system)"
+// @Param action query string false "Filter by action (create, update, delete)"
+// @Param resource_type query string false "Filter by resource type"
+// @Param resource_id query string false "Filter by resource ID"
+// @Param from query string false "Only events at or after this time (RFC 3339)"
+// @Param to query string false "Only events before this time (RFC 3339)"
+// @Param before_id query int false "Only events older than this event, for paging"
+// @Param limit query int false "Maximum number of events (default 100, max 1000)"
+// @Param format query string false "json or ndjson"
+// @Success 200 {array} models.AuditEvent
+// @Failure 400 {object} map[string]string
+// @Router /audit [get]
+func (h *AuditHandler) ListAuditEvents(c *fiber.Ctx) error {
+	filter := services.AuditFilter{
+		Namespace:    c.Query("namespace"),
+		Actor:        c.Query("actor"),
+		Action:       c.Query("action"),
+		ResourceType: c.Query("resource_type"),
+		ResourceID:   c.Query("resource_id"),
+		BeforeID:     int64(c.QueryInt("before_id")),
+	}
+	for name, t := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if value := c.Query(name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": name + " must be an RFC 3339 time"})
+			}
+			*t = parsed
+		}
+	}
+
+	if c.Query("format") == "ndjson" || strings.Contains(c.Get(fiber.HeaderAccept), ndjsonContentType) {
+		filter.BeforeID = 0
+		ctx := c.UserContext()
+		c.Set(fiber.HeaderContentType, ndjsonContentType)
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			if err := h.service.ExportEvents(ctx, filter, w); err != nil {
+				log.Printf("audit: export failed: %v", err)
+			}
+			w.Flush()
+		})
+		return nil
+	}
+
+	events, err := h.service.ListEvents(c.UserContext(), filter, c.QueryInt("limit"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(events)
+}
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"lambda-runner-server/models"
+	"lambda-runner-server/services"
+)
+
+type EventHandler struct {
+	service *services.EventBusService
+}
+
+func NewEventHandler(service *services.EventBusService) *EventHandler {
+	return &EventHandler{service: service}
+}
+
+// PublishEvent godoc
+// @Summary Publish an event
+// @Description Publish a CloudEvent (structured JSON mode) to the event bus. Functions targeted by matching rules are invoked with the event. Re-publishing a source/id pair returns the original result.
+// @Tags events
+// @Accept json
+// @Produce json
+// @Param event body models.PublishEventRequest true "CloudEvent"
+// @Success 202 {object} models.PublishEventResponse
+// @Success 200 {object} models.PublishEventResponse "Duplicate event"
+// @Failure 400 {object} map[string]string
+// @Router /events [post]
+func (h *EventHandler) PublishEvent(c *fiber.Ctx) error {
+	var req models.PublishEventRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	response, err := h.service.Publish(c.UserContext(), &req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if response.Duplicate {
+		return c.JSON(response)
+	}
+	return c.Status(fiber.StatusAccepted).JSON(response)
+}
+
+// ListEvents godoc
+// @Summary List events
+// @Tags events
+// @Produce json
+// @Param source query string false "Filter by source"
+// @Param type query string false "Filter by type"
+// @Param limit query int false "Maximum number of events (default 20, max 100)"
+// @Success 200 {array} models.Event
+// @Router /events [get]
+func (h *EventHandler) ListEvents(c *fiber.Ctx) error {
+	events, err := h.service.ListEvents(c.UserContext(), c.Query("source"), c.Query("type"), c.QueryInt("limit", 20))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(events)
+}
+
+// GetEvent godoc
+// @Summary Get an event
+// @Description Get a stored event with every rule match and resulting invocation, including replays
+// @Tags events
+// @Produce json
+// @Param seq path int true "Event sequence number"
+// @Success 200 {object} models.EventDetail
+// @Failure 404 {object} map[string]string
+// @Router /events/{seq} [get]
+func (h *EventHandler) GetEvent(c *fiber.Ctx) error {
+	seq, err := strconv.ParseInt(c.Params("seq"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid event sequence number"})
+	}
+
+	event, err := h.service.GetEvent(c.UserContext(), seq)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(event)
+}
+
+// ReplayEvent godoc
+// @Summary Replay an event
+// @Description Route a stored event again through all enabled rules, or only through rule_id (even if disabled)
+// @Tags events
+// @Produce json
+// @Param seq path int true "Event sequence number"
+// @Param rule_id query int false "Only replay through this rule"
+// @Success 200 {object} models.ReplayEventsResponse
+// @Failure 404 {object} map[string]string
+// @Router /events/{seq}/replay [post]
+func (h *EventHandler) ReplayEvent(c *fiber.Ctx) error {
+	seq, err := strconv.ParseInt(c.Params("seq"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid event sequence number"})
+	}
+
+	response, err := h.service.ReplayEvent(c.UserContext(), seq, int64(c.QueryInt("rule_id", 0)))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(response)
+}
+
+// ReplayEvents godoc
+// @Summary Replay events of a time window
+// @Description Route the events received in [from, to) again, in publish order (at most 1000 events)
+// @Tags events
+// @Accept json
+// @Produce json
+// @Param request body models.ReplayEventsRequest true "Replay window"
+// @Success 200 {object} models.ReplayEventsResponse
+// @Failure 400 {object} map[string]string
+// @Router /events/replay [post]
+func (h *EventHandler) ReplayEvents(c *fiber.Ctx) error {
+	var req models.ReplayEventsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	response, err := h.service.ReplayEvents(c.UserContext(), &req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(response)
+}
+
+// CreateRule godoc
+// @Summary Create an event rule
+// @Description Route events matching a JSON pattern to one or more functions
+// @Tags events
+// @Accept json
+// @Produce json
+// @Param rule body models.EventRuleRequest true "Event rule"
+// @Success 201 {object} models.EventRule
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /event-rules [post]
+func (h *EventHandler) CreateRule(c *fiber.Ctx) error {
+	var req models.EventRuleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	rule, err := h.service.SaveRule(c.UserContext(), 0, &req)
+	if errors.Is(err, services.ErrEventRuleNameTaken) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return functionError(c, fiber.StatusBadRequest, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(rule)
+}
+
+// ListRules godoc
+// @Summary List event rules
+// @Tags events
+// @Produce json
+// @Success 200 {array} models.EventRule
+// @Router /event-rules [get]
+func (h *EventHandler) ListRules(c *fiber.Ctx) error {
+	rules, err := h.service.ListRules(c.UserContext())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(rules)
+}
+
+// GetRule godoc
+// @Summary Get an event rule
+// @Tags events
+// @Produce json
+// @Param ruleId path int true "Event rule ID"
+// @Success 200 {object} models.EventRule
+// @Failure 404 {object} map[string]string
+// @Router /event-rules/{ruleId} [get]
+func (h *EventHandler) GetRule(c *fiber.Ctx) error {
+	ruleID, err := strconv.ParseInt(c.Params("ruleId"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid rule ID"})
+	}
+
+	rule, err := h.service.GetRule(c.UserContext(), ruleID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(rule)
+}
+
+// UpdateRule godoc
+// @Summary Update an event rule
+// @Description Replace the pattern, targets and enabled flag of an event rule
+// @Tags events
+// @Accept json
+// @Produce json
+// @Param ruleId path int true "Event rule ID"
+// @Param rule body models.EventRuleRequest true "Event rule"
+// @Success 200 {object} models.EventRule
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /event-rules/{ruleId} [put]
+func (h *EventHandler) UpdateRule(c *fiber.Ctx) error {
+	ruleID, err := strconv.ParseInt(c.Params("ruleId"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid rule ID"})
+	}
+	var req models.EventRuleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	rule, err := h.service.SaveRule(c.UserContext(), ruleID, &req)
+	if errors.Is(err, services.ErrEventRuleNameTaken) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return functionError(c, fiber.StatusBadRequest, err)
+	}
+	return c.JSON(rule)
+}
+
+// DeleteRule godoc
+// @Summary Delete an event rule
+// @Description Delete an event rule. Its recorded matches are kept for auditing.
+// @Tags events
+// @Param ruleId path int true "Event rule ID"
+// @Success 204
+// @Failure 404 {object} map[string]string
+// @Router /event-rules/{ruleId} [delete]
+func (h *EventHandler) DeleteRule(c *fiber.Ctx) error {
+	ruleID, err := strconv.ParseInt(c.Params("ruleId"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid rule ID"})
+	}
+
+	if err := h.service.DeleteRule(c.UserContext(), ruleID); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"lambda-runner-server/models"
+	"lambda-runner-server/services"
+)
+
+// UpdateVisibility godoc
+// @Summary Make a function public or private
+// @Description Public functions can be read and invoked by every key of the namespace; private ones only by their owner and the principals they are shared with
+// @Tags functions
+// @Accept json
+// @Produce json
+// @Param id path int true "Function ID"
+// @Param visibility body models.UpdateVisibilityRequest true "Visibility"
+// @Success 200 {object} models.Function
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /functions/{id}/visibility [put]
+func (h *FunctionHandler) UpdateVisibility(c *fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid function ID"})
+	}
+
+	var req models.UpdateVisibilityRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	fn, err := h.service.UpdateVisibility(c.UserContext(), id, &req)
+	if err != nil {
+		return functionError(c, fiber.StatusNotFound, err)
+	}
+	return c.JSON(fn)
+}
+
+// UpdateTags godoc
+// @Summary Replace the tags of a function
+// @Description Tags are lowercase letters, digits and _.:/=-, so labels like team:payments are tags too
+// @Tags functions
+// @Accept json
+// @Produce json
+// @Param id path int true "Function ID"
+// @Param tags body models.UpdateTagsRequest true "Tags"
+// @Success 200 {object} models.Function
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /functions/{id}/tags [put]
+func (h *FunctionHandler) UpdateTags(c *fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid function ID"})
+	}
+
+	var req models.UpdateTagsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if req.Tags, err = services.NormalizeTags(req.Tags); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	fn, err := h.service.UpdateTags(c.UserContext(), id, &req)
+	if err != nil {
+		return functionError(c, fiber.StatusNotFound, err)
+	}
+	return c.JSON(fn)
+}
+
+// ListGrants godoc
+// @Summary List function grants
+// @Description List the keys and users a function is shared with
+// @Tags functions
+// @Produce json
+// @Param id path int true "Function ID"
+// @Success 200 {array} models.FunctionGrant
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /functions/{id}/grants [get]
+func (h *FunctionHandler) ListGrants(c *fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid function ID"})
+	}
+
+	grants, err := h.service.ListGrants(c.UserContext(), id)
+	if err != nil {
+		return functionError(c, fiber.StatusNotFound, err)
+	}
+	return c.JSON(grants)
+}
+
+// GrantAccess godoc
+// @Summary Share a function
+// @Description Grant a key (key:<id>) or every key of a user (user:<name>) read, invoke or admin access to a function. Granting a principal again replaces its access.
+// @Tags functions
+// @Accept json
+// @Produce json
+// @Param id path int true "Function ID"
+// @Param grant body models.GrantFunctionAccessRequest true "Grant"
+// @Success 200 {object} models.FunctionGrant
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /functions/{id}/grants [post]
+func (h *FunctionHandler) GrantAccess(c *fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid function ID"})
+	}
+
+	var req models.GrantFunctionAccessRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	grant, err := h.service.GrantAccess(c.UserContext(), id, &req)
+	if err != nil {
+		return functionError(c, fiber.StatusBadRequest, err)
+	}
+	return c.JSON(grant)
+}
+
+// RevokeGrant godoc
+// @Summary Stop sharing a function
+// @Tags functions
+// @Param id path int true "Function ID"
+// @Param grantId path int true "Grant ID"
+// @Success 204
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /functions/{id}/grants/{grantId} [delete]
+func (h *FunctionHandler) RevokeGrant(c *fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid function ID"})
+	}
+	grantID, err := strconv.ParseInt(c.Params("grantId"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid grant ID"})
+	}
+
+	if err := h.service.RevokeGrant(c.UserContext(), id, grantID); err != nil {
+		return functionError(c, fiber.StatusNotFound, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -1,9 +1,16 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"lambda-runner-server/middleware"
 	"lambda-runner-server/models"
 	"lambda-runner-server/services"
 )
@@ -16,6 +23,18 @@ func NewFunctionHandler(svc *services.FunctionService) *FunctionHandler {
 	return &FunctionHandler{service: svc}
 }
 
+// callerID identifies the caller in invoked_by (and so for caller rate
+// limits): the API key when the request carries one, otherwise the client IP
+func callerID(c *fiber.Ctx) string {
+	if key := middleware.APIKeyFromContext(c.UserContext()); key != nil {
+		return fmt.Sprintf("key:%d", key.ID)
+	}
+	if ip := c.IP(); ip != "" {
+		return ip
+	}
+	return "anonymous"
+}
+
 // CreateFunction godoc
 // @Summary Create a new function
 // @Description Register a new serverless function with code and parameters
@@ -25,6 +44,7 @@ func NewFunctionHandler(svc *services.FunctionService) *FunctionHandler {
 // @Param function body models.CreateFunctionRequest true "Function to create"
 // @Success 200 {object} models.Function
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Router /functions [post]
 func (h *FunctionHandler) CreateFunction(c *fiber.Ctx) error {
 	var req models.CreateFunctionRequest
@@ -48,8 +68,25 @@ func (h *FunctionHandler) CreateFunction(c *fiber.Ctx) error {
 	if req.Runtime == "" {
 		req.Runtime = "python3.11"
 	}
+	if err := services.ValidateCallbackURL(req.CallbackURL); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	tags, err := services.NormalizeTags(req.Tags)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	req.Tags = tags
 
-	fn, err := h.service.CreateFunction(c.Context(), &req)
+	fn, err := h.service.CreateFunction(c.UserContext(), &req)
+	if errors.Is(err, services.ErrFunctionQuotaExceeded) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
@@ -60,14 +97,39 @@ func (h *FunctionHandler) CreateFunction(c *fiber.Ctx) error {
 }
 
 // ListFunctions godoc
-// @Summary List all functions
-// @Description Get a list of all registered functions
+// @Summary List functions
+// @Description Get a page of the registered functions with a summary of their recent invocations
 // @Tags functions
 // @Produce json
+// @Param q query string false "Words that must all appear in the name or description"
+// @Param runtime query string false "Runtime"
+// @Param tag query string false "Comma-separated tags the functions must all carry"
+// @Param sort query string false "name, created_at, updated_at or last_invoked_at" default(created_at)
+// @Param order query string false "asc or desc; names sort ascending and times descending by default"
+// @Param limit query int false "Number of results to return (max 1000); all when omitted"
+// @Param offset query int false "Number of results to skip"
 // @Success 200 {array} models.FunctionListItem
+// @Header 200 {integer} X-Total-Count "Number of functions matching the filters"
 // @Router /functions [get]
 func (h *FunctionHandler) ListFunctions(c *fiber.Ctx) error {
-	functions, err := h.service.ListFunctions(c.Context())
+	filter := services.FunctionFilter{
+		Query:   c.Query("q"),
+		Runtime: c.Query("runtime"),
+		Sort:    c.Query("sort"),
+		Order:   c.Query("order"),
+		Limit:   c.QueryInt("limit"),
+		Offset:  c.QueryInt("offset"),
+	}
+	if tag := c.Query("tag"); tag != "" {
+		filter.Tags = strings.Split(tag, ",")
+	}
+	if err := filter.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	functions, total, err := h.service.ListFunctions(c.UserContext(), filter)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
@@ -78,12 +140,13 @@ func (h *FunctionHandler) ListFunctions(c *fiber.Ctx) error {
 		functions = []models.FunctionListItem{}
 	}
 
+	c.Set("X-Total-Count", strconv.Itoa(total))
 	return c.JSON(functions)
 }
 
 // GetFunction godoc
 // @Summary Get function details
-// @Description Get detailed information about a specific function
+// @Description Get detailed information about a specific function. The code of a private function is only returned to callers with read access.
 // @Tags functions
 // @Produce json
 // @Param id path int true "Function ID"
@@ -99,11 +162,9 @@ func (h *FunctionHandler) GetFunction(c *fiber.Ctx) error {
 		})
 	}
 
-	fn, err := h.service.GetFunction(c.Context(), id)
+	fn, err := h.service.GetFunction(c.UserContext(), id)
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return functionError(c, fiber.StatusNotFound, err)
 	}
 
 	return c.JSON(fn)
@@ -111,7 +172,7 @@ func (h *FunctionHandler) GetFunction(c *fiber.Ctx) error {
 
 // InvokeFunction godoc
 // @Summary Invoke a function
-// @Description Execute a function with given parameters
+// @Description Execute a function with given parameters. Interactive invokes run in the high priority lane unless "priority" is set.
 // @Tags functions
 // @Accept json
 // @Produce json
@@ -119,6 +180,8 @@ func (h *FunctionHandler) GetFunction(c *fiber.Ctx) error {
 // @Param input body models.InvokeRequest true "Input parameters"
 // @Success 200 {object} models.InvokeResponse
 // @Failure 404 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Router /functions/{id}/invoke [post]
 func (h *FunctionHandler) InvokeFunction(c *fiber.Ctx) error {
 	idStr := c.Params("id")
@@ -134,18 +197,30 @@ func (h *FunctionHandler) InvokeFunction(c *fiber.Ctx) error {
 		req.Params = make(map[string]interface{})
 	}
 
-	// Get client IP for invoked_by
-	invokedBy := c.IP()
-	if invokedBy == "" {
-		invokedBy = "anonymous"
-	}
+	invokedBy := callerID(c)
 
-	inv, err := h.service.InvokeFunction(c.Context(), id, req.Params, invokedBy)
+	// Interactive API invokes default to the high priority lane
+	priority, err := services.NormalizePriority(req.Priority, models.PriorityHigh)
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
+	if err := services.ValidateCallbackURL(req.CallbackURL); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	inv, err := h.service.InvokeFunction(c.UserContext(), id, req.Params, services.InvokeOptions{
+		InvokedBy:      invokedBy,
+		Priority:       priority,
+		CallbackURL:    req.CallbackURL,
+		CallbackSecret: req.CallbackSecret,
+	})
+	if err != nil {
+		return invokeError(c, err)
+	}
 
 	// Return initial response with invocation ID
 	return c.JSON(fiber.Map{
@@ -157,6 +232,98 @@ func (h *FunctionHandler) InvokeFunction(c *fiber.Ctx) error {
 	})
 }
 
+// InvokeBatch godoc
+// @Summary Batch invoke a function
+// @Description Invoke a function once per item of "params". Items run in the normal priority lane unless "priority" is set; "max_parallelism" bounds how many run at once.
+// @Tags functions
+// @Accept json
+// @Produce json
+// @Param id path int true "Function ID"
+// @Param input body models.BatchInvokeRequest true "Batch items"
+// @Success 202 {object} models.BatchInvokeResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Router /functions/{id}/invoke-batch [post]
+func (h *FunctionHandler) InvokeBatch(c *fiber.Ctx) error {
+	idStr := c.Params("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid function ID",
+		})
+	}
+
+	var req models.BatchInvokeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if len(req.Params) == 0 || len(req.Params) > services.MaxBatchSize {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("params must contain between 1 and %d items", services.MaxBatchSize),
+		})
+	}
+	if req.MaxParallelism < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "max_parallelism must not be negative",
+		})
+	}
+
+	priority, err := services.NormalizePriority(req.Priority, models.PriorityNormal)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err := services.ValidateCallbackURL(req.CallbackURL); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	invokedBy := callerID(c)
+
+	resp, err := h.service.InvokeBatch(c.UserContext(), id, &req, services.InvokeOptions{
+		InvokedBy:      invokedBy,
+		Priority:       priority,
+		CallbackURL:    req.CallbackURL,
+		CallbackSecret: req.CallbackSecret,
+	})
+	if err != nil {
+		return invokeError(c, err)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(resp)
+}
+
+// GetBatch godoc
+// @Summary Get batch progress
+// @Description Get aggregate progress and per-item results of a batch invoke
+// @Tags functions
+// @Produce json
+// @Param id path int true "Batch ID"
+// @Success 200 {object} models.BatchStatus
+// @Failure 404 {object} map[string]string
+// @Router /batches/{id} [get]
+func (h *FunctionHandler) GetBatch(c *fiber.Ctx) error {
+	idStr := c.Params("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid batch ID",
+		})
+	}
+
+	status, err := h.service.GetBatch(c.UserContext(), id)
+	if err != nil {
+		return functionError(c, fiber.StatusNotFound, err)
+	}
+
+	return c.JSON(status)
+}
+
 // GetInvocationResult godoc
 // @Summary Get invocation result
 // @Description Poll for the result of a function invocation
@@ -176,39 +343,167 @@ func (h *FunctionHandler) GetInvocationResult(c *fiber.Ctx) error {
 		})
 	}
 
-	inv, err := h.service.GetInvocationResult(c.Context(), invocationId)
+	inv, err := h.service.GetInvocationResult(c.UserContext(), invocationId)
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+		return functionError(c, fiber.StatusNotFound, err)
+	}
+
+	return c.JSON(models.NewInvokeResponse(inv))
+}
+
+// CancelInvocation godoc
+// @Summary Cancel an invocation
+// @Description Stop a queued or running invocation; it ends with status "cancelled"
+// @Tags functions
+// @Produce json
+// @Param id path int true "Function ID"
+// @Param invocationId path int true "Invocation ID"
+// @Success 200 {object} models.InvokeResponse
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /functions/{id}/invocations/{invocationId}/cancel [post]
+func (h *FunctionHandler) CancelInvocation(c *fiber.Ctx) error {
+	idStr := c.Params("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid function ID",
+		})
+	}
+	invocationId, err := strconv.ParseInt(c.Params("invocationId"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid invocation ID",
+		})
+	}
+
+	inv, err := h.service.CancelInvocation(c.UserContext(), id, invocationId)
+	if err != nil {
+		status := fiber.StatusNotFound
+		if errors.Is(err, services.ErrInvocationFinished) {
+			status = fiber.StatusConflict
+		}
+		return functionError(c, status, err)
+	}
+
+	return c.JSON(models.NewInvokeResponse(inv))
+}
+
+// ReplayInvocation godoc
+// @Summary Replay an invocation
+// @Description Invoke a function again with the input of one of its invocations, optionally changed by a JSON Patch (RFC 6902). The replay links to the original through "replay_of"; with "pin_code_version" it fails unless the function still runs the original code.
+// @Tags functions
+// @Accept json
+// @Produce json
+// @Param id path int true "Function ID"
+// @Param invocationId path int true "Invocation ID"
+// @Param input body models.ReplayInvocationRequest false "Replay options"
+// @Success 200 {object} models.InvokeResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /functions/{id}/invocations/{invocationId}/replay [post]
+func (h *FunctionHandler) ReplayInvocation(c *fiber.Ctx) error {
+	idStr := c.Params("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid function ID",
+		})
+	}
+	invocationId, err := strconv.ParseInt(c.Params("invocationId"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid invocation ID",
+		})
+	}
+
+	var req models.ReplayInvocationRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+	}
+	if _, err := services.NormalizePriority(req.Priority, models.PriorityHigh); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
 
-	response := models.InvokeResponse{
-		Status:       inv.Status,
-		FunctionID:   inv.FunctionID,
-		InvocationID: inv.ID,
-		InputEvent:   inv.InputEvent,
-		DurationMs:   inv.DurationMs,
-		LoggedAt:     inv.InvokedAt,
+	inv, err := h.service.ReplayInvocation(c.UserContext(), id, invocationId, &req, callerID(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidPatch):
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		case errors.Is(err, services.ErrCodeVersionChanged):
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return invokeError(c, err)
 	}
 
-	if inv.Status == models.StatusSuccess {
-		response.Result = inv.OutputResult
-	} else if inv.Status == models.StatusFail || inv.Status == models.StatusTimeout {
-		response.ErrorMessage = inv.ErrorMessage
+	return c.JSON(models.NewInvokeResponse(inv))
+}
+
+// GetInvocationCallback godoc
+// @Summary Get invocation callback deliveries
+// @Description Get the callback delivery state and attempt log of an invocation
+// @Tags functions
+// @Produce json
+// @Param id path int true "Function ID"
+// @Param invocationId path int true "Invocation ID"
+// @Success 200 {object} models.InvocationCallback
+// @Failure 404 {object} map[string]string
+// @Router /functions/{id}/invocations/{invocationId}/callback [get]
+func (h *FunctionHandler) GetInvocationCallback(c *fiber.Ctx) error {
+	idStr := c.Params("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid function ID",
+		})
+	}
+	invocationId, err := strconv.ParseInt(c.Params("invocationId"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid invocation ID",
+		})
 	}
 
-	return c.JSON(response)
+	cb, err := h.service.GetInvocationCallback(c.UserContext(), id, invocationId)
+	if err != nil {
+		return functionError(c, fiber.StatusNotFound, err)
+	}
+
+	return c.JSON(cb)
 }
 
 // ListInvocations godoc
 // @Summary List function invocations
-// @Description Get execution history for a function
+// @Description Get execution history for a function, newest first
 // @Tags functions
 // @Produce json
 // @Param id path int true "Function ID"
-// @Param limit query int false "Number of results to return" default(20)
+// @Param status query string false "Comma-separated statuses"
+// @Param invoked_by query string false "Invoker"
+// @Param replay_of query int false "Only replays of this invocation"
+// @Param from query string false "Invoked at or after (RFC 3339)"
+// @Param to query string false "Invoked before (RFC 3339)"
+// @Param min_duration_ms query int false "Minimum duration in milliseconds"
+// @Param max_duration_ms query int false "Maximum duration in milliseconds"
+// @Param input query string false "JSON object the input event must contain"
+// @Param output query string false "JSON object the output must contain"
+// @Param cursor query string false "Cursor from the X-Next-Cursor header of the previous page"
+// @Param limit query int false "Number of results to return (max 1000)" default(20)
 // @Success 200 {array} models.InvocationListItem
+// @Header 200 {string} X-Next-Cursor "Cursor of the next page, absent on the last page"
 // @Router /functions/{id}/invocations [get]
 func (h *FunctionHandler) ListInvocations(c *fiber.Ctx) error {
 	idStr := c.Params("id")
@@ -219,22 +514,94 @@ func (h *FunctionHandler) ListInvocations(c *fiber.Ctx) error {
 		})
 	}
 
-	limit := c.QueryInt("limit", 20)
+	filter, err := invocationFilter(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	filter.FunctionID = id
+	return h.listInvocations(c, filter)
+}
 
-	invocations, err := h.service.ListInvocations(c.Context(), id, limit)
+// SearchInvocations godoc
+// @Summary Search invocations
+// @Description Search the invocations of every function the caller may see, newest first
+// @Tags invocations
+// @Produce json
+// @Param status query string false "Comma-separated statuses"
+// @Param invoked_by query string false "Invoker"
+// @Param replay_of query int false "Only replays of this invocation"
+// @Param from query string false "Invoked at or after (RFC 3339)"
+// @Param to query string false "Invoked before (RFC 3339)"
+// @Param min_duration_ms query int false "Minimum duration in milliseconds"
+// @Param max_duration_ms query int false "Maximum duration in milliseconds"
+// @Param input query string false "JSON object the input event must contain"
+// @Param output query string false "JSON object the output must contain"
+// @Param cursor query string false "Cursor from the X-Next-Cursor header of the previous page"
+// @Param limit query int false "Number of results to return (max 1000)" default(20)
+// @Success 200 {array} models.InvocationListItem
+// @Header 200 {string} X-Next-Cursor "Cursor of the next page, absent on the last page"
+// @Router /invocations [get]
+func (h *FunctionHandler) SearchInvocations(c *fiber.Ctx) error {
+	filter, err := invocationFilter(c)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return h.listInvocations(c, filter)
+}
+
+func (h *FunctionHandler) listInvocations(c *fiber.Ctx, filter services.InvocationFilter) error {
+	invocations, next, err := h.service.ListInvocations(c.UserContext(), filter, c.QueryInt("limit", 20))
+	if err != nil {
+		return functionError(c, fiber.StatusInternalServerError, err)
 	}
 
 	if invocations == nil {
 		invocations = []models.InvocationListItem{}
 	}
+	if next != nil {
+		c.Set("X-Next-Cursor", next.Encode())
+	}
 
 	return c.JSON(invocations)
 }
 
+// invocationFilter parses the invocation filters of the query string
+func invocationFilter(c *fiber.Ctx) (services.InvocationFilter, error) {
+	filter := services.InvocationFilter{
+		InvokedBy:     c.Query("invoked_by"),
+		MinDurationMs: c.QueryInt("min_duration_ms"),
+		MaxDurationMs: c.QueryInt("max_duration_ms"),
+		ReplayOf:      int64(c.QueryInt("replay_of")),
+	}
+	if status := c.Query("status"); status != "" {
+		filter.Statuses = strings.Split(status, ",")
+	}
+	for name, t := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if value := c.Query(name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return filter, fmt.Errorf("%s must be an RFC 3339 time", name)
+			}
+			*t = parsed
+		}
+	}
+	for name, doc := range map[string]*map[string]interface{}{"input": &filter.Input, "output": &filter.Output} {
+		if value := c.Query(name); value != "" {
+			if err := json.Unmarshal([]byte(value), doc); err != nil || *doc == nil {
+				return filter, fmt.Errorf("%s must be a JSON object", name)
+			}
+		}
+	}
+	if cursor := c.Query("cursor"); cursor != "" {
+		after, err := services.DecodeInvocationCursor(cursor)
+		if err != nil {
+			return filter, err
+		}
+		filter.After = after
+	}
+	return filter, filter.Validate()
+}
+
 // DeleteFunction godoc
 // @Summary Delete a function
 // @Description Delete a function and remove its stored code
@@ -243,6 +610,7 @@ func (h *FunctionHandler) ListInvocations(c *fiber.Ctx) error {
 // @Param id path int true "Function ID"
 // @Success 200 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Router /functions/{id} [delete]
 func (h *FunctionHandler) DeleteFunction(c *fiber.Ctx) error {
 	idStr := c.Params("id")
@@ -253,14 +621,147 @@ func (h *FunctionHandler) DeleteFunction(c *fiber.Ctx) error {
 		})
 	}
 
-	_, err = h.service.DeleteFunction(c.Context(), id)
+	_, err = h.service.DeleteFunction(c.UserContext(), id)
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return functionError(c, fiber.StatusNotFound, err)
 	}
 
 	return c.JSON(fiber.Map{
 		"message": "Function deleted successfully",
 	})
 }
+
+// UpdateCallback godoc
+// @Summary Update function callback
+// @Description Set the default completion callback URL and signing secret of a function (empty URL removes it)
+// @Tags functions
+// @Accept json
+// @Produce json
+// @Param id path int true "Function ID"
+// @Param callback body models.UpdateCallbackRequest true "Callback settings"
+// @Success 200 {object} models.Function
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /functions/{id}/callback [put]
+func (h *FunctionHandler) UpdateCallback(c *fiber.Ctx) error {
+	idStr := c.Params("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid function ID",
+		})
+	}
+
+	var req models.UpdateCallbackRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if err := services.ValidateCallbackURL(req.CallbackURL); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	fn, err := h.service.UpdateCallback(c.UserContext(), id, &req)
+	if err != nil {
+		return functionError(c, fiber.StatusNotFound, err)
+	}
+
+	return c.JSON(fn)
+}
+
+// GetConcurrency godoc
+// @Summary Get function concurrency
+// @Description Get concurrency settings and current usage of a function
+// @Tags functions
+// @Produce json
+// @Param id path int true "Function ID"
+// @Success 200 {object} models.ConcurrencyStatus
+// @Failure 404 {object} map[string]string
+// @Router /functions/{id}/concurrency [get]
+func (h *FunctionHandler) GetConcurrency(c *fiber.Ctx) error {
+	idStr := c.Params("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid function ID",
+		})
+	}
+
+	status, err := h.service.GetConcurrencyStatus(c.UserContext(), id)
+	if err != nil {
+		return functionError(c, fiber.StatusNotFound, err)
+	}
+
+	return c.JSON(status)
+}
+
+// UpdateConcurrency godoc
+// @Summary Update function concurrency
+// @Description Set max concurrency, reserved concurrency and overflow policy of a function
+// @Tags functions
+// @Accept json
+// @Produce json
+// @Param id path int true "Function ID"
+// @Param settings body models.UpdateConcurrencyRequest true "Concurrency settings"
+// @Success 200 {object} models.Function
+// @Failure 400 {object} map[string]string
+// @Router /functions/{id}/concurrency [put]
+func (h *FunctionHandler) UpdateConcurrency(c *fiber.Ctx) error {
+	idStr := c.Params("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid function ID",
+		})
+	}
+
+	var req models.UpdateConcurrencyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	fn, err := h.service.UpdateConcurrency(c.UserContext(), id, &req)
+	if err != nil {
+		return functionError(c, fiber.StatusBadRequest, err)
+	}
+
+	return c.JSON(fn)
+}
+
+// invokeError maps an invoke error to a response, turning throttling into 429
+func invokeError(c *fiber.Ctx, err error) error {
+	var throttleErr *services.ThrottleError
+	if errors.As(err, &throttleErr) {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfterSeconds(throttleErr.RetryAfter)))
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return functionError(c, fiber.StatusNotFound, err)
+}
+
+// functionError responds with err and status, or with 403 Forbidden when the
+// caller's access to the function is not enough
+func functionError(c *fiber.Ctx, status int, err error) error {
+	if errors.Is(err, services.ErrFunctionAccessDenied) {
+		status = fiber.StatusForbidden
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
+
+// retryAfterSeconds rounds a retry delay up to whole seconds (at least 1)
+func retryAfterSeconds(d time.Duration) int {
+	seconds := int(math.Ceil(d.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return seconds
+}
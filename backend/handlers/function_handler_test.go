@@ -0,0 +1,543 @@
+package handlers_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"lambda-runner-server/models"
+	"lambda-runner-server/services"
+)
+
+func TestCreateAndGetFunction(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend testBackend) {
+		s := newTestServer(t, backend, services.EchoHandler)
+
+		fn := s.createFunction(aliceKey, models.CreateFunctionRequest{Name: "echo", Description: "returns its input"})
+		if fn.ID == 0 || fn.Runtime != "python3.11" || fn.Owner != "user:alice" || !fn.IsPublic {
+			t.Fatalf("unexpected function: %+v", fn)
+		}
+
+		var got models.Function
+		s.expect(fiber.StatusOK, "GET", fmt.Sprintf("/api/functions/%d", fn.ID), aliceKey, nil, &got)
+		if got.Name != "echo" || got.Code == "" {
+			t.Fatalf("unexpected function: %+v", got)
+		}
+
+		var list []models.FunctionListItem
+		s.expect(fiber.StatusOK, "GET", "/api/functions", bobKey, nil, &list)
+		if len(list) != 1 || list[0].ID != fn.ID {
+			t.Fatalf("unexpected function list: %+v", list)
+		}
+
+		s.expect(fiber.StatusNotFound, "GET", "/api/functions/999", aliceKey, nil, nil)
+		s.expect(fiber.StatusBadRequest, "POST", "/api/functions", aliceKey, models.CreateFunctionRequest{Name: "no-code"}, nil)
+		s.expect(fiber.StatusUnauthorized, "GET", "/api/functions", "", nil, nil)
+	})
+}
+
+func TestInvokeAndGetResult(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend testBackend) {
+		s := newTestServer(t, backend, services.EchoHandler)
+		fn := s.createFunction(aliceKey, models.CreateFunctionRequest{Name: "echo"})
+
+		id := s.invoke(aliceKey, fn.ID, map[string]interface{}{"name": "world"})
+
+		var pending models.InvokeResponse
+		s.expect(fiber.StatusOK, "GET", fmt.Sprintf("/api/functions/%d/invocations/%d", fn.ID, id), aliceKey, nil, &pending)
+		if pending.Status != models.StatusPending {
+			t.Fatalf("status before the worker ran = %q, want pending", pending.Status)
+		}
+
+		if n := s.worker.Drain(context.Background()); n != 1 {
+			t.Fatalf("worker ran %d requests, want 1", n)
+		}
+		resp := s.result(aliceKey, fn.ID, id)
+		if resp.Status != models.StatusSuccess || resp.Result["name"] != "world" {
+			t.Fatalf("unexpected result: %+v", resp)
+		}
+
+		var invocations []models.InvocationListItem
+		s.expect(fiber.StatusOK, "GET", fmt.Sprintf("/api/functions/%d/invocations", fn.ID), aliceKey, nil, &invocations)
+		if len(invocations) != 1 || invocations[0].Status != models.StatusSuccess {
+			t.Fatalf("unexpected invocations: %+v", invocations)
+		}
+	})
+}
+
+func TestInvokeFailure(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend testBackend) {
+		s := newTestServer(t, backend, func(ctx context.Context, req *models.ExecutionRequest) (map[string]interface{}, error) {
+			return nil, errors.New("division by zero")
+		})
+		fn := s.createFunction(aliceKey, models.CreateFunctionRequest{Name: "broken"})
+
+		id := s.invoke(aliceKey, fn.ID, nil)
+		s.worker.Drain(context.Background())
+
+		resp := s.result(aliceKey, fn.ID, id)
+		if resp.Status != models.StatusFail || resp.ErrorMessage != "division by zero" {
+			t.Fatalf("unexpected result: %+v", resp)
+		}
+	})
+}
+
+func TestInvokeWithRunningWorker(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend testBackend) {
+		s := newTestServer(t, backend, services.EchoHandler)
+		s.worker.Start()
+		defer s.worker.Stop()
+
+		fn := s.createFunction(aliceKey, models.CreateFunctionRequest{Name: "echo"})
+		id := s.invoke(aliceKey, fn.ID, map[string]interface{}{"n": 1})
+
+		if resp := s.result(aliceKey, fn.ID, id); resp.Status != models.StatusSuccess {
+			t.Fatalf("unexpected result: %+v", resp)
+		}
+	})
+}
+
+func TestCancelQueuedInvocation(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend testBackend) {
+		s := newTestServer(t, backend, services.EchoHandler)
+		fn := s.createFunction(aliceKey, models.CreateFunctionRequest{Name: "echo"})
+		id := s.invoke(aliceKey, fn.ID, nil)
+
+		path := fmt.Sprintf("/api/functions/%d/invocations/%d/cancel", fn.ID, id)
+		var resp models.InvokeResponse
+		s.expect(fiber.StatusOK, "POST", path, aliceKey, nil, &resp)
+		if resp.Status != models.StatusCancelled {
+			t.Fatalf("status after cancel = %q, want cancelled", resp.Status)
+		}
+		if n := s.worker.Drain(context.Background()); n != 0 {
+			t.Fatalf("worker ran %d cancelled requests", n)
+		}
+		s.expect(fiber.StatusConflict, "POST", path, aliceKey, nil, nil)
+	})
+}
+
+func TestCancelInvocationAccess(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend testBackend) {
+		s := newTestServer(t, backend, services.EchoHandler)
+		private := false
+		fn := s.createFunction(aliceKey, models.CreateFunctionRequest{Name: "secret", IsPublic: &private})
+		id := s.invoke(aliceKey, fn.ID, nil)
+		s.worker.Drain(context.Background())
+		if resp := s.result(aliceKey, fn.ID, id); resp.Status != models.StatusSuccess {
+			t.Fatalf("unexpected result: %+v", resp)
+		}
+
+		// A finished invocation must not reveal its status to a non-grantee
+		path := fmt.Sprintf("/api/functions/%d/invocations/%d/cancel", fn.ID, id)
+		s.expect(fiber.StatusForbidden, "POST", path, bobKey, nil, nil)
+		s.expect(fiber.StatusConflict, "POST", path, aliceKey, nil, nil)
+	})
+}
+
+func TestPrivateFunctionAccess(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend testBackend) {
+		s := newTestServer(t, backend, services.EchoHandler)
+		private := false
+		fn := s.createFunction(aliceKey, models.CreateFunctionRequest{Name: "secret", IsPublic: &private})
+		path := fmt.Sprintf("/api/functions/%d", fn.ID)
+
+		var list []models.FunctionListItem
+		s.expect(fiber.StatusOK, "GET", "/api/functions", bobKey, nil, &list)
+		if len(list) != 0 {
+			t.Fatalf("bob sees private functions: %+v", list)
+		}
+		s.expect(fiber.StatusForbidden, "POST", path+"/invoke", bobKey, models.InvokeRequest{}, nil)
+		s.expect(fiber.StatusForbidden, "DELETE", path, bobKey, nil, nil)
+
+		s.expect(fiber.StatusOK, "POST", path+"/grants", aliceKey,
+			models.GrantFunctionAccessRequest{Principal: "user:bob", Access: models.AccessInvoke}, nil)
+		s.invoke(bobKey, fn.ID, nil)
+		s.expect(fiber.StatusForbidden, "DELETE", path, bobKey, nil, nil)
+
+		s.expect(fiber.StatusOK, "DELETE", path, aliceKey, nil, nil)
+		s.expect(fiber.StatusNotFound, "GET", path, adminKey, nil, nil)
+	})
+}
+
+func TestInvokeBatch(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend testBackend) {
+		s := newTestServer(t, backend, services.EchoHandler)
+		fn := s.createFunction(aliceKey, models.CreateFunctionRequest{Name: "echo"})
+
+		var batch models.BatchInvokeResponse
+		s.expect(fiber.StatusAccepted, "POST", fmt.Sprintf("/api/functions/%d/invoke-batch", fn.ID), aliceKey,
+			models.BatchInvokeRequest{
+				Params:         []map[string]interface{}{{"i": 1}, {"i": 2}, {"i": 3}},
+				MaxParallelism: 1,
+			}, &batch)
+		if batch.Total != 3 || len(batch.InvocationIDs) != 3 {
+			t.Fatalf("unexpected batch: %+v", batch)
+		}
+
+		// Each finished item releases the next one, so drain until the batch is done
+		var status models.BatchStatus
+		for i := 0; i < 3; i++ {
+			if n := s.worker.Drain(context.Background()); n != 1 {
+				t.Fatalf("round %d: worker ran %d requests, want 1 (max parallelism)", i, n)
+			}
+			if _, err := s.functions.CollectResults(context.Background(), time.Time{}, 100); err != nil {
+				t.Fatalf("collect results: %v", err)
+			}
+			s.expect(fiber.StatusOK, "GET", fmt.Sprintf("/api/batches/%d", batch.BatchID), aliceKey, nil, &status)
+		}
+		if !status.Done || status.Counts[models.StatusSuccess] != 3 {
+			t.Fatalf("unexpected batch status: %+v", status)
+		}
+	})
+}
+
+func TestInvokeCallbackURL(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend testBackend) {
+		s := newTestServer(t, backend, services.EchoHandler)
+		fn := s.createFunction(aliceKey, models.CreateFunctionRequest{Name: "echo"})
+		path := fmt.Sprintf("/api/functions/%d/invoke", fn.ID)
+
+		// Callbacks must not reach the platform's own network
+		for _, callbackURL := range []string{
+			"ftp://example.com/hook",
+			"http://localhost:6379/",
+			"http://127.0.0.1/hook",
+			"http://169.254.169.254/latest/meta-data/",
+			"http://10.0.0.5/hook",
+			"http://[::1]/hook",
+			"http://0.0.0.0/hook",
+		} {
+			s.expect(fiber.StatusBadRequest, "POST", path, aliceKey, models.InvokeRequest{CallbackURL: callbackURL}, nil)
+		}
+		s.expect(fiber.StatusOK, "POST", path, aliceKey, models.InvokeRequest{CallbackURL: "https://93.184.216.34/hook"}, nil)
+	})
+}
+
+func TestCollectResultsPaging(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend testBackend) {
+		s := newTestServer(t, backend, services.EchoHandler)
+		fn := s.createFunction(aliceKey, models.CreateFunctionRequest{Name: "echo"})
+
+		// The oldest invocations stay pending; only the newest has a result
+		var ids []int64
+		for i := 0; i < 5; i++ {
+			ids = append(ids, s.invoke(aliceKey, fn.ID, map[string]interface{}{"n": i}))
+		}
+		newest := ids[len(ids)-1]
+		s.queue.SetResult(&models.ExecutionResult{InvocationID: newest, Status: models.StatusSuccess, Output: map[string]interface{}{"n": 4}})
+
+		collected, err := s.functions.CollectResults(context.Background(), time.Time{}, 2)
+		if err != nil {
+			t.Fatalf("collect results: %v", err)
+		}
+		if collected != 1 {
+			t.Fatalf("collected %d results, want 1", collected)
+		}
+		inv, err := s.functions.GetInvocation(context.Background(), newest)
+		if err != nil || inv == nil || inv.Status != models.StatusSuccess {
+			t.Fatalf("newest invocation not completed: %+v, %v", inv, err)
+		}
+	})
+}
+
+func TestListInvocationsFilters(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend testBackend) {
+		s := newTestServer(t, backend, services.EchoHandler)
+		fn := s.createFunction(aliceKey, models.CreateFunctionRequest{Name: "echo"})
+		private := false
+		secret := s.createFunction(aliceKey, models.CreateFunctionRequest{Name: "secret", IsPublic: &private})
+
+		for i := 0; i < 5; i++ {
+			s.invoke(aliceKey, fn.ID, map[string]interface{}{"n": i, "tags": []string{"a", fmt.Sprint(i)}})
+		}
+		s.invoke(aliceKey, secret.ID, map[string]interface{}{"n": 0})
+		s.worker.Drain(context.Background())
+		if _, err := s.functions.CollectResults(context.Background(), time.Time{}, 100); err != nil {
+			t.Fatalf("collect results: %v", err)
+		}
+
+		path := fmt.Sprintf("/api/functions/%d/invocations", fn.ID)
+		list := func(key, path string, query url.Values) []models.InvocationListItem {
+			t.Helper()
+			var invocations []models.InvocationListItem
+			s.expect(fiber.StatusOK, "GET", path+"?"+query.Encode(), key, nil, &invocations)
+			return invocations
+		}
+
+		if got := list(aliceKey, path, url.Values{"input": {`{"n": 3}`}}); len(got) != 1 || got[0].InputEvent["n"] != float64(3) {
+			t.Fatalf("input filter returned %+v", got)
+		}
+		if got := list(aliceKey, path, url.Values{"output": {`{"tags": ["2"]}`}}); len(got) != 1 || got[0].OutputResult["n"] != float64(2) {
+			t.Fatalf("output filter returned %+v", got)
+		}
+		if got := list(aliceKey, path, url.Values{"status": {"fail,timeout"}}); len(got) != 0 {
+			t.Fatalf("status filter returned %+v", got)
+		}
+		if got := list(aliceKey, path, url.Values{"status": {"success"}, "to": {"2000-01-01T00:00:00Z"}}); len(got) != 0 {
+			t.Fatalf("time filter returned %+v", got)
+		}
+
+		// The cross-function search only covers the functions the caller may see
+		if got := list(aliceKey, "/api/invocations", url.Values{"input": {`{"n": 0}`}}); len(got) != 2 {
+			t.Fatalf("alice found %d invocations, want 2", len(got))
+		}
+		if got := list(bobKey, "/api/invocations", url.Values{"input": {`{"n": 0}`}}); len(got) != 1 || got[0].FunctionID != fn.ID {
+			t.Fatalf("bob found %+v", got)
+		}
+
+		for _, query := range []string{"status=bogus", "from=yesterday", "input=[1]", "cursor=x", "min_duration_ms=5&max_duration_ms=1"} {
+			s.expect(fiber.StatusBadRequest, "GET", path+"?"+query, aliceKey, nil, nil)
+		}
+	})
+}
+
+func TestListInvocationsPaging(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend testBackend) {
+		s := newTestServer(t, backend, services.EchoHandler)
+		fn := s.createFunction(aliceKey, models.CreateFunctionRequest{Name: "echo"})
+		for i := 0; i < 5; i++ {
+			s.invoke(aliceKey, fn.ID, nil)
+		}
+
+		var ids []int64
+		filter := services.InvocationFilter{FunctionID: fn.ID}
+		for page := 0; ; page++ {
+			invocations, next, err := s.functions.ListInvocations(context.Background(), filter, 2)
+			if err != nil {
+				t.Fatalf("list invocations: %v", err)
+			}
+			for _, inv := range invocations {
+				ids = append(ids, inv.ID)
+			}
+			if next == nil {
+				break
+			}
+			if page > 5 {
+				t.Fatalf("paging did not end: %v", ids)
+			}
+			if filter.After, err = services.DecodeInvocationCursor(next.Encode()); err != nil {
+				t.Fatalf("decode cursor: %v", err)
+			}
+		}
+		if len(ids) != 5 {
+			t.Fatalf("pages returned %v, want 5 invocations", ids)
+		}
+		for i := 1; i < len(ids); i++ {
+			if ids[i] >= ids[i-1] {
+				t.Fatalf("pages returned %v, want newest first", ids)
+			}
+		}
+	})
+}
+
+func TestListFunctionsFilters(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend testBackend) {
+		s := newTestServer(t, backend, services.EchoHandler)
+		resize := s.createFunction(aliceKey, models.CreateFunctionRequest{
+			Name: "resize-image", Description: "Scales uploaded images", Tags: []string{"Media", "team:web"},
+		})
+		s.createFunction(aliceKey, models.CreateFunctionRequest{Name: "send-email", Description: "Sends mail", Runtime: "nodejs18"})
+		report := s.createFunction(aliceKey, models.CreateFunctionRequest{Name: "build-report", Tags: []string{"team:web"}})
+		if len(resize.Tags) != 2 || resize.Tags[0] != "media" {
+			t.Fatalf("tags were not normalized: %v", resize.Tags)
+		}
+
+		s.invoke(aliceKey, report.ID, nil)
+		s.worker.Drain(context.Background())
+		if _, err := s.functions.CollectResults(context.Background(), time.Time{}, 100); err != nil {
+			t.Fatalf("collect results: %v", err)
+		}
+
+		names := func(query url.Values) []string {
+			t.Helper()
+			var list []models.FunctionListItem
+			s.expect(fiber.StatusOK, "GET", "/api/functions?"+query.Encode(), aliceKey, nil, &list)
+			var names []string
+			for _, fn := range list {
+				names = append(names, fn.Name)
+			}
+			return names
+		}
+		for _, tc := range []struct {
+			query url.Values
+			want  string
+		}{
+			{url.Values{"q": {"image"}}, "[resize-image]"},
+			{url.Values{"q": {"sends mail"}}, "[send-email]"},
+			{url.Values{"runtime": {"nodejs18"}}, "[send-email]"},
+			{url.Values{"tag": {"team:web"}, "sort": {"name"}}, "[build-report resize-image]"},
+			{url.Values{"tag": {"team:web,media"}}, "[resize-image]"},
+			{url.Values{"sort": {"name"}, "order": {"desc"}}, "[send-email resize-image build-report]"},
+			{url.Values{"sort": {"last_invoked_at"}}, "[build-report send-email resize-image]"},
+			{url.Values{"sort": {"name"}, "limit": {"1"}, "offset": {"1"}}, "[resize-image]"},
+		} {
+			if got := fmt.Sprint(names(tc.query)); got != tc.want {
+				t.Errorf("GET /api/functions?%s = %s, want %s", tc.query.Encode(), got, tc.want)
+			}
+		}
+
+		var list []models.FunctionListItem
+		s.expect(fiber.StatusOK, "GET", "/api/functions?q=report", aliceKey, nil, &list)
+		if len(list) != 1 || list[0].LastInvokedAt == nil || list[0].LastInvocationStatus != models.StatusSuccess || list[0].Invocations24h != 1 {
+			t.Fatalf("unexpected summary: %+v", list)
+		}
+
+		var fn models.Function
+		s.expect(fiber.StatusOK, "PUT", fmt.Sprintf("/api/functions/%d/tags", report.ID), aliceKey, models.UpdateTagsRequest{Tags: []string{"reports"}}, &fn)
+		if got := fmt.Sprint(names(url.Values{"tag": {"reports"}})); got != "[build-report]" {
+			t.Fatalf("functions tagged reports = %s", got)
+		}
+		s.expect(fiber.StatusBadRequest, "PUT", fmt.Sprintf("/api/functions/%d/tags", report.ID), aliceKey, models.UpdateTagsRequest{Tags: []string{"no spaces"}}, nil)
+		s.expect(fiber.StatusForbidden, "PUT", fmt.Sprintf("/api/functions/%d/tags", report.ID), bobKey, models.UpdateTagsRequest{}, nil)
+		s.expect(fiber.StatusBadRequest, "GET", "/api/functions?sort=size", aliceKey, nil, nil)
+	})
+}
+
+func TestFunctionStats(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend testBackend) {
+		s := newTestServer(t, backend, func(ctx context.Context, req *models.ExecutionRequest) (map[string]interface{}, error) {
+			if req.Input["fail"] == true {
+				return nil, errors.New("failed")
+			}
+			return req.Input, nil
+		})
+		fn := s.createFunction(aliceKey, models.CreateFunctionRequest{Name: "flaky"})
+
+		for _, fail := range []bool{false, false, false, true} {
+			s.invoke(aliceKey, fn.ID, map[string]interface{}{"fail": fail})
+		}
+		s.worker.Drain(context.Background())
+		if _, err := s.functions.CollectResults(context.Background(), time.Time{}, 100); err != nil {
+			t.Fatalf("collect results: %v", err)
+		}
+		s.invoke(aliceKey, fn.ID, nil)
+
+		now := time.Now().UTC()
+		query := url.Values{
+			"from":   {now.Add(-2 * time.Hour).Format(time.RFC3339)},
+			"to":     {now.Add(time.Hour).Format(time.RFC3339)},
+			"bucket": {"1h"},
+		}
+		path := fmt.Sprintf("/api/functions/%d/stats?", fn.ID)
+		var stats models.FunctionStats
+		s.expect(fiber.StatusOK, "GET", path+query.Encode(), aliceKey, nil, &stats)
+
+		summary := stats.Summary
+		if summary.Total != 5 || summary.Counts[models.StatusSuccess] != 3 || summary.Counts[models.StatusFail] != 1 ||
+			summary.Counts[models.StatusPending] != 1 || summary.ErrorRate != 0.25 || summary.P50Ms == nil || summary.P99Ms == nil {
+			t.Fatalf("unexpected summary: %+v", summary)
+		}
+		if len(stats.Buckets) < 3 || len(stats.Buckets) > 4 || stats.BucketSeconds != 3600 {
+			t.Fatalf("got %d buckets of %ds, want 3 or 4 of an hour", len(stats.Buckets), stats.BucketSeconds)
+		}
+		var total int64
+		for i, bucket := range stats.Buckets {
+			if !bucket.Start.Equal(stats.From.Add(time.Duration(i) * time.Hour)) {
+				t.Fatalf("bucket %d starts at %s", i, bucket.Start)
+			}
+			total += bucket.Total
+			if bucket.Total == 0 && (bucket.P50Ms != nil || bucket.ErrorRate != 0) {
+				t.Fatalf("empty bucket %d has statistics: %+v", i, bucket)
+			}
+		}
+		if total != 5 {
+			t.Fatalf("buckets hold %d invocations, want 5", total)
+		}
+
+		query.Set("bucket", "1s")
+		s.expect(fiber.StatusBadRequest, "GET", path+query.Encode(), aliceKey, nil, nil)
+		query.Set("bucket", "1m")
+		query.Set("from", now.Add(-48*time.Hour).Format(time.RFC3339))
+		s.expect(fiber.StatusBadRequest, "GET", path+query.Encode(), aliceKey, nil, nil)
+		s.expect(fiber.StatusNotFound, "GET", "/api/functions/999/stats", aliceKey, nil, nil)
+	})
+}
+
+func TestReplayInvocation(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend testBackend) {
+		s := newTestServer(t, backend, func(ctx context.Context, req *models.ExecutionRequest) (map[string]interface{}, error) {
+			if req.Input["fail"] == true {
+				return nil, errors.New("boom")
+			}
+			return req.Input, nil
+		})
+		fn := s.createFunction(aliceKey, models.CreateFunctionRequest{Name: "flaky"})
+		run := func() {
+			t.Helper()
+			s.worker.Drain(context.Background())
+			if _, err := s.functions.CollectResults(context.Background(), time.Time{}, 100); err != nil {
+				t.Fatalf("collect results: %v", err)
+			}
+		}
+
+		original := s.invoke(aliceKey, fn.ID, map[string]interface{}{"fail": true, "n": 1})
+		run()
+		if got := s.result(aliceKey, fn.ID, original); got.Status != models.StatusFail || got.CodeVersion == "" {
+			t.Fatalf("unexpected original: %+v", got)
+		}
+
+		path := fmt.Sprintf("/api/functions/%d/invocations/%d/replay", fn.ID, original)
+		var replay models.InvokeResponse
+		s.expect(fiber.StatusOK, "POST", path, bobKey, models.ReplayInvocationRequest{
+			Patch: []models.JSONPatchOperation{
+				{Op: "test", Path: "/n", Value: 1},
+				{Op: "replace", Path: "/fail", Value: false},
+				{Op: "add", Path: "/retry", Value: true},
+			},
+			PinCodeVersion: true,
+		}, &replay)
+		if replay.ReplayOf == nil || *replay.ReplayOf != original {
+			t.Fatalf("replay does not link to the original: %+v", replay)
+		}
+		run()
+		got := s.result(bobKey, fn.ID, replay.InvocationID)
+		if got.Status != models.StatusSuccess || got.InputEvent["fail"] != false || got.InputEvent["retry"] != true || got.InputEvent["n"] != float64(1) {
+			t.Fatalf("unexpected replay: %+v", got)
+		}
+		if got.ReplayOf == nil || *got.ReplayOf != original || got.CodeVersion == "" {
+			t.Fatalf("replay lineage not recorded: %+v", got)
+		}
+
+		// A replay without a body re-runs the original input unchanged
+		var again models.InvokeResponse
+		s.expect(fiber.StatusOK, "POST", path, aliceKey, nil, &again)
+		if again.InputEvent["fail"] != true {
+			t.Fatalf("unexpected replay input: %+v", again.InputEvent)
+		}
+
+		var replays []models.InvocationListItem
+		s.expect(fiber.StatusOK, "GET", fmt.Sprintf("/api/functions/%d/invocations?replay_of=%d", fn.ID, original), aliceKey, nil, &replays)
+		if len(replays) != 2 || replays[0].ID != again.InvocationID || replays[1].ID != replay.InvocationID || *replays[1].ReplayOf != original {
+			t.Fatalf("replay listing returned %+v", replays)
+		}
+
+		for _, patch := range [][]models.JSONPatchOperation{
+			{{Op: "test", Path: "/n", Value: 2}},
+			{{Op: "remove", Path: "/missing"}},
+			{{Op: "replace", Path: "", Value: []int{1}}},
+			{{Op: "frobnicate", Path: "/n"}},
+		} {
+			s.expect(fiber.StatusBadRequest, "POST", path, aliceKey, models.ReplayInvocationRequest{Patch: patch}, nil)
+		}
+		s.expect(fiber.StatusBadRequest, "POST", path, aliceKey, models.ReplayInvocationRequest{Priority: "urgent"}, nil)
+
+		// Without access to a private function, test operations reveal nothing of its inputs
+		private := false
+		secret := s.createFunction(aliceKey, models.CreateFunctionRequest{Name: "secret", IsPublic: &private})
+		hidden := s.invoke(aliceKey, secret.ID, map[string]interface{}{"token": "abc"})
+		secretPath := fmt.Sprintf("/api/functions/%d/invocations/%d/replay", secret.ID, hidden)
+		for _, value := range []string{"abc", "xyz"} {
+			s.expect(fiber.StatusForbidden, "POST", secretPath, bobKey, models.ReplayInvocationRequest{
+				Patch: []models.JSONPatchOperation{{Op: "test", Path: "/token", Value: value}},
+			}, nil)
+		}
+
+		other := s.createFunction(aliceKey, models.CreateFunctionRequest{Name: "other"})
+		s.expect(fiber.StatusNotFound, "POST", fmt.Sprintf("/api/functions/%d/invocations/%d/replay", other.ID, original), aliceKey, nil, nil)
+	})
+}
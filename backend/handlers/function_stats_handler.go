@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"lambda-runner-server/services"
+)
+
+// GetFunctionStats godoc
+// @Summary Get function statistics
+// @Description Invocation counts by status, error rate and p50/p90/p99 duration of a function, in total and per time bucket
+// @Tags functions
+// @Produce json
+// @Param id path int true "Function ID"
+// @Param from query string false "Start of the range (RFC 3339), aligned down to the bucket size; 24 hours before to by default"
+// @Param to query string false "End of the range (RFC 3339); now by default"
+// @Param bucket query string false "Bucket size as a duration of at least 1m, like 5m or 1h; at most 1000 buckets" default(1h)
+// @Success 200 {object} models.FunctionStats
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /functions/{id}/stats [get]
+func (h *FunctionHandler) GetFunctionStats(c *fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid function ID"})
+	}
+
+	r := services.StatsRange{To: time.Now(), Bucket: time.Hour}
+	if value := c.Query("to"); value != "" {
+		if r.To, err = time.Parse(time.RFC3339, value); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "to must be an RFC 3339 time"})
+		}
+	}
+	r.From = r.To.Add(-24 * time.Hour)
+	if value := c.Query("from"); value != "" {
+		if r.From, err = time.Parse(time.RFC3339, value); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "from must be an RFC 3339 time"})
+		}
+	}
+	if value := c.Query("bucket"); value != "" {
+		if r.Bucket, err = time.ParseDuration(value); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "bucket must be a duration like 5m or 1h"})
+		}
+	}
+	if err := r.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	stats, err := h.service.FunctionStats(c.UserContext(), id, r)
+	if err != nil {
+		return functionError(c, fiber.StatusNotFound, err)
+	}
+	return c.JSON(stats)
+}
@@ -0,0 +1,295 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"lambda-runner-server/handlers"
+	"lambda-runner-server/middleware"
+	pgmigrations "lambda-runner-server/migrations"
+	sqlitemigrations "lambda-runner-server/migrations/sqlite"
+	"lambda-runner-server/models"
+	"lambda-runner-server/services"
+)
+
+// Test API keys, all in the default namespace
+const (
+	adminKey = "test-admin"
+	aliceKey = "test-alice"
+	bobKey   = "test-bob"
+)
+
+type keyTable map[string]*models.APIKey
+
+func (t keyTable) Authenticate(ctx context.Context, key string) (*models.APIKey, error) {
+	return t[key], nil
+}
+
+var testKeys = keyTable{
+	adminKey: {ID: 1, Name: "admin", NamespaceID: 1, Namespace: "default", Scopes: []string{models.ScopeAdmin}},
+	aliceKey: {ID: 2, Name: "alice", User: "alice", NamespaceID: 1, Namespace: "default",
+		Scopes: []string{models.ScopeRead, models.ScopeWrite, models.ScopeInvoke}},
+	bobKey: {ID: 3, Name: "bob", User: "bob", NamespaceID: 1, Namespace: "default",
+		Scopes: []string{models.ScopeRead, models.ScopeWrite, models.ScopeInvoke}},
+}
+
+// testBackend opens an empty store with the test keys registered
+type testBackend struct {
+	name string
+	open func(t *testing.T) services.Store
+}
+
+// testBackends are the stores every test runs against. Postgres is included
+// when TEST_DB_HOST names a server whose database (TEST_DB_NAME, default
+// softgate_test) may be wiped by the tests.
+func testBackends() []testBackend {
+	backends := []testBackend{
+		{"memory", func(t *testing.T) services.Store { return services.NewMemoryStore() }},
+		{"sqlite", openSQLite},
+	}
+	if os.Getenv("TEST_DB_HOST") != "" {
+		backends = append(backends, testBackend{"postgres", openPostgres})
+	}
+	return backends
+}
+
+// forEachBackend runs test as a subtest per backend
+func forEachBackend(t *testing.T, test func(t *testing.T, backend testBackend)) {
+	for _, backend := range testBackends() {
+		backend := backend
+		t.Run(backend.name, func(t *testing.T) { test(t, backend) })
+	}
+}
+
+func openSQLite(t *testing.T) services.Store {
+	store, err := services.OpenSQLiteStore(filepath.Join(t.TempDir(), "softgate.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	migrations, err := services.NewSQLiteMigrationService(store, sqlitemigrations.FS)
+	if err != nil {
+		t.Fatalf("load sqlite migrations: %v", err)
+	}
+	if _, err := migrations.Up(context.Background()); err != nil {
+		t.Fatalf("migrate sqlite: %v", err)
+	}
+	registerTestKeys(t, store)
+	return store
+}
+
+func openPostgres(t *testing.T) services.Store {
+	env := func(key, fallback string) string {
+		if v := os.Getenv(key); v != "" {
+			return v
+		}
+		return fallback
+	}
+	host, name := env("TEST_DB_HOST", ""), env("TEST_DB_NAME", "softgate_test")
+	port, _ := strconv.Atoi(env("TEST_DB_PORT", "5432"))
+	user, password := env("TEST_DB_USER", "softgate"), env("TEST_DB_PASSWORD", "softgate")
+
+	// Start every test from the freshly migrated state
+	conn, err := sql.Open("postgres", fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
+		host, port, user, password, name))
+	if err != nil {
+		t.Fatalf("open postgres: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Exec(`DROP SCHEMA public CASCADE; CREATE SCHEMA public`); err != nil {
+		t.Fatalf("reset postgres: %v", err)
+	}
+
+	store, err := services.NewDBService(host, port, user, password, name, "disable")
+	if err != nil {
+		t.Fatalf("connect postgres: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	migrations, err := services.NewMigrationService(store, pgmigrations.FS)
+	if err != nil {
+		t.Fatalf("load migrations: %v", err)
+	}
+	if _, err := migrations.Up(context.Background()); err != nil {
+		t.Fatalf("migrate postgres: %v", err)
+	}
+	registerTestKeys(t, store)
+	return store
+}
+
+// registerTestKeys stores the test keys, so the key IDs recorded on
+// functions and invocations satisfy the foreign keys of SQL stores
+func registerTestKeys(t *testing.T, store services.APIKeyStore) {
+	for _, raw := range []string{adminKey, aliceKey, bobKey} {
+		want := testKeys[raw]
+		key, err := store.CreateAPIKey(context.Background(), want, raw)
+		if err != nil {
+			t.Fatalf("register key %s: %v", raw, err)
+		}
+		if key.ID != want.ID {
+			t.Fatalf("key %s stored with ID %d, want %d", raw, key.ID, want.ID)
+		}
+	}
+}
+
+// testServer wires the function and schedule handlers to a backend store
+// and the in-memory queue, with a LocalWorker in place of the runtime workers
+type testServer struct {
+	t         *testing.T
+	app       *fiber.App
+	queue     *services.MemoryQueue
+	worker    *services.LocalWorker
+	functions *services.FunctionService
+	schedules *services.ScheduleService
+}
+
+func newTestServer(t *testing.T, backend testBackend, handler services.LocalHandler) *testServer {
+	t.Helper()
+
+	storage, err := services.NewStorageService("local", t.TempDir())
+	if err != nil {
+		t.Fatalf("storage: %v", err)
+	}
+	store := backend.open(t)
+	queue := services.NewMemoryQueue()
+	functionService := services.NewFunctionService(store, storage, queue)
+	scheduleService := services.NewScheduleService(store)
+
+	functionHandler := handlers.NewFunctionHandler(functionService)
+	scheduleHandler := handlers.NewScheduleHandler(scheduleService)
+
+	app := fiber.New()
+	api := app.Group("/api", middleware.APIKeyAuth(testKeys))
+	read := middleware.RequireScope(models.ScopeRead)
+	write := middleware.RequireScope(models.ScopeWrite)
+	invoke := middleware.RequireScope(models.ScopeInvoke)
+	api.Post("/functions", write, functionHandler.CreateFunction)
+	api.Get("/functions", read, functionHandler.ListFunctions)
+	api.Get("/functions/:id", read, functionHandler.GetFunction)
+	api.Post("/functions/:id/invoke", invoke, functionHandler.InvokeFunction)
+	api.Post("/functions/:id/invoke-batch", invoke, functionHandler.InvokeBatch)
+	api.Get("/functions/:id/invocations", read, functionHandler.ListInvocations)
+	api.Get("/functions/:id/stats", read, functionHandler.GetFunctionStats)
+	api.Get("/functions/:id/invocations/:invocationId", read, functionHandler.GetInvocationResult)
+	api.Post("/functions/:id/invocations/:invocationId/cancel", invoke, functionHandler.CancelInvocation)
+	api.Post("/functions/:id/invocations/:invocationId/replay", invoke, functionHandler.ReplayInvocation)
+	api.Get("/invocations", read, functionHandler.SearchInvocations)
+	api.Delete("/functions/:id", write, functionHandler.DeleteFunction)
+	api.Post("/functions/:id/grants", write, functionHandler.GrantAccess)
+	api.Put("/functions/:id/tags", write, functionHandler.UpdateTags)
+	api.Post("/functions/:id/schedules", write, scheduleHandler.CreateSchedule)
+	api.Get("/functions/:id/schedules", read, scheduleHandler.ListSchedules)
+	api.Delete("/functions/:id/schedules/:scheduleId", write, scheduleHandler.DeleteSchedule)
+	api.Get("/batches/:id", read, functionHandler.GetBatch)
+
+	return &testServer{
+		t:         t,
+		app:       app,
+		queue:     queue,
+		worker:    services.NewLocalWorker(queue, handler),
+		functions: functionService,
+		schedules: scheduleService,
+	}
+}
+
+// do sends a request with the given API key and decodes the JSON response into out
+func (s *testServer) do(method, path, key string, body interface{}, out interface{}) int {
+	s.t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			s.t.Fatalf("marshal request: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	if key != "" {
+		req.Header.Set(middleware.APIKeyHeader, key)
+	}
+
+	resp, err := s.app.Test(req, -1)
+	if err != nil {
+		s.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		s.t.Fatalf("read response: %v", err)
+	}
+	if out != nil && len(data) > 0 {
+		if err := json.Unmarshal(data, out); err != nil {
+			s.t.Fatalf("%s %s: decode %q: %v", method, path, data, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// expect fails the test unless a request returns the wanted status
+func (s *testServer) expect(want int, method, path, key string, body interface{}, out interface{}) {
+	s.t.Helper()
+
+	var raw json.RawMessage
+	if got := s.do(method, path, key, body, &raw); got != want {
+		s.t.Fatalf("%s %s: status %d, want %d: %s", method, path, got, want, raw)
+	}
+	if out != nil {
+		if err := json.Unmarshal(raw, out); err != nil {
+			s.t.Fatalf("%s %s: decode %q: %v", method, path, raw, err)
+		}
+	}
+}
+
+// createFunction creates a function owned by the caller of key
+func (s *testServer) createFunction(key string, req models.CreateFunctionRequest) *models.Function {
+	s.t.Helper()
+
+	if req.Code == "" {
+		req.Code = "def handler(event):\n    return event\n"
+	}
+	var fn models.Function
+	s.expect(fiber.StatusOK, "POST", "/api/functions", key, req, &fn)
+	return &fn
+}
+
+// invoke invokes a function and returns the invocation ID
+func (s *testServer) invoke(key string, functionID int64, params map[string]interface{}) int64 {
+	s.t.Helper()
+
+	var resp models.InvokeResponse
+	s.expect(fiber.StatusOK, "POST", fmt.Sprintf("/api/functions/%d/invoke", functionID), key,
+		models.InvokeRequest{Params: params}, &resp)
+	return resp.InvocationID
+}
+
+// result polls an invocation through the API until it leaves pending
+func (s *testServer) result(key string, functionID, invocationID int64) models.InvokeResponse {
+	s.t.Helper()
+
+	path := fmt.Sprintf("/api/functions/%d/invocations/%d", functionID, invocationID)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var resp models.InvokeResponse
+		s.expect(fiber.StatusOK, "GET", path, key, nil, &resp)
+		if resp.Status != models.StatusPending || time.Now().After(deadline) {
+			return resp
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
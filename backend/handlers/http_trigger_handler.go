@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+
+	"lambda-runner-server/models"
+	"lambda-runner-server/services"
+)
+
+// httpResultPollInterval is how soon a synchronous HTTP trigger first checks
+// for the result; later checks back off
+const httpResultPollInterval = 50 * time.Millisecond
+
+type HTTPTriggerHandler struct {
+	routes    *services.HTTPRouteService
+	functions *services.FunctionService
+}
+
+func NewHTTPTriggerHandler(routes *services.HTTPRouteService, functions *services.FunctionService) *HTTPTriggerHandler {
+	return &HTTPTriggerHandler{
+		routes:    routes,
+		functions: functions,
+	}
+}
+
+// GetRoute godoc
+// @Summary Get function HTTP route
+// @Description Get the public HTTP route (/fn/{slug}/*) of a function
+// @Tags functions
+// @Produce json
+// @Param id path int true "Function ID"
+// @Success 200 {object} models.HTTPRoute
+// @Failure 404 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /functions/{id}/http-route [get]
+func (h *HTTPTriggerHandler) GetRoute(c *fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid function ID"})
+	}
+
+	route, err := h.routes.GetRoute(c.UserContext(), id)
+	if err != nil {
+		return functionError(c, fiber.StatusNotFound, err)
+	}
+
+	return c.JSON(route)
+}
+
+// UpsertRoute godoc
+// @Summary Configure function HTTP route
+// @Description Create or update the public HTTP route of a function. With auth_type "token" a bearer token is returned once, when issued or rotated.
+// @Tags functions
+// @Accept json
+// @Produce json
+// @Param id path int true "Function ID"
+// @Param route body models.UpsertHTTPRouteRequest true "Route settings"
+// @Success 200 {object} models.HTTPRoute
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /functions/{id}/http-route [put]
+func (h *HTTPTriggerHandler) UpsertRoute(c *fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid function ID"})
+	}
+
+	var req models.UpsertHTTPRouteRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	route, err := h.routes.UpsertRoute(c.UserContext(), id, &req)
+	if err != nil {
+		status := fiber.StatusBadRequest
+		if errors.Is(err, services.ErrRouteSlugTaken) {
+			status = fiber.StatusConflict
+		}
+		return functionError(c, status, err)
+	}
+
+	return c.JSON(route)
+}
+
+// DeleteRoute godoc
+// @Summary Delete function HTTP route
+// @Tags functions
+// @Param id path int true "Function ID"
+// @Success 204
+// @Failure 404 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /functions/{id}/http-route [delete]
+func (h *HTTPTriggerHandler) DeleteRoute(c *fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid function ID"})
+	}
+
+	if err := h.routes.DeleteRoute(c.UserContext(), id); err != nil {
+		return functionError(c, fiber.StatusNotFound, err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// Serve invokes the function behind /fn/<slug>/* synchronously. The HTTP
+// request is passed as a models.HTTPEvent and the function result is mapped
+// back to the response.
+func (h *HTTPTriggerHandler) Serve(c *fiber.Ctx) error {
+	slug := c.Params("slug")
+	route, err := h.routes.ResolveRoute(c.UserContext(), slug)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if route == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "route not found"})
+	}
+
+	token := strings.TrimPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+	if !h.routes.Authorize(route, token) {
+		c.Set(fiber.HeaderWWWAuthenticate, "Bearer")
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid or missing token"})
+	}
+
+	params, err := services.EventParams(buildHTTPEvent(c, route))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	invokedBy := c.IP()
+	if invokedBy == "" {
+		invokedBy = "anonymous"
+	}
+
+	inv, err := h.functions.InvokeFunction(c.UserContext(), route.FunctionID, params, services.InvokeOptions{
+		InvokedBy: invokedBy,
+		Priority:  models.PriorityHigh,
+	})
+	if err != nil {
+		return invokeError(c, err)
+	}
+	c.Set("X-Invocation-Id", strconv.FormatInt(inv.ID, 10))
+
+	timeout := time.Duration(route.TimeoutSeconds) * time.Second
+	result, err := h.functions.WaitForResult(c.UserContext(), inv.ID, timeout, httpResultPollInterval)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	switch result.Status {
+	case models.StatusSuccess:
+		return writeHTTPResult(c, result.OutputResult)
+	case models.StatusPending, models.StatusTimeout:
+		return c.Status(fiber.StatusGatewayTimeout).JSON(fiber.Map{
+			"error":         "function did not respond in time",
+			"invocation_id": inv.ID,
+		})
+	default:
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error":         result.ErrorMessage,
+			"invocation_id": inv.ID,
+		})
+	}
+}
+
+// buildHTTPEvent maps a request to the event passed to the function
+func buildHTTPEvent(c *fiber.Ctx, route *models.HTTPRoute) *models.HTTPEvent {
+	rawQuery := string(c.Request().URI().QueryString())
+	query, _ := url.ParseQuery(rawQuery)
+
+	headers := make(map[string]string)
+	c.Request().Header.VisitAll(func(key, value []byte) {
+		name := strings.ToLower(string(key))
+		// Never hand the route token to function code or the invocation log
+		if name == "authorization" && route.AuthType == models.RouteAuthToken {
+			return
+		}
+		if existing, ok := headers[name]; ok {
+			headers[name] = existing + "," + string(value)
+		} else {
+			headers[name] = string(value)
+		}
+	})
+
+	event := &models.HTTPEvent{
+		Method:   c.Method(),
+		Path:     "/" + c.Params("*"),
+		RawQuery: rawQuery,
+		Query:    query,
+		Headers:  headers,
+		RequestContext: models.HTTPRequestContext{
+			RouteSlug: route.Slug,
+			SourceIP:  c.IP(),
+			Time:      time.Now().UTC(),
+		},
+	}
+
+	body := c.Body()
+	if utf8.Valid(body) {
+		event.Body = string(body)
+	} else {
+		event.Body = base64.StdEncoding.EncodeToString(body)
+		event.IsBase64Encoded = true
+	}
+	return event
+}
+
+// writeHTTPResult writes a function result as the HTTP response. Results
+// with a statusCode are treated as a models.HTTPResult; anything else is
+// returned as a JSON body with status 200.
+func writeHTTPResult(c *fiber.Ctx, output map[string]interface{}) error {
+	if _, ok := output["statusCode"]; !ok {
+		return c.JSON(output)
+	}
+
+	data, err := json.Marshal(output)
+	if err != nil {
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": err.Error()})
+	}
+	var result models.HTTPResult
+	if err := json.Unmarshal(data, &result); err != nil || result.StatusCode < 100 || result.StatusCode > 599 {
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "function returned an invalid statusCode"})
+	}
+
+	for name, value := range result.Headers {
+		c.Set(name, value)
+	}
+	c.Status(result.StatusCode)
+
+	switch body := result.Body.(type) {
+	case nil:
+		return nil
+	case string:
+		if result.IsBase64Encoded {
+			decoded, err := base64.StdEncoding.DecodeString(body)
+			if err != nil {
+				return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "function returned an invalid base64 body"})
+			}
+			return c.Send(decoded)
+		}
+		return c.SendString(body)
+	default:
+		// Structured bodies are serialized as JSON
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": err.Error()})
+		}
+		if _, ok := result.Headers[fiber.HeaderContentType]; !ok {
+			c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		}
+		return c.Send(encoded)
+	}
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"lambda-runner-server/models"
+	"lambda-runner-server/services"
+)
+
+type NamespaceHandler struct {
+	service *services.NamespaceService
+}
+
+func NewNamespaceHandler(service *services.NamespaceService) *NamespaceHandler {
+	return &NamespaceHandler{service: service}
+}
+
+// CreateNamespace godoc
+// @Summary Create a namespace
+// @Description Create a namespace (project) that owns its own functions, workflows, events and API keys
+// @Tags namespaces
+// @Accept json
+// @Produce json
+// @Param request body models.CreateNamespaceRequest true "Namespace"
+// @Success 201 {object} models.Namespace
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /namespaces [post]
+func (h *NamespaceHandler) CreateNamespace(c *fiber.Ctx) error {
+	var req models.CreateNamespaceRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	ns, err := h.service.CreateNamespace(c.UserContext(), &req)
+	if errors.Is(err, services.ErrNamespaceNameTaken) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusCreated).JSON(ns)
+}
+
+// ListNamespaces godoc
+// @Summary List namespaces
+// @Description List all namespaces for admin keys, otherwise only the key's own namespace
+// @Tags namespaces
+// @Produce json
+// @Success 200 {array} models.Namespace
+// @Router /namespaces [get]
+func (h *NamespaceHandler) ListNamespaces(c *fiber.Ctx) error {
+	namespaces, err := h.service.ListNamespaces(c.UserContext())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(namespaces)
+}
+
+// GetNamespace godoc
+// @Summary Get a namespace
+// @Tags namespaces
+// @Produce json
+// @Param namespace path string true "Namespace name"
+// @Success 200 {object} models.Namespace
+// @Failure 404 {object} map[string]string
+// @Router /namespaces/{namespace} [get]
+func (h *NamespaceHandler) GetNamespace(c *fiber.Ctx) error {
+	ns, err := h.service.GetNamespace(c.UserContext())
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(ns)
+}
+
+// UpdateNamespace godoc
+// @Summary Update a namespace
+// @Description Change the description and quotas of a namespace
+// @Tags namespaces
+// @Accept json
+// @Produce json
+// @Param namespace path string true "Namespace name"
+// @Param request body models.UpdateNamespaceRequest true "Namespace settings"
+// @Success 200 {object} models.Namespace
+// @Failure 400 {object} map[string]string
+// @Router /namespaces/{namespace} [put]
+func (h *NamespaceHandler) UpdateNamespace(c *fiber.Ctx) error {
+	var req models.UpdateNamespaceRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	ns, err := h.service.UpdateNamespace(c.UserContext(), &req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(ns)
+}
+
+// DeleteNamespace godoc
+// @Summary Delete a namespace
+// @Description Delete an empty namespace with its API keys, events and event rules
+// @Tags namespaces
+// @Param namespace path string true "Namespace name"
+// @Success 204
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /namespaces/{namespace} [delete]
+func (h *NamespaceHandler) DeleteNamespace(c *fiber.Ctx) error {
+	err := h.service.DeleteNamespace(c.UserContext())
+	if errors.Is(err, services.ErrNamespaceNotEmpty) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"lambda-runner-server/models"
+	"lambda-runner-server/services"
+)
+
+type RedisTriggerHandler struct {
+	service *services.RedisTriggerService
+}
+
+func NewRedisTriggerHandler(service *services.RedisTriggerService) *RedisTriggerHandler {
+	return &RedisTriggerHandler{service: service}
+}
+
+// CreateTrigger godoc
+// @Summary Create a Redis trigger
+// @Description Subscribe a function to a Redis stream or pub/sub channel. Messages are delivered in batches of batch_size (1 = one invocation per message).
+// @Tags triggers
+// @Accept json
+// @Produce json
+// @Param id path int true "Function ID"
+// @Param trigger body models.CreateRedisTriggerRequest true "Redis trigger"
+// @Success 200 {object} models.RedisTrigger
+// @Failure 400 {object} map[string]string
+// @Router /functions/{id}/redis-triggers [post]
+func (h *RedisTriggerHandler) CreateTrigger(c *fiber.Ctx) error {
+	functionID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid function ID"})
+	}
+
+	var req models.CreateRedisTriggerRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	trigger, err := h.service.CreateTrigger(c.UserContext(), functionID, &req)
+	if err != nil {
+		return functionError(c, fiber.StatusBadRequest, err)
+	}
+
+	return c.JSON(trigger)
+}
+
+// ListTriggers godoc
+// @Summary List Redis triggers
+// @Tags triggers
+// @Produce json
+// @Param id path int true "Function ID"
+// @Success 200 {array} models.RedisTrigger
+// @Failure 404 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /functions/{id}/redis-triggers [get]
+func (h *RedisTriggerHandler) ListTriggers(c *fiber.Ctx) error {
+	functionID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid function ID"})
+	}
+
+	triggers, err := h.service.ListTriggers(c.UserContext(), functionID)
+	if err != nil {
+		return functionError(c, fiber.StatusNotFound, err)
+	}
+
+	return c.JSON(triggers)
+}
+
+// DeleteTrigger godoc
+// @Summary Delete a Redis trigger
+// @Tags triggers
+// @Param id path int true "Function ID"
+// @Param triggerId path int true "Redis trigger ID"
+// @Success 204
+// @Failure 404 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /functions/{id}/redis-triggers/{triggerId} [delete]
+func (h *RedisTriggerHandler) DeleteTrigger(c *fiber.Ctx) error {
+	functionID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid function ID"})
+	}
+	triggerID, err := strconv.ParseInt(c.Params("triggerId"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid trigger ID"})
+	}
+
+	if err := h.service.DeleteTrigger(c.UserContext(), functionID, triggerID); err != nil {
+		return functionError(c, fiber.StatusNotFound, err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
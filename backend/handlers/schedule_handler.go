@@ -37,9 +37,9 @@ func (h *ScheduleHandler) CreateSchedule(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
-	sched, err := h.service.CreateSchedule(c.Context(), functionID, &req)
+	sched, err := h.service.CreateSchedule(c.UserContext(), functionID, &req)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		return functionError(c, fiber.StatusBadRequest, err)
 	}
 
 	return c.JSON(sched)
@@ -51,6 +51,8 @@ func (h *ScheduleHandler) CreateSchedule(c *fiber.Ctx) error {
 // @Produce json
 // @Param id path int true "Function ID"
 // @Success 200 {array} models.FunctionSchedule
+// @Failure 404 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Router /functions/{id}/schedules [get]
 func (h *ScheduleHandler) ListSchedules(c *fiber.Ctx) error {
 	functionID, err := strconv.ParseInt(c.Params("id"), 10, 64)
@@ -58,9 +60,9 @@ func (h *ScheduleHandler) ListSchedules(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid function ID"})
 	}
 
-	schedules, err := h.service.ListSchedules(c.Context(), functionID)
+	schedules, err := h.service.ListSchedules(c.UserContext(), functionID)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return functionError(c, fiber.StatusNotFound, err)
 	}
 
 	return c.JSON(schedules)
@@ -72,6 +74,8 @@ func (h *ScheduleHandler) ListSchedules(c *fiber.Ctx) error {
 // @Param id path int true "Function ID"
 // @Param scheduleId path int true "Schedule ID"
 // @Success 204
+// @Failure 404 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Router /functions/{id}/schedules/{scheduleId} [delete]
 func (h *ScheduleHandler) DeleteSchedule(c *fiber.Ctx) error {
 	functionID, err := strconv.ParseInt(c.Params("id"), 10, 64)
@@ -83,8 +87,8 @@ func (h *ScheduleHandler) DeleteSchedule(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid schedule ID"})
 	}
 
-	if err := h.service.DeleteSchedule(c.Context(), functionID, scheduleID); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	if err := h.service.DeleteSchedule(c.UserContext(), functionID, scheduleID); err != nil {
+		return functionError(c, fiber.StatusNotFound, err)
 	}
 
 	return c.SendStatus(fiber.StatusNoContent)
@@ -0,0 +1,106 @@
+package handlers_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"lambda-runner-server/models"
+	"lambda-runner-server/services"
+)
+
+func TestCreateListDeleteSchedule(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend testBackend) {
+		s := newTestServer(t, backend, services.EchoHandler)
+		fn := s.createFunction(aliceKey, models.CreateFunctionRequest{Name: "echo"})
+		path := fmt.Sprintf("/api/functions/%d/schedules", fn.ID)
+
+		s.expect(fiber.StatusBadRequest, "POST", path, aliceKey,
+			models.CreateScheduleRequest{ScheduledAt: time.Now().Add(-time.Minute)}, nil)
+
+		var sched models.FunctionSchedule
+		s.expect(fiber.StatusOK, "POST", path, aliceKey,
+			models.CreateScheduleRequest{ScheduledAt: time.Now().Add(time.Hour), Payload: map[string]interface{}{"x": 1}}, &sched)
+		if sched.ID == 0 || sched.Executed || sched.Priority != models.PriorityNormal {
+			t.Fatalf("unexpected schedule: %+v", sched)
+		}
+
+		var list []models.FunctionSchedule
+		s.expect(fiber.StatusOK, "GET", path, aliceKey, nil, &list)
+		if len(list) != 1 || list[0].ID != sched.ID {
+			t.Fatalf("unexpected schedules: %+v", list)
+		}
+
+		s.expect(fiber.StatusForbidden, "POST", path, bobKey,
+			models.CreateScheduleRequest{ScheduledAt: time.Now().Add(time.Hour)}, nil)
+
+		s.expect(fiber.StatusNoContent, "DELETE", fmt.Sprintf("%s/%d", path, sched.ID), aliceKey, nil, nil)
+		s.expect(fiber.StatusOK, "GET", path, aliceKey, nil, &list)
+		if len(list) != 0 {
+			t.Fatalf("schedule not deleted: %+v", list)
+		}
+	})
+}
+
+func TestScheduleAccess(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend testBackend) {
+		s := newTestServer(t, backend, services.EchoHandler)
+		private := false
+		fn := s.createFunction(aliceKey, models.CreateFunctionRequest{Name: "secret", IsPublic: &private})
+		path := fmt.Sprintf("/api/functions/%d/schedules", fn.ID)
+
+		var sched models.FunctionSchedule
+		s.expect(fiber.StatusOK, "POST", path, aliceKey,
+			models.CreateScheduleRequest{ScheduledAt: time.Now().Add(time.Hour), Payload: map[string]interface{}{"token": "x"}}, &sched)
+
+		// Bob can neither see the payloads of alice's private function nor delete its schedules
+		s.expect(fiber.StatusForbidden, "GET", path, bobKey, nil, nil)
+		s.expect(fiber.StatusForbidden, "DELETE", fmt.Sprintf("%s/%d", path, sched.ID), bobKey, nil, nil)
+
+		var list []models.FunctionSchedule
+		s.expect(fiber.StatusOK, "GET", path, aliceKey, nil, &list)
+		if len(list) != 1 || list[0].ID != sched.ID {
+			t.Fatalf("unexpected schedules: %+v", list)
+		}
+	})
+}
+
+func TestScheduleRuns(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend testBackend) {
+		s := newTestServer(t, backend, services.EchoHandler)
+		s.worker.Start()
+		defer s.worker.Stop()
+		runner := services.NewScheduleRunner(s.schedules, s.functions)
+		runner.Start()
+		defer runner.Stop()
+
+		fn := s.createFunction(aliceKey, models.CreateFunctionRequest{Name: "echo"})
+		path := fmt.Sprintf("/api/functions/%d/schedules", fn.ID)
+		s.expect(fiber.StatusOK, "POST", path, aliceKey,
+			models.CreateScheduleRequest{ScheduledAt: time.Now().Add(100 * time.Millisecond), Payload: map[string]interface{}{"x": 1}}, nil)
+
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			var list []models.FunctionSchedule
+			s.expect(fiber.StatusOK, "GET", path, aliceKey, nil, &list)
+			if len(list) == 1 && list[0].Status != "" {
+				if !list[0].Executed || list[0].Status != models.StatusSuccess {
+					t.Fatalf("unexpected schedule: %+v", list[0])
+				}
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("schedule did not run: %+v", list)
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+
+		var invocations []models.InvocationListItem
+		s.expect(fiber.StatusOK, "GET", fmt.Sprintf("/api/functions/%d/invocations", fn.ID), aliceKey, nil, &invocations)
+		if len(invocations) != 1 || invocations[0].InputEvent["x"] != float64(1) {
+			t.Fatalf("unexpected invocations: %+v", invocations)
+		}
+	})
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"lambda-runner-server/models"
+	"lambda-runner-server/services"
+)
+
+type StorageTriggerHandler struct {
+	service *services.StorageTriggerService
+}
+
+func NewStorageTriggerHandler(service *services.StorageTriggerService) *StorageTriggerHandler {
+	return &StorageTriggerHandler{service: service}
+}
+
+// CreateTrigger godoc
+// @Summary Create a storage trigger
+// @Description Invoke a function with an S3-style event for each object created (or removed) under a bucket or local directory. Local directories must lie within the server's STORAGE_TRIGGER_ROOTS. Without storage_type/location the caller's namespace in the code storage is watched.
+// @Tags triggers
+// @Accept json
+// @Produce json
+// @Param id path int true "Function ID"
+// @Param trigger body models.CreateStorageTriggerRequest true "Storage trigger"
+// @Success 200 {object} models.StorageTrigger
+// @Failure 400 {object} map[string]string
+// @Router /functions/{id}/storage-triggers [post]
+func (h *StorageTriggerHandler) CreateTrigger(c *fiber.Ctx) error {
+	functionID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid function ID"})
+	}
+
+	var req models.CreateStorageTriggerRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	trigger, err := h.service.CreateTrigger(c.UserContext(), functionID, &req)
+	if err != nil {
+		return functionError(c, fiber.StatusBadRequest, err)
+	}
+
+	return c.JSON(trigger)
+}
+
+// ListTriggers godoc
+// @Summary List storage triggers
+// @Tags triggers
+// @Produce json
+// @Param id path int true "Function ID"
+// @Success 200 {array} models.StorageTrigger
+// @Failure 404 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /functions/{id}/storage-triggers [get]
+func (h *StorageTriggerHandler) ListTriggers(c *fiber.Ctx) error {
+	functionID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid function ID"})
+	}
+
+	triggers, err := h.service.ListTriggers(c.UserContext(), functionID)
+	if err != nil {
+		return functionError(c, fiber.StatusNotFound, err)
+	}
+
+	return c.JSON(triggers)
+}
+
+// DeleteTrigger godoc
+// @Summary Delete a storage trigger
+// @Tags triggers
+// @Param id path int true "Function ID"
+// @Param triggerId path int true "Storage trigger ID"
+// @Success 204
+// @Failure 404 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /functions/{id}/storage-triggers/{triggerId} [delete]
+func (h *StorageTriggerHandler) DeleteTrigger(c *fiber.Ctx) error {
+	functionID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid function ID"})
+	}
+	triggerID, err := strconv.ParseInt(c.Params("triggerId"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid trigger ID"})
+	}
+
+	if err := h.service.DeleteTrigger(c.UserContext(), functionID, triggerID); err != nil {
+		return functionError(c, fiber.StatusNotFound, err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"lambda-runner-server/models"
+	"lambda-runner-server/services"
+)
+
+type WebhookHandler struct {
+	service *services.WebhookService
+}
+
+func NewWebhookHandler(service *services.WebhookService) *WebhookHandler {
+	return &WebhookHandler{service: service}
+}
+
+// CreateTrigger godoc
+// @Summary Create a webhook trigger
+// @Description Create a webhook trigger for a function. The response includes the secret (generated when omitted) and is the only time it is returned.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path int true "Function ID"
+// @Param trigger body models.CreateWebhookTriggerRequest true "Webhook trigger"
+// @Success 200 {object} models.WebhookTrigger
+// @Failure 400 {object} map[string]string
+// @Router /functions/{id}/webhooks [post]
+func (h *WebhookHandler) CreateTrigger(c *fiber.Ctx) error {
+	functionID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid function ID"})
+	}
+
+	var req models.CreateWebhookTriggerRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	trigger, err := h.service.CreateTrigger(c.UserContext(), functionID, &req)
+	if err != nil {
+		return functionError(c, fiber.StatusBadRequest, err)
+	}
+
+	return c.JSON(trigger)
+}
+
+// ListTriggers godoc
+// @Summary List webhook triggers
+// @Tags webhooks
+// @Produce json
+// @Param id path int true "Function ID"
+// @Success 200 {array} models.WebhookTrigger
+// @Failure 404 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /functions/{id}/webhooks [get]
+func (h *WebhookHandler) ListTriggers(c *fiber.Ctx) error {
+	functionID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid function ID"})
+	}
+
+	triggers, err := h.service.ListTriggers(c.UserContext(), functionID)
+	if err != nil {
+		return functionError(c, fiber.StatusNotFound, err)
+	}
+
+	return c.JSON(triggers)
+}
+
+// DeleteTrigger godoc
+// @Summary Delete a webhook trigger
+// @Tags webhooks
+// @Param id path int true "Function ID"
+// @Param webhookId path int true "Webhook trigger ID"
+// @Success 204
+// @Failure 404 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /functions/{id}/webhooks/{webhookId} [delete]
+func (h *WebhookHandler) DeleteTrigger(c *fiber.Ctx) error {
+	functionID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid function ID"})
+	}
+	triggerID, err := strconv.ParseInt(c.Params("webhookId"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid webhook ID"})
+	}
+
+	if err := h.service.DeleteTrigger(c.UserContext(), functionID, triggerID); err != nil {
+		return functionError(c, fiber.StatusNotFound, err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ListDeliveries godoc
+// @Summary List webhook deliveries
+// @Description Get the most recent deliveries received by a webhook trigger
+// @Tags webhooks
+// @Produce json
+// @Param id path int true "Function ID"
+// @Param webhookId path int true "Webhook trigger ID"
+// @Param limit query int false "Number of results to return" default(20)
+// @Success 200 {array} models.WebhookDelivery
+// @Failure 404 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /functions/{id}/webhooks/{webhookId}/deliveries [get]
+func (h *WebhookHandler) ListDeliveries(c *fiber.Ctx) error {
+	functionID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid function ID"})
+	}
+	triggerID, err := strconv.ParseInt(c.Params("webhookId"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid webhook ID"})
+	}
+
+	deliveries, err := h.service.ListDeliveries(c.UserContext(), functionID, triggerID, c.QueryInt("limit", 20))
+	if err != nil {
+		return functionError(c, fiber.StatusNotFound, err)
+	}
+
+	return c.JSON(deliveries)
+}
+
+// Receive accepts a delivery at /hooks/<token>
+func (h *WebhookHandler) Receive(c *fiber.Ctx) error {
+	headers := make(map[string]string)
+	c.Request().Header.VisitAll(func(key, value []byte) {
+		headers[strings.ToLower(string(key))] = string(value)
+	})
+
+	delivery, duplicate, err := h.service.Receive(c.UserContext(), c.Params("token"), headers, c.Body())
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrWebhookNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		case errors.Is(err, services.ErrInvalidSignature):
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+		}
+		var throttleErr *services.ThrottleError
+		if errors.As(err, &throttleErr) {
+			return invokeError(c, err)
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	status := fiber.StatusAccepted
+	if duplicate {
+		status = fiber.StatusOK
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"delivery_id":   delivery.DeliveryID,
+		"invocation_id": delivery.InvocationID,
+		"duplicate":     duplicate,
+	})
+}
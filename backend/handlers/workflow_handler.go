@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gopkg.in/yaml.v3"
+
+	"lambda-runner-server/models"
+	"lambda-runner-server/services"
+)
+
+type WorkflowHandler struct {
+	service *services.WorkflowService
+}
+
+func NewWorkflowHandler(service *services.WorkflowService) *WorkflowHandler {
+	return &WorkflowHandler{service: service}
+}
+
+// parseWorkflowRequest reads a workflow from a JSON body or, when the content
+// type mentions yaml, from a YAML body with the same field names
+func parseWorkflowRequest(c *fiber.Ctx) (*models.CreateWorkflowRequest, error) {
+	var req models.CreateWorkflowRequest
+	if !strings.Contains(strings.ToLower(c.Get(fiber.HeaderContentType)), "yaml") {
+		if err := c.BodyParser(&req); err != nil {
+			return nil, err
+		}
+		return &req, nil
+	}
+
+	var doc interface{}
+	if err := yaml.Unmarshal(c.Body(), &doc); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// CreateWorkflow godoc
+// @Summary Create a workflow
+// @Description Create a workflow from a JSON or YAML (Content-Type: application/yaml) definition of task, parallel, choice, wait, pass, succeed and fail states
+// @Tags workflows
+// @Accept json
+// @Produce json
+// @Param workflow body models.CreateWorkflowRequest true "Workflow"
+// @Success 201 {object} models.Workflow
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /workflows [post]
+func (h *WorkflowHandler) CreateWorkflow(c *fiber.Ctx) error {
+	req, err := parseWorkflowRequest(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	workflow, err := h.service.CreateWorkflow(c.UserContext(), req)
+	if errors.Is(err, services.ErrWorkflowNameTaken) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return functionError(c, fiber.StatusBadRequest, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(workflow)
+}
+
+// ListWorkflows godoc
+// @Summary List workflows
+// @Tags workflows
+// @Produce json
+// @Success 200 {array} models.Workflow
+// @Router /workflows [get]
+func (h *WorkflowHandler) ListWorkflows(c *fiber.Ctx) error {
+	workflows, err := h.service.ListWorkflows(c.UserContext())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(workflows)
+}
+
+// GetWorkflow godoc
+// @Summary Get a workflow
+// @Tags workflows
+// @Produce json
+// @Param id path int true "Workflow ID"
+// @Success 200 {object} models.Workflow
+// @Failure 404 {object} map[string]string
+// @Router /workflows/{id} [get]
+func (h *WorkflowHandler) GetWorkflow(c *fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid workflow ID"})
+	}
+
+	workflow, err := h.service.GetWorkflow(c.UserContext(), id)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(workflow)
+}
+
+// UpdateWorkflow godoc
+// @Summary Update a workflow
+// @Description Replace a workflow definition. Running executions keep the definition they started with.
+// @Tags workflows
+// @Accept json
+// @Produce json
+// @Param id path int true "Workflow ID"
+// @Param workflow body models.CreateWorkflowRequest true "Workflow"
+// @Success 200 {object} models.Workflow
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /workflows/{id} [put]
+func (h *WorkflowHandler) UpdateWorkflow(c *fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid workflow ID"})
+	}
+	req, err := parseWorkflowRequest(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	workflow, err := h.service.UpdateWorkflow(c.UserContext(), id, req)
+	if errors.Is(err, services.ErrWorkflowNameTaken) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return functionError(c, fiber.StatusBadRequest, err)
+	}
+	return c.JSON(workflow)
+}
+
+// DeleteWorkflow godoc
+// @Summary Delete a workflow
+// @Description Delete a workflow together with its executions
+// @Tags workflows
+// @Param id path int true "Workflow ID"
+// @Success 204
+// @Failure 404 {object} map[string]string
+// @Router /workflows/{id} [delete]
+func (h *WorkflowHandler) DeleteWorkflow(c *fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid workflow ID"})
+	}
+
+	if err := h.service.DeleteWorkflow(c.UserContext(), id); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// StartExecution godoc
+// @Summary Start a workflow execution
+// @Tags workflows
+// @Accept json
+// @Produce json
+// @Param id path int true "Workflow ID"
+// @Param request body models.StartExecutionRequest false "Execution input"
+// @Success 202 {object} models.WorkflowExecution
+// @Failure 404 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /workflows/{id}/executions [post]
+func (h *WorkflowHandler) StartExecution(c *fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid workflow ID"})
+	}
+
+	var req models.StartExecutionRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+		}
+	}
+
+	execution, err := h.service.StartExecution(c.UserContext(), id, req.Input)
+	if err != nil {
+		return functionError(c, fiber.StatusNotFound, err)
+	}
+	return c.Status(fiber.StatusAccepted).JSON(execution)
+}
+
+// ListExecutions godoc
+// @Summary List workflow executions
+// @Tags workflows
+// @Produce json
+// @Param id path int true "Workflow ID"
+// @Param limit query int false "Maximum number of executions (default 20, max 100)"
+// @Success 200 {array} models.WorkflowExecution
+// @Router /workflows/{id}/executions [get]
+func (h *WorkflowHandler) ListExecutions(c *fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid workflow ID"})
+	}
+
+	executions, err := h.service.ListExecutions(c.UserContext(), id, c.QueryInt("limit", 20))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(executions)
+}
+
+// GetExecution godoc
+// @Summary Get a workflow execution
+// @Description Get an execution with its step history, including the steps of parallel branches
+// @Tags workflows
+// @Produce json
+// @Param id path int true "Execution ID"
+// @Success 200 {object} models.WorkflowExecutionDetail
+// @Failure 404 {object} map[string]string
+// @Router /workflow-executions/{id} [get]
+func (h *WorkflowHandler) GetExecution(c *fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid execution ID"})
+	}
+
+	execution, err := h.service.GetExecution(c.UserContext(), id)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(execution)
+}
+
+// CancelExecution godoc
+// @Summary Cancel a workflow execution
+// @Description Stop a running execution. Invocations already enqueued by its tasks still run.
+// @Tags workflows
+// @Produce json
+// @Param id path int true "Execution ID"
+// @Success 200 {object} models.WorkflowExecutionDetail
+// @Failure 404 {object} map[string]string
+// @Router /workflow-executions/{id}/cancel [post]
+func (h *WorkflowHandler) CancelExecution(c *fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid execution ID"})
+	}
+
+	execution, err := h.service.CancelExecution(c.UserContext(), id)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(execution)
+}
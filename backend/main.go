@@ -4,17 +4,22 @@ import (
 	"context"
 	"log"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/aws/aws-xray-sdk-go/xray"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
 	"github.com/gofiber/swagger"
 
 	"lambda-runner-server/handlers"
 	customMiddleware "lambda-runner-server/middleware"
+	"lambda-runner-server/migrations"
+	"lambda-runner-server/models"
 	"lambda-runner-server/services"
 
 	_ "lambda-runner-server/docs"
@@ -48,6 +53,11 @@ func main() {
 	redisPort, _ := strconv.Atoi(getEnv("REDIS_PORT", "6379"))
 	serverPort := getEnv("SERVER_PORT", "8080")
 
+	// Database driver: "postgres", or "sqlite" for single-node deployments
+	dbDriver := getEnv("DB_DRIVER", services.DriverPostgres)
+	// SQLite Config (database file used with DB_DRIVER=sqlite)
+	dbPath := getEnv("DB_PATH", "/data/db/softgate.db")
+
 	// PostgreSQL Config
 	dbHost := getEnv("DB_HOST", "localhost")
 	dbPort, _ := strconv.Atoi(getEnv("DB_PORT", "5432"))
@@ -56,9 +66,49 @@ func main() {
 	dbName := getEnv("DB_NAME", "softgate")
 	dbSSLMode := getEnv("DB_SSLMODE", "disable")
 
+	// Concurrency Config (worker slots per runtime, e.g. "python=4,golang=2,*=8")
+	runtimeConcurrency := getEnv("RUNTIME_CONCURRENCY", "")
+
+	// Callback Config (default HMAC secret for deliveries without their own)
+	callbackSigningSecret := getEnv("CALLBACK_SIGNING_SECRET", "")
+
+	// Redis Trigger Config (consumer name of this instance in consumer groups; must be unique among running instances)
+	hostname, _ := os.Hostname()
+	redisTriggerConsumer := getEnv("REDIS_TRIGGER_CONSUMER", hostname)
+
 	// Storage Config
 	storageType := getEnv("STORAGE_TYPE", "local")
 	storageBucket := getEnv("STORAGE_BUCKET", "/data/code")
+	// Optional S3-compatible endpoint polled by storage triggers (e.g. MinIO)
+	s3Endpoint := getEnv("S3_ENDPOINT", "")
+	// Directories local storage triggers may watch, separated like PATH (e.g. "/data/uploads:/srv/inbox")
+	storageTriggerRoots := filepath.SplitList(getEnv("STORAGE_TRIGGER_ROOTS", ""))
+	// Buckets S3 storage triggers may watch besides the code storage, separated by commas
+	storageTriggerBuckets := strings.Split(getEnv("STORAGE_TRIGGER_BUCKETS", ""), ",")
+
+	// Auth Config (ADMIN_API_KEY is registered as an admin key on startup)
+	adminAPIKey := getEnv("ADMIN_API_KEY", "")
+	corsAllowOrigins := getEnv("CORS_ALLOW_ORIGINS", "http://localhost,http://localhost:3000")
+
+	switch dbDriver {
+	case services.DriverPostgres:
+		// The full feature set, served below
+	case services.DriverSQLite:
+		runSingleNode(singleNodeConfig{
+			dbPath:                dbPath,
+			serverPort:            serverPort,
+			redisHost:             redisHost,
+			redisPort:             redisPort,
+			storageType:           storageType,
+			storageBucket:         storageBucket,
+			adminAPIKey:           adminAPIKey,
+			callbackSigningSecret: callbackSigningSecret,
+			corsAllowOrigins:      corsAllowOrigins,
+		})
+		return
+	default:
+		log.Fatalf("Invalid DB_DRIVER %q (valid: %s, %s)", dbDriver, services.DriverPostgres, services.DriverSQLite)
+	}
 
 	// Initialize services
 	dbService, dbErr := services.NewDBService(dbHost, dbPort, dbUser, dbPassword, dbName, dbSSLMode)
@@ -68,11 +118,27 @@ func main() {
 	}
 	defer dbService.Close()
 
-	// Initialize database schema
-	if err := dbService.InitSchema(context.Background()); err != nil {
-		log.Fatalf("Failed to initialize database schema: %v", err)
+	migrationService, err := services.NewMigrationService(dbService, migrations.FS)
+	if err != nil {
+		log.Fatalf("Failed to load database migrations: %v", err)
+	}
+
+	// "server migrate up|down [n]|status" manages the schema and exits
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(context.Background(), migrationService, os.Args[2:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
+	// Apply pending database migrations
+	applyMigrations(context.Background(), migrationService)
+
+	apiKeyService := services.NewAPIKeyService(dbService)
+	namespaceService := services.NewNamespaceService(dbService)
+	if err := apiKeyService.EnsureBootstrapKey(context.Background(), adminAPIKey); err != nil {
+		log.Fatalf("Failed to register admin API key: %v", err)
 	}
-	log.Println("Database schema initialized")
 
 	// Initialize storage service
 	storageService, err := services.NewStorageService(storageType, storageBucket)
@@ -87,10 +153,60 @@ func main() {
 	// Initialize function service
 	functionService := services.NewFunctionService(dbService, storageService, redisService)
 
+	runtimeCapacity, err := services.ParseRuntimeCapacity(runtimeConcurrency)
+	if err != nil {
+		log.Fatalf("Invalid RUNTIME_CONCURRENCY: %v", err)
+	}
+	functionService.UseConcurrencyLimiter(services.NewConcurrencyLimiter(dbService, redisService, runtimeCapacity))
+
+	rateLimitService := services.NewRateLimitService(dbService, redisService)
+	functionService.UseRateLimiter(rateLimitService)
+
+	// Start result collector
+	resultCollector := services.NewResultCollector(functionService)
+	resultCollector.Start()
+	defer resultCollector.Stop()
+
+	// Start callback dispatcher
+	callbackDispatcher := services.NewCallbackDispatcher(dbService, callbackSigningSecret)
+	callbackDispatcher.Start()
+	defer callbackDispatcher.Stop()
+
+	// Start Redis trigger runner
+	redisTriggerRunner := services.NewRedisTriggerRunner(dbService, redisService, functionService, redisTriggerConsumer)
+	redisTriggerRunner.Start()
+	defer redisTriggerRunner.Stop()
+
+	// Start storage watcher
+	storageWatcher := services.NewStorageWatcher(dbService, redisService, functionService, hostname, s3Endpoint)
+	storageWatcher.Start()
+	defer storageWatcher.Stop()
+
+	// Start workflow runner
+	workflowRunner := services.NewWorkflowRunner(dbService, functionService)
+	workflowRunner.Start()
+	defer workflowRunner.Stop()
+
+	// Start invocation purger
+	invocationPurger := services.NewInvocationPurger(dbService, storageService)
+	invocationPurger.Start()
+	defer invocationPurger.Stop()
+
 	// Initialize handlers/services
 	functionHandler := handlers.NewFunctionHandler(functionService)
 	scheduleService := services.NewScheduleService(dbService)
 	scheduleHandler := handlers.NewScheduleHandler(scheduleService)
+	adminHandler := handlers.NewAdminHandler(rateLimitService, services.NewRetentionService(dbService, invocationPurger))
+	httpRouteService := services.NewHTTPRouteService(dbService)
+	httpTriggerHandler := handlers.NewHTTPTriggerHandler(httpRouteService, functionService)
+	webhookHandler := handlers.NewWebhookHandler(services.NewWebhookService(dbService, functionService))
+	redisTriggerHandler := handlers.NewRedisTriggerHandler(services.NewRedisTriggerService(dbService))
+	storageTriggerHandler := handlers.NewStorageTriggerHandler(services.NewStorageTriggerService(dbService, storageType, storageBucket, storageTriggerRoots, storageTriggerBuckets))
+	workflowHandler := handlers.NewWorkflowHandler(services.NewWorkflowService(dbService, functionService))
+	eventHandler := handlers.NewEventHandler(services.NewEventBusService(dbService, functionService))
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService)
+	namespaceHandler := handlers.NewNamespaceHandler(namespaceService)
+	auditHandler := handlers.NewAuditHandler(services.NewAuditService(dbService))
 
 	// Start schedule runner
 	scheduleRunner := services.NewScheduleRunner(scheduleService, functionService)
@@ -98,6 +214,101 @@ func main() {
 	defer scheduleRunner.Stop()
 
 	// Fiber App
+	app := newApp(corsAllowOrigins)
+
+	// API routes (every route requires an API key with the scope it names)
+	api := app.Group("/api", customMiddleware.APIKeyAuth(apiKeyService))
+	read := customMiddleware.RequireScope(models.ScopeRead)
+	write := customMiddleware.RequireScope(models.ScopeWrite)
+	invoke := customMiddleware.RequireScope(models.ScopeInvoke)
+	admin := customMiddleware.RequireScope(models.ScopeAdmin)
+
+	// Resource routes are served for the key's own namespace under /api and for
+	// any namespace the key may access under /api/namespaces/:namespace
+	routes := func(r fiber.Router) {
+		// Function routes (PRD spec)
+		registerFunctionRoutes(r, functionHandler, scheduleHandler)
+		r.Get("/functions/:id/http-route", read, httpTriggerHandler.GetRoute)
+		r.Put("/functions/:id/http-route", write, httpTriggerHandler.UpsertRoute)
+		r.Delete("/functions/:id/http-route", write, httpTriggerHandler.DeleteRoute)
+		r.Post("/functions/:id/webhooks", write, webhookHandler.CreateTrigger)
+		r.Get("/functions/:id/webhooks", read, webhookHandler.ListTriggers)
+		r.Delete("/functions/:id/webhooks/:webhookId", write, webhookHandler.DeleteTrigger)
+		r.Get("/functions/:id/webhooks/:webhookId/deliveries", read, webhookHandler.ListDeliveries)
+		r.Post("/functions/:id/redis-triggers", write, redisTriggerHandler.CreateTrigger)
+		r.Get("/functions/:id/redis-triggers", read, redisTriggerHandler.ListTriggers)
+		r.Delete("/functions/:id/redis-triggers/:triggerId", write, redisTriggerHandler.DeleteTrigger)
+		r.Post("/functions/:id/storage-triggers", write, storageTriggerHandler.CreateTrigger)
+		r.Get("/functions/:id/storage-triggers", read, storageTriggerHandler.ListTriggers)
+		r.Delete("/functions/:id/storage-triggers/:triggerId", write, storageTriggerHandler.DeleteTrigger)
+
+		// Workflow routes
+		r.Post("/workflows", write, workflowHandler.CreateWorkflow)
+		r.Get("/workflows", read, workflowHandler.ListWorkflows)
+		r.Get("/workflows/:id", read, workflowHandler.GetWorkflow)
+		r.Put("/workflows/:id", write, workflowHandler.UpdateWorkflow)
+		r.Delete("/workflows/:id", write, workflowHandler.DeleteWorkflow)
+		r.Post("/workflows/:id/executions", invoke, workflowHandler.StartExecution)
+		r.Get("/workflows/:id/executions", read, workflowHandler.ListExecutions)
+		r.Get("/workflow-executions/:id", read, workflowHandler.GetExecution)
+		r.Post("/workflow-executions/:id/cancel", invoke, workflowHandler.CancelExecution)
+
+		// Event bus routes
+		r.Post("/events", invoke, eventHandler.PublishEvent)
+		r.Get("/events", read, eventHandler.ListEvents)
+		r.Post("/events/replay", invoke, eventHandler.ReplayEvents)
+		r.Get("/events/:seq", read, eventHandler.GetEvent)
+		r.Post("/events/:seq/replay", invoke, eventHandler.ReplayEvent)
+		r.Post("/event-rules", write, eventHandler.CreateRule)
+		r.Get("/event-rules", read, eventHandler.ListRules)
+		r.Get("/event-rules/:ruleId", read, eventHandler.GetRule)
+		r.Put("/event-rules/:ruleId", write, eventHandler.UpdateRule)
+		r.Delete("/event-rules/:ruleId", write, eventHandler.DeleteRule)
+	}
+	routes(api)
+	ns := api.Group("/namespaces/:namespace", customMiddleware.NamespaceAccess(namespaceService))
+	routes(ns)
+
+	// Namespace routes
+	api.Post("/namespaces", admin, namespaceHandler.CreateNamespace)
+	api.Get("/namespaces", read, namespaceHandler.ListNamespaces)
+	ns.Get("", read, namespaceHandler.GetNamespace)
+	ns.Put("", admin, namespaceHandler.UpdateNamespace)
+	ns.Delete("", admin, namespaceHandler.DeleteNamespace)
+
+	// API key routes
+	registerAPIKeyRoutes(api, apiKeyHandler)
+
+	// Audit log
+	api.Get("/audit", admin, auditHandler.ListAuditEvents)
+
+	// Admin routes
+	adminAPI := api.Group("/admin", admin)
+	adminAPI.Get("/rate-limits", adminHandler.ListRateLimits)
+	adminAPI.Put("/rate-limits", adminHandler.UpsertRateLimit)
+	adminAPI.Delete("/rate-limits/:limitId", adminHandler.DeleteRateLimit)
+	adminAPI.Get("/quotas/:scope/:subject", adminHandler.GetQuotaUsage)
+	adminAPI.Get("/retention", adminHandler.GetRetentionStatus)
+	adminAPI.Get("/retention/policies", adminHandler.ListRetentionPolicies)
+	adminAPI.Put("/retention/policies", adminHandler.UpsertRetentionPolicy)
+	adminAPI.Delete("/retention/policies/:policyId", adminHandler.DeleteRetentionPolicy)
+
+	// Function URLs (public HTTP triggers)
+	app.All("/fn/:slug", httpTriggerHandler.Serve)
+	app.All("/fn/:slug/*", httpTriggerHandler.Serve)
+
+	// Inbound webhooks
+	app.Post("/hooks/:token", webhookHandler.Receive)
+
+	log.Printf("SoftGate Server starting on port %s", serverPort)
+	log.Printf("Database: %s:%d/%s", dbHost, dbPort, dbName)
+	log.Printf("Redis: %s:%d", redisHost, redisPort)
+	log.Fatal(app.Listen(":" + serverPort))
+}
+
+// newApp creates the Fiber app with the global middleware, Swagger and the
+// health endpoint
+func newApp(corsAllowOrigins string) *fiber.App {
 	app := fiber.New(fiber.Config{
 		AppName: "SoftGate",
 	})
@@ -105,11 +316,14 @@ func main() {
 	// Middleware
 	app.Use(logger.New())
 	app.Use(recover.New())
-	app.Use(customMiddleware.XRayMiddleware()) // X-Ray tracing
+	app.Use(requestid.New())
+	app.Use(customMiddleware.RequestMetadata()) // request details for the audit log
+	app.Use(customMiddleware.XRayMiddleware())  // X-Ray tracing
 	app.Use(cors.New(cors.Config{
-		AllowOrigins: "*",
-		AllowMethods: "GET,POST,PUT,DELETE,OPTIONS",
-		AllowHeaders: "Origin,Content-Type,Accept",
+		AllowOrigins:  corsAllowOrigins,
+		AllowMethods:  "GET,POST,PUT,PATCH,DELETE,HEAD,OPTIONS",
+		AllowHeaders:  "Origin,Content-Type,Accept,Authorization," + customMiddleware.APIKeyHeader,
+		ExposeHeaders: "Retry-After,X-Request-ID,X-Next-Cursor,X-Total-Count",
 	}))
 
 	// Swagger
@@ -120,25 +334,50 @@ func main() {
 		return c.JSON(fiber.Map{"status": "UP"})
 	})
 
-	// API routes
-	api := app.Group("/api")
-
-	// Function routes (PRD spec)
-	api.Post("/functions", functionHandler.CreateFunction)
-	api.Get("/functions", functionHandler.ListFunctions)
-	api.Get("/functions/:id", functionHandler.GetFunction)
-	api.Post("/functions/:id/invoke", functionHandler.InvokeFunction)
-	api.Get("/functions/:id/invocations", functionHandler.ListInvocations)
-	api.Get("/functions/:id/invocations/:invocationId", functionHandler.GetInvocationResult)
-	api.Delete("/functions/:id", functionHandler.DeleteFunction)
-	api.Post("/functions/:id/schedules", scheduleHandler.CreateSchedule)
-	api.Get("/functions/:id/schedules", scheduleHandler.ListSchedules)
-	api.Delete("/functions/:id/schedules/:scheduleId", scheduleHandler.DeleteSchedule)
+	return app
+}
 
-	log.Printf("SoftGate Server starting on port %s", serverPort)
-	log.Printf("Database: %s:%d/%s", dbHost, dbPort, dbName)
-	log.Printf("Redis: %s:%d", redisHost, redisPort)
-	log.Fatal(app.Listen(":" + serverPort))
+// registerFunctionRoutes registers the routes of functions, their
+// invocations, grants and schedules, and batches
+func registerFunctionRoutes(r fiber.Router, functionHandler *handlers.FunctionHandler, scheduleHandler *handlers.ScheduleHandler) {
+	read := customMiddleware.RequireScope(models.ScopeRead)
+	write := customMiddleware.RequireScope(models.ScopeWrite)
+	invoke := customMiddleware.RequireScope(models.ScopeInvoke)
+
+	r.Post("/functions", write, functionHandler.CreateFunction)
+	r.Get("/functions", read, functionHandler.ListFunctions)
+	r.Get("/functions/:id", read, functionHandler.GetFunction)
+	r.Post("/functions/:id/invoke", invoke, functionHandler.InvokeFunction)
+	r.Post("/functions/:id/invoke-batch", invoke, functionHandler.InvokeBatch)
+	r.Get("/functions/:id/invocations", read, functionHandler.ListInvocations)
+	r.Get("/functions/:id/stats", read, functionHandler.GetFunctionStats)
+	r.Get("/functions/:id/invocations/:invocationId", read, functionHandler.GetInvocationResult)
+	r.Post("/functions/:id/invocations/:invocationId/cancel", invoke, functionHandler.CancelInvocation)
+	r.Post("/functions/:id/invocations/:invocationId/replay", invoke, functionHandler.ReplayInvocation)
+	r.Get("/functions/:id/invocations/:invocationId/callback", read, functionHandler.GetInvocationCallback)
+	r.Get("/invocations", read, functionHandler.SearchInvocations)
+	r.Delete("/functions/:id", write, functionHandler.DeleteFunction)
+	r.Get("/functions/:id/concurrency", read, functionHandler.GetConcurrency)
+	r.Put("/functions/:id/concurrency", write, functionHandler.UpdateConcurrency)
+	r.Put("/functions/:id/callback", write, functionHandler.UpdateCallback)
+	r.Put("/functions/:id/visibility", write, functionHandler.UpdateVisibility)
+	r.Put("/functions/:id/tags", write, functionHandler.UpdateTags)
+	r.Get("/functions/:id/grants", read, functionHandler.ListGrants)
+	r.Post("/functions/:id/grants", write, functionHandler.GrantAccess)
+	r.Delete("/functions/:id/grants/:grantId", write, functionHandler.RevokeGrant)
+	r.Post("/functions/:id/schedules", write, scheduleHandler.CreateSchedule)
+	r.Get("/functions/:id/schedules", read, scheduleHandler.ListSchedules)
+	r.Delete("/functions/:id/schedules/:scheduleId", write, scheduleHandler.DeleteSchedule)
+	r.Get("/batches/:id", read, functionHandler.GetBatch)
+}
+
+// registerAPIKeyRoutes registers the API key routes
+func registerAPIKeyRoutes(api fiber.Router, apiKeyHandler *handlers.APIKeyHandler) {
+	admin := customMiddleware.RequireScope(models.ScopeAdmin)
+	api.Get("/api-keys/me", apiKeyHandler.GetCurrentAPIKey)
+	api.Post("/api-keys", admin, apiKeyHandler.CreateAPIKey)
+	api.Get("/api-keys", admin, apiKeyHandler.ListAPIKeys)
+	api.Delete("/api-keys/:keyId", admin, apiKeyHandler.RevokeAPIKey)
 }
 
 func getEnv(key, defaultValue string) string {
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/gofiber/fiber/v2"
+
+	"lambda-runner-server/models"
+)
+
+type namespaceContextKey struct{}
+
+// NamespaceResolver looks up a namespace by name. It returns nil for unknown names.
+type NamespaceResolver interface {
+	ResolveNamespace(ctx context.Context, name string) (*models.Namespace, error)
+}
+
+// NamespaceAccess switches the request to the namespace named by the
+// :namespace route parameter. Keys may only address their own namespace
+// unless they hold the admin scope.
+func NamespaceAccess(resolver NamespaceResolver) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		name := c.Params("namespace")
+		ns, err := resolver.ResolveNamespace(c.UserContext(), name)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		if ns == nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "namespace not found: " + name})
+		}
+
+		key := APIKeyFromContext(c.UserContext())
+		if key == nil || (key.NamespaceID != ns.ID && !key.HasScope(models.ScopeAdmin)) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "API key has no access to namespace " + name})
+		}
+
+		c.SetUserContext(WithNamespace(c.UserContext(), ns))
+		return c.Next()
+	}
+}
+
+// WithNamespace returns a context confined to a namespace
+func WithNamespace(ctx context.Context, ns *models.Namespace) context.Context {
+	return context.WithValue(ctx, namespaceContextKey{}, ns)
+}
+
+// NamespaceFromContext returns the namespace a request is confined to, or nil
+// outside API requests
+func NamespaceFromContext(ctx context.Context) *models.Namespace {
+	ns, _ := ctx.Value(namespaceContextKey{}).(*models.Namespace)
+	return ns
+}
+
+// NamespaceID returns the ID of the namespace a request is confined to, or 0
+// outside API requests (schedules, triggers and other background work see
+// every namespace)
+func NamespaceID(ctx context.Context) int64 {
+	if ns := NamespaceFromContext(ctx); ns != nil {
+		return ns.ID
+	}
+	return 0
+}
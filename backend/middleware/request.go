@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/gofiber/fiber/v2"
+
+	"lambda-runner-server/models"
+)
+
+type requestContextKey struct{}
+
+// RequestMetadata stores who sent a request and where it went in the user
+// context, for the audit log. Register it after the requestid middleware.
+func RequestMetadata() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.SetUserContext(context.WithValue(c.UserContext(), requestContextKey{}, &models.RequestMetadata{
+			RequestID: c.GetRespHeader(fiber.HeaderXRequestID),
+			Method:    c.Method(),
+			Path:      c.Path(),
+			IP:        c.IP(),
+			UserAgent: c.Get(fiber.HeaderUserAgent),
+		}))
+		return c.Next()
+	}
+}
+
+// RequestFromContext returns the metadata of the API request, or nil outside requests
+func RequestFromContext(ctx context.Context) *models.RequestMetadata {
+	req, _ := ctx.Value(requestContextKey{}).(*models.RequestMetadata)
+	return req
+}
@@ -0,0 +1,28 @@
+DROP TRIGGER IF EXISTS audit_events_append_only ON audit_events;
+DROP FUNCTION IF EXISTS audit_events_append_only();
+
+DROP TABLE IF EXISTS audit_events;
+DROP TABLE IF EXISTS function_grants;
+DROP TABLE IF EXISTS event_matches;
+DROP TABLE IF EXISTS event_rule_targets;
+DROP TABLE IF EXISTS event_rules;
+DROP TABLE IF EXISTS events;
+DROP TABLE IF EXISTS workflow_steps;
+DROP TABLE IF EXISTS workflow_executions;
+DROP TABLE IF EXISTS workflows;
+DROP TABLE IF EXISTS storage_triggers;
+DROP TABLE IF EXISTS redis_trigger_checkpoints;
+DROP TABLE IF EXISTS redis_triggers;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhook_triggers;
+DROP TABLE IF EXISTS http_routes;
+DROP TABLE IF EXISTS invocation_callback_attempts;
+DROP TABLE IF EXISTS invocation_callbacks;
+DROP TABLE IF EXISTS rate_limits;
+DROP TABLE IF EXISTS function_schedules;
+DROP TABLE IF EXISTS function_invocations;
+DROP TABLE IF EXISTS invocation_batches;
+DROP TABLE IF EXISTS function_params;
+DROP TABLE IF EXISTS functions;
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS namespaces;
@@ -0,0 +1,403 @@
+CREATE TABLE IF NOT EXISTS functions (
+	id BIGSERIAL PRIMARY KEY,
+	name VARCHAR(100) NOT NULL,
+	description TEXT NOT NULL,
+	runtime VARCHAR(50) NOT NULL,
+	code_s3_key TEXT NOT NULL,
+	sample_event JSONB,
+	is_public BOOLEAN NOT NULL DEFAULT TRUE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS function_params (
+	id BIGSERIAL PRIMARY KEY,
+	function_id BIGINT NOT NULL REFERENCES functions(id) ON DELETE CASCADE,
+	param_key VARCHAR(100) NOT NULL,
+	param_type VARCHAR(50) NOT NULL,
+	is_required BOOLEAN NOT NULL DEFAULT TRUE,
+	description TEXT,
+	default_value JSONB
+);
+
+CREATE TABLE IF NOT EXISTS function_invocations (
+	id BIGSERIAL PRIMARY KEY,
+	function_id BIGINT NOT NULL REFERENCES functions(id) ON DELETE CASCADE,
+	invoked_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	invoked_by VARCHAR(255),
+	input_event JSONB NOT NULL,
+	status VARCHAR(20) NOT NULL,
+	output_result JSONB,
+	error_message TEXT,
+	duration_ms INTEGER,
+	container_id VARCHAR(255),
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_function_invocations_function_id ON function_invocations(function_id);
+CREATE INDEX IF NOT EXISTS idx_function_invocations_invoked_at ON function_invocations(invoked_at DESC);
+
+CREATE TABLE IF NOT EXISTS function_schedules (
+	id BIGSERIAL PRIMARY KEY,
+	function_id BIGINT NOT NULL REFERENCES functions(id) ON DELETE CASCADE,
+	scheduled_at TIMESTAMPTZ NOT NULL,
+	payload JSONB NOT NULL DEFAULT '{}'::jsonb,
+	executed BOOLEAN NOT NULL DEFAULT FALSE,
+	executed_at TIMESTAMPTZ,
+	status VARCHAR(20),
+	error_message TEXT,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_function_schedules_function_id ON function_schedules(function_id);
+CREATE INDEX IF NOT EXISTS idx_function_schedules_pending ON function_schedules(scheduled_at) WHERE executed = FALSE;
+
+ALTER TABLE functions ADD COLUMN IF NOT EXISTS max_concurrency INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE functions ADD COLUMN IF NOT EXISTS reserved_concurrency INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE functions ADD COLUMN IF NOT EXISTS overflow_policy VARCHAR(20) NOT NULL DEFAULT 'throttle';
+
+CREATE INDEX IF NOT EXISTS idx_function_invocations_pending ON function_invocations(invoked_at) WHERE status = 'pending';
+
+CREATE TABLE IF NOT EXISTS rate_limits (
+	id BIGSERIAL PRIMARY KEY,
+	scope VARCHAR(20) NOT NULL,
+	subject VARCHAR(255) NOT NULL,
+	requests_per_second DOUBLE PRECISION NOT NULL DEFAULT 0,
+	burst INTEGER NOT NULL DEFAULT 0,
+	daily_invocations BIGINT NOT NULL DEFAULT 0,
+	monthly_invocations BIGINT NOT NULL DEFAULT 0,
+	daily_compute_seconds BIGINT NOT NULL DEFAULT 0,
+	monthly_compute_seconds BIGINT NOT NULL DEFAULT 0,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	UNIQUE (scope, subject)
+);
+
+ALTER TABLE function_schedules ADD COLUMN IF NOT EXISTS priority VARCHAR(10) NOT NULL DEFAULT 'normal';
+
+CREATE TABLE IF NOT EXISTS invocation_batches (
+	id BIGSERIAL PRIMARY KEY,
+	function_id BIGINT NOT NULL REFERENCES functions(id) ON DELETE CASCADE,
+	total INTEGER NOT NULL,
+	max_parallelism INTEGER NOT NULL DEFAULT 0,
+	priority VARCHAR(10) NOT NULL DEFAULT 'normal',
+	invoked_by VARCHAR(255),
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE function_invocations ADD COLUMN IF NOT EXISTS batch_id BIGINT REFERENCES invocation_batches(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_function_invocations_batch_id ON function_invocations(batch_id) WHERE batch_id IS NOT NULL;
+
+ALTER TABLE functions ADD COLUMN IF NOT EXISTS callback_url TEXT;
+ALTER TABLE functions ADD COLUMN IF NOT EXISTS callback_secret TEXT;
+ALTER TABLE function_invocations ADD COLUMN IF NOT EXISTS callback_url TEXT;
+ALTER TABLE function_invocations ADD COLUMN IF NOT EXISTS callback_secret TEXT;
+
+CREATE TABLE IF NOT EXISTS invocation_callbacks (
+	id BIGSERIAL PRIMARY KEY,
+	invocation_id BIGINT NOT NULL UNIQUE REFERENCES function_invocations(id) ON DELETE CASCADE,
+	url TEXT NOT NULL,
+	secret TEXT,
+	status VARCHAR(20) NOT NULL DEFAULT 'pending',
+	attempts INTEGER NOT NULL DEFAULT 0,
+	next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	last_status_code INTEGER,
+	last_error TEXT,
+	delivered_at TIMESTAMPTZ,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_invocation_callbacks_due ON invocation_callbacks(next_attempt_at) WHERE status = 'pending';
+
+CREATE TABLE IF NOT EXISTS invocation_callback_attempts (
+	id BIGSERIAL PRIMARY KEY,
+	callback_id BIGINT NOT NULL REFERENCES invocation_callbacks(id) ON DELETE CASCADE,
+	attempt INTEGER NOT NULL,
+	status_code INTEGER,
+	error TEXT,
+	duration_ms INTEGER NOT NULL DEFAULT 0,
+	attempted_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_invocation_callback_attempts_callback_id ON invocation_callback_attempts(callback_id);
+
+CREATE TABLE IF NOT EXISTS http_routes (
+	id BIGSERIAL PRIMARY KEY,
+	function_id BIGINT NOT NULL UNIQUE REFERENCES functions(id) ON DELETE CASCADE,
+	slug VARCHAR(63) NOT NULL UNIQUE,
+	auth_type VARCHAR(20) NOT NULL DEFAULT 'none',
+	token_hash VARCHAR(64),
+	timeout_seconds INTEGER NOT NULL DEFAULT 30,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS webhook_triggers (
+	id BIGSERIAL PRIMARY KEY,
+	function_id BIGINT NOT NULL REFERENCES functions(id) ON DELETE CASCADE,
+	name VARCHAR(255) NOT NULL,
+	token VARCHAR(64) NOT NULL UNIQUE,
+	secret TEXT NOT NULL,
+	signature_header VARCHAR(255) NOT NULL,
+	signature_algorithm VARCHAR(10) NOT NULL,
+	signature_prefix VARCHAR(50) NOT NULL DEFAULT '',
+	signature_encoding VARCHAR(10) NOT NULL DEFAULT 'hex',
+	delivery_id_header VARCHAR(255) NOT NULL DEFAULT '',
+	enabled BOOLEAN NOT NULL DEFAULT TRUE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_triggers_function_id ON webhook_triggers(function_id);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+	id BIGSERIAL PRIMARY KEY,
+	trigger_id BIGINT NOT NULL REFERENCES webhook_triggers(id) ON DELETE CASCADE,
+	delivery_id VARCHAR(255),
+	status VARCHAR(20) NOT NULL,
+	invocation_id BIGINT REFERENCES function_invocations(id) ON DELETE SET NULL,
+	error TEXT,
+	received_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_trigger_id ON webhook_deliveries(trigger_id, received_at DESC);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_webhook_deliveries_dedupe ON webhook_deliveries(trigger_id, delivery_id)
+	WHERE status = 'accepted' AND delivery_id IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS redis_triggers (
+	id BIGSERIAL PRIMARY KEY,
+	function_id BIGINT NOT NULL REFERENCES functions(id) ON DELETE CASCADE,
+	source_type VARCHAR(10) NOT NULL,
+	source_key VARCHAR(255) NOT NULL,
+	consumer_group VARCHAR(255) NOT NULL DEFAULT '',
+	batch_size INTEGER NOT NULL DEFAULT 1,
+	batch_window_ms INTEGER NOT NULL DEFAULT 1000,
+	enabled BOOLEAN NOT NULL DEFAULT TRUE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_redis_triggers_function_id ON redis_triggers(function_id);
+
+CREATE TABLE IF NOT EXISTS redis_trigger_checkpoints (
+	trigger_id BIGINT NOT NULL REFERENCES redis_triggers(id) ON DELETE CASCADE,
+	consumer VARCHAR(255) NOT NULL,
+	last_message_id VARCHAR(64) NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY (trigger_id, consumer)
+);
+
+CREATE TABLE IF NOT EXISTS storage_triggers (
+	id BIGSERIAL PRIMARY KEY,
+	function_id BIGINT NOT NULL REFERENCES functions(id) ON DELETE CASCADE,
+	storage_type VARCHAR(10) NOT NULL,
+	location TEXT NOT NULL,
+	prefix TEXT NOT NULL DEFAULT '',
+	suffix TEXT NOT NULL DEFAULT '',
+	event_types VARCHAR(10) NOT NULL DEFAULT 'created',
+	enabled BOOLEAN NOT NULL DEFAULT TRUE,
+	last_event_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_storage_triggers_function_id ON storage_triggers(function_id);
+
+CREATE TABLE IF NOT EXISTS workflows (
+	id BIGSERIAL PRIMARY KEY,
+	name VARCHAR(100) NOT NULL UNIQUE,
+	description TEXT NOT NULL DEFAULT '',
+	definition JSONB NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS workflow_executions (
+	id BIGSERIAL PRIMARY KEY,
+	workflow_id BIGINT NOT NULL REFERENCES workflows(id) ON DELETE CASCADE,
+	status VARCHAR(20) NOT NULL DEFAULT 'running',
+	definition JSONB NOT NULL,
+	input JSONB NOT NULL DEFAULT '{}',
+	output JSONB,
+	error TEXT,
+	cause TEXT,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_workflow_executions_workflow_id ON workflow_executions(workflow_id, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS workflow_steps (
+	id BIGSERIAL PRIMARY KEY,
+	execution_id BIGINT NOT NULL REFERENCES workflow_executions(id) ON DELETE CASCADE,
+	parent_step_id BIGINT REFERENCES workflow_steps(id) ON DELETE CASCADE,
+	branch_index INTEGER NOT NULL DEFAULT 0,
+	state_name VARCHAR(255) NOT NULL,
+	state_type VARCHAR(20) NOT NULL,
+	status VARCHAR(20) NOT NULL,
+	input JSONB NOT NULL DEFAULT '{}',
+	output JSONB,
+	error TEXT,
+	cause TEXT,
+	attempt INTEGER NOT NULL DEFAULT 0,
+	invocation_id BIGINT REFERENCES function_invocations(id) ON DELETE SET NULL,
+	terminal BOOLEAN NOT NULL DEFAULT FALSE,
+	next_run_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	started_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_workflow_steps_execution_id ON workflow_steps(execution_id, id);
+CREATE INDEX IF NOT EXISTS idx_workflow_steps_parent_step_id ON workflow_steps(parent_step_id);
+CREATE INDEX IF NOT EXISTS idx_workflow_steps_due ON workflow_steps(next_run_at)
+	WHERE status IN ('running', 'waiting');
+
+CREATE TABLE IF NOT EXISTS events (
+	seq BIGSERIAL PRIMARY KEY,
+	event_id VARCHAR(255) NOT NULL,
+	source VARCHAR(255) NOT NULL,
+	type VARCHAR(255) NOT NULL,
+	subject VARCHAR(255),
+	specversion VARCHAR(10) NOT NULL,
+	time TIMESTAMPTZ NOT NULL,
+	datacontenttype VARCHAR(100),
+	data JSONB,
+	received_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	UNIQUE (source, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_events_received_at ON events(received_at);
+
+CREATE TABLE IF NOT EXISTS event_rules (
+	id BIGSERIAL PRIMARY KEY,
+	name VARCHAR(100) NOT NULL UNIQUE,
+	description TEXT NOT NULL DEFAULT '',
+	pattern JSONB NOT NULL,
+	enabled BOOLEAN NOT NULL DEFAULT TRUE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS event_rule_targets (
+	rule_id BIGINT NOT NULL REFERENCES event_rules(id) ON DELETE CASCADE,
+	function_id BIGINT NOT NULL REFERENCES functions(id) ON DELETE CASCADE,
+	PRIMARY KEY (rule_id, function_id)
+);
+
+CREATE TABLE IF NOT EXISTS event_matches (
+	id BIGSERIAL PRIMARY KEY,
+	event_seq BIGINT NOT NULL REFERENCES events(seq) ON DELETE CASCADE,
+	rule_id BIGINT REFERENCES event_rules(id) ON DELETE SET NULL,
+	function_id BIGINT NOT NULL,
+	invocation_id BIGINT REFERENCES function_invocations(id) ON DELETE SET NULL,
+	status VARCHAR(20) NOT NULL,
+	error TEXT,
+	replay BOOLEAN NOT NULL DEFAULT FALSE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_matches_event_seq ON event_matches(event_seq);
+CREATE INDEX IF NOT EXISTS idx_event_matches_rule_id ON event_matches(rule_id, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+	id BIGSERIAL PRIMARY KEY,
+	name VARCHAR(100) NOT NULL,
+	prefix VARCHAR(20) NOT NULL,
+	key_hash CHAR(64) NOT NULL UNIQUE,
+	scopes TEXT[] NOT NULL,
+	expires_at TIMESTAMPTZ,
+	last_used_at TIMESTAMPTZ,
+	revoked_at TIMESTAMPTZ,
+	created_by_key_id BIGINT REFERENCES api_keys(id) ON DELETE SET NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE function_invocations ADD COLUMN IF NOT EXISTS api_key_id BIGINT REFERENCES api_keys(id) ON DELETE SET NULL;
+ALTER TABLE function_schedules ADD COLUMN IF NOT EXISTS created_by_key_id BIGINT REFERENCES api_keys(id) ON DELETE SET NULL;
+ALTER TABLE functions ADD COLUMN IF NOT EXISTS created_by_key_id BIGINT REFERENCES api_keys(id) ON DELETE SET NULL;
+ALTER TABLE functions ADD COLUMN IF NOT EXISTS updated_by_key_id BIGINT REFERENCES api_keys(id) ON DELETE SET NULL;
+
+CREATE TABLE IF NOT EXISTS namespaces (
+	id BIGSERIAL PRIMARY KEY,
+	name VARCHAR(63) NOT NULL UNIQUE,
+	description TEXT NOT NULL DEFAULT '',
+	max_functions INT NOT NULL DEFAULT 0,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+INSERT INTO namespaces (name, description) VALUES ('default', 'Default namespace') ON CONFLICT (name) DO NOTHING;
+
+ALTER TABLE functions ADD COLUMN IF NOT EXISTS namespace_id BIGINT REFERENCES namespaces(id);
+UPDATE functions SET namespace_id = (SELECT id FROM namespaces WHERE name = 'default') WHERE namespace_id IS NULL;
+ALTER TABLE functions ALTER COLUMN namespace_id SET NOT NULL;
+
+ALTER TABLE workflows ADD COLUMN IF NOT EXISTS namespace_id BIGINT REFERENCES namespaces(id);
+UPDATE workflows SET namespace_id = (SELECT id FROM namespaces WHERE name = 'default') WHERE namespace_id IS NULL;
+ALTER TABLE workflows ALTER COLUMN namespace_id SET NOT NULL;
+
+ALTER TABLE event_rules ADD COLUMN IF NOT EXISTS namespace_id BIGINT REFERENCES namespaces(id) ON DELETE CASCADE;
+UPDATE event_rules SET namespace_id = (SELECT id FROM namespaces WHERE name = 'default') WHERE namespace_id IS NULL;
+ALTER TABLE event_rules ALTER COLUMN namespace_id SET NOT NULL;
+
+ALTER TABLE events ADD COLUMN IF NOT EXISTS namespace_id BIGINT REFERENCES namespaces(id) ON DELETE CASCADE;
+UPDATE events SET namespace_id = (SELECT id FROM namespaces WHERE name = 'default') WHERE namespace_id IS NULL;
+ALTER TABLE events ALTER COLUMN namespace_id SET NOT NULL;
+
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS namespace_id BIGINT REFERENCES namespaces(id) ON DELETE CASCADE;
+UPDATE api_keys SET namespace_id = (SELECT id FROM namespaces WHERE name = 'default') WHERE namespace_id IS NULL;
+ALTER TABLE api_keys ALTER COLUMN namespace_id SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_functions_namespace_id ON functions(namespace_id);
+
+ALTER TABLE workflows DROP CONSTRAINT IF EXISTS workflows_name_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_workflows_namespace_name ON workflows(namespace_id, name);
+ALTER TABLE event_rules DROP CONSTRAINT IF EXISTS event_rules_name_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_event_rules_namespace_name ON event_rules(namespace_id, name);
+ALTER TABLE events DROP CONSTRAINT IF EXISTS events_source_event_id_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_events_namespace_source_id ON events(namespace_id, source, event_id);
+
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS user_name VARCHAR(255);
+ALTER TABLE functions ADD COLUMN IF NOT EXISTS owner VARCHAR(255);
+
+CREATE TABLE IF NOT EXISTS function_grants (
+	id BIGSERIAL PRIMARY KEY,
+	function_id BIGINT NOT NULL REFERENCES functions(id) ON DELETE CASCADE,
+	principal VARCHAR(255) NOT NULL,
+	access VARCHAR(10) NOT NULL,
+	granted_by_key_id BIGINT REFERENCES api_keys(id) ON DELETE SET NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	UNIQUE (function_id, principal)
+);
+
+CREATE INDEX IF NOT EXISTS idx_function_grants_principal ON function_grants(principal);
+
+CREATE TABLE IF NOT EXISTS audit_events (
+	id BIGSERIAL PRIMARY KEY,
+	namespace_id BIGINT,
+	actor VARCHAR(255) NOT NULL,
+	api_key_id BIGINT,
+	action VARCHAR(20) NOT NULL,
+	resource_type VARCHAR(50) NOT NULL,
+	resource_id VARCHAR(255) NOT NULL,
+	before_state JSONB,
+	after_state JSONB,
+	request JSONB,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events(created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_events_resource ON audit_events(resource_type, resource_id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor ON audit_events(actor);
+
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_events_append_only ON audit_events;
+CREATE TRIGGER audit_events_append_only BEFORE UPDATE OR DELETE ON audit_events
+	FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
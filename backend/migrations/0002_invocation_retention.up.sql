@@ -0,0 +1,13 @@
+-- Retention of function_invocations: one global policy (function_id NULL)
+-- and optional per-function policies overriding it
+CREATE TABLE IF NOT EXISTS retention_policies (
+	id BIGSERIAL PRIMARY KEY,
+	function_id BIGINT REFERENCES functions(id) ON DELETE CASCADE,
+	max_age_days INTEGER NOT NULL DEFAULT 0,
+	max_count INTEGER NOT NULL DEFAULT 0,
+	archive BOOLEAN NOT NULL DEFAULT FALSE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_retention_policies_function ON retention_policies((COALESCE(function_id, 0)));
@@ -0,0 +1,64 @@
+ALTER TABLE function_invocations RENAME TO function_invocations_partitioned;
+ALTER TABLE function_invocations_partitioned RENAME CONSTRAINT function_invocations_pkey TO function_invocations_partitioned_pkey;
+DROP INDEX IF EXISTS idx_function_invocations_function_id;
+DROP INDEX IF EXISTS idx_function_invocations_invoked_at;
+DROP INDEX IF EXISTS idx_function_invocations_pending;
+DROP INDEX IF EXISTS idx_function_invocations_batch_id;
+
+CREATE TABLE function_invocations (
+	id BIGINT PRIMARY KEY DEFAULT nextval('function_invocations_id_seq'),
+	function_id BIGINT NOT NULL REFERENCES functions(id) ON DELETE CASCADE,
+	invoked_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	invoked_by VARCHAR(255),
+	input_event JSONB NOT NULL,
+	status VARCHAR(20) NOT NULL,
+	output_result JSONB,
+	error_message TEXT,
+	duration_ms INTEGER,
+	container_id VARCHAR(255),
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	batch_id BIGINT REFERENCES invocation_batches(id) ON DELETE SET NULL,
+	callback_url TEXT,
+	callback_secret TEXT,
+	api_key_id BIGINT REFERENCES api_keys(id) ON DELETE SET NULL
+);
+
+ALTER SEQUENCE function_invocations_id_seq OWNED BY function_invocations.id;
+
+INSERT INTO function_invocations (id, function_id, invoked_at, invoked_by, input_event, status, output_result,
+	error_message, duration_ms, container_id, created_at, batch_id, callback_url, callback_secret, api_key_id)
+SELECT id, function_id, invoked_at, invoked_by, input_event, status, output_result,
+	error_message, duration_ms, container_id, created_at, batch_id, callback_url, callback_secret, api_key_id
+FROM function_invocations_partitioned;
+
+DROP TABLE function_invocations_partitioned;
+DROP FUNCTION IF EXISTS function_invocations_deleted();
+
+CREATE INDEX IF NOT EXISTS idx_function_invocations_function_id ON function_invocations(function_id);
+CREATE INDEX IF NOT EXISTS idx_function_invocations_invoked_at ON function_invocations(invoked_at DESC);
+CREATE INDEX IF NOT EXISTS idx_function_invocations_pending ON function_invocations(invoked_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_function_invocations_batch_id ON function_invocations(batch_id) WHERE batch_id IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_webhook_deliveries_invocation_id;
+DROP INDEX IF EXISTS idx_workflow_steps_invocation_id;
+DROP INDEX IF EXISTS idx_event_matches_invocation_id;
+
+-- Restore the foreign keys, clearing references to invocations deleted meanwhile
+DELETE FROM invocation_callbacks WHERE invocation_id NOT IN (SELECT id FROM function_invocations);
+UPDATE webhook_deliveries SET invocation_id = NULL WHERE invocation_id NOT IN (SELECT id FROM function_invocations);
+UPDATE workflow_steps SET invocation_id = NULL WHERE invocation_id NOT IN (SELECT id FROM function_invocations);
+UPDATE event_matches SET invocation_id = NULL WHERE invocation_id NOT IN (SELECT id FROM function_invocations);
+ALTER TABLE invocation_callbacks ADD CONSTRAINT invocation_callbacks_invocation_id_fkey
+	FOREIGN KEY (invocation_id) REFERENCES function_invocations(id) ON DELETE CASCADE;
+ALTER TABLE webhook_deliveries ADD CONSTRAINT webhook_deliveries_invocation_id_fkey
+	FOREIGN KEY (invocation_id) REFERENCES function_invocations(id) ON DELETE SET NULL;
+ALTER TABLE workflow_steps ADD CONSTRAINT workflow_steps_invocation_id_fkey
+	FOREIGN KEY (invocation_id) REFERENCES function_invocations(id) ON DELETE SET NULL;
+ALTER TABLE event_matches ADD CONSTRAINT event_matches_invocation_id_fkey
+	FOREIGN KEY (invocation_id) REFERENCES function_invocations(id) ON DELETE SET NULL;
+
+DROP FUNCTION IF EXISTS drop_invocation_partition(TEXT);
+DROP FUNCTION IF EXISTS maintain_invocation_partitions(INTEGER);
+DROP FUNCTION IF EXISTS create_invocation_partition(DATE);
+DROP FUNCTION IF EXISTS invocation_id_floor(BIGINT);
+DROP TABLE IF EXISTS invocation_partitions;
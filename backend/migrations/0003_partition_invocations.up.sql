@@ -0,0 +1,175 @@
+-- function_invocations becomes range partitioned by month of invoked_at (UTC).
+-- Partitions are named function_invocations_<yyyymm> and recorded in
+-- invocation_partitions; the server creates them ahead of time and drops
+-- those past retention.
+--
+-- The primary key of a partitioned table must include the partition key, so
+-- invocation IDs can no longer be referenced by foreign keys. The references
+-- from invocation_callbacks, webhook_deliveries, workflow_steps and
+-- event_matches are kept up to date by function_invocations_deleted and
+-- drop_invocation_partition instead.
+
+CREATE TABLE IF NOT EXISTS invocation_partitions (
+	name TEXT PRIMARY KEY,
+	starts_at TIMESTAMPTZ NOT NULL UNIQUE,
+	ends_at TIMESTAMPTZ NOT NULL,
+	-- Smallest invocation ID in the partition, recorded once it has rows
+	first_id BIGINT
+);
+
+-- Invocation IDs and invoked_at both grow with time, so an invocation with a
+-- given ID cannot be older than the partitions holding smaller IDs. The day of
+-- margin covers invocations whose transaction began before a month boundary
+-- but took their ID after it. Being STABLE, it prunes partitions at executor startup.
+CREATE OR REPLACE FUNCTION invocation_id_floor(invocation_id BIGINT) RETURNS TIMESTAMPTZ AS $$
+	SELECT COALESCE(max(starts_at) - interval '1 day', '-infinity')
+	FROM invocation_partitions
+	WHERE first_id <= invocation_id
+$$ LANGUAGE sql STABLE;
+
+-- create_invocation_partition creates the partition for the month of the given
+-- date unless it exists, and reports whether it was created
+CREATE OR REPLACE FUNCTION create_invocation_partition(month DATE) RETURNS BOOLEAN AS $$
+DECLARE
+	first_day DATE := date_trunc('month', month::timestamp)::date;
+	partition_name TEXT := 'function_invocations_' || to_char(first_day, 'YYYYMM');
+	range_start TIMESTAMPTZ := first_day::timestamp AT TIME ZONE 'UTC';
+	range_end TIMESTAMPTZ := (first_day + interval '1 month') AT TIME ZONE 'UTC';
+BEGIN
+	PERFORM pg_advisory_xact_lock(hashtext('invocation_partitions'));
+	IF to_regclass(partition_name) IS NOT NULL THEN
+		RETURN FALSE;
+	END IF;
+
+	EXECUTE format('CREATE TABLE %I PARTITION OF function_invocations FOR VALUES FROM (%L) TO (%L)',
+		partition_name, range_start, range_end);
+	INSERT INTO invocation_partitions (name, starts_at, ends_at) VALUES (partition_name, range_start, range_end);
+	RETURN TRUE;
+END;
+$$ LANGUAGE plpgsql;
+
+-- maintain_invocation_partitions creates the partitions of the current and the
+-- next months_ahead months, records the first ID of partitions that got rows,
+-- and returns the names of the partitions it created
+CREATE OR REPLACE FUNCTION maintain_invocation_partitions(months_ahead INTEGER) RETURNS SETOF TEXT AS $$
+DECLARE
+	this_month DATE := date_trunc('month', now() AT TIME ZONE 'UTC')::date;
+	p RECORD;
+	min_id BIGINT;
+BEGIN
+	FOR i IN 0..months_ahead LOOP
+		IF create_invocation_partition((this_month + make_interval(months => i))::date) THEN
+			RETURN NEXT 'function_invocations_' || to_char(this_month + make_interval(months => i), 'YYYYMM');
+		END IF;
+	END LOOP;
+
+	FOR p IN SELECT name FROM invocation_partitions WHERE first_id IS NULL AND starts_at <= now() LOOP
+		EXECUTE format('SELECT min(id) FROM %I', p.name) INTO min_id;
+		IF min_id IS NOT NULL THEN
+			UPDATE invocation_partitions SET first_id = min_id WHERE name = p.name;
+		END IF;
+	END LOOP;
+END;
+$$ LANGUAGE plpgsql;
+
+-- drop_invocation_partition drops a partition with all its invocations,
+-- releasing the references to them like function_invocations_deleted does
+CREATE OR REPLACE FUNCTION drop_invocation_partition(partition_name TEXT) RETURNS VOID AS $$
+BEGIN
+	PERFORM pg_advisory_xact_lock(hashtext('invocation_partitions'));
+	IF NOT EXISTS (SELECT 1 FROM invocation_partitions WHERE name = partition_name) THEN
+		RETURN;
+	END IF;
+
+	EXECUTE format('DELETE FROM invocation_callbacks WHERE invocation_id IN (SELECT id FROM %I)', partition_name);
+	EXECUTE format('UPDATE webhook_deliveries SET invocation_id = NULL WHERE invocation_id IN (SELECT id FROM %I)', partition_name);
+	EXECUTE format('UPDATE workflow_steps SET invocation_id = NULL WHERE invocation_id IN (SELECT id FROM %I)', partition_name);
+	EXECUTE format('UPDATE event_matches SET invocation_id = NULL WHERE invocation_id IN (SELECT id FROM %I)', partition_name);
+	EXECUTE format('DROP TABLE %I', partition_name);
+	DELETE FROM invocation_partitions WHERE name = partition_name;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Replace the table, keeping its ID sequence
+ALTER TABLE function_invocations RENAME TO function_invocations_unpartitioned;
+ALTER TABLE function_invocations_unpartitioned RENAME CONSTRAINT function_invocations_pkey TO function_invocations_unpartitioned_pkey;
+DROP INDEX IF EXISTS idx_function_invocations_function_id;
+DROP INDEX IF EXISTS idx_function_invocations_invoked_at;
+DROP INDEX IF EXISTS idx_function_invocations_pending;
+DROP INDEX IF EXISTS idx_function_invocations_batch_id;
+
+ALTER TABLE invocation_callbacks DROP CONSTRAINT IF EXISTS invocation_callbacks_invocation_id_fkey;
+ALTER TABLE webhook_deliveries DROP CONSTRAINT IF EXISTS webhook_deliveries_invocation_id_fkey;
+ALTER TABLE workflow_steps DROP CONSTRAINT IF EXISTS workflow_steps_invocation_id_fkey;
+ALTER TABLE event_matches DROP CONSTRAINT IF EXISTS event_matches_invocation_id_fkey;
+
+CREATE TABLE function_invocations (
+	id BIGINT NOT NULL DEFAULT nextval('function_invocations_id_seq'),
+	function_id BIGINT NOT NULL REFERENCES functions(id) ON DELETE CASCADE,
+	invoked_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	invoked_by VARCHAR(255),
+	input_event JSONB NOT NULL,
+	status VARCHAR(20) NOT NULL,
+	output_result JSONB,
+	error_message TEXT,
+	duration_ms INTEGER,
+	container_id VARCHAR(255),
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	batch_id BIGINT REFERENCES invocation_batches(id) ON DELETE SET NULL,
+	callback_url TEXT,
+	callback_secret TEXT,
+	api_key_id BIGINT REFERENCES api_keys(id) ON DELETE SET NULL,
+	PRIMARY KEY (id, invoked_at)
+) PARTITION BY RANGE (invoked_at);
+
+ALTER SEQUENCE function_invocations_id_seq OWNED BY function_invocations.id;
+
+CREATE INDEX idx_function_invocations_function_id ON function_invocations(function_id, invoked_at DESC);
+CREATE INDEX idx_function_invocations_invoked_at ON function_invocations(invoked_at DESC);
+CREATE INDEX idx_function_invocations_pending ON function_invocations(invoked_at) WHERE status = 'pending';
+CREATE INDEX idx_function_invocations_batch_id ON function_invocations(batch_id) WHERE batch_id IS NOT NULL;
+
+-- Releasing references scans these on every purge
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_invocation_id ON webhook_deliveries(invocation_id) WHERE invocation_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_workflow_steps_invocation_id ON workflow_steps(invocation_id) WHERE invocation_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_event_matches_invocation_id ON event_matches(invocation_id) WHERE invocation_id IS NOT NULL;
+
+-- function_invocations_deleted applies the former ON DELETE actions of the
+-- references to deleted invocations
+CREATE OR REPLACE FUNCTION function_invocations_deleted() RETURNS trigger AS $$
+BEGIN
+	DELETE FROM invocation_callbacks WHERE invocation_id IN (SELECT id FROM deleted_invocations);
+	UPDATE webhook_deliveries SET invocation_id = NULL WHERE invocation_id IN (SELECT id FROM deleted_invocations);
+	UPDATE workflow_steps SET invocation_id = NULL WHERE invocation_id IN (SELECT id FROM deleted_invocations);
+	UPDATE event_matches SET invocation_id = NULL WHERE invocation_id IN (SELECT id FROM deleted_invocations);
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER function_invocations_deleted AFTER DELETE ON function_invocations
+	REFERENCING OLD TABLE AS deleted_invocations
+	FOR EACH STATEMENT EXECUTE FUNCTION function_invocations_deleted();
+
+-- Partitions for the existing invocations and the next months, then move the rows
+DO $$
+DECLARE
+	month DATE := date_trunc('month', COALESCE(
+		(SELECT min(invoked_at) FROM function_invocations_unpartitioned), now()) AT TIME ZONE 'UTC')::date;
+BEGIN
+	WHILE month < date_trunc('month', now() AT TIME ZONE 'UTC')::date LOOP
+		PERFORM create_invocation_partition(month);
+		month := (month + interval '1 month')::date;
+	END LOOP;
+END;
+$$;
+SELECT maintain_invocation_partitions(3);
+
+INSERT INTO function_invocations (id, function_id, invoked_at, invoked_by, input_event, status, output_result,
+	error_message, duration_ms, container_id, created_at, batch_id, callback_url, callback_secret, api_key_id)
+SELECT id, function_id, invoked_at, invoked_by, input_event, status, output_result,
+	error_message, duration_ms, container_id, created_at, batch_id, callback_url, callback_secret, api_key_id
+FROM function_invocations_unpartitioned;
+
+DROP TABLE function_invocations_unpartitioned;
+
+SELECT maintain_invocation_partitions(3);
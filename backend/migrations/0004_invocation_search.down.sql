@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idx_function_invocations_output;
+DROP INDEX IF EXISTS idx_function_invocations_input;
+DROP INDEX IF EXISTS idx_function_invocations_duration;
+DROP INDEX IF EXISTS idx_function_invocations_invoked_by;
+DROP INDEX IF EXISTS idx_function_invocations_status;
+DROP INDEX IF EXISTS idx_function_invocations_function_status;
+DROP INDEX IF EXISTS idx_function_invocations_invoked_at;
+DROP INDEX IF EXISTS idx_function_invocations_function_id;
+CREATE INDEX idx_function_invocations_function_id ON function_invocations(function_id, invoked_at DESC);
+CREATE INDEX idx_function_invocations_invoked_at ON function_invocations(invoked_at DESC);
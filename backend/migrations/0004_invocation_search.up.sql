@@ -0,0 +1,13 @@
+-- Invocations are listed by invoked_at and then ID; every filter of the
+-- invocation search is backed by an index in that order, containment queries
+-- on the input and output by GIN indexes
+DROP INDEX IF EXISTS idx_function_invocations_function_id;
+DROP INDEX IF EXISTS idx_function_invocations_invoked_at;
+CREATE INDEX idx_function_invocations_function_id ON function_invocations(function_id, invoked_at DESC, id DESC);
+CREATE INDEX idx_function_invocations_invoked_at ON function_invocations(invoked_at DESC, id DESC);
+CREATE INDEX idx_function_invocations_function_status ON function_invocations(function_id, status, invoked_at DESC, id DESC);
+CREATE INDEX idx_function_invocations_status ON function_invocations(status, invoked_at DESC, id DESC);
+CREATE INDEX idx_function_invocations_invoked_by ON function_invocations(invoked_by, invoked_at DESC, id DESC);
+CREATE INDEX idx_function_invocations_duration ON function_invocations(function_id, duration_ms);
+CREATE INDEX idx_function_invocations_input ON function_invocations USING GIN (input_event jsonb_path_ops);
+CREATE INDEX idx_function_invocations_output ON function_invocations USING GIN (output_result jsonb_path_ops);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_functions_namespace_updated_at;
+DROP INDEX IF EXISTS idx_functions_namespace_name;
+DROP INDEX IF EXISTS idx_functions_search;
+DROP INDEX IF EXISTS idx_functions_tags;
+ALTER TABLE functions DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE functions DROP COLUMN IF EXISTS tags;
@@ -0,0 +1,11 @@
+-- Functions are tagged and searched by the words of their name and
+-- description; the simple configuration keeps identifiers like "resize-image"
+-- searchable without stemming them
+ALTER TABLE functions ADD COLUMN tags TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE functions ADD COLUMN search_vector TSVECTOR
+	GENERATED ALWAYS AS (to_tsvector('simple', name || ' ' || description)) STORED;
+
+CREATE INDEX idx_functions_tags ON functions USING GIN (tags);
+CREATE INDEX idx_functions_search ON functions USING GIN (search_vector);
+CREATE INDEX idx_functions_namespace_name ON functions(namespace_id, name);
+CREATE INDEX idx_functions_namespace_updated_at ON functions(namespace_id, updated_at DESC);
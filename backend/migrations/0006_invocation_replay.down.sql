@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_function_invocations_replay_of;
+ALTER TABLE function_invocations DROP COLUMN IF EXISTS replay_of;
+ALTER TABLE function_invocations DROP COLUMN IF EXISTS code_version;
@@ -0,0 +1,8 @@
+-- code_version is the SHA-256 of the code an invocation ran. replay_of links a
+-- replay to the invocation it re-ran; like the other references to invocations
+-- it has no foreign key, so it may point to an invocation purged since.
+ALTER TABLE function_invocations ADD COLUMN code_version TEXT;
+ALTER TABLE function_invocations ADD COLUMN replay_of BIGINT;
+
+CREATE INDEX idx_function_invocations_replay_of ON function_invocations(replay_of, invoked_at DESC, id DESC)
+	WHERE replay_of IS NOT NULL;
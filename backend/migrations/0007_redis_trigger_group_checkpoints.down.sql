@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS redis_trigger_checkpoints (
+	trigger_id BIGINT NOT NULL REFERENCES redis_triggers(id) ON DELETE CASCADE,
+	consumer VARCHAR(255) NOT NULL,
+	last_message_id VARCHAR(64) NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY (trigger_id, consumer)
+);
+
+DROP TABLE IF EXISTS redis_trigger_group_checkpoints;
@@ -0,0 +1,22 @@
+-- Redis trigger checkpoints are kept per consumer group instead of per
+-- consumer: entries left pending by a consumer that went away are claimed by
+-- another one, which must still recognise those already handed to the
+-- function. The earliest checkpoint of each trigger is carried over, so the
+-- upgrade may repeat entries but never skips one.
+CREATE TABLE redis_trigger_group_checkpoints (
+	trigger_id BIGINT NOT NULL REFERENCES redis_triggers(id) ON DELETE CASCADE,
+	consumer_group VARCHAR(255) NOT NULL,
+	last_message_id VARCHAR(64) NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY (trigger_id, consumer_group)
+);
+
+INSERT INTO redis_trigger_group_checkpoints (trigger_id, consumer_group, last_message_id, updated_at)
+SELECT DISTINCT ON (c.trigger_id) c.trigger_id, t.consumer_group, c.last_message_id, c.updated_at
+FROM redis_trigger_checkpoints c
+JOIN redis_triggers t ON t.id = c.trigger_id
+ORDER BY c.trigger_id,
+	split_part(c.last_message_id, '-', 1)::NUMERIC,
+	split_part(c.last_message_id, '-', 2)::NUMERIC;
+
+DROP TABLE redis_trigger_checkpoints;
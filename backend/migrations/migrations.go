@@ -0,0 +1,14 @@
+// Package migrations holds the versioned database schema.
+//
+// Each migration is a pair of files named <version>_<name>.up.sql and
+// <version>_<name>.down.sql. Versions are applied in ascending order and
+// recorded in the schema_migrations table; a migration is never edited once
+// released, schema changes go into a new version instead.
+package migrations
+
+import "embed"
+
+// FS contains the migration files
+//
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,14 @@
+DROP TRIGGER IF EXISTS audit_events_no_delete;
+DROP TRIGGER IF EXISTS audit_events_no_update;
+
+DROP TABLE IF EXISTS audit_events;
+DROP TABLE IF EXISTS function_schedules;
+DROP TABLE IF EXISTS invocation_callback_attempts;
+DROP TABLE IF EXISTS invocation_callbacks;
+DROP TABLE IF EXISTS function_invocations;
+DROP TABLE IF EXISTS invocation_batches;
+DROP TABLE IF EXISTS function_grants;
+DROP TABLE IF EXISTS function_params;
+DROP TABLE IF EXISTS functions;
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS namespaces;
@@ -0,0 +1,178 @@
+CREATE TABLE IF NOT EXISTS namespaces (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name VARCHAR(63) NOT NULL UNIQUE,
+	description TEXT NOT NULL DEFAULT '',
+	max_functions INTEGER NOT NULL DEFAULT 0,
+	created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+	updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+INSERT INTO namespaces (name, description) VALUES ('default', 'Default namespace') ON CONFLICT (name) DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS api_keys (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name VARCHAR(100) NOT NULL,
+	prefix VARCHAR(20) NOT NULL,
+	user_name VARCHAR(255),
+	namespace_id INTEGER NOT NULL REFERENCES namespaces(id) ON DELETE CASCADE,
+	key_hash CHAR(64) NOT NULL UNIQUE,
+	scopes TEXT NOT NULL,
+	expires_at TIMESTAMP,
+	last_used_at TIMESTAMP,
+	revoked_at TIMESTAMP,
+	created_by_key_id INTEGER REFERENCES api_keys(id) ON DELETE SET NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE TABLE IF NOT EXISTS functions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	namespace_id INTEGER NOT NULL REFERENCES namespaces(id),
+	name VARCHAR(100) NOT NULL,
+	description TEXT NOT NULL,
+	runtime VARCHAR(50) NOT NULL,
+	code_s3_key TEXT NOT NULL,
+	sample_event TEXT,
+	is_public BOOLEAN NOT NULL DEFAULT TRUE,
+	owner VARCHAR(255),
+	max_concurrency INTEGER NOT NULL DEFAULT 0,
+	reserved_concurrency INTEGER NOT NULL DEFAULT 0,
+	overflow_policy VARCHAR(20) NOT NULL DEFAULT 'throttle',
+	callback_url TEXT,
+	callback_secret TEXT,
+	created_by_key_id INTEGER REFERENCES api_keys(id) ON DELETE SET NULL,
+	updated_by_key_id INTEGER REFERENCES api_keys(id) ON DELETE SET NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+	updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_functions_namespace_id ON functions(namespace_id);
+
+CREATE TABLE IF NOT EXISTS function_params (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	function_id INTEGER NOT NULL REFERENCES functions(id) ON DELETE CASCADE,
+	param_key VARCHAR(100) NOT NULL,
+	param_type VARCHAR(50) NOT NULL,
+	is_required BOOLEAN NOT NULL DEFAULT TRUE,
+	description TEXT,
+	default_value TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_function_params_function_id ON function_params(function_id);
+
+CREATE TABLE IF NOT EXISTS function_grants (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	function_id INTEGER NOT NULL REFERENCES functions(id) ON DELETE CASCADE,
+	principal VARCHAR(255) NOT NULL,
+	access VARCHAR(10) NOT NULL,
+	granted_by_key_id INTEGER REFERENCES api_keys(id) ON DELETE SET NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+	UNIQUE (function_id, principal)
+);
+
+CREATE INDEX IF NOT EXISTS idx_function_grants_principal ON function_grants(principal);
+
+CREATE TABLE IF NOT EXISTS invocation_batches (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	function_id INTEGER NOT NULL REFERENCES functions(id) ON DELETE CASCADE,
+	total INTEGER NOT NULL,
+	max_parallelism INTEGER NOT NULL DEFAULT 0,
+	priority VARCHAR(10) NOT NULL DEFAULT 'normal',
+	invoked_by VARCHAR(255),
+	created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE TABLE IF NOT EXISTS function_invocations (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	function_id INTEGER NOT NULL REFERENCES functions(id) ON DELETE CASCADE,
+	invoked_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+	invoked_by VARCHAR(255),
+	input_event TEXT NOT NULL,
+	status VARCHAR(20) NOT NULL,
+	output_result TEXT,
+	error_message TEXT,
+	duration_ms INTEGER,
+	container_id VARCHAR(255),
+	batch_id INTEGER REFERENCES invocation_batches(id) ON DELETE SET NULL,
+	callback_url TEXT,
+	callback_secret TEXT,
+	api_key_id INTEGER REFERENCES api_keys(id) ON DELETE SET NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_function_invocations_function_id ON function_invocations(function_id, invoked_at DESC);
+CREATE INDEX IF NOT EXISTS idx_function_invocations_pending ON function_invocations(invoked_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_function_invocations_batch_id ON function_invocations(batch_id) WHERE batch_id IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS invocation_callbacks (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	invocation_id INTEGER NOT NULL UNIQUE REFERENCES function_invocations(id) ON DELETE CASCADE,
+	url TEXT NOT NULL,
+	secret TEXT,
+	status VARCHAR(20) NOT NULL DEFAULT 'pending',
+	attempts INTEGER NOT NULL DEFAULT 0,
+	next_attempt_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+	last_status_code INTEGER,
+	last_error TEXT,
+	delivered_at TIMESTAMP,
+	created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_invocation_callbacks_due ON invocation_callbacks(next_attempt_at) WHERE status = 'pending';
+
+CREATE TABLE IF NOT EXISTS invocation_callback_attempts (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	callback_id INTEGER NOT NULL REFERENCES invocation_callbacks(id) ON DELETE CASCADE,
+	attempt INTEGER NOT NULL,
+	status_code INTEGER,
+	error TEXT,
+	duration_ms INTEGER NOT NULL DEFAULT 0,
+	attempted_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_invocation_callback_attempts_callback_id ON invocation_callback_attempts(callback_id);
+
+CREATE TABLE IF NOT EXISTS function_schedules (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	function_id INTEGER NOT NULL REFERENCES functions(id) ON DELETE CASCADE,
+	scheduled_at TIMESTAMP NOT NULL,
+	payload TEXT NOT NULL DEFAULT '{}',
+	priority VARCHAR(10) NOT NULL DEFAULT 'normal',
+	executed BOOLEAN NOT NULL DEFAULT FALSE,
+	executed_at TIMESTAMP,
+	status VARCHAR(20),
+	error_message TEXT,
+	created_by_key_id INTEGER REFERENCES api_keys(id) ON DELETE SET NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+	updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_function_schedules_function_id ON function_schedules(function_id);
+CREATE INDEX IF NOT EXISTS idx_function_schedules_pending ON function_schedules(scheduled_at) WHERE executed = FALSE;
+
+CREATE TABLE IF NOT EXISTS audit_events (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	namespace_id INTEGER,
+	actor VARCHAR(255) NOT NULL,
+	api_key_id INTEGER,
+	action VARCHAR(20) NOT NULL,
+	resource_type VARCHAR(50) NOT NULL,
+	resource_id VARCHAR(255) NOT NULL,
+	before_state TEXT,
+	after_state TEXT,
+	request TEXT,
+	created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events(created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_events_resource ON audit_events(resource_type, resource_id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor ON audit_events(actor);
+
+CREATE TRIGGER IF NOT EXISTS audit_events_no_update BEFORE UPDATE ON audit_events
+BEGIN
+	SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS audit_events_no_delete BEFORE DELETE ON audit_events
+BEGIN
+	SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
@@ -0,0 +1,8 @@
+-- Invocations are listed by invoked_at and then ID, see the Postgres migration
+DROP INDEX IF EXISTS idx_function_invocations_function_id;
+CREATE INDEX idx_function_invocations_function_id ON function_invocations(function_id, invoked_at DESC, id DESC);
+CREATE INDEX idx_function_invocations_invoked_at ON function_invocations(invoked_at DESC, id DESC);
+CREATE INDEX idx_function_invocations_function_status ON function_invocations(function_id, status, invoked_at DESC, id DESC);
+CREATE INDEX idx_function_invocations_status ON function_invocations(status, invoked_at DESC, id DESC);
+CREATE INDEX idx_function_invocations_invoked_by ON function_invocations(invoked_by, invoked_at DESC, id DESC);
+CREATE INDEX idx_function_invocations_duration ON function_invocations(function_id, duration_ms);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_functions_namespace_updated_at;
+DROP INDEX IF EXISTS idx_functions_namespace_name;
+ALTER TABLE functions DROP COLUMN tags;
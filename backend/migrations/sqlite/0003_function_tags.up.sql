@@ -0,0 +1,5 @@
+-- Tags are stored as a JSON array of strings
+ALTER TABLE functions ADD COLUMN tags TEXT NOT NULL DEFAULT '[]';
+
+CREATE INDEX idx_functions_namespace_name ON functions(namespace_id, name);
+CREATE INDEX idx_functions_namespace_updated_at ON functions(namespace_id, updated_at DESC);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_function_invocations_replay_of;
+ALTER TABLE function_invocations DROP COLUMN replay_of;
+ALTER TABLE function_invocations DROP COLUMN code_version;
@@ -0,0 +1,6 @@
+-- See the Postgres migration
+ALTER TABLE function_invocations ADD COLUMN code_version TEXT;
+ALTER TABLE function_invocations ADD COLUMN replay_of INTEGER;
+
+CREATE INDEX idx_function_invocations_replay_of ON function_invocations(replay_of, invoked_at DESC, id DESC)
+	WHERE replay_of IS NOT NULL;
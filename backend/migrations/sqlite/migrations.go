@@ -0,0 +1,13 @@
+// Package sqlite holds the schema of the SQLite backend (DB_DRIVER=sqlite).
+//
+// It covers the tables of the single-node feature set: namespaces, API keys,
+// functions, invocations, batches, callbacks, schedules, grants and the audit
+// log. Files follow the naming and versioning rules of the parent package.
+package sqlite
+
+import "embed"
+
+// FS contains the migration files
+//
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"strconv"
+	"time"
+)
+
+// API key scopes. admin grants every other scope.
+const (
+	ScopeRead   = "read"   // GET endpoints
+	ScopeWrite  = "write"  // create, change and delete resources
+	ScopeInvoke = "invoke" // invoke functions, publish events, start workflows
+	ScopeAdmin  = "admin"  // every namespace, rate limits, quotas and key management
+)
+
+// ValidScopes lists every scope a key can hold
+var ValidScopes = []string{ScopeRead, ScopeWrite, ScopeInvoke, ScopeAdmin}
+
+// APIKey authenticates API clients (api_keys table). Only a SHA-256 hash of
+// the key is stored; Prefix identifies it in listings. Requests are confined
+// to the key's namespace; only admin keys may address other namespaces.
+type APIKey struct {
+	ID             int64      `json:"id"`
+	Name           string     `json:"name"`
+	Prefix         string     `json:"prefix"`
+	User           string     `json:"user,omitempty"`
+	NamespaceID    int64      `json:"namespace_id"`
+	Namespace      string     `json:"namespace"`
+	Scopes         []string   `json:"scopes"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt     *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+	CreatedByKeyID *int64     `json:"created_by_key_id,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// HasScope reports whether the key grants scope
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// Principal is who the key acts as when it creates functions: its user, or
+// the key itself when it belongs to no user
+func (k *APIKey) Principal() string {
+	if k.User != "" {
+		return PrincipalUserPrefix + k.User
+	}
+	return PrincipalKeyPrefix + strconv.FormatInt(k.ID, 10)
+}
+
+// Principals lists every principal function grants to the key apply to
+func (k *APIKey) Principals() []string {
+	principals := []string{PrincipalKeyPrefix + strconv.FormatInt(k.ID, 10)}
+	if k.User != "" {
+		principals = append(principals, PrincipalUserPrefix+k.User)
+	}
+	return principals
+}
+
+// Usable reports whether the key is neither revoked nor expired at now
+func (k *APIKey) Usable(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// CreateAPIKeyRequest represents the request body for creating an API key
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name"`
+	Namespace string     `json:"namespace,omitempty"` // defaults to the namespace of the calling key
+	User      string     `json:"user,omitempty"`      // user the key belongs to, for function sharing
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // never expires when omitted
+}
+
+// CreateAPIKeyResponse carries the new key. The key itself is only shown here.
+type CreateAPIKeyResponse struct {
+	APIKey
+	Key string `json:"key"`
+}
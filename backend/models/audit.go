@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+// Audit actions
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+)
+
+// Audited resource types
+const (
+	AuditFunction        = "function"
+	AuditFunctionGrant   = "function_grant"
+	AuditSchedule        = "schedule"
+	AuditWebhook         = "webhook"
+	AuditRedisTrigger    = "redis_trigger"
+	AuditStorageTrigger  = "storage_trigger"
+	AuditHTTPRoute       = "http_route"
+	AuditWorkflow        = "workflow"
+	AuditEventRule       = "event_rule"
+	AuditAPIKey          = "api_key"
+	AuditNamespace       = "namespace"
+	AuditRateLimit       = "rate_limit"
+	AuditRetentionPolicy = "retention_policy"
+)
+
+// AuditActorSystem is the actor of changes made outside API requests
+const AuditActorSystem = "system"
+
+// RequestMetadata describes the API request behind a change
+type RequestMetadata struct {
+	RequestID string `json:"request_id,omitempty"`
+	Method    string `json:"method"`
+	Path      string `json:"path"`
+	IP        string `json:"ip,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+}
+
+// AuditEvent records one change to a resource (audit_events table, append-only).
+// Before is empty for creations and After for deletions; updates only keep
+// the fields that changed. Code bodies and secrets are never recorded.
+type AuditEvent struct {
+	ID           int64                  `json:"id"`
+	NamespaceID  *int64                 `json:"namespace_id,omitempty"`
+	Actor        string                 `json:"actor"` // key:<id>, user:<name> or system
+	APIKeyID     *int64                 `json:"api_key_id,omitempty"`
+	Action       string                 `json:"action"`
+	ResourceType string                 `json:"resource_type"`
+	ResourceID   string                 `json:"resource_id"`
+	Before       map[string]interface{} `json:"before,omitempty"`
+	After        map[string]interface{} `json:"after,omitempty"`
+	Request      *RequestMetadata       `json:"request,omitempty"`
+	CreatedAt    time.Time              `json:"created_at"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// InvocationBatch represents a fan-out of one function over many inputs (invocation_batches table)
+type InvocationBatch struct {
+	ID             int64     `json:"id"`
+	FunctionID     int64     `json:"function_id"`
+	Total          int       `json:"total"`
+	MaxParallelism int       `json:"max_parallelism"`
+	Priority       string    `json:"priority"`
+	InvokedBy      string    `json:"invoked_by,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// BatchInvokeRequest represents the request body for a batch invoke
+type BatchInvokeRequest struct {
+	Params         []map[string]interface{} `json:"params"`
+	MaxParallelism int                      `json:"max_parallelism,omitempty"`
+	Priority       string                   `json:"priority,omitempty"`
+	CallbackURL    string                   `json:"callback_url,omitempty"`
+	CallbackSecret string                   `json:"callback_secret,omitempty"`
+}
+
+// BatchInvokeResponse represents the response for a batch invoke
+type BatchInvokeResponse struct {
+	BatchID       int64     `json:"batch_id"`
+	FunctionID    int64     `json:"function_id"`
+	Total         int       `json:"total"`
+	InvocationIDs []int64   `json:"invocation_ids"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// BatchStatus represents aggregate progress and results of a batch
+type BatchStatus struct {
+	InvocationBatch
+	Counts    map[string]int       `json:"counts"`
+	Completed int                  `json:"completed"`
+	Done      bool                 `json:"done"`
+	Results   []InvocationListItem `json:"results"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// InvocationCallback tracks delivery of an invocation's final result to its
+// callback URL (invocation_callbacks table)
+type InvocationCallback struct {
+	ID             int64             `json:"id"`
+	InvocationID   int64             `json:"invocation_id"`
+	URL            string            `json:"url"`
+	Secret         string            `json:"-"`
+	Status         string            `json:"status"`
+	Attempts       int               `json:"attempts"`
+	NextAttemptAt  time.Time         `json:"next_attempt_at"`
+	LastStatusCode int               `json:"last_status_code,omitempty"`
+	LastError      string            `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time        `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time         `json:"created_at"`
+	Log            []CallbackAttempt `json:"log,omitempty"`
+}
+
+// CallbackAttempt represents one delivery attempt (invocation_callback_attempts table)
+type CallbackAttempt struct {
+	ID          int64     `json:"id"`
+	CallbackID  int64     `json:"callback_id"`
+	Attempt     int       `json:"attempt"`
+	StatusCode  int       `json:"status_code,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	DurationMs  int       `json:"duration_ms"`
+	AttemptedAt time.Time `json:"attempted_at"`
+}
+
+// Callback delivery statuses
+const (
+	CallbackPending   = "pending"
+	CallbackDelivered = "delivered"
+	CallbackFailed    = "failed" // gave up after the last retry
+)
+
+// UpdateCallbackRequest represents the request body for setting a function's default callback
+type UpdateCallbackRequest struct {
+	CallbackURL    string `json:"callback_url"`
+	CallbackSecret string `json:"callback_secret,omitempty"`
+}
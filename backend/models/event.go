@@ -0,0 +1,113 @@
+package models
+
+import "time"
+
+// CloudEventsSpecVersion is the CloudEvents version accepted and delivered by the event bus
+const CloudEventsSpecVersion = "1.0"
+
+// Event is a CloudEvent published to the event bus (events table). Source and
+// ID identify an event within a namespace; publishing the same pair again is a no-op.
+type Event struct {
+	Seq             int64       `json:"seq"` // bus-assigned sequence number
+	NamespaceID     int64       `json:"namespace_id"`
+	SpecVersion     string      `json:"specversion"`
+	ID              string      `json:"id"`
+	Source          string      `json:"source"`
+	Type            string      `json:"type"`
+	Subject         string      `json:"subject,omitempty"`
+	Time            time.Time   `json:"time"`
+	DataContentType string      `json:"datacontenttype,omitempty"`
+	Data            interface{} `json:"data,omitempty"`
+	ReceivedAt      time.Time   `json:"received_at"`
+}
+
+// PublishEventRequest is a CloudEvent in structured JSON mode. id and time
+// are filled in when omitted.
+type PublishEventRequest struct {
+	SpecVersion     string      `json:"specversion"`
+	ID              string      `json:"id"`
+	Source          string      `json:"source"`
+	Type            string      `json:"type"`
+	Subject         string      `json:"subject,omitempty"`
+	Time            *time.Time  `json:"time,omitempty"`
+	DataContentType string      `json:"datacontenttype,omitempty"`
+	Data            interface{} `json:"data,omitempty"`
+}
+
+// EventRule routes events matching its pattern to its target functions (event_rules table).
+// Rules only see events published in their own namespace.
+//
+// A pattern is a JSON object mirroring the event (specversion, id, source,
+// type, subject, datacontenttype, data). Every field in the pattern must
+// match: nested objects match nested fields, and arrays list accepted values.
+// Array elements are literals or matchers: {"prefix": "s"}, {"suffix": "s"},
+// {"anything-but": value or [values]}, {"numeric": [">", 0, "<=", 10]} and
+// {"exists": bool}. When the event field is an array, any element may match.
+type EventRule struct {
+	ID          int64                  `json:"id"`
+	NamespaceID int64                  `json:"namespace_id"`
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Pattern     map[string]interface{} `json:"pattern"`
+	FunctionIDs []int64                `json:"function_ids"`
+	Enabled     bool                   `json:"enabled"`
+	CreatedAt   time.Time              `json:"created_at"`
+	UpdatedAt   time.Time              `json:"updated_at"`
+}
+
+// EventRuleRequest represents the request body for creating or updating an event rule
+type EventRuleRequest struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Pattern     map[string]interface{} `json:"pattern"`
+	FunctionIDs []int64                `json:"function_ids"`
+	Enabled     *bool                  `json:"enabled,omitempty"` // default true
+}
+
+// Event match statuses
+const (
+	MatchInvoked = "invoked"
+	MatchFailed  = "failed"
+)
+
+// EventMatch records an event routed by a rule to a function (event_matches table)
+type EventMatch struct {
+	ID           int64     `json:"id"`
+	EventSeq     int64     `json:"event_seq"`
+	RuleID       *int64    `json:"rule_id,omitempty"` // nil once the rule is deleted
+	FunctionID   int64     `json:"function_id"`
+	InvocationID *int64    `json:"invocation_id,omitempty"`
+	Status       string    `json:"status"`
+	Error        string    `json:"error,omitempty"`
+	Replay       bool      `json:"replay"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// PublishEventResponse is the result of publishing an event
+type PublishEventResponse struct {
+	Event     *Event       `json:"event"`
+	Duplicate bool         `json:"duplicate"`
+	Matches   []EventMatch `json:"matches"`
+}
+
+// EventDetail is an event with every match recorded for it
+type EventDetail struct {
+	Event
+	Matches []EventMatch `json:"matches"`
+}
+
+// ReplayEventsRequest selects stored events to route again. Without rule_id
+// events go through all enabled rules.
+type ReplayEventsRequest struct {
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+	Source string    `json:"source,omitempty"`
+	Type   string    `json:"type,omitempty"`
+	RuleID int64     `json:"rule_id,omitempty"`
+}
+
+// ReplayEventsResponse summarizes a replay
+type ReplayEventsResponse struct {
+	Events  int          `json:"events"`
+	Matches []EventMatch `json:"matches"`
+}
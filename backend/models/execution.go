@@ -7,8 +7,17 @@ type ExecutionRequest struct {
 	Code         string                 `json:"code"`
 	Input        map[string]interface{} `json:"input"`
 	Runtime      string                 `json:"runtime"`
+	Priority     string                 `json:"priority,omitempty"`
 }
 
+// Execution priorities. Each priority has its own queue per runtime; workers
+// drain the queues in weighted order (high 3 : normal 2 : low 1).
+const (
+	PriorityHigh   = "high"
+	PriorityNormal = "normal"
+	PriorityLow    = "low"
+)
+
 // ExecutionResult represents the result from worker (stored in Redis)
 type ExecutionResult struct {
 	InvocationID int64                  `json:"invocationId"`
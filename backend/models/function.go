@@ -7,18 +7,44 @@ import (
 // Function represents a serverless function metadata
 type Function struct {
 	ID          int64                  `json:"id"`
+	NamespaceID int64                  `json:"namespace_id"`
 	Name        string                 `json:"name"`
 	Description string                 `json:"description"`
 	Runtime     string                 `json:"runtime"`
 	CodeS3Key   string                 `json:"code_s3_key,omitempty"`
 	Code        string                 `json:"code,omitempty"`
 	SampleEvent map[string]interface{} `json:"sample_event,omitempty"`
-	IsPublic    bool                   `json:"is_public"`
 	CreatedAt   time.Time              `json:"created_at"`
 	UpdatedAt   time.Time              `json:"updated_at"`
 	Params      []FunctionParam        `json:"params,omitempty"`
+	Tags        []string               `json:"tags"`
+
+	// Public functions can be read and invoked by every key of the namespace;
+	// private ones only by their owner and the principals they are shared with
+	// (see FunctionGrant). Owner is the principal that created the function.
+	IsPublic bool   `json:"is_public"`
+	Owner    string `json:"owner,omitempty"`
+
+	// API keys that created and last changed the function
+	CreatedByKeyID *int64 `json:"created_by_key_id,omitempty"`
+	UpdatedByKeyID *int64 `json:"updated_by_key_id,omitempty"`
+
+	// Concurrency settings (0 means unlimited / no reservation)
+	MaxConcurrency      int    `json:"max_concurrency"`
+	ReservedConcurrency int    `json:"reserved_concurrency"`
+	OverflowPolicy      string `json:"overflow_policy"`
+
+	// Default completion callback for invocations that do not set their own
+	CallbackURL    string `json:"callback_url,omitempty"`
+	CallbackSecret string `json:"-"`
 }
 
+// Overflow policies applied when a function is at its concurrency limit
+const (
+	OverflowThrottle = "throttle" // reject with 429
+	OverflowQueue    = "queue"    // hold in the per-function overflow queue
+)
+
 // FunctionParam represents a parameter definition for a function
 type FunctionParam struct {
 	ID           int64                  `json:"id,omitempty"`
@@ -33,12 +59,30 @@ type FunctionParam struct {
 // FunctionListItem represents a function in list view (without code)
 type FunctionListItem struct {
 	ID          int64     `json:"id"`
+	NamespaceID int64     `json:"namespace_id"`
 	Name        string    `json:"name"`
 	Description string    `json:"description"`
 	Runtime     string    `json:"runtime"`
+	Tags        []string  `json:"tags"`
+	IsPublic    bool      `json:"is_public"`
+	Owner       string    `json:"owner,omitempty"`
 	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	// Summary of the recent invocations
+	LastInvokedAt        *time.Time `json:"last_invoked_at,omitempty"`
+	LastInvocationStatus string     `json:"last_invocation_status,omitempty"`
+	Invocations24h       int64      `json:"invocations_24h"`
 }
 
+// Sort orders of function listings
+const (
+	FunctionSortName          = "name"
+	FunctionSortCreatedAt     = "created_at"
+	FunctionSortUpdatedAt     = "updated_at"
+	FunctionSortLastInvokedAt = "last_invoked_at"
+)
+
 // CreateFunctionRequest represents the request body for creating a function
 type CreateFunctionRequest struct {
 	Name        string                 `json:"name"`
@@ -47,9 +91,45 @@ type CreateFunctionRequest struct {
 	Params      []FunctionParam        `json:"params"`
 	SampleEvent map[string]interface{} `json:"sample_event"`
 	Code        string                 `json:"code"`
+	Tags        []string               `json:"tags"`
+	IsPublic    *bool                  `json:"is_public,omitempty"` // defaults to true
+
+	MaxConcurrency      int    `json:"max_concurrency"`
+	ReservedConcurrency int    `json:"reserved_concurrency"`
+	OverflowPolicy      string `json:"overflow_policy"`
+
+	CallbackURL    string `json:"callback_url,omitempty"`
+	CallbackSecret string `json:"callback_secret,omitempty"`
+}
+
+// UpdateConcurrencyRequest represents the request body for changing concurrency settings
+type UpdateConcurrencyRequest struct {
+	MaxConcurrency      int    `json:"max_concurrency"`
+	ReservedConcurrency int    `json:"reserved_concurrency"`
+	OverflowPolicy      string `json:"overflow_policy"`
+}
+
+// UpdateTagsRequest represents the request body for replacing the tags of a function
+type UpdateTagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+// ConcurrencyStatus represents the current concurrency usage of a function
+type ConcurrencyStatus struct {
+	FunctionID          int64  `json:"function_id"`
+	MaxConcurrency      int    `json:"max_concurrency"`
+	ReservedConcurrency int    `json:"reserved_concurrency"`
+	OverflowPolicy      string `json:"overflow_policy"`
+	InFlight            int64  `json:"in_flight"`
+	OverflowDepth       int64  `json:"overflow_depth"`
 }
 
 // InvokeRequest represents the request body for invoking a function
 type InvokeRequest struct {
-	Params map[string]interface{} `json:"params"`
+	Params   map[string]interface{} `json:"params"`
+	Priority string                 `json:"priority,omitempty"`
+
+	// Optional completion callback, overriding the function's default
+	CallbackURL    string `json:"callback_url,omitempty"`
+	CallbackSecret string `json:"callback_secret,omitempty"`
 }
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Function access levels, from least to most. Each level includes the ones before it.
+const (
+	AccessRead   = "read"   // see the code, invocations and settings
+	AccessInvoke = "invoke" // invoke and cancel invocations
+	AccessAdmin  = "admin"  // change, share and delete the function
+)
+
+var accessRank = map[string]int{AccessRead: 1, AccessInvoke: 2, AccessAdmin: 3}
+
+// ValidAccess reports whether level is a known access level
+func ValidAccess(level string) bool {
+	return accessRank[level] > 0
+}
+
+// AccessAllows reports whether the granted level includes the required one
+func AccessAllows(granted, required string) bool {
+	return accessRank[granted] > 0 && accessRank[granted] >= accessRank[required]
+}
+
+// MaxAccess returns the higher of two access levels
+func MaxAccess(a, b string) string {
+	if accessRank[b] > accessRank[a] {
+		return b
+	}
+	return a
+}
+
+// Principals that functions are owned by and shared with
+const (
+	PrincipalKeyPrefix  = "key:"  // key:<id>, a single API key
+	PrincipalUserPrefix = "user:" // user:<name>, every API key of a user
+)
+
+// ValidPrincipal reports whether p names an API key or a user
+func ValidPrincipal(p string) bool {
+	if id, ok := strings.CutPrefix(p, PrincipalKeyPrefix); ok {
+		return id != "" && strings.Trim(id, "0123456789") == ""
+	}
+	name, ok := strings.CutPrefix(p, PrincipalUserPrefix)
+	return ok && name != ""
+}
+
+// FunctionGrant shares a private function with a principal (function_grants table)
+type FunctionGrant struct {
+	ID             int64     `json:"id"`
+	FunctionID     int64     `json:"function_id"`
+	Principal      string    `json:"principal"`
+	Access         string    `json:"access"`
+	GrantedByKeyID *int64    `json:"granted_by_key_id,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// GrantFunctionAccessRequest represents the request body for sharing a function
+type GrantFunctionAccessRequest struct {
+	Principal string `json:"principal"` // key:<id> or user:<name>
+	Access    string `json:"access"`    // read, invoke or admin
+}
+
+// UpdateVisibilityRequest represents the request body for making a function public or private
+type UpdateVisibilityRequest struct {
+	IsPublic bool `json:"is_public"`
+}
@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+// HTTPRoute exposes a function as a public HTTP endpoint at /fn/<slug>/* (http_routes table)
+type HTTPRoute struct {
+	ID             int64     `json:"id"`
+	FunctionID     int64     `json:"function_id"`
+	Slug           string    `json:"slug"`
+	AuthType       string    `json:"auth_type"`
+	TokenHash      string    `json:"-"`
+	TimeoutSeconds int       `json:"timeout_seconds"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+
+	// Token is only returned when a token is issued or rotated
+	Token string `json:"token,omitempty"`
+}
+
+// HTTP route auth types
+const (
+	RouteAuthNone  = "none"  // public
+	RouteAuthToken = "token" // requires "Authorization: Bearer <token>"
+)
+
+// UpsertHTTPRouteRequest represents the request body for configuring a function's HTTP route
+type UpsertHTTPRouteRequest struct {
+	Slug           string `json:"slug"`
+	AuthType       string `json:"auth_type"`
+	TimeoutSeconds int    `json:"timeout_seconds"`
+	RotateToken    bool   `json:"rotate_token,omitempty"`
+}
+
+// HTTPEvent is the event a function receives for a request to its HTTP route
+type HTTPEvent struct {
+	Method          string              `json:"method"`
+	Path            string              `json:"path"`
+	RawQuery        string              `json:"rawQuery"`
+	Query           map[string][]string `json:"query"`
+	Headers         map[string]string   `json:"headers"`
+	Body            string              `json:"body"`
+	IsBase64Encoded bool                `json:"isBase64Encoded"`
+	RequestContext  HTTPRequestContext  `json:"requestContext"`
+}
+
+// HTTPRequestContext describes where an HTTP event came from
+type HTTPRequestContext struct {
+	RouteSlug string    `json:"routeSlug"`
+	SourceIP  string    `json:"sourceIp"`
+	Time      time.Time `json:"time"`
+}
+
+// HTTPResult is the structured result a function may return to shape the
+// HTTP response. Results without statusCode are returned as a JSON body.
+type HTTPResult struct {
+	StatusCode      int               `json:"statusCode"`
+	Headers         map[string]string `json:"headers,omitempty"`
+	Body            interface{}       `json:"body,omitempty"`
+	IsBase64Encoded bool              `json:"isBase64Encoded,omitempty"`
+}
@@ -16,15 +16,26 @@ type Invocation struct {
 	ErrorMessage string                 `json:"error_message,omitempty"`
 	DurationMs   int                    `json:"duration_ms"`
 	ContainerID  string                 `json:"container_id,omitempty"`
+	BatchID      *int64                 `json:"batch_id,omitempty"`
+	APIKeyID     *int64                 `json:"api_key_id,omitempty"` // key of the API caller, if any
 	CreatedAt    time.Time              `json:"created_at"`
+
+	// SHA-256 of the code the invocation runs, and the invocation it replays
+	CodeVersion string `json:"code_version,omitempty"`
+	ReplayOf    *int64 `json:"replay_of,omitempty"`
+
+	CallbackURL    string `json:"callback_url,omitempty"`
+	CallbackSecret string `json:"-"`
 }
 
 // InvocationStatus constants
 const (
-	StatusSuccess = "success"
-	StatusFail    = "fail"
-	StatusTimeout = "timeout"
-	StatusPending = "pending"
+	StatusSuccess   = "success"
+	StatusFail      = "fail"
+	StatusTimeout   = "timeout"
+	StatusPending   = "pending"
+	StatusThrottled = "throttled"
+	StatusCancelled = "cancelled"
 )
 
 // InvokeResponse represents the response for function invocation
@@ -37,6 +48,30 @@ type InvokeResponse struct {
 	ErrorMessage string                 `json:"error_message,omitempty"`
 	DurationMs   int                    `json:"duration_ms"`
 	LoggedAt     time.Time              `json:"logged_at"`
+	CodeVersion  string                 `json:"code_version,omitempty"`
+	ReplayOf     *int64                 `json:"replay_of,omitempty"`
+}
+
+// NewInvokeResponse builds the API view of an invocation: the result on
+// success, the error message for any other final status
+func NewInvokeResponse(inv *Invocation) InvokeResponse {
+	response := InvokeResponse{
+		Status:       inv.Status,
+		FunctionID:   inv.FunctionID,
+		InvocationID: inv.ID,
+		InputEvent:   inv.InputEvent,
+		DurationMs:   inv.DurationMs,
+		LoggedAt:     inv.InvokedAt,
+		CodeVersion:  inv.CodeVersion,
+		ReplayOf:     inv.ReplayOf,
+	}
+
+	if inv.Status == StatusSuccess {
+		response.Result = inv.OutputResult
+	} else if inv.Status != StatusPending {
+		response.ErrorMessage = inv.ErrorMessage
+	}
+	return response
 }
 
 // InvocationListItem represents an invocation in list view
@@ -49,4 +84,22 @@ type InvocationListItem struct {
 	OutputResult map[string]interface{} `json:"output_result,omitempty"`
 	ErrorMessage string                 `json:"error_message,omitempty"`
 	DurationMs   int                    `json:"duration_ms"`
+	BatchID      *int64                 `json:"batch_id,omitempty"`
+	ReplayOf     *int64                 `json:"replay_of,omitempty"`
+}
+
+// ReplayInvocationRequest represents the request body for replaying an
+// invocation. Patch is applied to a copy of the original input event.
+type ReplayInvocationRequest struct {
+	Patch          []JSONPatchOperation `json:"patch,omitempty"`
+	PinCodeVersion bool                 `json:"pin_code_version"` // fail unless the function still runs the original code
+	Priority       string               `json:"priority,omitempty"`
+}
+
+// JSONPatchOperation is an operation of a JSON Patch (RFC 6902)
+type JSONPatchOperation struct {
+	Op    string      `json:"op"` // add, remove, replace, move, copy or test
+	Path  string      `json:"path"`
+	From  string      `json:"from,omitempty"`
+	Value interface{} `json:"value,omitempty"`
 }
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// DefaultNamespace owns everything created before namespaces existed and is
+// the namespace of API keys created without one. It cannot be deleted.
+const DefaultNamespace = "default"
+
+// Namespace is a project that owns functions (with their schedules,
+// triggers and invocations), workflows, events and API keys (namespaces table)
+type Namespace struct {
+	ID           int64     `json:"id"`
+	Name         string    `json:"name"`
+	Description  string    `json:"description"`
+	MaxFunctions int       `json:"max_functions"` // 0 means unlimited
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// CreateNamespaceRequest represents the request body for creating a namespace
+type CreateNamespaceRequest struct {
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	MaxFunctions int    `json:"max_functions"`
+}
+
+// UpdateNamespaceRequest represents the request body for updating a namespace.
+// Namespace names are fixed, since storage keys and rate limits refer to them.
+type UpdateNamespaceRequest struct {
+	Description  string `json:"description"`
+	MaxFunctions int    `json:"max_functions"`
+}
@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+// RateLimit represents invoke limits for a function, caller or namespace (rate_limits table).
+// Subject "*" is the default for every subject of the scope without its own entry.
+// Zero values mean unlimited.
+type RateLimit struct {
+	ID                    int64     `json:"id"`
+	Scope                 string    `json:"scope"`
+	Subject               string    `json:"subject"`
+	RequestsPerSecond     float64   `json:"requests_per_second"`
+	Burst                 int       `json:"burst"`
+	DailyInvocations      int64     `json:"daily_invocations"`
+	MonthlyInvocations    int64     `json:"monthly_invocations"`
+	DailyComputeSeconds   int64     `json:"daily_compute_seconds"`
+	MonthlyComputeSeconds int64     `json:"monthly_compute_seconds"`
+	CreatedAt             time.Time `json:"created_at"`
+	UpdatedAt             time.Time `json:"updated_at"`
+}
+
+// Rate limit scopes
+const (
+	RateLimitScopeFunction  = "function"  // subject is the function ID
+	RateLimitScopeCaller    = "caller"    // subject is the invoked_by value (API key or IP)
+	RateLimitScopeNamespace = "namespace" // subject is the namespace name
+)
+
+// RateLimitDefaultSubject applies to every subject of a scope without its own limit
+const RateLimitDefaultSubject = "*"
+
+// UpsertRateLimitRequest is used to create or replace a rate limit
+type UpsertRateLimitRequest struct {
+	Scope                 string  `json:"scope"`
+	Subject               string  `json:"subject"`
+	RequestsPerSecond     float64 `json:"requests_per_second"`
+	Burst                 int     `json:"burst"`
+	DailyInvocations      int64   `json:"daily_invocations"`
+	MonthlyInvocations    int64   `json:"monthly_invocations"`
+	DailyComputeSeconds   int64   `json:"daily_compute_seconds"`
+	MonthlyComputeSeconds int64   `json:"monthly_compute_seconds"`
+}
+
+// QuotaUsage reports the current quota consumption of a subject
+type QuotaUsage struct {
+	Scope                 string  `json:"scope"`
+	Subject               string  `json:"subject"`
+	DailyInvocations      int64   `json:"daily_invocations"`
+	MonthlyInvocations    int64   `json:"monthly_invocations"`
+	DailyComputeSeconds   float64 `json:"daily_compute_seconds"`
+	MonthlyComputeSeconds float64 `json:"monthly_compute_seconds"`
+}
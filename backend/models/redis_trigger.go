@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// RedisTrigger invokes a function for messages on a Redis stream or pub/sub channel (redis_triggers table)
+type RedisTrigger struct {
+	ID            int64     `json:"id"`
+	FunctionID    int64     `json:"function_id"`
+	SourceType    string    `json:"source_type"`
+	SourceKey     string    `json:"source_key"`
+	ConsumerGroup string    `json:"consumer_group"`
+	BatchSize     int       `json:"batch_size"`
+	BatchWindowMs int       `json:"batch_window_ms"`
+	Enabled       bool      `json:"enabled"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Redis trigger source types
+const (
+	RedisSourceStream = "stream" // consumed with a consumer group, at-least-once with checkpoints
+	RedisSourcePubSub = "pubsub" // bridged into an internal stream; messages published while no backend listens are lost
+)
+
+// CreateRedisTriggerRequest represents the request body for creating a Redis trigger
+type CreateRedisTriggerRequest struct {
+	SourceType    string `json:"source_type"`
+	SourceKey     string `json:"source_key"`
+	ConsumerGroup string `json:"consumer_group,omitempty"`  // defaults to "softgate-trigger-<id>"
+	BatchSize     int    `json:"batch_size,omitempty"`      // messages per invocation, default 1
+	BatchWindowMs int    `json:"batch_window_ms,omitempty"` // max wait to fill a batch, default 1000
+}
+
+// RedisMessage is one stream entry or pub/sub message in a trigger event
+type RedisMessage struct {
+	ID      string            `json:"id"`
+	Fields  map[string]string `json:"fields,omitempty"`
+	Payload string            `json:"payload,omitempty"`
+}
+
+// RedisTriggerEvent is the event a function receives from a Redis trigger
+type RedisTriggerEvent struct {
+	TriggerID  int64          `json:"triggerId"`
+	SourceType string         `json:"sourceType"`
+	SourceKey  string         `json:"sourceKey"`
+	Messages   []RedisMessage `json:"messages"`
+}
@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+// RetentionPolicy limits how long finished invocations are kept (retention_policies table).
+// The policy without a function is the global default for every function
+// without its own policy. Zero values mean unlimited.
+type RetentionPolicy struct {
+	ID         int64     `json:"id"`
+	FunctionID *int64    `json:"function_id,omitempty"` // nil for the global policy
+	MaxAgeDays int       `json:"max_age_days"`          // delete invocations older than this
+	MaxCount   int       `json:"max_count"`             // keep only the newest invocations per function
+	Archive    bool      `json:"archive"`               // archive invocations to storage before deleting them
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// UpsertRetentionPolicyRequest is used to create or replace a retention policy;
+// without a function ID it sets the global policy
+type UpsertRetentionPolicyRequest struct {
+	FunctionID *int64 `json:"function_id,omitempty"`
+	MaxAgeDays int    `json:"max_age_days"`
+	MaxCount   int    `json:"max_count"`
+	Archive    bool   `json:"archive"`
+}
+
+// TableSize reports the disk usage of a table
+type TableSize struct {
+	Name          string `json:"name"`
+	EstimatedRows int64  `json:"estimated_rows"`
+	TableBytes    int64  `json:"table_bytes"`
+	IndexBytes    int64  `json:"index_bytes"`
+	TotalBytes    int64  `json:"total_bytes"`
+}
+
+// InvocationPartition is a monthly partition of function_invocations (invocation_partitions table)
+type InvocationPartition struct {
+	Name          string    `json:"name"`
+	StartsAt      time.Time `json:"starts_at"`
+	EndsAt        time.Time `json:"ends_at"`
+	FirstID       *int64    `json:"first_id,omitempty"` // smallest invocation ID, once the partition has rows
+	EstimatedRows int64     `json:"estimated_rows"`
+	TotalBytes    int64     `json:"total_bytes"`
+}
+
+// PurgeStatus reports the invocation purger of this server instance
+type PurgeStatus struct {
+	Running       bool       `json:"running"`
+	LastRunAt     *time.Time `json:"last_run_at,omitempty"`
+	LastDeleted   int64      `json:"last_deleted"`
+	LastArchived  int64      `json:"last_archived"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastDropped   []string   `json:"last_dropped_partitions,omitempty"`
+	TotalDeleted  int64      `json:"total_deleted"`
+	TotalArchived int64      `json:"total_archived"`
+}
+
+// RetentionStatus reports table sizes and the state of invocation retention
+type RetentionStatus struct {
+	Tables             []TableSize           `json:"tables"`
+	Policies           []RetentionPolicy     `json:"policies"`
+	Partitions         []InvocationPartition `json:"partitions"`
+	OldestInvocationAt *time.Time            `json:"oldest_invocation_at,omitempty"`
+	Purger             PurgeStatus           `json:"purger"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// FunctionStats summarizes the invocations of a function over a time range,
+// as a whole and per time bucket. Buckets cover the range without gaps, empty
+// ones included.
+type FunctionStats struct {
+	FunctionID    int64           `json:"function_id"`
+	From          time.Time       `json:"from"`
+	To            time.Time       `json:"to"`
+	BucketSeconds int64           `json:"bucket_seconds"`
+	Summary       InvocationStats `json:"summary"`
+	Buckets       []StatsBucket   `json:"buckets"`
+}
+
+// StatsBucket holds the statistics of the invocations started in
+// [Start, Start + bucket)
+type StatsBucket struct {
+	Start time.Time `json:"start"`
+	InvocationStats
+}
+
+// InvocationStats counts invocations by status. ErrorRate is the share of
+// failed and timed out invocations among the finished ones; the duration
+// percentiles are taken over the finished invocations and are absent when
+// there are none.
+type InvocationStats struct {
+	Total     int64            `json:"total"`
+	Counts    map[string]int64 `json:"counts"`
+	ErrorRate float64          `json:"error_rate"`
+	P50Ms     *float64         `json:"p50_ms,omitempty"`
+	P90Ms     *float64         `json:"p90_ms,omitempty"`
+	P99Ms     *float64         `json:"p99_ms,omitempty"`
+}
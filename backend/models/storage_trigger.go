@@ -0,0 +1,81 @@
+package models
+
+import "time"
+
+// StorageTrigger invokes a function when objects change in a bucket or directory (storage_triggers table)
+type StorageTrigger struct {
+	ID          int64     `json:"id"`
+	FunctionID  int64     `json:"function_id"`
+	StorageType string    `json:"storage_type"` // local or s3
+	Location    string    `json:"location"`     // directory or bucket
+	Prefix      string    `json:"prefix,omitempty"`
+	Suffix      string    `json:"suffix,omitempty"`
+	EventTypes  string    `json:"event_types"`
+	Enabled     bool      `json:"enabled"`
+	LastEventAt time.Time `json:"last_event_at"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Storage trigger event type filters
+const (
+	StorageEventsCreated = "created"
+	StorageEventsRemoved = "removed"
+	StorageEventsAll     = "all"
+)
+
+// CreateStorageTriggerRequest represents the request body for creating a storage trigger.
+// Without storage_type/location the trigger watches the caller's namespace in
+// the code storage, and prefix is relative to it.
+type CreateStorageTriggerRequest struct {
+	StorageType string `json:"storage_type,omitempty"`
+	Location    string `json:"location,omitempty"`
+	Prefix      string `json:"prefix,omitempty"`
+	Suffix      string `json:"suffix,omitempty"`
+	EventTypes  string `json:"event_types,omitempty"` // created (default), removed or all
+}
+
+// S3 event names used in storage events
+const (
+	S3ObjectCreatedPut    = "ObjectCreated:Put"
+	S3ObjectRemovedDelete = "ObjectRemoved:Delete"
+)
+
+// S3Event is the S3 notification shape storage triggers deliver, for both
+// local and S3 storage, so functions work against either backend
+type S3Event struct {
+	Records []S3EventRecord `json:"Records"`
+}
+
+// S3EventRecord is one object change in an S3Event
+type S3EventRecord struct {
+	EventVersion string    `json:"eventVersion"`
+	EventSource  string    `json:"eventSource"`
+	AWSRegion    string    `json:"awsRegion"`
+	EventTime    time.Time `json:"eventTime"`
+	EventName    string    `json:"eventName"`
+	S3           S3Entity  `json:"s3"`
+}
+
+// S3Entity describes the bucket and object of an S3EventRecord
+type S3Entity struct {
+	SchemaVersion   string   `json:"s3SchemaVersion"`
+	ConfigurationID string   `json:"configurationId"`
+	Bucket          S3Bucket `json:"bucket"`
+	Object          S3Object `json:"object"`
+}
+
+// S3Bucket identifies the bucket (or local directory) of an event
+type S3Bucket struct {
+	Name string `json:"name"`
+	ARN  string `json:"arn"`
+}
+
+// S3Object describes the object of an event
+type S3Object struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size,omitempty"`
+	ETag         string    `json:"eTag,omitempty"`
+	LastModified time.Time `json:"lastModified"`
+	Sequencer    string    `json:"sequencer"`
+}
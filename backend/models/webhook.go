@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+// WebhookTrigger invokes a function for every verified request to /hooks/<token> (webhook_triggers table)
+type WebhookTrigger struct {
+	ID                 int64     `json:"id"`
+	FunctionID         int64     `json:"function_id"`
+	Name               string    `json:"name"`
+	Token              string    `json:"token"`
+	Path               string    `json:"path"`
+	Secret             string    `json:"secret,omitempty"` // only returned on creation
+	SignatureHeader    string    `json:"signature_header"`
+	SignatureAlgorithm string    `json:"signature_algorithm"`
+	SignaturePrefix    string    `json:"signature_prefix"`
+	SignatureEncoding  string    `json:"signature_encoding"`
+	DeliveryIDHeader   string    `json:"delivery_id_header,omitempty"`
+	Enabled            bool      `json:"enabled"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+// CreateWebhookTriggerRequest represents the request body for creating a webhook trigger.
+// Empty fields default to GitHub conventions (X-Hub-Signature-256, sha256, "sha256=", hex, X-GitHub-Delivery).
+type CreateWebhookTriggerRequest struct {
+	Name               string `json:"name"`
+	Secret             string `json:"secret,omitempty"` // generated when empty
+	SignatureHeader    string `json:"signature_header,omitempty"`
+	SignatureAlgorithm string `json:"signature_algorithm,omitempty"` // sha1, sha256 or sha512
+	SignaturePrefix    string `json:"signature_prefix,omitempty"`
+	SignatureEncoding  string `json:"signature_encoding,omitempty"` // hex or base64
+	DeliveryIDHeader   string `json:"delivery_id_header,omitempty"`
+}
+
+// WebhookDelivery records one request received by a webhook trigger (webhook_deliveries table)
+type WebhookDelivery struct {
+	ID           int64     `json:"id"`
+	TriggerID    int64     `json:"trigger_id"`
+	DeliveryID   string    `json:"delivery_id,omitempty"`
+	Status       string    `json:"status"`
+	InvocationID *int64    `json:"invocation_id,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	ReceivedAt   time.Time `json:"received_at"`
+}
+
+// Webhook delivery statuses
+const (
+	DeliveryAccepted = "accepted" // invocation enqueued
+	DeliveryRejected = "rejected" // signature verification failed
+	DeliveryFailed   = "failed"   // verified, but the invocation could not be enqueued
+)
+
+// WebhookEvent is the event a function receives for a webhook delivery
+type WebhookEvent struct {
+	TriggerID       int64             `json:"triggerId"`
+	DeliveryID      string            `json:"deliveryId,omitempty"`
+	Headers         map[string]string `json:"headers"`
+	Body            string            `json:"body"`
+	IsBase64Encoded bool              `json:"isBase64Encoded"`
+}
@@ -0,0 +1,177 @@
+package models
+
+import "time"
+
+// Workflow is a named state machine chaining function invocations (workflows table)
+type Workflow struct {
+	ID          int64              `json:"id"`
+	NamespaceID int64              `json:"namespace_id"`
+	Name        string             `json:"name"`
+	Description string             `json:"description,omitempty"`
+	Definition  WorkflowDefinition `json:"definition"`
+	CreatedAt   time.Time          `json:"created_at"`
+	UpdatedAt   time.Time          `json:"updated_at"`
+}
+
+// WorkflowDefinition describes the states of a workflow (or of a parallel
+// branch) and where execution starts. State names are unique across the
+// whole definition, including nested branches.
+type WorkflowDefinition struct {
+	StartAt string                   `json:"start_at"`
+	States  map[string]WorkflowState `json:"states"`
+}
+
+// Workflow state types
+const (
+	StateTask     = "task"     // invoke a function
+	StateParallel = "parallel" // run branches concurrently
+	StateChoice   = "choice"   // branch on fields of the data
+	StateWait     = "wait"     // pause for a number of seconds
+	StatePass     = "pass"     // pass data through, optionally merging result
+	StateSucceed  = "succeed"  // end successfully
+	StateFail     = "fail"     // end with an error
+)
+
+// WorkflowState is one state of a definition. Which fields apply depends on Type.
+type WorkflowState struct {
+	Type    string `json:"type"`
+	Comment string `json:"comment,omitempty"`
+	Next    string `json:"next,omitempty"`
+	End     bool   `json:"end,omitempty"`
+
+	// task
+	FunctionID int64 `json:"function_id,omitempty"`
+	// task, pass: key the result is stored under; empty replaces the data.
+	// parallel: key of the list of branch outputs (default "branches").
+	ResultPath string                 `json:"result_path,omitempty"`
+	Retry      []WorkflowRetryRule    `json:"retry,omitempty"`
+	Catch      []WorkflowCatchRule    `json:"catch,omitempty"`
+	Result     map[string]interface{} `json:"result,omitempty"` // pass
+
+	// parallel
+	Branches []WorkflowDefinition `json:"branches,omitempty"`
+
+	// choice
+	Choices []WorkflowChoiceRule `json:"choices,omitempty"`
+	Default string               `json:"default,omitempty"`
+
+	// wait
+	Seconds int `json:"seconds,omitempty"`
+
+	// fail
+	Error string `json:"error,omitempty"`
+	Cause string `json:"cause,omitempty"`
+}
+
+// Error names matched by retry and catch rules
+const (
+	WorkflowErrorAll    = "*"
+	WorkflowErrorInvoke = "invoke_error" // the invocation could not be enqueued
+	WorkflowErrorBranch = "branch_failed"
+	// Failed invocations use their status: fail, timeout, cancelled
+)
+
+// WorkflowRetryRule re-runs a failed task with exponential backoff
+type WorkflowRetryRule struct {
+	Errors          []string `json:"errors"`
+	MaxAttempts     int      `json:"max_attempts"`
+	IntervalSeconds int      `json:"interval_seconds,omitempty"` // default 1
+	BackoffRate     float64  `json:"backoff_rate,omitempty"`     // default 2
+}
+
+// WorkflowCatchRule moves to a fallback state once retries are exhausted.
+// The error is stored in the data under ResultPath (default "error").
+type WorkflowCatchRule struct {
+	Errors     []string `json:"errors"`
+	Next       string   `json:"next"`
+	ResultPath string   `json:"result_path,omitempty"`
+}
+
+// WorkflowChoiceRule moves to Next when the data field at Variable
+// (dot path, optionally prefixed with "$.") satisfies the one set comparison
+type WorkflowChoiceRule struct {
+	Variable string `json:"variable"`
+	Next     string `json:"next"`
+
+	StringEquals       *string  `json:"string_equals,omitempty"`
+	NumericEquals      *float64 `json:"numeric_equals,omitempty"`
+	NumericGreaterThan *float64 `json:"numeric_greater_than,omitempty"`
+	NumericLessThan    *float64 `json:"numeric_less_than,omitempty"`
+	BooleanEquals      *bool    `json:"boolean_equals,omitempty"`
+	IsPresent          *bool    `json:"is_present,omitempty"`
+}
+
+// CreateWorkflowRequest represents the request body for creating or updating a
+// workflow. It is accepted as JSON or, with a YAML content type, as YAML.
+type CreateWorkflowRequest struct {
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	Definition  WorkflowDefinition `json:"definition"`
+}
+
+// Workflow execution statuses
+const (
+	ExecutionRunning   = "running"
+	ExecutionSucceeded = "succeeded"
+	ExecutionFailed    = "failed"
+	ExecutionCancelled = "cancelled"
+)
+
+// WorkflowExecution is one run of a workflow (workflow_executions table). The
+// definition is snapshotted at start so later edits do not affect it.
+type WorkflowExecution struct {
+	ID         int64                  `json:"id"`
+	WorkflowID int64                  `json:"workflow_id"`
+	Status     string                 `json:"status"`
+	Definition WorkflowDefinition     `json:"-"`
+	Input      map[string]interface{} `json:"input"`
+	Output     map[string]interface{} `json:"output,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+	Cause      string                 `json:"cause,omitempty"`
+	CreatedAt  time.Time              `json:"created_at"`
+	UpdatedAt  time.Time              `json:"updated_at"`
+	FinishedAt *time.Time             `json:"finished_at,omitempty"`
+}
+
+// StartExecutionRequest represents the request body for starting a workflow execution
+type StartExecutionRequest struct {
+	Input map[string]interface{} `json:"input"`
+}
+
+// Workflow step statuses
+const (
+	StepRunning   = "running"
+	StepWaiting   = "waiting"
+	StepSucceeded = "succeeded"
+	StepFailed    = "failed"
+	StepCancelled = "cancelled"
+)
+
+// WorkflowStep records one state entered by an execution (workflow_steps
+// table); the steps of an execution form its history. Steps of parallel
+// branches point to the parallel step.
+type WorkflowStep struct {
+	ID           int64                  `json:"id"`
+	ExecutionID  int64                  `json:"execution_id"`
+	ParentStepID *int64                 `json:"parent_step_id,omitempty"`
+	BranchIndex  int                    `json:"branch_index"`
+	StateName    string                 `json:"state_name"`
+	StateType    string                 `json:"state_type"`
+	Status       string                 `json:"status"`
+	Input        map[string]interface{} `json:"input"`
+	Output       map[string]interface{} `json:"output,omitempty"`
+	Error        string                 `json:"error,omitempty"`
+	Cause        string                 `json:"cause,omitempty"`
+	Attempt      int                    `json:"attempt"`
+	InvocationID *int64                 `json:"invocation_id,omitempty"`
+	Terminal     bool                   `json:"terminal"` // last step of the execution or of its branch
+	NextRunAt    time.Time              `json:"-"`
+	StartedAt    time.Time              `json:"started_at"`
+	FinishedAt   *time.Time             `json:"finished_at,omitempty"`
+}
+
+// WorkflowExecutionDetail is an execution with its step history
+type WorkflowExecutionDetail struct {
+	WorkflowExecution
+	Steps []WorkflowStep `json:"steps"`
+}
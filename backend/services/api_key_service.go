@@ -0,0 +1,172 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"lambda-runner-server/middleware"
+	"lambda-runner-server/models"
+)
+
+const (
+	apiKeyPrefix       = "sg_"
+	apiKeyDisplayChars = len(apiKeyPrefix) + 8
+	minBootstrapKeyLen = 16
+)
+
+// APIKeyService issues, revokes and authenticates API keys. Keys are random
+// tokens shown once on creation; only their SHA-256 hash is stored.
+type APIKeyService struct {
+	db APIKeyStore
+}
+
+func NewAPIKeyService(db APIKeyStore) *APIKeyService {
+	return &APIKeyService{db: db}
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func displayPrefix(key string) string {
+	if len(key) > apiKeyDisplayChars {
+		return key[:apiKeyDisplayChars]
+	}
+	return key
+}
+
+func validateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return fmt.Errorf("scopes must not be empty")
+	}
+	for _, scope := range scopes {
+		valid := false
+		for _, v := range models.ValidScopes {
+			if scope == v {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("unknown scope %q (valid: %v)", scope, models.ValidScopes)
+		}
+	}
+	return nil
+}
+
+// CreateKey issues a new API key on behalf of the calling key
+func (s *APIKeyService) CreateKey(ctx context.Context, req *models.CreateAPIKeyRequest) (*models.CreateAPIKeyResponse, error) {
+	if req.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if err := validateScopes(req.Scopes); err != nil {
+		return nil, err
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("expires_at must be in the future")
+	}
+
+	namespaceID := requestNamespace(ctx)
+	if req.Namespace != "" {
+		ns, err := s.db.GetNamespaceByName(ctx, req.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		if ns == nil {
+			return nil, fmt.Errorf("namespace not found: %s", req.Namespace)
+		}
+		namespaceID = ns.ID
+	}
+
+	secret, err := randomHex(24)
+	if err != nil {
+		return nil, err
+	}
+	key := apiKeyPrefix + secret
+
+	created, err := s.db.CreateAPIKey(ctx, &models.APIKey{
+		Name:           req.Name,
+		Prefix:         displayPrefix(key),
+		User:           strings.TrimSpace(req.User),
+		NamespaceID:    namespaceID,
+		Scopes:         req.Scopes,
+		ExpiresAt:      req.ExpiresAt,
+		CreatedByKeyID: middleware.APIKeyID(ctx),
+	}, hashAPIKey(key))
+	if err != nil {
+		return nil, err
+	}
+	audit(ctx, s.db, models.AuditCreate, models.AuditAPIKey, created.ID, nil, created)
+	return &models.CreateAPIKeyResponse{APIKey: *created, Key: key}, nil
+}
+
+// ListKeys returns all API keys without their secrets
+func (s *APIKeyService) ListKeys(ctx context.Context) ([]models.APIKey, error) {
+	return s.db.ListAPIKeys(ctx)
+}
+
+// RevokeKey revokes an API key; requests using it fail from then on
+func (s *APIKeyService) RevokeKey(ctx context.Context, id int64) (*models.APIKey, error) {
+	key, err := s.db.RevokeAPIKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, fmt.Errorf("API key not found: %d", id)
+	}
+	audit(ctx, s.db, models.AuditUpdate, models.AuditAPIKey, id, map[string]interface{}{"revoked_at": nil},
+		map[string]interface{}{"revoked_at": key.RevokedAt})
+	return key, nil
+}
+
+// Authenticate implements middleware.KeyAuthenticator
+func (s *APIKeyService) Authenticate(ctx context.Context, key string) (*models.APIKey, error) {
+	k, err := s.db.GetAPIKeyByHash(ctx, hashAPIKey(key))
+	if err != nil {
+		return nil, err
+	}
+	if k == nil || !k.Usable(time.Now()) {
+		return nil, nil
+	}
+	if err := s.db.TouchAPIKey(ctx, k.ID); err != nil {
+		log.Printf("api keys: failed to record use of key %d: %v", k.ID, err)
+	}
+	return k, nil
+}
+
+// EnsureBootstrapKey registers key (from ADMIN_API_KEY) as an admin key, so
+// a fresh install can create its first keys. Without it and without any
+// usable key, every /api request is rejected.
+func (s *APIKeyService) EnsureBootstrapKey(ctx context.Context, key string) error {
+	if key != "" {
+		if len(key) < minBootstrapKeyLen {
+			return fmt.Errorf("ADMIN_API_KEY must be at least %d characters", minBootstrapKeyLen)
+		}
+		inserted, err := s.db.EnsureAPIKey(ctx, &models.APIKey{
+			Name:   "bootstrap admin",
+			Prefix: displayPrefix(key),
+			Scopes: []string{models.ScopeAdmin},
+		}, hashAPIKey(key))
+		if err != nil {
+			return err
+		}
+		if inserted {
+			log.Println("Registered ADMIN_API_KEY as bootstrap admin key")
+		}
+	}
+
+	n, err := s.db.CountAPIKeys(ctx)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		log.Println("Warning: no usable API keys; set ADMIN_API_KEY to access the API")
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"reflect"
+	"strings"
+
+	"lambda-runner-server/middleware"
+	"lambda-runner-server/models"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// audit appends a change to the audit log. before is nil for creations and
+// after is nil for deletions. A failed write is logged and never fails the
+// change itself.
+func audit(ctx context.Context, db AuditStore, action, resourceType string, resourceID interface{}, before, after interface{}) {
+	event := &models.AuditEvent{
+		Actor:        models.AuditActorSystem,
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   fmt.Sprint(resourceID),
+		Before:       auditState(before),
+		After:        auditState(after),
+		Request:      middleware.RequestFromContext(ctx),
+	}
+	if key := middleware.APIKeyFromContext(ctx); key != nil {
+		event.Actor = key.Principal()
+		event.APIKeyID = &key.ID
+	}
+	if ns := requestNamespace(ctx); ns != 0 {
+		event.NamespaceID = &ns
+	}
+
+	// Updates keep only the fields that changed
+	if event.Before != nil && event.After != nil {
+		for field, value := range event.Before {
+			if after, ok := event.After[field]; ok && reflect.DeepEqual(value, after) {
+				delete(event.Before, field)
+				delete(event.After, field)
+			}
+		}
+	}
+
+	if err := db.InsertAuditEvent(ctx, event); err != nil {
+		log.Printf("audit: failed to record %s of %s %s: %v", action, resourceType, event.ResourceID, err)
+	}
+}
+
+// auditState turns a resource into its JSON fields without code bodies and secrets
+func auditState(resource interface{}) map[string]interface{} {
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return nil
+	}
+	var state map[string]interface{}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil
+	}
+	redactAuditState(state)
+	return state
+}
+
+func redactAuditState(state map[string]interface{}) {
+	for field, value := range state {
+		if redactedAuditField(field) {
+			delete(state, field)
+			continue
+		}
+		switch value := value.(type) {
+		case map[string]interface{}:
+			redactAuditState(value)
+		case []interface{}:
+			for _, item := range value {
+				if nested, ok := item.(map[string]interface{}); ok {
+					redactAuditState(nested)
+				}
+			}
+		}
+	}
+}
+
+func redactedAuditField(field string) bool {
+	field = strings.ToLower(field)
+	if field == "code" {
+		return true
+	}
+	for _, secret := range []string{"secret", "token", "password", "hash"} {
+		if strings.Contains(field, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// AuditService reads the audit log
+type AuditService struct {
+	db *DBService
+}
+
+func NewAuditService(db *DBService) *AuditService {
+	return &AuditService{db: db}
+}
+
+// ListEvents returns the most recent audit events matching the filter
+func (s *AuditService) ListEvents(ctx context.Context, filter AuditFilter, limit int) ([]models.AuditEvent, error) {
+	if limit <= 0 {
+		limit = defaultAuditLimit
+	}
+	if limit > maxAuditLimit {
+		limit = maxAuditLimit
+	}
+	return s.db.ListAuditEvents(ctx, filter, limit)
+}
+
+// ExportEvents writes every audit event matching the filter to w as
+// newline-delimited JSON, oldest first
+func (s *AuditService) ExportEvents(ctx context.Context, filter AuditFilter, w io.Writer) error {
+	enc := json.NewEncoder(w)
+	return s.db.EachAuditEvent(ctx, filter, func(e *models.AuditEvent) error {
+		return enc.Encode(e)
+	})
+}
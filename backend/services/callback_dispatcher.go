@@ -0,0 +1,270 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"lambda-runner-server/middleware"
+	"lambda-runner-server/models"
+)
+
+// Headers sent with every callback delivery
+const (
+	CallbackIDHeader        = "X-Callback-Id"
+	CallbackTimestampHeader = "X-Callback-Timestamp"
+	CallbackSignatureHeader = "X-Callback-Signature"
+)
+
+const (
+	callbackMaxAttempts = 10
+	callbackBaseBackoff = 5 * time.Second
+	callbackMaxBackoff  = time.Hour
+)
+
+// CallbackDispatcher delivers final invocation results to callback URLs as
+// signed POST requests, retrying failed deliveries with exponential backoff.
+type CallbackDispatcher struct {
+	db            CallbackStore
+	client        *http.Client
+	defaultSecret string
+	interval      time.Duration
+	lease         time.Duration
+	batchSize     int
+	stopCh        chan struct{}
+	wg            sync.WaitGroup
+}
+
+// NewCallbackDispatcher creates a dispatcher. defaultSecret signs deliveries
+// whose invocation and function have no secret of their own.
+func NewCallbackDispatcher(db CallbackStore, defaultSecret string) *CallbackDispatcher {
+	return &CallbackDispatcher{
+		db:            db,
+		client:        middleware.GetCustomXRayHTTPClient(&http.Client{Timeout: 10 * time.Second, Transport: callbackTransport()}),
+		defaultSecret: defaultSecret,
+		interval:      time.Second,
+		lease:         time.Minute,
+		batchSize:     50,
+		stopCh:        make(chan struct{}),
+	}
+}
+
+func (d *CallbackDispatcher) Start() {
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		ticker := time.NewTicker(d.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				d.dispatchDue()
+			case <-d.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+func (d *CallbackDispatcher) Stop() {
+	close(d.stopCh)
+	d.wg.Wait()
+}
+
+func (d *CallbackDispatcher) dispatchDue() {
+	ctx := context.Background()
+	callbacks, err := d.db.ClaimDueCallbacks(ctx, d.batchSize, d.lease)
+	if err != nil {
+		log.Printf("callbacks: failed to claim deliveries: %v", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, cb := range callbacks {
+		wg.Add(1)
+		go func(cb models.InvocationCallback) {
+			defer wg.Done()
+			d.deliver(ctx, &cb)
+		}(cb)
+	}
+	wg.Wait()
+}
+
+// deliver makes one delivery attempt and records its outcome
+func (d *CallbackDispatcher) deliver(ctx context.Context, cb *models.InvocationCallback) {
+	ctx, seg := xray.BeginSegment(ctx, "softgate-callback")
+	defer seg.Close(nil)
+
+	attempt := &models.CallbackAttempt{
+		CallbackID: cb.ID,
+		Attempt:    cb.Attempts + 1,
+	}
+
+	start := time.Now()
+	statusCode, err := d.post(ctx, cb)
+	attempt.DurationMs = int(time.Since(start).Milliseconds())
+	attempt.StatusCode = statusCode
+
+	status := models.CallbackDelivered
+	nextAttemptAt := time.Now()
+	if err != nil {
+		attempt.Error = err.Error()
+		seg.AddError(err)
+		if attempt.Attempt >= callbackMaxAttempts {
+			status = models.CallbackFailed
+		} else {
+			status = models.CallbackPending
+			nextAttemptAt = nextAttemptAt.Add(callbackBackoff(attempt.Attempt))
+		}
+	}
+
+	if err := d.db.RecordCallbackAttempt(ctx, attempt, status, nextAttemptAt); err != nil {
+		log.Printf("callbacks: failed to record attempt %d of callback %d: %v", attempt.Attempt, cb.ID, err)
+	}
+}
+
+// post sends the invocation result to the callback URL. Any non-2xx response
+// counts as a failed attempt.
+func (d *CallbackDispatcher) post(ctx context.Context, cb *models.InvocationCallback) (int, error) {
+	inv, err := d.db.GetInvocation(ctx, cb.InvocationID)
+	if err != nil {
+		return 0, err
+	}
+	if inv == nil {
+		return 0, fmt.Errorf("invocation not found: %d", cb.InvocationID)
+	}
+
+	body, err := json.Marshal(models.NewInvokeResponse(inv))
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cb.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(CallbackIDHeader, strconv.FormatInt(cb.ID, 10))
+	req.Header.Set(CallbackTimestampHeader, timestamp)
+
+	secret := cb.Secret
+	if secret == "" {
+		secret = d.defaultSecret
+	}
+	if secret != "" {
+		req.Header.Set(CallbackSignatureHeader, SignCallback(secret, timestamp, body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("callback returned status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// SignCallback returns the signature header value for a delivery:
+// "sha256=" + hex(HMAC-SHA256(secret, timestamp + "." + body))
+func SignCallback(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// callbackBackoff returns the delay before the next attempt after a failed one
+func callbackBackoff(attempt int) time.Duration {
+	delay := callbackBaseBackoff
+	for i := 1; i < attempt && delay < callbackMaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > callbackMaxBackoff {
+		delay = callbackMaxBackoff
+	}
+	return delay
+}
+
+// blockedCallbackIP reports whether an address is off limits for callbacks:
+// loopback, link-local (including cloud metadata endpoints), private or
+// unspecified addresses reach the platform's own network
+func blockedCallbackIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsPrivate() || ip.IsUnspecified()
+}
+
+// callbackTransport dials only public addresses. The check runs on the
+// address actually dialed, so DNS rebinding and redirects cannot reach
+// internal services; proxies are not used, as they would hide that address.
+func callbackTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || blockedCallbackIP(ip) {
+				return fmt.Errorf("callback to %s refused: not a public address", address)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return transport
+}
+
+// ValidateCallbackURL checks that a callback URL is an absolute http(s) URL
+// whose host resolves to public addresses only
+func ValidateCallbackURL(rawURL string) error {
+	if rawURL == "" {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("callback_url must be an absolute http or https URL")
+	}
+
+	host := u.Hostname()
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = append(ips, ip)
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return fmt.Errorf("callback_url host %q cannot be resolved", host)
+		}
+		for _, addr := range addrs {
+			ips = append(ips, addr.IP)
+		}
+	}
+	for _, ip := range ips {
+		if blockedCallbackIP(ip) {
+			return fmt.Errorf("callback_url must not point to a loopback, link-local or private address")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"lambda-runner-server/models"
+)
+
+const reservedCacheTTL = 5 * time.Second
+
+// ConcurrencyLimiter enforces per-function concurrency limits and per-runtime
+// reserved capacity using Redis-backed slot sets.
+type ConcurrencyLimiter struct {
+	db    *DBService
+	redis *RedisService
+
+	// capacity holds the worker slots per runtime pool ("*" is the default).
+	// A capacity of 0 leaves the shared pool unbounded.
+	capacity map[string]int
+
+	mu             sync.Mutex
+	reserved       map[string]int
+	reservedLoaded time.Time
+}
+
+func NewConcurrencyLimiter(db *DBService, redis *RedisService, capacity map[string]int) *ConcurrencyLimiter {
+	if capacity == nil {
+		capacity = map[string]int{}
+	}
+	return &ConcurrencyLimiter{
+		db:       db,
+		redis:    redis,
+		capacity: capacity,
+	}
+}
+
+// ParseRuntimeCapacity parses a capacity spec such as "python=4,golang=2,*=8"
+func ParseRuntimeCapacity(spec string) (map[string]int, error) {
+	capacity := map[string]int{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		pool, value, found := strings.Cut(part, "=")
+		if !found {
+			pool, value = "*", part
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid runtime capacity %q", part)
+		}
+		capacity[strings.TrimSpace(pool)] = n
+	}
+	return capacity, nil
+}
+
+// runtimePool returns the worker pool a runtime is executed on
+func runtimePool(runtime string) string {
+	return strings.TrimPrefix(getQueueName(runtime), "execution_queue:")
+}
+
+func (l *ConcurrencyLimiter) poolCapacity(pool string) int {
+	if n, ok := l.capacity[pool]; ok {
+		return n
+	}
+	return l.capacity["*"]
+}
+
+// reservedByPool returns the total reserved concurrency per pool
+func (l *ConcurrencyLimiter) reservedByPool(ctx context.Context) (map[string]int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.reserved != nil && time.Since(l.reservedLoaded) < reservedCacheTTL {
+		return l.reserved, nil
+	}
+
+	byRuntime, err := l.db.ReservedConcurrencyByRuntime(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+	byPool := make(map[string]int)
+	for runtime, n := range byRuntime {
+		byPool[runtimePool(runtime)] += n
+	}
+
+	l.reserved = byPool
+	l.reservedLoaded = time.Now()
+	return byPool, nil
+}
+
+// invalidate drops cached reservations after settings change
+func (l *ConcurrencyLimiter) invalidate() {
+	l.mu.Lock()
+	l.reserved = nil
+	l.mu.Unlock()
+}
+
+// sharedPoolLimit returns the unreserved slots of a pool, or -1 when unbounded
+func (l *ConcurrencyLimiter) sharedPoolLimit(ctx context.Context, pool string) (int, error) {
+	capacity := l.poolCapacity(pool)
+	if capacity <= 0 {
+		return -1, nil
+	}
+	reserved, err := l.reservedByPool(ctx)
+	if err != nil {
+		return 0, err
+	}
+	limit := capacity - reserved[pool]
+	if limit < 0 {
+		limit = 0
+	}
+	return limit, nil
+}
+
+// Acquire takes a slot for an invocation of fn. It reports false when the
+// function or its runtime pool is at capacity.
+func (l *ConcurrencyLimiter) Acquire(ctx context.Context, fn *models.Function, invocationID int64) (bool, error) {
+	pool := runtimePool(fn.Runtime)
+	poolLimit, err := l.sharedPoolLimit(ctx, pool)
+	if err != nil {
+		return false, err
+	}
+	return l.redis.AcquireSlot(ctx, fn.ID, pool, invocationID, fn.MaxConcurrency, fn.ReservedConcurrency, poolLimit)
+}
+
+// Release frees the slot held by an invocation
+func (l *ConcurrencyLimiter) Release(ctx context.Context, fn *models.Function, invocationID int64) (bool, error) {
+	return l.redis.ReleaseSlot(ctx, fn.ID, runtimePool(fn.Runtime), invocationID)
+}
+
+// ValidateSettings checks concurrency settings against runtime capacity
+func (l *ConcurrencyLimiter) ValidateSettings(ctx context.Context, fn *models.Function, req *models.UpdateConcurrencyRequest) error {
+	if req.ReservedConcurrency == 0 {
+		return nil
+	}
+
+	pool := runtimePool(fn.Runtime)
+	capacity := l.poolCapacity(pool)
+	if capacity <= 0 {
+		return nil
+	}
+
+	byRuntime, err := l.db.ReservedConcurrencyByRuntime(ctx, fn.ID)
+	if err != nil {
+		return err
+	}
+	reserved := 0
+	for runtime, n := range byRuntime {
+		if runtimePool(runtime) == pool {
+			reserved += n
+		}
+	}
+	if reserved+req.ReservedConcurrency > capacity {
+		return fmt.Errorf("reserved_concurrency exceeds available capacity for runtime %s (%d of %d slots already reserved)",
+			pool, reserved, capacity)
+	}
+	return nil
+}
+
+// Status returns the current concurrency usage of a function
+func (l *ConcurrencyLimiter) Status(ctx context.Context, fn *models.Function) (*models.ConcurrencyStatus, error) {
+	inFlight, err := l.redis.CountSlots(ctx, fn.ID)
+	if err != nil {
+		return nil, err
+	}
+	depth, err := l.redis.OverflowLength(ctx, fn.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.ConcurrencyStatus{
+		FunctionID:          fn.ID,
+		MaxConcurrency:      fn.MaxConcurrency,
+		ReservedConcurrency: fn.ReservedConcurrency,
+		OverflowPolicy:      fn.OverflowPolicy,
+		InFlight:            inFlight,
+		OverflowDepth:       depth,
+	}, nil
+}
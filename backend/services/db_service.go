@@ -5,12 +5,14 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
+	"lambda-runner-server/middleware"
 	"lambda-runner-server/models"
 
 	"github.com/aws/aws-xray-sdk-go/xray"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 type DBService struct {
@@ -40,69 +42,6 @@ func (s *DBService) Close() error {
 	return s.db.Close()
 }
 
-// InitSchema creates tables if they don't exist
-func (s *DBService) InitSchema(ctx context.Context) error {
-	schema := `
-	CREATE TABLE IF NOT EXISTS functions (
-		id BIGSERIAL PRIMARY KEY,
-		name VARCHAR(100) NOT NULL,
-		description TEXT NOT NULL,
-		runtime VARCHAR(50) NOT NULL,
-		code_s3_key TEXT NOT NULL,
-		sample_event JSONB,
-		is_public BOOLEAN NOT NULL DEFAULT TRUE,
-		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
-		updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
-	);
-
-	CREATE TABLE IF NOT EXISTS function_params (
-		id BIGSERIAL PRIMARY KEY,
-		function_id BIGINT NOT NULL REFERENCES functions(id) ON DELETE CASCADE,
-		param_key VARCHAR(100) NOT NULL,
-		param_type VARCHAR(50) NOT NULL,
-		is_required BOOLEAN NOT NULL DEFAULT TRUE,
-		description TEXT,
-		default_value JSONB
-	);
-
-	CREATE TABLE IF NOT EXISTS function_invocations (
-		id BIGSERIAL PRIMARY KEY,
-		function_id BIGINT NOT NULL REFERENCES functions(id) ON DELETE CASCADE,
-		invoked_at TIMESTAMPTZ NOT NULL DEFAULT now(),
-		invoked_by VARCHAR(255),
-		input_event JSONB NOT NULL,
-		status VARCHAR(20) NOT NULL,
-		output_result JSONB,
-		error_message TEXT,
-		duration_ms INTEGER,
-		container_id VARCHAR(255),
-		created_at TIMESTAMPTZ NOT NULL DEFAULT now()
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_function_invocations_function_id ON function_invocations(function_id);
-	CREATE INDEX IF NOT EXISTS idx_function_invocations_invoked_at ON function_invocations(invoked_at DESC);
-
-	CREATE TABLE IF NOT EXISTS function_schedules (
-		id BIGSERIAL PRIMARY KEY,
-		function_id BIGINT NOT NULL REFERENCES functions(id) ON DELETE CASCADE,
-		scheduled_at TIMESTAMPTZ NOT NULL,
-		payload JSONB NOT NULL DEFAULT '{}'::jsonb,
-		executed BOOLEAN NOT NULL DEFAULT FALSE,
-		executed_at TIMESTAMPTZ,
-		status VARCHAR(20),
-		error_message TEXT,
-		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
-		updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_function_schedules_function_id ON function_schedules(function_id);
-	CREATE INDEX IF NOT EXISTS idx_function_schedules_pending ON function_schedules(scheduled_at) WHERE executed = FALSE;
-	`
-
-	_, err := s.db.ExecContext(ctx, schema)
-	return err
-}
-
 // CreateFunction inserts a new function and its params
 func (s *DBService) CreateFunction(ctx context.Context, fn *models.Function) (*models.Function, error) {
 	var result *models.Function
@@ -121,10 +60,12 @@ func (s *DBService) CreateFunction(ctx context.Context, fn *models.Function) (*m
 		var id int64
 		var createdAt, updatedAt time.Time
 		err = tx.QueryRowContext(ctx, `
-			INSERT INTO functions (name, description, runtime, code_s3_key, sample_event, is_public)
-			VALUES ($1, $2, $3, $4, $5, $6)
+			INSERT INTO functions (name, description, runtime, code_s3_key, sample_event, is_public, max_concurrency, reserved_concurrency, overflow_policy,
+				callback_url, callback_secret, created_by_key_id, updated_by_key_id, namespace_id, owner, tags)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, ''), NULLIF($11, ''), $12, $12, $13, NULLIF($14, ''), $15)
 			RETURNING id, created_at, updated_at
-		`, fn.Name, fn.Description, fn.Runtime, fn.CodeS3Key, sampleEventJSON, true).Scan(&id, &createdAt, &updatedAt)
+		`, fn.Name, fn.Description, fn.Runtime, fn.CodeS3Key, sampleEventJSON, fn.IsPublic, fn.MaxConcurrency, fn.ReservedConcurrency, fn.OverflowPolicy,
+			fn.CallbackURL, fn.CallbackSecret, fn.CreatedByKeyID, fn.NamespaceID, fn.Owner, pq.Array(fn.Tags)).Scan(&id, &createdAt, &updatedAt)
 		if err != nil {
 			finalErr = err
 			return err
@@ -133,7 +74,7 @@ func (s *DBService) CreateFunction(ctx context.Context, fn *models.Function) (*m
 		fn.ID = id
 		fn.CreatedAt = createdAt
 		fn.UpdatedAt = updatedAt
-		fn.IsPublic = true
+		fn.UpdatedByKeyID = fn.CreatedByKeyID
 
 		// Insert params
 		for i := range fn.Params {
@@ -183,11 +124,16 @@ func (s *DBService) GetFunction(ctx context.Context, id int64) (*models.Function
 	xray.Capture(ctx, "DB.GetFunction", func(ctx1 context.Context) error {
 		fn := &models.Function{}
 		var sampleEventJSON []byte
+		var createdByKeyID, updatedByKeyID sql.NullInt64
 
 		err := s.db.QueryRowContext(ctx, `
-			SELECT id, name, description, runtime, code_s3_key, sample_event, is_public, created_at, updated_at
-			FROM functions WHERE id = $1
-		`, id).Scan(&fn.ID, &fn.Name, &fn.Description, &fn.Runtime, &fn.CodeS3Key, &sampleEventJSON, &fn.IsPublic, &fn.CreatedAt, &fn.UpdatedAt)
+			SELECT id, name, description, runtime, code_s3_key, sample_event, is_public, created_at, updated_at,
+				max_concurrency, reserved_concurrency, overflow_policy, COALESCE(callback_url, ''), COALESCE(callback_secret, ''),
+				created_by_key_id, updated_by_key_id, namespace_id, COALESCE(owner, ''), tags
+			FROM functions WHERE id = $1 AND `+inNamespace("namespace_id", 2)+`
+		`, id, requestNamespace(ctx)).Scan(&fn.ID, &fn.Name, &fn.Description, &fn.Runtime, &fn.CodeS3Key, &sampleEventJSON, &fn.IsPublic, &fn.CreatedAt, &fn.UpdatedAt,
+			&fn.MaxConcurrency, &fn.ReservedConcurrency, &fn.OverflowPolicy, &fn.CallbackURL, &fn.CallbackSecret,
+			&createdByKeyID, &updatedByKeyID, &fn.NamespaceID, &fn.Owner, pq.Array(&fn.Tags))
 		if err == sql.ErrNoRows {
 			result = nil
 			finalErr = nil
@@ -201,6 +147,12 @@ func (s *DBService) GetFunction(ctx context.Context, id int64) (*models.Function
 		if sampleEventJSON != nil {
 			json.Unmarshal(sampleEventJSON, &fn.SampleEvent)
 		}
+		if createdByKeyID.Valid {
+			fn.CreatedByKeyID = &createdByKeyID.Int64
+		}
+		if updatedByKeyID.Valid {
+			fn.UpdatedByKeyID = &updatedByKeyID.Int64
+		}
 
 		// Get params
 		rows, err := s.db.QueryContext(ctx, `
@@ -247,14 +199,68 @@ func (s *DBService) GetFunction(ctx context.Context, id int64) (*models.Function
 	return result, finalErr
 }
 
+// Function updates record the calling API key (see middleware.APIKeyID) as
+// updated_by_key_id; updates outside API requests keep the previous one.
+
 // UpdateCodeKey updates the code_s3_key for a function
 func (s *DBService) UpdateCodeKey(ctx context.Context, id int64, codeKey string) error {
 	_, err := s.db.ExecContext(ctx, `
-		UPDATE functions SET code_s3_key = $2, updated_at = now() WHERE id = $1
-	`, id, codeKey)
+		UPDATE functions SET code_s3_key = $2, updated_by_key_id = COALESCE($3, updated_by_key_id), updated_at = now()
+		WHERE id = $1 AND `+inNamespace("namespace_id", 4)+`
+	`, id, codeKey, middleware.APIKeyID(ctx), requestNamespace(ctx))
 	return err
 }
 
+// UpdateFunctionConcurrency updates the concurrency settings for a function
+func (s *DBService) UpdateFunctionConcurrency(ctx context.Context, id int64, maxConcurrency, reservedConcurrency int, overflowPolicy string) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE functions
+		SET max_concurrency = $2, reserved_concurrency = $3, overflow_policy = $4,
+			updated_by_key_id = COALESCE($5, updated_by_key_id), updated_at = now()
+		WHERE id = $1 AND `+inNamespace("namespace_id", 6)+`
+	`, id, maxConcurrency, reservedConcurrency, overflowPolicy, middleware.APIKeyID(ctx), requestNamespace(ctx))
+	return err
+}
+
+// UpdateFunctionCallback sets the default completion callback of a function
+func (s *DBService) UpdateFunctionCallback(ctx context.Context, id int64, callbackURL, callbackSecret string) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE functions
+		SET callback_url = NULLIF($2, ''), callback_secret = NULLIF($3, ''),
+			updated_by_key_id = COALESCE($4, updated_by_key_id), updated_at = now()
+		WHERE id = $1 AND `+inNamespace("namespace_id", 5)+`
+	`, id, callbackURL, callbackSecret, middleware.APIKeyID(ctx), requestNamespace(ctx))
+	return err
+}
+
+// ReservedConcurrencyByRuntime returns the sum of reserved concurrency per runtime,
+// excluding the given function (pass 0 to include all functions). Runtime
+// capacity is shared, so this counts every namespace.
+func (s *DBService) ReservedConcurrencyByRuntime(ctx context.Context, excludeFunctionID int64) (map[string]int, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT runtime, COALESCE(SUM(reserved_concurrency), 0)
+		FROM functions
+		WHERE reserved_concurrency > 0 AND id <> $1
+		GROUP BY runtime
+	`, excludeFunctionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reserved := make(map[string]int)
+	for rows.Next() {
+		var runtime string
+		var sum int
+		if err := rows.Scan(&runtime, &sum); err != nil {
+			return nil, err
+		}
+		reserved[runtime] = sum
+	}
+
+	return reserved, nil
+}
+
 // DeleteFunction removes a function record (cascades to params/invocations)
 func (s *DBService) DeleteFunction(ctx context.Context, id int64) (*models.Function, error) {
 	fn, err := s.GetFunction(ctx, id)
@@ -265,7 +271,7 @@ func (s *DBService) DeleteFunction(ctx context.Context, id int64) (*models.Funct
 		return nil, nil
 	}
 
-	_, err = s.db.ExecContext(ctx, `DELETE FROM functions WHERE id = $1`, id)
+	_, err = s.db.ExecContext(ctx, `DELETE FROM functions WHERE id = $1 AND `+inNamespace("namespace_id", 2), id, requestNamespace(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -273,28 +279,81 @@ func (s *DBService) DeleteFunction(ctx context.Context, id int64) (*models.Funct
 	return fn, nil
 }
 
-// ListFunctions returns all functions (without code)
-func (s *DBService) ListFunctions(ctx context.Context) ([]models.FunctionListItem, error) {
-	rows, err := s.db.QueryContext(ctx, `
-		SELECT id, name, description, runtime, created_at
-		FROM functions ORDER BY created_at DESC
-	`)
+func (f FunctionFilter) where(ctx context.Context) (string, []interface{}) {
+	conditions := []string{inNamespace("namespace_id", 1), functionVisible(2)}
+	args := []interface{}{requestNamespace(ctx), pq.Array(requestPrincipals(ctx))}
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(cond, len(args)))
+	}
+	if f.Runtime != "" {
+		add("runtime = $%d", f.Runtime)
+	}
+	if len(f.Tags) > 0 {
+		add("tags @> $%d", pq.Array(f.Tags))
+	}
+	if terms := f.searchTerms(); len(terms) > 0 {
+		// Every term matches the start of a word: "resi img" finds resize-image
+		add("search_vector @@ to_tsquery('simple', $%d)", strings.Join(terms, ":* & ")+":*")
+	}
+	return ` WHERE ` + strings.Join(conditions, " AND "), args
+}
+
+// ListFunctions returns a page of the functions (without code) matching the
+// filter that the caller may see, and the number of all of them. The summary
+// of the latest invocation and the count of the last 24 hours are read from
+// the newest invocation partitions only.
+func (s *DBService) ListFunctions(ctx context.Context, filter FunctionFilter) ([]models.FunctionListItem, int, error) {
+	where, args := filter.where(ctx)
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, `SELECT count(*) FROM functions`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	var limit interface{} // NULL is LIMIT ALL
+	if filter.Limit > 0 {
+		limit = filter.Limit
+	}
+	args = append(args, limit, filter.Offset)
+	n := len(args)
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT id, namespace_id, name, description, runtime, tags, is_public, COALESCE(owner, ''), created_at, updated_at,
+			last.invoked_at AS last_invoked_at, last.status,
+			(SELECT count(*) FROM function_invocations i
+			 WHERE i.function_id = functions.id AND i.invoked_at >= now() - interval '24 hours')
+		FROM functions
+		LEFT JOIN LATERAL (
+			SELECT invoked_at, status FROM function_invocations i
+			WHERE i.function_id = functions.id
+			ORDER BY invoked_at DESC, id DESC
+			LIMIT 1
+		) last ON true%s%s
+		LIMIT $%d OFFSET $%d
+	`, where, filter.orderBy(), n-1, n), args...)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
 	var functions []models.FunctionListItem
 	for rows.Next() {
 		var fn models.FunctionListItem
-		err := rows.Scan(&fn.ID, &fn.Name, &fn.Description, &fn.Runtime, &fn.CreatedAt)
+		var lastInvokedAt sql.NullTime
+		var lastStatus sql.NullString
+		err := rows.Scan(&fn.ID, &fn.NamespaceID, &fn.Name, &fn.Description, &fn.Runtime, pq.Array(&fn.Tags), &fn.IsPublic, &fn.Owner, &fn.CreatedAt, &fn.UpdatedAt,
+			&lastInvokedAt, &lastStatus, &fn.Invocations24h)
 		if err != nil {
-			return nil, err
+			return nil, 0, err
 		}
+		if lastInvokedAt.Valid {
+			fn.LastInvokedAt = &lastInvokedAt.Time
+		}
+		fn.LastInvocationStatus = lastStatus.String
 		functions = append(functions, fn)
 	}
 
-	return functions, nil
+	return functions, total, rows.Err()
 }
 
 // CreateInvocation creates a new invocation record
@@ -308,10 +367,10 @@ func (s *DBService) CreateInvocation(ctx context.Context, inv *models.Invocation
 		var id int64
 		var invokedAt, createdAt time.Time
 		err := s.db.QueryRowContext(ctx, `
-			INSERT INTO function_invocations (function_id, invoked_by, input_event, status)
-			VALUES ($1, $2, $3, $4)
+			INSERT INTO function_invocations (function_id, invoked_by, input_event, status, callback_url, callback_secret, api_key_id, code_version, replay_of)
+			VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), $7, NULLIF($8, ''), $9)
 			RETURNING id, invoked_at, created_at
-		`, inv.FunctionID, inv.InvokedBy, inputEventJSON, inv.Status).Scan(&id, &invokedAt, &createdAt)
+		`, inv.FunctionID, inv.InvokedBy, inputEventJSON, inv.Status, inv.CallbackURL, inv.CallbackSecret, inv.APIKeyID, inv.CodeVersion, inv.ReplayOf).Scan(&id, &invokedAt, &createdAt)
 		if err != nil {
 			finalErr = err
 			return err
@@ -337,18 +396,25 @@ func (s *DBService) CreateInvocation(ctx context.Context, inv *models.Invocation
 	return result, finalErr
 }
 
-// UpdateInvocationResult updates the invocation with execution result
-func (s *DBService) UpdateInvocationResult(ctx context.Context, id int64, status string, outputResult map[string]interface{}, errorMessage string, durationMs int) error {
+// UpdateInvocationResult updates a pending invocation with execution result.
+// It reports whether the row was still pending, so callers can run completion
+// side effects (releasing concurrency slots, ...) exactly once.
+func (s *DBService) UpdateInvocationResult(ctx context.Context, id int64, status string, outputResult map[string]interface{}, errorMessage string, durationMs int) (bool, error) {
+	var updated bool
 	var finalErr error
 
 	xray.Capture(ctx, "DB.UpdateInvocationResult", func(ctx1 context.Context) error {
 		outputJSON, _ := json.Marshal(outputResult)
 
-		_, err := s.db.ExecContext(ctx, `
+		res, err := s.db.ExecContext(ctx, `
 			UPDATE function_invocations
 			SET status = $2, output_result = $3, error_message = $4, duration_ms = $5
-			WHERE id = $1
+			WHERE `+invocationByID(1)+` AND status = 'pending'
 		`, id, status, outputJSON, errorMessage, durationMs)
+		if err == nil {
+			affected, _ := res.RowsAffected()
+			updated = affected > 0
+		}
 
 		finalErr = err
 
@@ -363,20 +429,69 @@ func (s *DBService) UpdateInvocationResult(ctx context.Context, id int64, status
 		return err
 	})
 
-	return finalErr
+	return updated, finalErr
+}
+
+// SetInvocationStatus sets the status of a pending invocation without a result.
+// It reports whether the invocation was still pending.
+func (s *DBService) SetInvocationStatus(ctx context.Context, id int64, status, errorMessage string) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE function_invocations
+		SET status = $2, error_message = $3
+		WHERE `+invocationByID(1)+` AND status = 'pending' AND `+functionInNamespace("function_id", 4)+`
+	`, id, status, errorMessage, requestNamespace(ctx))
+	if err != nil {
+		return false, err
+	}
+	affected, _ := res.RowsAffected()
+	return affected > 0, nil
+}
+
+// ListPendingInvocations returns the oldest pending invocations after a
+// position in (invoked_at, id) order, so callers can page through them all
+func (s *DBService) ListPendingInvocations(ctx context.Context, after InvocationCursor, limit int) ([]models.Invocation, error) {
+	// The plain bound on invoked_at prunes the older partitions
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, function_id, invoked_at, COALESCE(invoked_by, ''), batch_id
+		FROM function_invocations
+		WHERE status = 'pending' AND invoked_at >= $1 AND (invoked_at, id) > ($1, $2)
+		ORDER BY invoked_at, id
+		LIMIT $3
+	`, after.InvokedAt, after.ID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var invocations []models.Invocation
+	for rows.Next() {
+		var inv models.Invocation
+		var batchID sql.NullInt64
+		if err := rows.Scan(&inv.ID, &inv.FunctionID, &inv.InvokedAt, &inv.InvokedBy, &batchID); err != nil {
+			return nil, err
+		}
+		if batchID.Valid {
+			inv.BatchID = &batchID.Int64
+		}
+		inv.Status = models.StatusPending
+		invocations = append(invocations, inv)
+	}
+
+	return invocations, nil
 }
 
 // GetInvocation retrieves an invocation by ID
 func (s *DBService) GetInvocation(ctx context.Context, id int64) (*models.Invocation, error) {
 	inv := &models.Invocation{}
 	var inputEventJSON, outputResultJSON []byte
-	var errorMessage, invokedBy, containerID sql.NullString
+	var errorMessage, invokedBy, containerID, codeVersion sql.NullString
 	var durationMs sql.NullInt32
+	var batchID, apiKeyID, replayOf sql.NullInt64
 
 	err := s.db.QueryRowContext(ctx, `
-		SELECT id, function_id, invoked_at, invoked_by, input_event, status, output_result, error_message, duration_ms, container_id, created_at
-		FROM function_invocations WHERE id = $1
-	`, id).Scan(&inv.ID, &inv.FunctionID, &inv.InvokedAt, &invokedBy, &inputEventJSON, &inv.Status, &outputResultJSON, &errorMessage, &durationMs, &containerID, &inv.CreatedAt)
+		SELECT id, function_id, invoked_at, invoked_by, input_event, status, output_result, error_message, duration_ms, container_id, batch_id, api_key_id, created_at, code_version, replay_of
+		FROM function_invocations WHERE `+invocationByID(1)+` AND `+functionInNamespace("function_id", 2)+`
+	`, id, requestNamespace(ctx)).Scan(&inv.ID, &inv.FunctionID, &inv.InvokedAt, &invokedBy, &inputEventJSON, &inv.Status, &outputResultJSON, &errorMessage, &durationMs, &containerID, &batchID, &apiKeyID, &inv.CreatedAt, &codeVersion, &replayOf)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -402,23 +517,89 @@ func (s *DBService) GetInvocation(ctx context.Context, id int64) (*models.Invoca
 	if durationMs.Valid {
 		inv.DurationMs = int(durationMs.Int32)
 	}
+	if batchID.Valid {
+		inv.BatchID = &batchID.Int64
+	}
+	if apiKeyID.Valid {
+		inv.APIKeyID = &apiKeyID.Int64
+	}
+	if codeVersion.Valid {
+		inv.CodeVersion = codeVersion.String
+	}
+	if replayOf.Valid {
+		inv.ReplayOf = &replayOf.Int64
+	}
 
 	return inv, nil
 }
 
-// ListInvocations returns invocations for a function
-func (s *DBService) ListInvocations(ctx context.Context, functionID int64, limit int) ([]models.InvocationListItem, error) {
+func (f InvocationFilter) where(ctx context.Context) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(cond, len(args)))
+	}
+	args = append(args, requestNamespace(ctx))
+	conditions = append(conditions, functionInNamespace("function_id", 1))
+	if f.FunctionID != 0 {
+		add("function_id = $%d", f.FunctionID)
+	} else {
+		args = append(args, pq.Array(requestPrincipals(ctx)))
+		conditions = append(conditions, "function_id IN (SELECT id FROM functions WHERE "+functionVisible(len(args))+")")
+	}
+	if len(f.Statuses) > 0 {
+		add("status = ANY($%d)", pq.Array(f.Statuses))
+	}
+	if f.InvokedBy != "" {
+		add("invoked_by = $%d", f.InvokedBy)
+	}
+	if f.ReplayOf != 0 {
+		add("replay_of = $%d", f.ReplayOf)
+	}
+	if !f.From.IsZero() {
+		add("invoked_at >= $%d", f.From)
+	}
+	if !f.To.IsZero() {
+		add("invoked_at < $%d", f.To)
+	}
+	if f.MinDurationMs > 0 {
+		add("duration_ms >= $%d", f.MinDurationMs)
+	}
+	if f.MaxDurationMs > 0 {
+		add("duration_ms <= $%d", f.MaxDurationMs)
+	}
+	if f.Input != nil {
+		input, _ := json.Marshal(f.Input)
+		add("input_event @> $%d::jsonb", string(input))
+	}
+	if f.Output != nil {
+		output, _ := json.Marshal(f.Output)
+		add("output_result @> $%d::jsonb", string(output))
+	}
+	if f.After != nil {
+		// The plain bound on invoked_at prunes the newer partitions
+		args = append(args, f.After.InvokedAt, f.After.ID)
+		conditions = append(conditions, fmt.Sprintf(`invoked_at <= $%[1]d AND (invoked_at, id) < ($%[1]d, $%[2]d)`, len(args)-1, len(args)))
+	}
+	return ` WHERE ` + strings.Join(conditions, " AND "), args
+}
+
+// ListInvocations returns the latest invocations matching the filter. Ordering
+// by the partition key, Postgres reads the newest partitions first and stops
+// once it has found limit rows.
+func (s *DBService) ListInvocations(ctx context.Context, filter InvocationFilter, limit int) ([]models.InvocationListItem, error) {
 	if limit <= 0 {
 		limit = 20
 	}
 
+	where, args := filter.where(ctx)
+	args = append(args, limit)
 	rows, err := s.db.QueryContext(ctx, `
-		SELECT id, function_id, invoked_at, input_event, status, output_result, error_message, duration_ms
-		FROM function_invocations
-		WHERE function_id = $1
-		ORDER BY invoked_at DESC
-		LIMIT $2
-	`, functionID, limit)
+		SELECT id, function_id, invoked_at, input_event, status, output_result, error_message, duration_ms, replay_of
+		FROM function_invocations`+where+`
+		ORDER BY invoked_at DESC, id DESC
+		`+fmt.Sprintf(`LIMIT $%d`, len(args)), args...)
 	if err != nil {
 		return nil, err
 	}
@@ -430,8 +611,9 @@ func (s *DBService) ListInvocations(ctx context.Context, functionID int64, limit
 		var inputEventJSON, outputResultJSON []byte
 		var errorMessage sql.NullString
 		var durationMs sql.NullInt32
+		var replayOf sql.NullInt64
 
-		err := rows.Scan(&inv.ID, &inv.FunctionID, &inv.InvokedAt, &inputEventJSON, &inv.Status, &outputResultJSON, &errorMessage, &durationMs)
+		err := rows.Scan(&inv.ID, &inv.FunctionID, &inv.InvokedAt, &inputEventJSON, &inv.Status, &outputResultJSON, &errorMessage, &durationMs, &replayOf)
 		if err != nil {
 			return nil, err
 		}
@@ -448,6 +630,9 @@ func (s *DBService) ListInvocations(ctx context.Context, functionID int64, limit
 		if durationMs.Valid {
 			inv.DurationMs = int(durationMs.Int32)
 		}
+		if replayOf.Valid {
+			inv.ReplayOf = &replayOf.Int64
+		}
 
 		invocations = append(invocations, inv)
 	}
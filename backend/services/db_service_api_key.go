@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+	"lambda-runner-server/models"
+)
+
+const apiKeyColumns = `id, name, prefix, COALESCE(user_name, ''), namespace_id, (SELECT name FROM namespaces n WHERE n.id = api_keys.namespace_id),
+	scopes, expires_at, last_used_at, revoked_at, created_by_key_id, created_at`
+
+func scanAPIKey(scanner interface{ Scan(...interface{}) error }) (*models.APIKey, error) {
+	var k models.APIKey
+	var scopes pq.StringArray
+	var expiresAt, lastUsedAt, revokedAt sql.NullTime
+	var createdByKeyID sql.NullInt64
+	err := scanner.Scan(&k.ID, &k.Name, &k.Prefix, &k.User, &k.NamespaceID, &k.Namespace, &scopes, &expiresAt, &lastUsedAt, &revokedAt, &createdByKeyID, &k.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	k.Scopes = []string(scopes)
+	if expiresAt.Valid {
+		k.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		k.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		k.RevokedAt = &revokedAt.Time
+	}
+	if createdByKeyID.Valid {
+		k.CreatedByKeyID = &createdByKeyID.Int64
+	}
+	return &k, nil
+}
+
+// CreateAPIKey inserts an API key identified by the hash of its secret
+func (s *DBService) CreateAPIKey(ctx context.Context, k *models.APIKey, keyHash string) (*models.APIKey, error) {
+	return scanAPIKey(s.db.QueryRowContext(ctx, `
+		INSERT INTO api_keys (name, prefix, user_name, namespace_id, key_hash, scopes, expires_at, created_by_key_id)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7, $8)
+		RETURNING `+apiKeyColumns,
+		k.Name, k.Prefix, k.User, k.NamespaceID, keyHash, pq.Array(k.Scopes), k.ExpiresAt, k.CreatedByKeyID))
+}
+
+// EnsureAPIKey inserts an API key into the default namespace unless one with
+// the same hash exists. It reports whether the key was inserted.
+func (s *DBService) EnsureAPIKey(ctx context.Context, k *models.APIKey, keyHash string) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `
+		INSERT INTO api_keys (name, prefix, namespace_id, key_hash, scopes)
+		SELECT $1, $2, id, $3, $4 FROM namespaces WHERE name = $5
+		ON CONFLICT (key_hash) DO NOTHING
+	`, k.Name, k.Prefix, keyHash, pq.Array(k.Scopes), models.DefaultNamespace)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// GetAPIKeyByHash returns the API key with the given hash, or nil
+func (s *DBService) GetAPIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	return scanAPIKey(s.db.QueryRowContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE key_hash = $1`, keyHash))
+}
+
+// ListAPIKeys returns all API keys, including revoked and expired ones
+func (s *DBService) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *k)
+	}
+	return keys, rows.Err()
+}
+
+// CountAPIKeys returns the number of API keys that are neither revoked nor expired
+func (s *DBService) CountAPIKeys(ctx context.Context) (int, error) {
+	var n int
+	err := s.db.QueryRowContext(ctx, `
+		SELECT count(*) FROM api_keys
+		WHERE revoked_at IS NULL AND (expires_at IS NULL OR expires_at > now())
+	`).Scan(&n)
+	return n, err
+}
+
+// RevokeAPIKey marks an API key as revoked; revoking twice keeps the first time.
+// It returns nil when the key does not exist.
+func (s *DBService) RevokeAPIKey(ctx context.Context, id int64) (*models.APIKey, error) {
+	return scanAPIKey(s.db.QueryRowContext(ctx, `
+		UPDATE api_keys SET revoked_at = COALESCE(revoked_at, now())
+		WHERE id = $1
+		RETURNING `+apiKeyColumns, id))
+}
+
+// TouchAPIKey records the use of an API key. To keep authenticated requests
+// from writing on every call, last_used_at is only advanced once a minute.
+func (s *DBService) TouchAPIKey(ctx context.Context, id int64) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE api_keys SET last_used_at = now()
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < now() - interval '1 minute')
+	`, id)
+	return err
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"lambda-runner-server/models"
+)
+
+const auditEventColumns = `id, namespace_id, actor, api_key_id, action, resource_type, resource_id,
+	before_state, after_state, request, created_at`
+
+func scanAuditEvent(scanner interface{ Scan(...interface{}) error }) (*models.AuditEvent, error) {
+	var e models.AuditEvent
+	var namespaceID, apiKeyID sql.NullInt64
+	var beforeJSON, afterJSON, requestJSON []byte
+	err := scanner.Scan(&e.ID, &namespaceID, &e.Actor, &apiKeyID, &e.Action, &e.ResourceType, &e.ResourceID,
+		&beforeJSON, &afterJSON, &requestJSON, &e.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if namespaceID.Valid {
+		e.NamespaceID = &namespaceID.Int64
+	}
+	if apiKeyID.Valid {
+		e.APIKeyID = &apiKeyID.Int64
+	}
+	if beforeJSON != nil {
+		json.Unmarshal(beforeJSON, &e.Before)
+	}
+	if afterJSON != nil {
+		json.Unmarshal(afterJSON, &e.After)
+	}
+	if requestJSON != nil {
+		json.Unmarshal(requestJSON, &e.Request)
+	}
+	return &e, nil
+}
+
+// InsertAuditEvent appends an event to the audit log
+func (s *DBService) InsertAuditEvent(ctx context.Context, e *models.AuditEvent) error {
+	var beforeJSON, afterJSON, requestJSON []byte
+	var err error
+	if e.Before != nil {
+		if beforeJSON, err = json.Marshal(e.Before); err != nil {
+			return err
+		}
+	}
+	if e.After != nil {
+		if afterJSON, err = json.Marshal(e.After); err != nil {
+			return err
+		}
+	}
+	if e.Request != nil {
+		if requestJSON, err = json.Marshal(e.Request); err != nil {
+			return err
+		}
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO audit_events (namespace_id, actor, api_key_id, action, resource_type, resource_id, before_state, after_state, request)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, e.NamespaceID, e.Actor, e.APIKeyID, e.Action, e.ResourceType, e.ResourceID, beforeJSON, afterJSON, requestJSON)
+	return err
+}
+
+// AuditFilter selects audit events; zero fields do not filter
+type AuditFilter struct {
+	Namespace    string
+	Actor        string
+	Action       string
+	ResourceType string
+	ResourceID   string
+	From         time.Time
+	To           time.Time
+	BeforeID     int64 // only events older than this one, for paging
+}
+
+func (f AuditFilter) where() (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(cond, len(args)))
+	}
+	if f.Namespace != "" {
+		add("namespace_id = (SELECT id FROM namespaces WHERE name = $%d)", f.Namespace)
+	}
+	if f.Actor != "" {
+		add("actor = $%d", f.Actor)
+	}
+	if f.Action != "" {
+		add("action = $%d", f.Action)
+	}
+	if f.ResourceType != "" {
+		add("resource_type = $%d", f.ResourceType)
+	}
+	if f.ResourceID != "" {
+		add("resource_id = $%d", f.ResourceID)
+	}
+	if !f.From.IsZero() {
+		add("created_at >= $%d", f.From)
+	}
+	if !f.To.IsZero() {
+		add("created_at < $%d", f.To)
+	}
+	if f.BeforeID > 0 {
+		add("id < $%d", f.BeforeID)
+	}
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return ` WHERE ` + strings.Join(conditions, " AND "), args
+}
+
+// ListAuditEvents returns the most recent audit events matching the filter
+func (s *DBService) ListAuditEvents(ctx context.Context, filter AuditFilter, limit int) ([]models.AuditEvent, error) {
+	where, args := filter.where()
+	args = append(args, limit)
+	rows, err := s.db.QueryContext(ctx, `SELECT `+auditEventColumns+` FROM audit_events`+where+
+		fmt.Sprintf(` ORDER BY id DESC LIMIT $%d`, len(args)), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []models.AuditEvent{}
+	for rows.Next() {
+		e, err := scanAuditEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, *e)
+	}
+	return events, rows.Err()
+}
+
+// EachAuditEvent calls fn for every audit event matching the filter, oldest
+// first, without holding them all in memory
+func (s *DBService) EachAuditEvent(ctx context.Context, filter AuditFilter, fn func(*models.AuditEvent) error) error {
+	where, args := filter.where()
+	rows, err := s.db.QueryContext(ctx, `SELECT `+auditEventColumns+` FROM audit_events`+where+` ORDER BY id`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e, err := scanAuditEvent(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
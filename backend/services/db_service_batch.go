@@ -0,0 +1,146 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"lambda-runner-server/models"
+)
+
+// CreateBatch inserts a batch and all of its invocations in one transaction,
+// so a batch is never visible with only part of its items
+func (s *DBService) CreateBatch(ctx context.Context, batch *models.InvocationBatch, invocations []*models.Invocation) (*models.InvocationBatch, error) {
+	var finalErr error
+
+	xray.Capture(ctx, "DB.CreateBatch", func(ctx1 context.Context) error {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			finalErr = err
+			return err
+		}
+		defer tx.Rollback()
+
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO invocation_batches (function_id, total, max_parallelism, priority, invoked_by)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id, created_at
+		`, batch.FunctionID, batch.Total, batch.MaxParallelism, batch.Priority, batch.InvokedBy).Scan(&batch.ID, &batch.CreatedAt)
+		if err != nil {
+			finalErr = err
+			return err
+		}
+
+		stmt, err := tx.PrepareContext(ctx, `
+			INSERT INTO function_invocations (function_id, invoked_by, input_event, status, batch_id, callback_url, callback_secret, api_key_id, code_version)
+			VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''), $8, NULLIF($9, ''))
+			RETURNING id, invoked_at, created_at
+		`)
+		if err != nil {
+			finalErr = err
+			return err
+		}
+		defer stmt.Close()
+
+		for _, inv := range invocations {
+			inputEventJSON, _ := json.Marshal(inv.InputEvent)
+			err := stmt.QueryRowContext(ctx, inv.FunctionID, inv.InvokedBy, inputEventJSON, inv.Status, batch.ID, inv.CallbackURL, inv.CallbackSecret, inv.APIKeyID, inv.CodeVersion).
+				Scan(&inv.ID, &inv.InvokedAt, &inv.CreatedAt)
+			if err != nil {
+				finalErr = err
+				return err
+			}
+			inv.BatchID = &batch.ID
+		}
+
+		if err := tx.Commit(); err != nil {
+			finalErr = err
+			return err
+		}
+
+		// Add metadata to subsegment
+		if seg := xray.GetSegment(ctx1); seg != nil {
+			seg.AddMetadata("db.operation", "INSERT")
+			seg.AddMetadata("db.table", "invocation_batches")
+			seg.AddMetadata("db.function_id", batch.FunctionID)
+			seg.AddMetadata("db.batch_size", len(invocations))
+		}
+
+		return nil
+	})
+
+	if finalErr != nil {
+		return nil, finalErr
+	}
+	return batch, nil
+}
+
+// GetBatch retrieves a batch by ID
+func (s *DBService) GetBatch(ctx context.Context, id int64) (*models.InvocationBatch, error) {
+	batch := &models.InvocationBatch{}
+	var invokedBy sql.NullString
+
+	err := s.db.QueryRowContext(ctx, `
+		SELECT id, function_id, total, max_parallelism, priority, invoked_by, created_at
+		FROM invocation_batches WHERE id = $1 AND `+functionInNamespace("function_id", 2)+`
+	`, id, requestNamespace(ctx)).Scan(&batch.ID, &batch.FunctionID, &batch.Total, &batch.MaxParallelism, &batch.Priority, &invokedBy, &batch.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if invokedBy.Valid {
+		batch.InvokedBy = invokedBy.String
+	}
+	return batch, nil
+}
+
+// ListBatchInvocations returns the invocations of a batch in submission order
+func (s *DBService) ListBatchInvocations(ctx context.Context, batchID int64) ([]models.InvocationListItem, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, function_id, invoked_at, input_event, status, output_result, error_message, duration_ms
+		FROM function_invocations
+		WHERE batch_id = $1 AND `+functionInNamespace("function_id", 2)+`
+			-- Batch invocations are created with their batch
+			AND invoked_at >= (SELECT created_at FROM invocation_batches WHERE id = $1)
+		ORDER BY id
+	`, batchID, requestNamespace(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invocations := []models.InvocationListItem{}
+	for rows.Next() {
+		var inv models.InvocationListItem
+		var inputEventJSON, outputResultJSON []byte
+		var errorMessage sql.NullString
+		var durationMs sql.NullInt32
+
+		err := rows.Scan(&inv.ID, &inv.FunctionID, &inv.InvokedAt, &inputEventJSON, &inv.Status, &outputResultJSON, &errorMessage, &durationMs)
+		if err != nil {
+			return nil, err
+		}
+
+		if inputEventJSON != nil {
+			json.Unmarshal(inputEventJSON, &inv.InputEvent)
+		}
+		if outputResultJSON != nil {
+			json.Unmarshal(outputResultJSON, &inv.OutputResult)
+		}
+		if errorMessage.Valid {
+			inv.ErrorMessage = errorMessage.String
+		}
+		if durationMs.Valid {
+			inv.DurationMs = int(durationMs.Int32)
+		}
+		inv.BatchID = &batchID
+
+		invocations = append(invocations, inv)
+	}
+
+	return invocations, rows.Err()
+}
@@ -0,0 +1,146 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"lambda-runner-server/models"
+)
+
+const callbackColumns = `id, invocation_id, url, COALESCE(secret, ''), status, attempts, next_attempt_at,
+	COALESCE(last_status_code, 0), COALESCE(last_error, ''), delivered_at, created_at`
+
+func scanCallback(scanner interface{ Scan(...interface{}) error }) (*models.InvocationCallback, error) {
+	var cb models.InvocationCallback
+	var deliveredAt sql.NullTime
+	err := scanner.Scan(&cb.ID, &cb.InvocationID, &cb.URL, &cb.Secret, &cb.Status, &cb.Attempts, &cb.NextAttemptAt,
+		&cb.LastStatusCode, &cb.LastError, &deliveredAt, &cb.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if deliveredAt.Valid {
+		cb.DeliveredAt = &deliveredAt.Time
+	}
+	return &cb, nil
+}
+
+// EnqueueCallback schedules delivery of an invocation's result when the
+// invocation or its function has a callback URL. It reports whether a
+// delivery was scheduled.
+func (s *DBService) EnqueueCallback(ctx context.Context, invocationID int64) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `
+		INSERT INTO invocation_callbacks (invocation_id, url, secret)
+		SELECT i.id, COALESCE(i.callback_url, f.callback_url),
+			CASE WHEN i.callback_url IS NOT NULL THEN i.callback_secret ELSE f.callback_secret END
+		FROM function_invocations i
+		JOIN functions f ON f.id = i.function_id
+		WHERE i.id = $1 AND i.invoked_at >= invocation_id_floor($1) AND COALESCE(i.callback_url, f.callback_url) IS NOT NULL
+		ON CONFLICT (invocation_id) DO NOTHING
+	`, invocationID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// ClaimDueCallbacks leases pending deliveries that are due. The lease pushes
+// next_attempt_at forward so other backend instances skip them meanwhile.
+func (s *DBService) ClaimDueCallbacks(ctx context.Context, limit int, lease time.Duration) ([]models.InvocationCallback, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		UPDATE invocation_callbacks
+		SET next_attempt_at = now() + $2 * interval '1 millisecond'
+		WHERE id IN (
+			SELECT id FROM invocation_callbacks
+			WHERE status = 'pending' AND next_attempt_at <= now()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+callbackColumns,
+		limit, lease.Milliseconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var callbacks []models.InvocationCallback
+	for rows.Next() {
+		cb, err := scanCallback(rows)
+		if err != nil {
+			return nil, err
+		}
+		callbacks = append(callbacks, *cb)
+	}
+	return callbacks, rows.Err()
+}
+
+// RecordCallbackAttempt logs a delivery attempt and moves the delivery to its
+// next state: delivered, failed, or pending again at nextAttemptAt
+func (s *DBService) RecordCallbackAttempt(ctx context.Context, attempt *models.CallbackAttempt, status string, nextAttemptAt time.Time) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO invocation_callback_attempts (callback_id, attempt, status_code, error, duration_ms)
+		VALUES ($1, $2, NULLIF($3, 0), NULLIF($4, ''), $5)
+	`, attempt.CallbackID, attempt.Attempt, attempt.StatusCode, attempt.Error, attempt.DurationMs)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE invocation_callbacks
+		SET status = $2, attempts = $3, next_attempt_at = $4,
+			last_status_code = NULLIF($5, 0), last_error = NULLIF($6, ''),
+			delivered_at = CASE WHEN $7 THEN now() ELSE delivered_at END
+		WHERE id = $1
+	`, attempt.CallbackID, status, attempt.Attempt, nextAttemptAt, attempt.StatusCode, attempt.Error, status == models.CallbackDelivered)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetInvocationCallback returns the callback delivery of an invocation with
+// its attempt log, or nil when the invocation has no callback
+func (s *DBService) GetInvocationCallback(ctx context.Context, invocationID int64) (*models.InvocationCallback, error) {
+	cb, err := scanCallback(s.db.QueryRowContext(ctx, `
+		SELECT `+callbackColumns+`
+		FROM invocation_callbacks
+		WHERE invocation_id = $1
+			AND invocation_id IN (SELECT id FROM function_invocations WHERE `+invocationByID(1)+` AND `+functionInNamespace("function_id", 2)+`)
+	`, invocationID, requestNamespace(ctx)))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, callback_id, attempt, COALESCE(status_code, 0), COALESCE(error, ''), duration_ms, attempted_at
+		FROM invocation_callback_attempts
+		WHERE callback_id = $1
+		ORDER BY attempt
+	`, cb.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cb.Log = []models.CallbackAttempt{}
+	for rows.Next() {
+		var a models.CallbackAttempt
+		if err := rows.Scan(&a.ID, &a.CallbackID, &a.Attempt, &a.StatusCode, &a.Error, &a.DurationMs, &a.AttemptedAt); err != nil {
+			return nil, err
+		}
+		cb.Log = append(cb.Log, a)
+	}
+	return cb, rows.Err()
+}
@@ -0,0 +1,313 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"lambda-runner-server/models"
+)
+
+const eventColumns = `seq, namespace_id, event_id, source, type, subject, specversion, time, datacontenttype, data, received_at`
+
+func scanEvent(scanner interface{ Scan(...interface{}) error }) (*models.Event, error) {
+	var e models.Event
+	var subject, dataContentType sql.NullString
+	var dataJSON []byte
+	err := scanner.Scan(&e.Seq, &e.NamespaceID, &e.ID, &e.Source, &e.Type, &subject, &e.SpecVersion, &e.Time, &dataContentType,
+		&dataJSON, &e.ReceivedAt)
+	if err != nil {
+		return nil, err
+	}
+	e.Subject = subject.String
+	e.DataContentType = dataContentType.String
+	if dataJSON != nil {
+		json.Unmarshal(dataJSON, &e.Data)
+	}
+	return &e, nil
+}
+
+// InsertEvent stores an event unless one with the same source and ID exists
+// in its namespace. It returns the stored event and whether this call inserted it.
+func (s *DBService) InsertEvent(ctx context.Context, e *models.Event) (*models.Event, bool, error) {
+	var dataJSON []byte
+	if e.Data != nil {
+		var err error
+		if dataJSON, err = json.Marshal(e.Data); err != nil {
+			return nil, false, err
+		}
+	}
+
+	inserted, err := scanEvent(s.db.QueryRowContext(ctx, `
+		INSERT INTO events (namespace_id, event_id, source, type, subject, specversion, time, datacontenttype, data)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, NULLIF($8, ''), $9)
+		ON CONFLICT (namespace_id, source, event_id) DO NOTHING
+		RETURNING `+eventColumns,
+		e.NamespaceID, e.ID, e.Source, e.Type, e.Subject, e.SpecVersion, e.Time, e.DataContentType, dataJSON))
+	if err == nil {
+		return inserted, true, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, false, err
+	}
+
+	existing, err := scanEvent(s.db.QueryRowContext(ctx, `
+		SELECT `+eventColumns+` FROM events WHERE namespace_id = $1 AND source = $2 AND event_id = $3
+	`, e.NamespaceID, e.Source, e.ID))
+	if err != nil {
+		return nil, false, err
+	}
+	return existing, false, nil
+}
+
+// GetEvent retrieves an event by sequence number
+func (s *DBService) GetEvent(ctx context.Context, seq int64) (*models.Event, error) {
+	e, err := scanEvent(s.db.QueryRowContext(ctx, `
+		SELECT `+eventColumns+` FROM events WHERE seq = $1 AND `+inNamespace("namespace_id", 2)+`
+	`, seq, requestNamespace(ctx)))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return e, err
+}
+
+// EventFilter selects stored events; zero fields do not filter
+type EventFilter struct {
+	Source string
+	Type   string
+	From   time.Time
+	To     time.Time
+}
+
+// ListEvents returns stored events matching the filter. newestFirst orders
+// them for browsing; otherwise they come in publish order for replay.
+func (s *DBService) ListEvents(ctx context.Context, filter EventFilter, newestFirst bool, limit int) ([]models.Event, error) {
+	var conditions []string
+	var args []interface{}
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(cond, len(args)))
+	}
+	if ns := requestNamespace(ctx); ns != 0 {
+		add("namespace_id = $%d", ns)
+	}
+	if filter.Source != "" {
+		add("source = $%d", filter.Source)
+	}
+	if filter.Type != "" {
+		add("type = $%d", filter.Type)
+	}
+	if !filter.From.IsZero() {
+		add("received_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		add("received_at < $%d", filter.To)
+	}
+
+	query := `SELECT ` + eventColumns + ` FROM events`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	if newestFirst {
+		query += ` ORDER BY seq DESC`
+	} else {
+		query += ` ORDER BY seq`
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(` LIMIT $%d`, len(args))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []models.Event{}
+	for rows.Next() {
+		e, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, *e)
+	}
+	return events, rows.Err()
+}
+
+const eventRuleSelect = `
+	SELECT r.id, r.namespace_id, r.name, r.description, r.pattern, r.enabled, r.created_at, r.updated_at,
+		COALESCE(array_agg(t.function_id ORDER BY t.function_id) FILTER (WHERE t.function_id IS NOT NULL), '{}')
+	FROM event_rules r
+	LEFT JOIN event_rule_targets t ON t.rule_id = r.id`
+
+func scanEventRule(scanner interface{ Scan(...interface{}) error }) (*models.EventRule, error) {
+	var r models.EventRule
+	var patternJSON []byte
+	var functionIDs pq.Int64Array
+	err := scanner.Scan(&r.ID, &r.NamespaceID, &r.Name, &r.Description, &patternJSON, &r.Enabled, &r.CreatedAt, &r.UpdatedAt, &functionIDs)
+	if err != nil {
+		return nil, err
+	}
+	json.Unmarshal(patternJSON, &r.Pattern)
+	r.FunctionIDs = []int64(functionIDs)
+	return &r, nil
+}
+
+func (s *DBService) listEventRules(ctx context.Context, where string, args ...interface{}) ([]models.EventRule, error) {
+	rows, err := s.db.QueryContext(ctx, eventRuleSelect+` `+where+` GROUP BY r.id ORDER BY r.id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []models.EventRule{}
+	for rows.Next() {
+		r, err := scanEventRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, *r)
+	}
+	return rules, rows.Err()
+}
+
+// ListEventRules returns event rules, optionally only the enabled ones
+func (s *DBService) ListEventRules(ctx context.Context, enabledOnly bool) ([]models.EventRule, error) {
+	if enabledOnly {
+		return s.listEventRules(ctx, `WHERE r.enabled AND `+inNamespace("r.namespace_id", 1), requestNamespace(ctx))
+	}
+	return s.listEventRules(ctx, `WHERE `+inNamespace("r.namespace_id", 1), requestNamespace(ctx))
+}
+
+func (s *DBService) getEventRule(ctx context.Context, where string, args ...interface{}) (*models.EventRule, error) {
+	rules, err := s.listEventRules(ctx, where, args...)
+	if err != nil || len(rules) == 0 {
+		return nil, err
+	}
+	return &rules[0], nil
+}
+
+// GetEventRule retrieves an event rule by ID
+func (s *DBService) GetEventRule(ctx context.Context, id int64) (*models.EventRule, error) {
+	return s.getEventRule(ctx, `WHERE r.id = $1 AND `+inNamespace("r.namespace_id", 2), id, requestNamespace(ctx))
+}
+
+// GetEventRuleByName retrieves an event rule by its name, which is unique within a namespace
+func (s *DBService) GetEventRuleByName(ctx context.Context, namespaceID int64, name string) (*models.EventRule, error) {
+	return s.getEventRule(ctx, `WHERE r.namespace_id = $1 AND r.name = $2`, namespaceID, name)
+}
+
+// SaveEventRule inserts a rule (ID 0) or updates one, replacing its targets
+// in the same transaction. It returns false when the rule to update is gone.
+func (s *DBService) SaveEventRule(ctx context.Context, r *models.EventRule) (bool, error) {
+	patternJSON, err := json.Marshal(r.Pattern)
+	if err != nil {
+		return false, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if r.ID == 0 {
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO event_rules (namespace_id, name, description, pattern, enabled)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id
+		`, r.NamespaceID, r.Name, r.Description, patternJSON, r.Enabled).Scan(&r.ID)
+		if err != nil {
+			return false, err
+		}
+	} else {
+		res, err := tx.ExecContext(ctx, `
+			UPDATE event_rules SET name = $2, description = $3, pattern = $4, enabled = $5, updated_at = now()
+			WHERE id = $1 AND `+inNamespace("namespace_id", 6)+`
+		`, r.ID, r.Name, r.Description, patternJSON, r.Enabled, requestNamespace(ctx))
+		if err != nil {
+			return false, err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return false, nil
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM event_rule_targets WHERE rule_id = $1`, r.ID); err != nil {
+			return false, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO event_rule_targets (rule_id, function_id)
+		SELECT $1, unnest($2::bigint[])
+		ON CONFLICT DO NOTHING
+	`, r.ID, pq.Array(r.FunctionIDs))
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// DeleteEventRule removes an event rule; its recorded matches are kept
+func (s *DBService) DeleteEventRule(ctx context.Context, id int64) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `
+		DELETE FROM event_rules WHERE id = $1 AND `+inNamespace("namespace_id", 2)+`
+	`, id, requestNamespace(ctx))
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+const eventMatchColumns = `id, event_seq, rule_id, function_id, invocation_id, status, error, replay, created_at`
+
+func scanEventMatch(scanner interface{ Scan(...interface{}) error }) (*models.EventMatch, error) {
+	var m models.EventMatch
+	var ruleID, invocationID sql.NullInt64
+	var errorMessage sql.NullString
+	err := scanner.Scan(&m.ID, &m.EventSeq, &ruleID, &m.FunctionID, &invocationID, &m.Status, &errorMessage, &m.Replay, &m.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if ruleID.Valid {
+		m.RuleID = &ruleID.Int64
+	}
+	if invocationID.Valid {
+		m.InvocationID = &invocationID.Int64
+	}
+	m.Error = errorMessage.String
+	return &m, nil
+}
+
+// CreateEventMatch records an event routed to a function
+func (s *DBService) CreateEventMatch(ctx context.Context, m *models.EventMatch) (*models.EventMatch, error) {
+	return scanEventMatch(s.db.QueryRowContext(ctx, `
+		INSERT INTO event_matches (event_seq, rule_id, function_id, invocation_id, status, error, replay)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7)
+		RETURNING `+eventMatchColumns,
+		m.EventSeq, m.RuleID, m.FunctionID, m.InvocationID, m.Status, m.Error, m.Replay))
+}
+
+// ListEventMatches returns the matches recorded for an event
+func (s *DBService) ListEventMatches(ctx context.Context, eventSeq int64) ([]models.EventMatch, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+eventMatchColumns+` FROM event_matches WHERE event_seq = $1 ORDER BY id
+	`, eventSeq)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	matches := []models.EventMatch{}
+	for rows.Next() {
+		m, err := scanEventMatch(rows)
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, *m)
+	}
+	return matches, rows.Err()
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+	"lambda-runner-server/middleware"
+	"lambda-runner-server/models"
+)
+
+// functionVisible is an SQL condition limiting functions to those the
+// principals passed as parameter $n may see; a NULL parameter sees every function
+func functionVisible(n int) string {
+	return fmt.Sprintf(`($%[1]d::text[] IS NULL OR is_public OR owner IS NULL OR owner = ANY($%[1]d::text[])
+		OR id IN (SELECT function_id FROM function_grants WHERE principal = ANY($%[1]d::text[])))`, n)
+}
+
+const functionGrantColumns = `id, function_id, principal, access, granted_by_key_id, created_at`
+
+func scanFunctionGrant(scanner interface{ Scan(...interface{}) error }) (*models.FunctionGrant, error) {
+	var g models.FunctionGrant
+	var grantedByKeyID sql.NullInt64
+	err := scanner.Scan(&g.ID, &g.FunctionID, &g.Principal, &g.Access, &grantedByKeyID, &g.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if grantedByKeyID.Valid {
+		g.GrantedByKeyID = &grantedByKeyID.Int64
+	}
+	return &g, nil
+}
+
+// SaveFunctionGrant shares a function with a principal, replacing the
+// access level of an existing grant to the same principal
+func (s *DBService) SaveFunctionGrant(ctx context.Context, g *models.FunctionGrant) (*models.FunctionGrant, error) {
+	return scanFunctionGrant(s.db.QueryRowContext(ctx, `
+		INSERT INTO function_grants (function_id, principal, access, granted_by_key_id)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (function_id, principal) DO UPDATE
+		SET access = EXCLUDED.access, granted_by_key_id = EXCLUDED.granted_by_key_id
+		RETURNING `+functionGrantColumns,
+		g.FunctionID, g.Principal, g.Access, middleware.APIKeyID(ctx)))
+}
+
+// ListFunctionGrants returns the grants of a function
+func (s *DBService) ListFunctionGrants(ctx context.Context, functionID int64) ([]models.FunctionGrant, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+functionGrantColumns+` FROM function_grants
+		WHERE function_id = $1 AND `+functionInNamespace("function_id", 2)+`
+		ORDER BY id
+	`, functionID, requestNamespace(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	grants := []models.FunctionGrant{}
+	for rows.Next() {
+		g, err := scanFunctionGrant(rows)
+		if err != nil {
+			return nil, err
+		}
+		grants = append(grants, *g)
+	}
+	return grants, rows.Err()
+}
+
+// FunctionGrantLevels returns the access levels a function is granted to any of the principals
+func (s *DBService) FunctionGrantLevels(ctx context.Context, functionID int64, principals []string) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT access FROM function_grants WHERE function_id = $1 AND principal = ANY($2)
+	`, functionID, pq.Array(principals))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var levels []string
+	for rows.Next() {
+		var level string
+		if err := rows.Scan(&level); err != nil {
+			return nil, err
+		}
+		levels = append(levels, level)
+	}
+	return levels, rows.Err()
+}
+
+// DeleteFunctionGrant removes a grant of a function
+func (s *DBService) DeleteFunctionGrant(ctx context.Context, functionID, grantID int64) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `
+		DELETE FROM function_grants WHERE id = $1 AND function_id = $2 AND `+functionInNamespace("function_id", 3)+`
+	`, grantID, functionID, requestNamespace(ctx))
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// UpdateFunctionTags replaces the tags of a function
+func (s *DBService) UpdateFunctionTags(ctx context.Context, id int64, tags []string) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE functions SET tags = $2, updated_by_key_id = COALESCE($3, updated_by_key_id), updated_at = now()
+		WHERE id = $1 AND `+inNamespace("namespace_id", 4)+`
+	`, id, pq.Array(tags), middleware.APIKeyID(ctx), requestNamespace(ctx))
+	return err
+}
+
+// UpdateFunctionVisibility makes a function public or private
+func (s *DBService) UpdateFunctionVisibility(ctx context.Context, id int64, isPublic bool) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE functions SET is_public = $2, updated_by_key_id = COALESCE($3, updated_by_key_id), updated_at = now()
+		WHERE id = $1 AND `+inNamespace("namespace_id", 4)+`
+	`, id, isPublic, middleware.APIKeyID(ctx), requestNamespace(ctx))
+	return err
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+
+	"lambda-runner-server/models"
+)
+
+const httpRouteColumns = `id, function_id, slug, auth_type, COALESCE(token_hash, ''), timeout_seconds, created_at, updated_at`
+
+func scanHTTPRoute(scanner interface{ Scan(...interface{}) error }) (*models.HTTPRoute, error) {
+	var route models.HTTPRoute
+	err := scanner.Scan(&route.ID, &route.FunctionID, &route.Slug, &route.AuthType, &route.TokenHash,
+		&route.TimeoutSeconds, &route.CreatedAt, &route.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &route, nil
+}
+
+// UpsertHTTPRoute creates or replaces the HTTP route of a function
+func (s *DBService) UpsertHTTPRoute(ctx context.Context, route *models.HTTPRoute) (*models.HTTPRoute, error) {
+	return scanHTTPRoute(s.db.QueryRowContext(ctx, `
+		INSERT INTO http_routes (function_id, slug, auth_type, token_hash, timeout_seconds)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5)
+		ON CONFLICT (function_id) DO UPDATE SET
+			slug = EXCLUDED.slug,
+			auth_type = EXCLUDED.auth_type,
+			token_hash = EXCLUDED.token_hash,
+			timeout_seconds = EXCLUDED.timeout_seconds,
+			updated_at = now()
+		RETURNING `+httpRouteColumns,
+		route.FunctionID, route.Slug, route.AuthType, route.TokenHash, route.TimeoutSeconds))
+}
+
+// GetHTTPRouteByFunction returns the HTTP route of a function, or nil
+func (s *DBService) GetHTTPRouteByFunction(ctx context.Context, functionID int64) (*models.HTTPRoute, error) {
+	return scanHTTPRoute(s.db.QueryRowContext(ctx, `
+		SELECT `+httpRouteColumns+` FROM http_routes WHERE function_id = $1 AND `+functionInNamespace("function_id", 2)+`
+	`, functionID, requestNamespace(ctx)))
+}
+
+// GetHTTPRouteBySlug returns the HTTP route with the given slug, or nil
+func (s *DBService) GetHTTPRouteBySlug(ctx context.Context, slug string) (*models.HTTPRoute, error) {
+	return scanHTTPRoute(s.db.QueryRowContext(ctx, `
+		SELECT `+httpRouteColumns+` FROM http_routes WHERE slug = $1
+	`, slug))
+}
+
+// DeleteHTTPRoute removes the HTTP route of a function
+func (s *DBService) DeleteHTTPRoute(ctx context.Context, functionID int64) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `
+		DELETE FROM http_routes WHERE function_id = $1 AND `+functionInNamespace("function_id", 2)+`
+	`, functionID, requestNamespace(ctx))
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
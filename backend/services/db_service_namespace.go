@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"lambda-runner-server/middleware"
+	"lambda-runner-server/models"
+)
+
+// Queries made on behalf of an API request are confined to the request's
+// namespace (see middleware.NamespaceAccess). Background work such as
+// runners and triggers carries no namespace and sees all of them.
+
+// requestNamespace returns the namespace ID a request is confined to, or 0
+func requestNamespace(ctx context.Context) int64 {
+	return middleware.NamespaceID(ctx)
+}
+
+// inNamespace is an SQL condition confining column, a namespace ID, to the
+// namespace passed as parameter $n; a 0 parameter matches every namespace
+func inNamespace(column string, n int) string {
+	return fmt.Sprintf(`($%[1]d::bigint = 0 OR %[2]s = $%[1]d::bigint)`, n, column)
+}
+
+// functionInNamespace is like inNamespace for a column holding a function ID
+func functionInNamespace(column string, n int) string {
+	return fmt.Sprintf(`($%[1]d::bigint = 0 OR %[2]s IN (SELECT id FROM functions WHERE namespace_id = $%[1]d::bigint))`, n, column)
+}
+
+const namespaceColumns = `id, name, description, max_functions, created_at, updated_at`
+
+func scanNamespace(scanner interface{ Scan(...interface{}) error }) (*models.Namespace, error) {
+	var ns models.Namespace
+	err := scanner.Scan(&ns.ID, &ns.Name, &ns.Description, &ns.MaxFunctions, &ns.CreatedAt, &ns.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ns, nil
+}
+
+// CreateNamespace inserts a namespace
+func (s *DBService) CreateNamespace(ctx context.Context, ns *models.Namespace) (*models.Namespace, error) {
+	return scanNamespace(s.db.QueryRowContext(ctx, `
+		INSERT INTO namespaces (name, description, max_functions)
+		VALUES ($1, $2, $3)
+		RETURNING `+namespaceColumns,
+		ns.Name, ns.Description, ns.MaxFunctions))
+}
+
+// GetNamespace returns a namespace by ID, or nil
+func (s *DBService) GetNamespace(ctx context.Context, id int64) (*models.Namespace, error) {
+	return scanNamespace(s.db.QueryRowContext(ctx, `SELECT `+namespaceColumns+` FROM namespaces WHERE id = $1`, id))
+}
+
+// GetNamespaceByName returns a namespace by its unique name, or nil
+func (s *DBService) GetNamespaceByName(ctx context.Context, name string) (*models.Namespace, error) {
+	return scanNamespace(s.db.QueryRowContext(ctx, `SELECT `+namespaceColumns+` FROM namespaces WHERE name = $1`, name))
+}
+
+// ListNamespaces returns all namespaces
+func (s *DBService) ListNamespaces(ctx context.Context) ([]models.Namespace, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+namespaceColumns+` FROM namespaces ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	namespaces := []models.Namespace{}
+	for rows.Next() {
+		ns, err := scanNamespace(rows)
+		if err != nil {
+			return nil, err
+		}
+		namespaces = append(namespaces, *ns)
+	}
+	return namespaces, rows.Err()
+}
+
+// UpdateNamespace updates the description and quotas of a namespace
+func (s *DBService) UpdateNamespace(ctx context.Context, ns *models.Namespace) (*models.Namespace, error) {
+	return scanNamespace(s.db.QueryRowContext(ctx, `
+		UPDATE namespaces SET description = $2, max_functions = $3, updated_at = now()
+		WHERE id = $1
+		RETURNING `+namespaceColumns,
+		ns.ID, ns.Description, ns.MaxFunctions))
+}
+
+// DeleteNamespace removes a namespace together with its API keys, events and
+// event rules. It fails while functions or workflows still belong to it.
+func (s *DBService) DeleteNamespace(ctx context.Context, id int64) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM namespaces WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// CountNamespaceResources returns the number of functions and workflows in a namespace
+func (s *DBService) CountNamespaceResources(ctx context.Context, id int64) (functions, workflows int, err error) {
+	err = s.db.QueryRowContext(ctx, `
+		SELECT (SELECT count(*) FROM functions WHERE namespace_id = $1),
+			(SELECT count(*) FROM workflows WHERE namespace_id = $1)
+	`, id).Scan(&functions, &workflows)
+	return functions, workflows, err
+}
+
+// FunctionNamespace returns the name of the namespace a function belongs to,
+// or "" when the function does not exist
+func (s *DBService) FunctionNamespace(ctx context.Context, functionID int64) (string, error) {
+	var name string
+	err := s.db.QueryRowContext(ctx, `
+		SELECT n.name FROM functions f JOIN namespaces n ON n.id = f.namespace_id WHERE f.id = $1
+	`, functionID).Scan(&name)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return name, err
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"lambda-runner-server/models"
+)
+
+// invocationByID returns a condition matching the invocation whose ID is
+// parameter $n. Bounding invoked_at by the ID lets Postgres skip the
+// partitions that cannot hold the invocation.
+func invocationByID(n int) string {
+	return fmt.Sprintf(`id = $%[1]d AND invoked_at >= invocation_id_floor($%[1]d)`, n)
+}
+
+// MaintainInvocationPartitions creates the partitions of function_invocations
+// for the current and the next monthsAhead months and returns the names of the
+// partitions it created
+func (s *DBService) MaintainInvocationPartitions(ctx context.Context, monthsAhead int) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT maintain_invocation_partitions($1)`, monthsAhead)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var created []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		created = append(created, name)
+	}
+	return created, rows.Err()
+}
+
+// ListInvocationPartitions returns the partitions of function_invocations, oldest first
+func (s *DBService) ListInvocationPartitions(ctx context.Context) ([]models.InvocationPartition, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT p.name, p.starts_at, p.ends_at, p.first_id,
+			GREATEST(c.reltuples, 0)::bigint, pg_total_relation_size(c.oid)
+		FROM invocation_partitions p
+		JOIN pg_class c ON c.oid = to_regclass(p.name)
+		ORDER BY p.starts_at
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	partitions := []models.InvocationPartition{}
+	for rows.Next() {
+		var p models.InvocationPartition
+		var firstID sql.NullInt64
+		if err := rows.Scan(&p.Name, &p.StartsAt, &p.EndsAt, &firstID, &p.EstimatedRows, &p.TotalBytes); err != nil {
+			return nil, err
+		}
+		if firstID.Valid {
+			p.FirstID = &firstID.Int64
+		}
+		partitions = append(partitions, p)
+	}
+	return partitions, rows.Err()
+}
+
+// InvocationPartitionEmpty reports whether a partition holds no invocations
+func (s *DBService) InvocationPartitionEmpty(ctx context.Context, name string) (bool, error) {
+	var empty bool
+	err := s.db.QueryRowContext(ctx, `SELECT NOT EXISTS (SELECT 1 FROM `+pq.QuoteIdentifier(name)+`)`).Scan(&empty)
+	return empty, err
+}
+
+// DropInvocationPartition drops a partition with all its invocations
+func (s *DBService) DropInvocationPartition(ctx context.Context, name string) error {
+	_, err := s.db.ExecContext(ctx, `SELECT drop_invocation_partition($1)`, name)
+	return err
+}
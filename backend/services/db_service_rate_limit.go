@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+
+	"lambda-runner-server/models"
+)
+
+const rateLimitColumns = `id, scope, subject, requests_per_second, burst, daily_invocations, monthly_invocations,
+	daily_compute_seconds, monthly_compute_seconds, created_at, updated_at`
+
+func scanRateLimit(scanner interface{ Scan(...interface{}) error }) (*models.RateLimit, error) {
+	var limit models.RateLimit
+	err := scanner.Scan(&limit.ID, &limit.Scope, &limit.Subject, &limit.RequestsPerSecond, &limit.Burst,
+		&limit.DailyInvocations, &limit.MonthlyInvocations, &limit.DailyComputeSeconds, &limit.MonthlyComputeSeconds,
+		&limit.CreatedAt, &limit.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &limit, nil
+}
+
+// UpsertRateLimit creates or replaces the limit for a scope/subject pair
+func (s *DBService) UpsertRateLimit(ctx context.Context, limit *models.RateLimit) (*models.RateLimit, error) {
+	row := s.db.QueryRowContext(ctx, `
+		INSERT INTO rate_limits (scope, subject, requests_per_second, burst, daily_invocations, monthly_invocations,
+			daily_compute_seconds, monthly_compute_seconds)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (scope, subject) DO UPDATE SET
+			requests_per_second = EXCLUDED.requests_per_second,
+			burst = EXCLUDED.burst,
+			daily_invocations = EXCLUDED.daily_invocations,
+			monthly_invocations = EXCLUDED.monthly_invocations,
+			daily_compute_seconds = EXCLUDED.daily_compute_seconds,
+			monthly_compute_seconds = EXCLUDED.monthly_compute_seconds,
+			updated_at = now()
+		RETURNING `+rateLimitColumns,
+		limit.Scope, limit.Subject, limit.RequestsPerSecond, limit.Burst, limit.DailyInvocations, limit.MonthlyInvocations,
+		limit.DailyComputeSeconds, limit.MonthlyComputeSeconds)
+	return scanRateLimit(row)
+}
+
+// ListRateLimits returns all configured rate limits
+func (s *DBService) ListRateLimits(ctx context.Context) ([]models.RateLimit, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+rateLimitColumns+`
+		FROM rate_limits
+		ORDER BY scope, subject
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	limits := []models.RateLimit{}
+	for rows.Next() {
+		limit, err := scanRateLimit(rows)
+		if err != nil {
+			return nil, err
+		}
+		limits = append(limits, *limit)
+	}
+
+	return limits, nil
+}
+
+// GetRateLimit retrieves a rate limit by ID
+func (s *DBService) GetRateLimit(ctx context.Context, id int64) (*models.RateLimit, error) {
+	row := s.db.QueryRowContext(ctx, `
+		SELECT `+rateLimitColumns+`
+		FROM rate_limits WHERE id = $1
+	`, id)
+	limit, err := scanRateLimit(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return limit, err
+}
+
+// DeleteRateLimit removes a rate limit
+func (s *DBService) DeleteRateLimit(ctx context.Context, id int64) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM rate_limits WHERE id = $1`, id)
+	return err
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"lambda-runner-server/models"
+)
+
+const redisTriggerColumns = `id, function_id, source_type, source_key, consumer_group, batch_size, batch_window_ms,
+	enabled, created_at, updated_at`
+
+func scanRedisTrigger(scanner interface{ Scan(...interface{}) error }) (*models.RedisTrigger, error) {
+	var t models.RedisTrigger
+	err := scanner.Scan(&t.ID, &t.FunctionID, &t.SourceType, &t.SourceKey, &t.ConsumerGroup, &t.BatchSize,
+		&t.BatchWindowMs, &t.Enabled, &t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// CreateRedisTrigger inserts a Redis trigger. An empty consumer group defaults
+// to one derived from the trigger ID.
+func (s *DBService) CreateRedisTrigger(ctx context.Context, t *models.RedisTrigger) (*models.RedisTrigger, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	created, err := scanRedisTrigger(tx.QueryRowContext(ctx, `
+		INSERT INTO redis_triggers (function_id, source_type, source_key, consumer_group, batch_size, batch_window_ms)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+redisTriggerColumns,
+		t.FunctionID, t.SourceType, t.SourceKey, t.ConsumerGroup, t.BatchSize, t.BatchWindowMs))
+	if err != nil {
+		return nil, err
+	}
+
+	if created.ConsumerGroup == "" {
+		created.ConsumerGroup = fmt.Sprintf("softgate-trigger-%d", created.ID)
+		if _, err := tx.ExecContext(ctx, `UPDATE redis_triggers SET consumer_group = $2 WHERE id = $1`,
+			created.ID, created.ConsumerGroup); err != nil {
+			return nil, err
+		}
+	}
+
+	return created, tx.Commit()
+}
+
+func (s *DBService) listRedisTriggers(ctx context.Context, query string, args ...interface{}) ([]models.RedisTrigger, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	triggers := []models.RedisTrigger{}
+	for rows.Next() {
+		t, err := scanRedisTrigger(rows)
+		if err != nil {
+			return nil, err
+		}
+		triggers = append(triggers, *t)
+	}
+	return triggers, rows.Err()
+}
+
+// ListRedisTriggers returns the Redis triggers of a function
+func (s *DBService) ListRedisTriggers(ctx context.Context, functionID int64) ([]models.RedisTrigger, error) {
+	return s.listRedisTriggers(ctx, `
+		SELECT `+redisTriggerColumns+` FROM redis_triggers
+		WHERE function_id = $1 AND `+functionInNamespace("function_id", 2)+`
+		ORDER BY id
+	`, functionID, requestNamespace(ctx))
+}
+
+// ListEnabledRedisTriggers returns every enabled Redis trigger
+func (s *DBService) ListEnabledRedisTriggers(ctx context.Context) ([]models.RedisTrigger, error) {
+	return s.listRedisTriggers(ctx, `
+		SELECT `+redisTriggerColumns+` FROM redis_triggers WHERE enabled ORDER BY id
+	`)
+}
+
+// DeleteRedisTrigger removes a Redis trigger of a function
+func (s *DBService) DeleteRedisTrigger(ctx context.Context, functionID, id int64) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `
+		DELETE FROM redis_triggers WHERE id = $1 AND function_id = $2 AND `+functionInNamespace("function_id", 3)+`
+	`, id, functionID, requestNamespace(ctx))
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// GetRedisTriggerCheckpoint returns the last message a consumer group handed
+// to the function, or "" when it has not processed any
+func (s *DBService) GetRedisTriggerCheckpoint(ctx context.Context, triggerID int64, group string) (string, error) {
+	var id string
+	err := s.db.QueryRowContext(ctx, `
+		SELECT last_message_id FROM redis_trigger_group_checkpoints WHERE trigger_id = $1 AND consumer_group = $2
+	`, triggerID, group).Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return id, err
+}
+
+// SaveRedisTriggerCheckpoint records the last message a consumer group handed to the function
+func (s *DBService) SaveRedisTriggerCheckpoint(ctx context.Context, triggerID int64, group, messageID string) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO redis_trigger_group_checkpoints (trigger_id, consumer_group, last_message_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (trigger_id, consumer_group) DO UPDATE SET
+			last_message_id = EXCLUDED.last_message_id,
+			updated_at = now()
+	`, triggerID, group, messageID)
+	return err
+}
@@ -0,0 +1,242 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"lambda-runner-server/models"
+)
+
+const retentionPolicyColumns = `id, function_id, max_age_days, max_count, archive, created_at, updated_at`
+
+func scanRetentionPolicy(scanner interface{ Scan(...interface{}) error }) (*models.RetentionPolicy, error) {
+	var policy models.RetentionPolicy
+	var functionID sql.NullInt64
+	err := scanner.Scan(&policy.ID, &functionID, &policy.MaxAgeDays, &policy.MaxCount, &policy.Archive,
+		&policy.CreatedAt, &policy.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if functionID.Valid {
+		policy.FunctionID = &functionID.Int64
+	}
+	return &policy, nil
+}
+
+// UpsertRetentionPolicy creates or replaces the policy of a function, or the
+// global policy when the function ID is nil
+func (s *DBService) UpsertRetentionPolicy(ctx context.Context, policy *models.RetentionPolicy) (*models.RetentionPolicy, error) {
+	row := s.db.QueryRowContext(ctx, `
+		INSERT INTO retention_policies (function_id, max_age_days, max_count, archive)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT ((COALESCE(function_id, 0))) DO UPDATE SET
+			max_age_days = EXCLUDED.max_age_days,
+			max_count = EXCLUDED.max_count,
+			archive = EXCLUDED.archive,
+			updated_at = now()
+		RETURNING `+retentionPolicyColumns,
+		policy.FunctionID, policy.MaxAgeDays, policy.MaxCount, policy.Archive)
+	return scanRetentionPolicy(row)
+}
+
+// ListRetentionPolicies returns all retention policies, the global one first
+func (s *DBService) ListRetentionPolicies(ctx context.Context) ([]models.RetentionPolicy, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+retentionPolicyColumns+`
+		FROM retention_policies
+		ORDER BY function_id NULLS FIRST
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	policies := []models.RetentionPolicy{}
+	for rows.Next() {
+		policy, err := scanRetentionPolicy(rows)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, *policy)
+	}
+	return policies, rows.Err()
+}
+
+// GetRetentionPolicy retrieves a retention policy by ID
+func (s *DBService) GetRetentionPolicy(ctx context.Context, id int64) (*models.RetentionPolicy, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+retentionPolicyColumns+` FROM retention_policies WHERE id = $1`, id)
+	policy, err := scanRetentionPolicy(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return policy, err
+}
+
+// DeleteRetentionPolicy removes a retention policy
+func (s *DBService) DeleteRetentionPolicy(ctx context.Context, id int64) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM retention_policies WHERE id = $1`, id)
+	return err
+}
+
+// expiredInvocations returns the query selecting the IDs and times of up to limit
+// invocations the policy expires, oldest first. Pending invocations never
+// expire, but count towards MaxCount.
+func expiredInvocations(policy *models.RetentionPolicy, limit int) (string, []interface{}) {
+	var args []interface{}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	var scope string
+	if policy.FunctionID != nil {
+		scope = "function_id = " + arg(*policy.FunctionID)
+	} else {
+		scope = "function_id NOT IN (SELECT function_id FROM retention_policies WHERE function_id IS NOT NULL)"
+	}
+
+	from := "function_invocations"
+	var expired []string
+	if policy.MaxAgeDays > 0 {
+		expired = append(expired, "invoked_at < now() - make_interval(days => "+arg(policy.MaxAgeDays)+")")
+	}
+	if policy.MaxCount > 0 {
+		// Scope conditions on function_id are pushed into the window subquery
+		from = `(SELECT id, function_id, invoked_at, status,
+			row_number() OVER (PARTITION BY function_id ORDER BY id DESC) AS newest
+			FROM function_invocations) ranked`
+		expired = append(expired, "newest > "+arg(policy.MaxCount))
+	}
+	if len(expired) == 0 {
+		return "", nil
+	}
+
+	return fmt.Sprintf(`SELECT id, invoked_at FROM %s WHERE %s AND status <> '%s' AND (%s) ORDER BY id LIMIT %s`,
+		from, scope, models.StatusPending, strings.Join(expired, " OR "), arg(limit)), args
+}
+
+// PurgeExpiredInvocations deletes up to limit invocations expired by the
+// policy and returns how many were deleted. Rows locked by a concurrent purge
+// are skipped. When archive is set it receives the rows as JSON objects, without
+// callback secrets, before the delete commits; if it fails nothing is deleted.
+func (s *DBService) PurgeExpiredInvocations(ctx context.Context, policy *models.RetentionPolicy, limit int, archive func(rows []json.RawMessage) error) (int, error) {
+	expired, args := expiredInvocations(policy, limit)
+	if expired == "" {
+		return 0, nil
+	}
+
+	columns := "i.id, i.invoked_at, NULL"
+	if archive != nil {
+		columns = "i.id, i.invoked_at, to_jsonb(i) - 'callback_secret'"
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT `+columns+` FROM function_invocations i
+		WHERE (i.id, i.invoked_at) IN (`+expired+`)
+		ORDER BY i.id
+		FOR UPDATE SKIP LOCKED
+	`, args...)
+	if err != nil {
+		return 0, err
+	}
+	var ids []int64
+	var records []json.RawMessage
+	var oldest, newest time.Time
+	for rows.Next() {
+		var id int64
+		var invokedAt time.Time
+		var record []byte
+		if err := rows.Scan(&id, &invokedAt, &record); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+		records = append(records, record)
+		if oldest.IsZero() || invokedAt.Before(oldest) {
+			oldest = invokedAt
+		}
+		if invokedAt.After(newest) {
+			newest = invokedAt
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	if archive != nil {
+		if err := archive(records); err != nil {
+			return 0, err
+		}
+	}
+
+	// The time range limits the delete to the partitions holding the rows
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM function_invocations WHERE id = ANY($1) AND invoked_at BETWEEN $2 AND $3
+	`, pq.Array(ids), oldest, newest); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
+
+// ListTableSizes returns the disk usage of every table in the schema, largest
+// first. Partitioned tables include their partitions. Row counts are the
+// planner's estimates.
+func (s *DBService) ListTableSizes(ctx context.Context) ([]models.TableSize, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT c.relname, sum(GREATEST(p.reltuples, 0))::bigint,
+			sum(pg_table_size(p.oid))::bigint, sum(pg_indexes_size(p.oid))::bigint, sum(pg_total_relation_size(p.oid))::bigint AS total
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		CROSS JOIN LATERAL pg_partition_tree(c.oid) t
+		JOIN pg_class p ON p.oid = t.relid AND t.isleaf
+		WHERE n.nspname = current_schema() AND c.relkind IN ('r', 'p') AND NOT c.relispartition
+		GROUP BY c.relname
+		ORDER BY total DESC, c.relname
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tables := []models.TableSize{}
+	for rows.Next() {
+		var t models.TableSize
+		if err := rows.Scan(&t.Name, &t.EstimatedRows, &t.TableBytes, &t.IndexBytes, &t.TotalBytes); err != nil {
+			return nil, err
+		}
+		tables = append(tables, t)
+	}
+	return tables, rows.Err()
+}
+
+// OldestInvocationTime returns when the oldest stored invocation ran, or nil
+// when there are none
+func (s *DBService) OldestInvocationTime(ctx context.Context) (*time.Time, error) {
+	var oldest sql.NullTime
+	if err := s.db.QueryRowContext(ctx, `SELECT min(invoked_at) FROM function_invocations`).Scan(&oldest); err != nil {
+		return nil, err
+	}
+	if !oldest.Valid {
+		return nil, nil
+	}
+	return &oldest.Time, nil
+}
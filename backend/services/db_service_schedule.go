@@ -4,6 +4,8 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"fmt"
+	"time"
 
 	"lambda-runner-server/models"
 )
@@ -14,12 +16,13 @@ func (s *DBService) CreateSchedule(ctx context.Context, sched *models.FunctionSc
 	var created models.FunctionSchedule
 	var executedAt sql.NullTime
 	var status, errorMsg sql.NullString
+	var createdByKeyID sql.NullInt64
 	err := s.db.QueryRowContext(ctx, `
-		INSERT INTO function_schedules (function_id, scheduled_at, payload, executed)
-		VALUES ($1, $2, $3, FALSE)
-		RETURNING id, function_id, scheduled_at, payload, executed, executed_at, status, error_message, created_at, updated_at
-	`, sched.FunctionID, sched.ScheduledAt, payloadJSON).
-		Scan(&created.ID, &created.FunctionID, &created.ScheduledAt, &payloadJSON, &created.Executed, &executedAt, &status, &errorMsg, &created.CreatedAt, &created.UpdatedAt)
+		INSERT INTO function_schedules (function_id, scheduled_at, payload, priority, executed, created_by_key_id)
+		VALUES ($1, $2, $3, $4, FALSE, $5)
+		RETURNING id, function_id, scheduled_at, payload, priority, executed, executed_at, status, error_message, created_by_key_id, created_at, updated_at
+	`, sched.FunctionID, sched.ScheduledAt, payloadJSON, sched.Priority, sched.CreatedByKeyID).
+		Scan(&created.ID, &created.FunctionID, &created.ScheduledAt, &payloadJSON, &created.Priority, &created.Executed, &executedAt, &status, &errorMsg, &createdByKeyID, &created.CreatedAt, &created.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -33,6 +36,9 @@ func (s *DBService) CreateSchedule(ctx context.Context, sched *models.FunctionSc
 	if errorMsg.Valid {
 		created.ErrorMessage = errorMsg.String
 	}
+	if createdByKeyID.Valid {
+		created.CreatedByKeyID = &createdByKeyID.Int64
+	}
 	if payloadJSON != nil {
 		json.Unmarshal(payloadJSON, &created.Payload)
 	}
@@ -43,11 +49,11 @@ func (s *DBService) CreateSchedule(ctx context.Context, sched *models.FunctionSc
 // ListSchedules returns schedules for a function
 func (s *DBService) ListSchedules(ctx context.Context, functionID int64) ([]models.FunctionSchedule, error) {
 	rows, err := s.db.QueryContext(ctx, `
-		SELECT id, function_id, scheduled_at, payload, executed, executed_at, status, error_message, created_at, updated_at
+		SELECT id, function_id, scheduled_at, payload, priority, executed, executed_at, status, error_message, created_by_key_id, created_at, updated_at
 		FROM function_schedules
-		WHERE function_id = $1
+		WHERE function_id = $1 AND `+functionInNamespace("function_id", 2)+`
 		ORDER BY scheduled_at DESC
-	`, functionID)
+	`, functionID, requestNamespace(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -59,7 +65,8 @@ func (s *DBService) ListSchedules(ctx context.Context, functionID int64) ([]mode
 		var payloadJSON []byte
 		var executedAt sql.NullTime
 		var status, errorMsg sql.NullString
-		if err := rows.Scan(&sched.ID, &sched.FunctionID, &sched.ScheduledAt, &payloadJSON, &sched.Executed, &executedAt, &status, &errorMsg, &sched.CreatedAt, &sched.UpdatedAt); err != nil {
+		var createdByKeyID sql.NullInt64
+		if err := rows.Scan(&sched.ID, &sched.FunctionID, &sched.ScheduledAt, &payloadJSON, &sched.Priority, &sched.Executed, &executedAt, &status, &errorMsg, &createdByKeyID, &sched.CreatedAt, &sched.UpdatedAt); err != nil {
 			return nil, err
 		}
 		if executedAt.Valid {
@@ -71,6 +78,9 @@ func (s *DBService) ListSchedules(ctx context.Context, functionID int64) ([]mode
 		if errorMsg.Valid {
 			sched.ErrorMessage = errorMsg.String
 		}
+		if createdByKeyID.Valid {
+			sched.CreatedByKeyID = &createdByKeyID.Int64
+		}
 		if payloadJSON != nil {
 			json.Unmarshal(payloadJSON, &sched.Payload)
 		}
@@ -80,12 +90,16 @@ func (s *DBService) ListSchedules(ctx context.Context, functionID int64) ([]mode
 	return schedules, nil
 }
 
-// DeleteSchedule removes a schedule
-func (s *DBService) DeleteSchedule(ctx context.Context, functionID, scheduleID int64) error {
-	_, err := s.db.ExecContext(ctx, `
-		DELETE FROM function_schedules WHERE id = $1 AND function_id = $2
-	`, scheduleID, functionID)
-	return err
+// DeleteSchedule removes a schedule and reports whether it existed
+func (s *DBService) DeleteSchedule(ctx context.Context, functionID, scheduleID int64) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `
+		DELETE FROM function_schedules WHERE id = $1 AND function_id = $2 AND `+functionInNamespace("function_id", 3)+`
+	`, scheduleID, functionID, requestNamespace(ctx))
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
 }
 
 // MarkScheduleExecuted updates the schedule execution result
@@ -97,3 +111,87 @@ func (s *DBService) MarkScheduleExecuted(ctx context.Context, scheduleID int64,
 	`, scheduleID, status, errMsg)
 	return err
 }
+
+// ClaimDueSchedules locks up to limit due schedules, marks them executed and
+// returns them. Locked rows are skipped, so concurrent backends never claim
+// the same schedule.
+func (s *DBService) ClaimDueSchedules(ctx context.Context, limit int) ([]models.FunctionSchedule, error) {
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, function_id, scheduled_at, payload, priority, executed, executed_at, status, error_message, created_at, updated_at
+		FROM function_schedules
+		WHERE executed = FALSE AND scheduled_at <= $1
+		ORDER BY scheduled_at
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	`, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schedules []models.FunctionSchedule
+	var scheduleIDs []int64
+	for rows.Next() {
+		var sched models.FunctionSchedule
+		var payloadJSON []byte
+		var executedAt sql.NullTime
+		var status, errorMsg sql.NullString
+		if err := rows.Scan(&sched.ID, &sched.FunctionID, &sched.ScheduledAt, &payloadJSON, &sched.Priority, &sched.Executed, &executedAt, &status, &errorMsg, &sched.CreatedAt, &sched.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if payloadJSON != nil {
+			json.Unmarshal(payloadJSON, &sched.Payload)
+		}
+		if executedAt.Valid {
+			sched.ExecutedAt = &executedAt.Time
+		}
+		if status.Valid {
+			sched.Status = status.String
+		}
+		if errorMsg.Valid {
+			sched.ErrorMessage = errorMsg.String
+		}
+		schedules = append(schedules, sched)
+		scheduleIDs = append(scheduleIDs, sched.ID)
+	}
+
+	// Mark as executed immediately to prevent duplicate execution
+	if len(scheduleIDs) > 0 {
+		// Create placeholder string for IN clause
+		placeholders := ""
+		for i := range scheduleIDs {
+			if i > 0 {
+				placeholders += ","
+			}
+			placeholders += fmt.Sprintf("$%d", i+1)
+		}
+
+		query := fmt.Sprintf(`
+			UPDATE function_schedules
+			SET executed = TRUE, executed_at = now(), updated_at = now()
+			WHERE id IN (%s)
+		`, placeholders)
+
+		args := make([]interface{}, len(scheduleIDs))
+		for i, id := range scheduleIDs {
+			args[i] = id
+		}
+
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return schedules, nil
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// InvocationStats groups the invocations of a function started in [from, to)
+// by time bucket and status, by each alone and not at all in one pass. The
+// range bounds the scan to the partitions it overlaps.
+func (s *DBService) InvocationStats(ctx context.Context, functionID int64, from, to time.Time, bucket time.Duration) ([]InvocationStatsRow, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT bucket, status, count(*),
+			percentile_cont(0.5) WITHIN GROUP (ORDER BY duration_ms) FILTER (WHERE finished),
+			percentile_cont(0.9) WITHIN GROUP (ORDER BY duration_ms) FILTER (WHERE finished),
+			percentile_cont(0.99) WITHIN GROUP (ORDER BY duration_ms) FILTER (WHERE finished)
+		FROM (
+			SELECT floor(extract(epoch FROM invoked_at - $2::timestamptz) / $4::integer)::bigint AS bucket, status, duration_ms,
+				status IN ('success', 'fail', 'timeout') AS finished
+			FROM function_invocations
+			WHERE function_id = $1 AND invoked_at >= $2 AND invoked_at < $3 AND `+functionInNamespace("function_id", 5)+`
+		) i
+		GROUP BY GROUPING SETS ((bucket, status), (bucket), (status), ())
+	`, functionID, from, to, int64(bucket/time.Second), requestNamespace(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanInvocationStatsRows(rows)
+}
+
+func scanInvocationStatsRows(rows *sql.Rows) ([]InvocationStatsRow, error) {
+	var result []InvocationStatsRow
+	for rows.Next() {
+		var row InvocationStatsRow
+		var bucket sql.NullInt64
+		var status sql.NullString
+		var p50, p90, p99 sql.NullFloat64
+		if err := rows.Scan(&bucket, &status, &row.Count, &p50, &p90, &p99); err != nil {
+			return nil, err
+		}
+		if bucket.Valid {
+			row.Bucket = &bucket.Int64
+		}
+		if status.Valid {
+			row.Status = &status.String
+		}
+		row.P50, row.P90, row.P99 = nullFloat(p50), nullFloat(p90), nullFloat(p99)
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+func nullFloat(f sql.NullFloat64) *float64 {
+	if !f.Valid {
+		return nil
+	}
+	return &f.Float64
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"lambda-runner-server/models"
+)
+
+const storageTriggerColumns = `id, function_id, storage_type, location, prefix, suffix, event_types, enabled,
+	last_event_at, created_at, updated_at`
+
+func scanStorageTrigger(scanner interface{ Scan(...interface{}) error }) (*models.StorageTrigger, error) {
+	var t models.StorageTrigger
+	err := scanner.Scan(&t.ID, &t.FunctionID, &t.StorageType, &t.Location, &t.Prefix, &t.Suffix, &t.EventTypes,
+		&t.Enabled, &t.LastEventAt, &t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// CreateStorageTrigger inserts a storage trigger
+func (s *DBService) CreateStorageTrigger(ctx context.Context, t *models.StorageTrigger) (*models.StorageTrigger, error) {
+	return scanStorageTrigger(s.db.QueryRowContext(ctx, `
+		INSERT INTO storage_triggers (function_id, storage_type, location, prefix, suffix, event_types)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+storageTriggerColumns,
+		t.FunctionID, t.StorageType, t.Location, t.Prefix, t.Suffix, t.EventTypes))
+}
+
+func (s *DBService) listStorageTriggers(ctx context.Context, query string, args ...interface{}) ([]models.StorageTrigger, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	triggers := []models.StorageTrigger{}
+	for rows.Next() {
+		t, err := scanStorageTrigger(rows)
+		if err != nil {
+			return nil, err
+		}
+		triggers = append(triggers, *t)
+	}
+	return triggers, rows.Err()
+}
+
+// ListStorageTriggers returns the storage triggers of a function
+func (s *DBService) ListStorageTriggers(ctx context.Context, functionID int64) ([]models.StorageTrigger, error) {
+	return s.listStorageTriggers(ctx, `
+		SELECT `+storageTriggerColumns+` FROM storage_triggers
+		WHERE function_id = $1 AND `+functionInNamespace("function_id", 2)+`
+		ORDER BY id
+	`, functionID, requestNamespace(ctx))
+}
+
+// ListEnabledStorageTriggers returns every enabled storage trigger
+func (s *DBService) ListEnabledStorageTriggers(ctx context.Context) ([]models.StorageTrigger, error) {
+	return s.listStorageTriggers(ctx, `
+		SELECT `+storageTriggerColumns+` FROM storage_triggers WHERE enabled ORDER BY id
+	`)
+}
+
+// DeleteStorageTrigger removes a storage trigger of a function
+func (s *DBService) DeleteStorageTrigger(ctx context.Context, functionID, id int64) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `
+		DELETE FROM storage_triggers WHERE id = $1 AND function_id = $2 AND `+functionInNamespace("function_id", 3)+`
+	`, id, functionID, requestNamespace(ctx))
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// AdvanceStorageTriggerWatermark moves the time up to which object changes were
+// delivered. It never moves backwards.
+func (s *DBService) AdvanceStorageTriggerWatermark(ctx context.Context, id int64, at time.Time) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE storage_triggers SET last_event_at = $2 WHERE id = $1 AND last_event_at < $2
+	`, id, at)
+	return err
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+
+	"lambda-runner-server/models"
+)
+
+const webhookTriggerColumns = `id, function_id, name, token, secret, signature_header, signature_algorithm,
+	signature_prefix, signature_encoding, delivery_id_header, enabled, created_at, updated_at`
+
+func scanWebhookTrigger(scanner interface{ Scan(...interface{}) error }) (*models.WebhookTrigger, error) {
+	var t models.WebhookTrigger
+	err := scanner.Scan(&t.ID, &t.FunctionID, &t.Name, &t.Token, &t.Secret, &t.SignatureHeader, &t.SignatureAlgorithm,
+		&t.SignaturePrefix, &t.SignatureEncoding, &t.DeliveryIDHeader, &t.Enabled, &t.CreatedAt, &t.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+const webhookDeliveryColumns = `id, trigger_id, COALESCE(delivery_id, ''), status, invocation_id, COALESCE(error, ''), received_at`
+
+func scanWebhookDelivery(scanner interface{ Scan(...interface{}) error }) (*models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	var invocationID sql.NullInt64
+	err := scanner.Scan(&d.ID, &d.TriggerID, &d.DeliveryID, &d.Status, &invocationID, &d.Error, &d.ReceivedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if invocationID.Valid {
+		d.InvocationID = &invocationID.Int64
+	}
+	return &d, nil
+}
+
+// CreateWebhookTrigger inserts a webhook trigger
+func (s *DBService) CreateWebhookTrigger(ctx context.Context, t *models.WebhookTrigger) (*models.WebhookTrigger, error) {
+	return scanWebhookTrigger(s.db.QueryRowContext(ctx, `
+		INSERT INTO webhook_triggers (function_id, name, token, secret, signature_header, signature_algorithm,
+			signature_prefix, signature_encoding, delivery_id_header)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING `+webhookTriggerColumns,
+		t.FunctionID, t.Name, t.Token, t.Secret, t.SignatureHeader, t.SignatureAlgorithm,
+		t.SignaturePrefix, t.SignatureEncoding, t.DeliveryIDHeader))
+}
+
+// ListWebhookTriggers returns the webhook triggers of a function
+func (s *DBService) ListWebhookTriggers(ctx context.Context, functionID int64) ([]models.WebhookTrigger, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+webhookTriggerColumns+`
+		FROM webhook_triggers
+		WHERE function_id = $1 AND `+functionInNamespace("function_id", 2)+`
+		ORDER BY id
+	`, functionID, requestNamespace(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	triggers := []models.WebhookTrigger{}
+	for rows.Next() {
+		t, err := scanWebhookTrigger(rows)
+		if err != nil {
+			return nil, err
+		}
+		triggers = append(triggers, *t)
+	}
+	return triggers, rows.Err()
+}
+
+// GetWebhookTrigger returns a webhook trigger by ID, or nil
+func (s *DBService) GetWebhookTrigger(ctx context.Context, id int64) (*models.WebhookTrigger, error) {
+	return scanWebhookTrigger(s.db.QueryRowContext(ctx, `
+		SELECT `+webhookTriggerColumns+` FROM webhook_triggers WHERE id = $1 AND `+functionInNamespace("function_id", 2)+`
+	`, id, requestNamespace(ctx)))
+}
+
+// GetWebhookTriggerByToken returns the webhook trigger serving a URL token, or nil
+func (s *DBService) GetWebhookTriggerByToken(ctx context.Context, token string) (*models.WebhookTrigger, error) {
+	return scanWebhookTrigger(s.db.QueryRowContext(ctx, `
+		SELECT `+webhookTriggerColumns+` FROM webhook_triggers WHERE token = $1
+	`, token))
+}
+
+// DeleteWebhookTrigger removes a webhook trigger of a function
+func (s *DBService) DeleteWebhookTrigger(ctx context.Context, functionID, id int64) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `
+		DELETE FROM webhook_triggers WHERE id = $1 AND function_id = $2 AND `+functionInNamespace("function_id", 3)+`
+	`, id, functionID, requestNamespace(ctx))
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// InsertWebhookDelivery records a delivery
+func (s *DBService) InsertWebhookDelivery(ctx context.Context, d *models.WebhookDelivery) (*models.WebhookDelivery, error) {
+	return scanWebhookDelivery(s.db.QueryRowContext(ctx, `
+		INSERT INTO webhook_deliveries (trigger_id, delivery_id, status, invocation_id, error)
+		VALUES ($1, NULLIF($2, ''), $3, $4, NULLIF($5, ''))
+		RETURNING `+webhookDeliveryColumns,
+		d.TriggerID, d.DeliveryID, d.Status, d.InvocationID, d.Error))
+}
+
+// ClaimWebhookDelivery records a verified delivery as accepted unless the same
+// delivery ID was already accepted. It returns the new row, or the earlier
+// accepted row with duplicate set.
+func (s *DBService) ClaimWebhookDelivery(ctx context.Context, triggerID int64, deliveryID string) (*models.WebhookDelivery, bool, error) {
+	d, err := scanWebhookDelivery(s.db.QueryRowContext(ctx, `
+		INSERT INTO webhook_deliveries (trigger_id, delivery_id, status)
+		VALUES ($1, $2, 'accepted')
+		ON CONFLICT (trigger_id, delivery_id) WHERE status = 'accepted' AND delivery_id IS NOT NULL DO NOTHING
+		RETURNING `+webhookDeliveryColumns,
+		triggerID, deliveryID))
+	if err != nil || d != nil {
+		return d, false, err
+	}
+
+	existing, err := scanWebhookDelivery(s.db.QueryRowContext(ctx, `
+		SELECT `+webhookDeliveryColumns+`
+		FROM webhook_deliveries
+		WHERE trigger_id = $1 AND delivery_id = $2 AND status = 'accepted'
+	`, triggerID, deliveryID))
+	return existing, true, err
+}
+
+// UpdateWebhookDelivery sets the outcome of a delivery
+func (s *DBService) UpdateWebhookDelivery(ctx context.Context, id int64, status string, invocationID *int64, errMsg string) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = $2, invocation_id = $3, error = NULLIF($4, '')
+		WHERE id = $1
+	`, id, status, invocationID, errMsg)
+	return err
+}
+
+// ListWebhookDeliveries returns the most recent deliveries of a trigger
+func (s *DBService) ListWebhookDeliveries(ctx context.Context, triggerID int64, limit int) ([]models.WebhookDelivery, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+webhookDeliveryColumns+`
+		FROM webhook_deliveries
+		WHERE trigger_id = $1
+		ORDER BY received_at DESC, id DESC
+		LIMIT $2
+	`, triggerID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		d, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, *d)
+	}
+	return deliveries, rows.Err()
+}
@@ -0,0 +1,465 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"lambda-runner-server/models"
+)
+
+const workflowColumns = `id, namespace_id, name, description, definition, created_at, updated_at`
+
+func scanWorkflow(scanner interface{ Scan(...interface{}) error }) (*models.Workflow, error) {
+	var w models.Workflow
+	var definitionJSON []byte
+	if err := scanner.Scan(&w.ID, &w.NamespaceID, &w.Name, &w.Description, &definitionJSON, &w.CreatedAt, &w.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(definitionJSON, &w.Definition); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// CreateWorkflow inserts a workflow
+func (s *DBService) CreateWorkflow(ctx context.Context, w *models.Workflow) (*models.Workflow, error) {
+	definitionJSON, err := json.Marshal(w.Definition)
+	if err != nil {
+		return nil, err
+	}
+	return scanWorkflow(s.db.QueryRowContext(ctx, `
+		INSERT INTO workflows (namespace_id, name, description, definition)
+		VALUES ($1, $2, $3, $4)
+		RETURNING `+workflowColumns,
+		w.NamespaceID, w.Name, w.Description, definitionJSON))
+}
+
+// UpdateWorkflow replaces the name, description and definition of a workflow
+func (s *DBService) UpdateWorkflow(ctx context.Context, w *models.Workflow) (*models.Workflow, error) {
+	definitionJSON, err := json.Marshal(w.Definition)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := scanWorkflow(s.db.QueryRowContext(ctx, `
+		UPDATE workflows SET name = $2, description = $3, definition = $4, updated_at = now()
+		WHERE id = $1 AND `+inNamespace("namespace_id", 5)+`
+		RETURNING `+workflowColumns,
+		w.ID, w.Name, w.Description, definitionJSON, requestNamespace(ctx)))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return updated, err
+}
+
+// GetWorkflow retrieves a workflow by ID
+func (s *DBService) GetWorkflow(ctx context.Context, id int64) (*models.Workflow, error) {
+	w, err := scanWorkflow(s.db.QueryRowContext(ctx, `
+		SELECT `+workflowColumns+` FROM workflows WHERE id = $1 AND `+inNamespace("namespace_id", 2)+`
+	`, id, requestNamespace(ctx)))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return w, err
+}
+
+// GetWorkflowByName retrieves a workflow by its name, which is unique within a namespace
+func (s *DBService) GetWorkflowByName(ctx context.Context, namespaceID int64, name string) (*models.Workflow, error) {
+	w, err := scanWorkflow(s.db.QueryRowContext(ctx, `
+		SELECT `+workflowColumns+` FROM workflows WHERE namespace_id = $1 AND name = $2
+	`, namespaceID, name))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return w, err
+}
+
+// ListWorkflows returns all workflows
+func (s *DBService) ListWorkflows(ctx context.Context) ([]models.Workflow, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+workflowColumns+` FROM workflows WHERE `+inNamespace("namespace_id", 1)+` ORDER BY id
+	`, requestNamespace(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	workflows := []models.Workflow{}
+	for rows.Next() {
+		w, err := scanWorkflow(rows)
+		if err != nil {
+			return nil, err
+		}
+		workflows = append(workflows, *w)
+	}
+	return workflows, rows.Err()
+}
+
+// DeleteWorkflow removes a workflow together with its executions
+func (s *DBService) DeleteWorkflow(ctx context.Context, id int64) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `
+		DELETE FROM workflows WHERE id = $1 AND `+inNamespace("namespace_id", 2)+`
+	`, id, requestNamespace(ctx))
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+const workflowExecutionColumns = `id, workflow_id, status, definition, input, output, error, cause,
+	created_at, updated_at, finished_at`
+
+func scanWorkflowExecution(scanner interface{ Scan(...interface{}) error }) (*models.WorkflowExecution, error) {
+	var e models.WorkflowExecution
+	var definitionJSON, inputJSON, outputJSON []byte
+	var errorName, cause sql.NullString
+	var finishedAt sql.NullTime
+	err := scanner.Scan(&e.ID, &e.WorkflowID, &e.Status, &definitionJSON, &inputJSON, &outputJSON, &errorName, &cause,
+		&e.CreatedAt, &e.UpdatedAt, &finishedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(definitionJSON, &e.Definition); err != nil {
+		return nil, err
+	}
+	json.Unmarshal(inputJSON, &e.Input)
+	if outputJSON != nil {
+		json.Unmarshal(outputJSON, &e.Output)
+	}
+	e.Error = errorName.String
+	e.Cause = cause.String
+	if finishedAt.Valid {
+		e.FinishedAt = &finishedAt.Time
+	}
+	return &e, nil
+}
+
+// StartWorkflowExecution inserts an execution and its first step in one transaction
+func (s *DBService) StartWorkflowExecution(ctx context.Context, e *models.WorkflowExecution, first *models.WorkflowStep) (*models.WorkflowExecution, error) {
+	definitionJSON, err := json.Marshal(e.Definition)
+	if err != nil {
+		return nil, err
+	}
+	inputJSON, _ := json.Marshal(e.Input)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	execution, err := scanWorkflowExecution(tx.QueryRowContext(ctx, `
+		INSERT INTO workflow_executions (workflow_id, definition, input)
+		VALUES ($1, $2, $3)
+		RETURNING `+workflowExecutionColumns,
+		e.WorkflowID, definitionJSON, inputJSON))
+	if err != nil {
+		return nil, err
+	}
+
+	first.ExecutionID = execution.ID
+	if err := insertWorkflowStep(ctx, tx, first); err != nil {
+		return nil, err
+	}
+	return execution, tx.Commit()
+}
+
+// GetWorkflowExecution retrieves an execution by ID
+func (s *DBService) GetWorkflowExecution(ctx context.Context, id int64) (*models.WorkflowExecution, error) {
+	e, err := scanWorkflowExecution(s.db.QueryRowContext(ctx, `
+		SELECT `+workflowExecutionColumns+` FROM workflow_executions
+		WHERE id = $1 AND workflow_id IN (SELECT id FROM workflows WHERE `+inNamespace("namespace_id", 2)+`)
+	`, id, requestNamespace(ctx)))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return e, err
+}
+
+// ListWorkflowExecutions returns the most recent executions of a workflow
+func (s *DBService) ListWorkflowExecutions(ctx context.Context, workflowID int64, limit int) ([]models.WorkflowExecution, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+workflowExecutionColumns+` FROM workflow_executions
+		WHERE workflow_id = $1 AND workflow_id IN (SELECT id FROM workflows WHERE `+inNamespace("namespace_id", 3)+`)
+		ORDER BY created_at DESC
+		LIMIT $2
+	`, workflowID, limit, requestNamespace(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	executions := []models.WorkflowExecution{}
+	for rows.Next() {
+		e, err := scanWorkflowExecution(rows)
+		if err != nil {
+			return nil, err
+		}
+		executions = append(executions, *e)
+	}
+	return executions, rows.Err()
+}
+
+// CancelWorkflowExecution stops a running execution and all of its active steps
+func (s *DBService) CancelWorkflowExecution(ctx context.Context, id int64) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE workflow_executions SET status = 'cancelled', updated_at = now(), finished_at = now()
+		WHERE id = $1 AND status = 'running'
+			AND workflow_id IN (SELECT id FROM workflows WHERE `+inNamespace("namespace_id", 2)+`)
+	`, id, requestNamespace(ctx))
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE workflow_steps SET status = 'cancelled', finished_at = now()
+		WHERE execution_id = $1 AND status IN ('running', 'waiting')
+	`, id)
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+const workflowStepColumns = `id, execution_id, parent_step_id, branch_index, state_name, state_type, status,
+	input, output, error, cause, attempt, invocation_id, terminal, next_run_at, started_at, finished_at`
+
+func scanWorkflowStep(scanner interface{ Scan(...interface{}) error }) (*models.WorkflowStep, error) {
+	var st models.WorkflowStep
+	var inputJSON, outputJSON []byte
+	var parentStepID, invocationID sql.NullInt64
+	var errorName, cause sql.NullString
+	var finishedAt sql.NullTime
+	err := scanner.Scan(&st.ID, &st.ExecutionID, &parentStepID, &st.BranchIndex, &st.StateName, &st.StateType, &st.Status,
+		&inputJSON, &outputJSON, &errorName, &cause, &st.Attempt, &invocationID, &st.Terminal, &st.NextRunAt,
+		&st.StartedAt, &finishedAt)
+	if err != nil {
+		return nil, err
+	}
+	json.Unmarshal(inputJSON, &st.Input)
+	if outputJSON != nil {
+		json.Unmarshal(outputJSON, &st.Output)
+	}
+	if parentStepID.Valid {
+		st.ParentStepID = &parentStepID.Int64
+	}
+	if invocationID.Valid {
+		st.InvocationID = &invocationID.Int64
+	}
+	st.Error = errorName.String
+	st.Cause = cause.String
+	if finishedAt.Valid {
+		st.FinishedAt = &finishedAt.Time
+	}
+	return &st, nil
+}
+
+func insertWorkflowStep(ctx context.Context, tx *sql.Tx, st *models.WorkflowStep) error {
+	inputJSON, _ := json.Marshal(st.Input)
+	inserted, err := scanWorkflowStep(tx.QueryRowContext(ctx, `
+		INSERT INTO workflow_steps (execution_id, parent_step_id, branch_index, state_name, state_type, status, input, next_run_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING `+workflowStepColumns,
+		st.ExecutionID, st.ParentStepID, st.BranchIndex, st.StateName, st.StateType, st.Status, inputJSON, st.NextRunAt))
+	if err != nil {
+		return err
+	}
+	*st = *inserted
+	return nil
+}
+
+func (s *DBService) listWorkflowSteps(ctx context.Context, query string, args ...interface{}) ([]models.WorkflowStep, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	steps := []models.WorkflowStep{}
+	for rows.Next() {
+		st, err := scanWorkflowStep(rows)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, *st)
+	}
+	return steps, rows.Err()
+}
+
+// ListWorkflowSteps returns the step history of an execution in order
+func (s *DBService) ListWorkflowSteps(ctx context.Context, executionID int64) ([]models.WorkflowStep, error) {
+	return s.listWorkflowSteps(ctx, `
+		SELECT `+workflowStepColumns+` FROM workflow_steps WHERE execution_id = $1 ORDER BY id
+	`, executionID)
+}
+
+// ListTerminalChildSteps returns the last steps of the finished branches of a parallel step
+func (s *DBService) ListTerminalChildSteps(ctx context.Context, parentStepID int64) ([]models.WorkflowStep, error) {
+	return s.listWorkflowSteps(ctx, `
+		SELECT `+workflowStepColumns+` FROM workflow_steps
+		WHERE parent_step_id = $1 AND terminal
+		ORDER BY branch_index
+	`, parentStepID)
+}
+
+// ClaimDueWorkflowSteps leases active steps of running executions that are
+// due. The lease pushes next_run_at forward so other backend instances skip
+// them meanwhile, and a step whose runner died is picked up again once it expires.
+func (s *DBService) ClaimDueWorkflowSteps(ctx context.Context, limit int, lease time.Duration) ([]models.WorkflowStep, error) {
+	return s.listWorkflowSteps(ctx, `
+		UPDATE workflow_steps
+		SET next_run_at = now() + $2 * interval '1 millisecond'
+		WHERE id IN (
+			SELECT st.id FROM workflow_steps st
+			JOIN workflow_executions e ON e.id = st.execution_id
+			WHERE st.status IN ('running', 'waiting') AND st.next_run_at <= now() AND e.status = 'running'
+			ORDER BY st.next_run_at
+			LIMIT $1
+			FOR UPDATE OF st SKIP LOCKED
+		)
+		RETURNING `+workflowStepColumns,
+		limit, lease.Milliseconds())
+}
+
+// RescheduleWorkflowStep saves the progress of an active step (attempt,
+// invocation) and when it is due next
+func (s *DBService) RescheduleWorkflowStep(ctx context.Context, st *models.WorkflowStep) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE workflow_steps SET attempt = $2, invocation_id = $3, next_run_at = $4
+		WHERE id = $1 AND status IN ('running', 'waiting')
+	`, st.ID, st.Attempt, st.InvocationID, st.NextRunAt)
+	return err
+}
+
+// StartWorkflowBranches inserts the first steps of a parallel step's branches
+// and marks the parallel step started
+func (s *DBService) StartWorkflowBranches(ctx context.Context, parent *models.WorkflowStep, children []*models.WorkflowStep) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE workflow_steps SET attempt = 1, next_run_at = $2
+		WHERE id = $1 AND attempt = 0 AND status = 'running'
+	`, parent.ID, parent.NextRunAt)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil
+	}
+	for _, child := range children {
+		if err := insertWorkflowStep(ctx, tx, child); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// workflowTransition finishes a step and moves its execution on: to the next
+// step, to the waiting parallel step, or to the end of the execution
+type workflowTransition struct {
+	Step *models.WorkflowStep // with final Status, Output, Error, Cause and Terminal
+	Next *models.WorkflowStep
+
+	// Set when a terminal top-level step ends the execution
+	ExecutionStatus string
+	ExecutionOutput map[string]interface{}
+}
+
+// FinishWorkflowStep applies a transition in one transaction. It reports
+// false when the step was no longer active (finished elsewhere or cancelled).
+func (s *DBService) FinishWorkflowStep(ctx context.Context, t *workflowTransition) (bool, error) {
+	st := t.Step
+	outputJSON, _ := json.Marshal(st.Output)
+	if st.Output == nil {
+		outputJSON = nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE workflow_steps
+		SET status = $2, output = $3, error = NULLIF($4, ''), cause = NULLIF($5, ''), terminal = $6,
+			attempt = $7, invocation_id = $8, finished_at = now()
+		WHERE id = $1 AND status IN ('running', 'waiting')
+	`, st.ID, st.Status, outputJSON, st.Error, st.Cause, st.Terminal, st.Attempt, st.InvocationID)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+
+	// Branches of a finished parallel step that are still running are abandoned
+	if st.StateType == models.StateParallel {
+		_, err := tx.ExecContext(ctx, `
+			WITH RECURSIVE subtree AS (
+				SELECT id FROM workflow_steps WHERE parent_step_id = $1
+				UNION ALL
+				SELECT c.id FROM workflow_steps c JOIN subtree ON c.parent_step_id = subtree.id
+			)
+			UPDATE workflow_steps SET status = 'cancelled', finished_at = now()
+			WHERE id IN (SELECT id FROM subtree) AND status IN ('running', 'waiting')
+		`, st.ID)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if t.Next != nil {
+		if err := insertWorkflowStep(ctx, tx, t.Next); err != nil {
+			return false, err
+		}
+	}
+
+	if st.Terminal && st.ParentStepID != nil {
+		// Wake the parallel step so it checks its branches
+		_, err := tx.ExecContext(ctx, `
+			UPDATE workflow_steps SET next_run_at = now() WHERE id = $1 AND status = 'running'
+		`, *st.ParentStepID)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if st.Terminal && st.ParentStepID == nil {
+		var executionOutputJSON []byte
+		if t.ExecutionOutput != nil {
+			executionOutputJSON, _ = json.Marshal(t.ExecutionOutput)
+		}
+		_, err := tx.ExecContext(ctx, `
+			UPDATE workflow_executions
+			SET status = $2, output = $3, error = NULLIF($4, ''), cause = NULLIF($5, ''), updated_at = now(), finished_at = now()
+			WHERE id = $1 AND status = 'running'
+		`, st.ExecutionID, t.ExecutionStatus, executionOutputJSON, st.Error, st.Cause)
+		if err != nil {
+			return false, err
+		}
+		_, err = tx.ExecContext(ctx, `
+			UPDATE workflow_steps SET status = 'cancelled', finished_at = now()
+			WHERE execution_id = $1 AND status IN ('running', 'waiting')
+		`, st.ExecutionID)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return true, tx.Commit()
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrInvocationFinished is returned when an operation needs a pending
+// invocation but it already reached a final status
+var ErrInvocationFinished = errors.New("invocation already finished")
+
+// ErrWebhookNotFound is returned for unknown or disabled webhook tokens
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+// ErrInvalidSignature is returned when a webhook delivery fails signature verification
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// ErrRouteSlugTaken is returned when an HTTP route slug belongs to another function
+var ErrRouteSlugTaken = errors.New("slug is already in use")
+
+// ThrottleError is returned when an invocation is rejected by a limit.
+// Handlers translate it into 429 Too Many Requests with a Retry-After header.
+type ThrottleError struct {
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e *ThrottleError) Error() string {
+	return e.Reason
+}
+
+// ErrWorkflowNameTaken is returned when a workflow name belongs to another workflow
+var ErrWorkflowNameTaken = errors.New("workflow name is already in use")
+
+// ErrEventRuleNameTaken is returned when an event rule name belongs to another rule
+var ErrEventRuleNameTaken = errors.New("event rule name is already in use")
+
+// ErrNamespaceNameTaken is returned when a namespace name is already in use
+var ErrNamespaceNameTaken = errors.New("namespace name is already in use")
+
+// ErrNamespaceNotEmpty is returned when deleting a namespace that still owns functions or workflows
+var ErrNamespaceNotEmpty = errors.New("namespace still has functions or workflows")
+
+// ErrFunctionQuotaExceeded is returned when a namespace already holds its maximum number of functions
+var ErrFunctionQuotaExceeded = errors.New("namespace function quota exceeded")
+
+// ErrFunctionAccessDenied is returned when the caller's access to a function
+// is below what an operation needs
+var ErrFunctionAccessDenied = errors.New("access to function denied")
+
+// ErrInvalidPatch is returned when a JSON Patch is malformed or does not apply
+var ErrInvalidPatch = errors.New("invalid patch")
+
+// ErrCodeVersionChanged is returned when a replay pins the code version of
+// the original invocation but the function's code has changed since
+var ErrCodeVersionChanged = errors.New("the function's code has changed since the original invocation")
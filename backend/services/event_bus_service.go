@@ -0,0 +1,313 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"lambda-runner-server/models"
+)
+
+const maxReplayEvents = 1000
+
+// EventBusService stores published CloudEvents and routes them through event
+// rules to functions. Every routing decision is recorded as an event match
+// with the resulting invocation, so events can be audited and replayed.
+type EventBusService struct {
+	db        *DBService
+	functions *FunctionService
+}
+
+func NewEventBusService(db *DBService, functions *FunctionService) *EventBusService {
+	return &EventBusService{db: db, functions: functions}
+}
+
+// Publish stores an event in the request's namespace and invokes the targets
+// of all matching rules of that namespace.
+// Publishing an already stored source/id pair returns the original event
+// and its matches without routing it again.
+func (s *EventBusService) Publish(ctx context.Context, req *models.PublishEventRequest) (*models.PublishEventResponse, error) {
+	if req.SpecVersion == "" {
+		req.SpecVersion = models.CloudEventsSpecVersion
+	}
+	if req.SpecVersion != models.CloudEventsSpecVersion {
+		return nil, fmt.Errorf("specversion must be %q", models.CloudEventsSpecVersion)
+	}
+	if req.Source == "" || req.Type == "" {
+		return nil, fmt.Errorf("source and type are required")
+	}
+	event := &models.Event{
+		NamespaceID:     requestNamespace(ctx),
+		SpecVersion:     req.SpecVersion,
+		ID:              req.ID,
+		Source:          req.Source,
+		Type:            req.Type,
+		Subject:         req.Subject,
+		Time:            time.Now().UTC(),
+		DataContentType: req.DataContentType,
+		Data:            req.Data,
+	}
+	if event.ID == "" {
+		id, err := randomHex(16)
+		if err != nil {
+			return nil, err
+		}
+		event.ID = id
+	}
+	if req.Time != nil {
+		event.Time = *req.Time
+	}
+
+	stored, inserted, err := s.db.InsertEvent(ctx, event)
+	if err != nil {
+		return nil, err
+	}
+	if !inserted {
+		matches, err := s.db.ListEventMatches(ctx, stored.Seq)
+		if err != nil {
+			return nil, err
+		}
+		return &models.PublishEventResponse{Event: stored, Duplicate: true, Matches: matches}, nil
+	}
+
+	rules, err := s.db.ListEventRules(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	return &models.PublishEventResponse{Event: stored, Matches: s.route(ctx, stored, rules, false)}, nil
+}
+
+// route invokes the targets of every rule matching the event and records the matches
+func (s *EventBusService) route(ctx context.Context, event *models.Event, rules []models.EventRule, replay bool) []models.EventMatch {
+	params, err := EventParams(cloudEvent(event))
+	if err != nil {
+		log.Printf("events: failed to encode event %d: %v", event.Seq, err)
+		return []models.EventMatch{}
+	}
+
+	matches := []models.EventMatch{}
+	for _, rule := range rules {
+		if !matchEventPattern(rule.Pattern, params) {
+			continue
+		}
+		ruleID := rule.ID
+		for _, functionID := range rule.FunctionIDs {
+			match := &models.EventMatch{
+				EventSeq:   event.Seq,
+				RuleID:     &ruleID,
+				FunctionID: functionID,
+				Status:     models.MatchInvoked,
+				Replay:     replay,
+			}
+			inv, err := s.functions.InvokeFunction(ctx, functionID, params, InvokeOptions{
+				InvokedBy: fmt.Sprintf("event:%d", rule.ID),
+			})
+			if err != nil {
+				match.Status = models.MatchFailed
+				match.Error = err.Error()
+			} else {
+				match.InvocationID = &inv.ID
+			}
+
+			recorded, err := s.db.CreateEventMatch(ctx, match)
+			if err != nil {
+				log.Printf("events: failed to record match of event %d and rule %d: %v", event.Seq, rule.ID, err)
+				recorded = match
+			}
+			matches = append(matches, *recorded)
+		}
+	}
+	return matches
+}
+
+// cloudEvent is the CloudEvent delivered to functions and matched by rule
+// patterns; bus bookkeeping fields are left out
+func cloudEvent(e *models.Event) *models.PublishEventRequest {
+	t := e.Time
+	return &models.PublishEventRequest{
+		SpecVersion:     e.SpecVersion,
+		ID:              e.ID,
+		Source:          e.Source,
+		Type:            e.Type,
+		Subject:         e.Subject,
+		Time:            &t,
+		DataContentType: e.DataContentType,
+		Data:            e.Data,
+	}
+}
+
+// GetEvent returns a stored event with all of its matches
+func (s *EventBusService) GetEvent(ctx context.Context, seq int64) (*models.EventDetail, error) {
+	event, err := s.db.GetEvent(ctx, seq)
+	if err != nil {
+		return nil, err
+	}
+	if event == nil {
+		return nil, fmt.Errorf("event not found: %d", seq)
+	}
+	matches, err := s.db.ListEventMatches(ctx, seq)
+	if err != nil {
+		return nil, err
+	}
+	return &models.EventDetail{Event: *event, Matches: matches}, nil
+}
+
+// ListEvents returns the most recent stored events
+func (s *EventBusService) ListEvents(ctx context.Context, source, eventType string, limit int) ([]models.Event, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	return s.db.ListEvents(ctx, EventFilter{Source: source, Type: eventType}, true, limit)
+}
+
+// replayRules returns the rules a replay routes through: one rule, even if
+// disabled, or all enabled rules
+func (s *EventBusService) replayRules(ctx context.Context, ruleID int64) ([]models.EventRule, error) {
+	if ruleID == 0 {
+		return s.db.ListEventRules(ctx, true)
+	}
+	rule, err := s.db.GetEventRule(ctx, ruleID)
+	if err != nil {
+		return nil, err
+	}
+	if rule == nil {
+		return nil, fmt.Errorf("event rule not found: %d", ruleID)
+	}
+	return []models.EventRule{*rule}, nil
+}
+
+// ReplayEvent routes a stored event again through the current rules
+func (s *EventBusService) ReplayEvent(ctx context.Context, seq, ruleID int64) (*models.ReplayEventsResponse, error) {
+	event, err := s.db.GetEvent(ctx, seq)
+	if err != nil {
+		return nil, err
+	}
+	if event == nil {
+		return nil, fmt.Errorf("event not found: %d", seq)
+	}
+	rules, err := s.replayRules(ctx, ruleID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.ReplayEventsResponse{Events: 1, Matches: s.route(ctx, event, rules, true)}, nil
+}
+
+// ReplayEvents routes the events received in a time window again, in publish order
+func (s *EventBusService) ReplayEvents(ctx context.Context, req *models.ReplayEventsRequest) (*models.ReplayEventsResponse, error) {
+	if req.From.IsZero() || req.To.IsZero() || !req.From.Before(req.To) {
+		return nil, fmt.Errorf("from and to are required and from must be before to")
+	}
+	rules, err := s.replayRules(ctx, req.RuleID)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := s.db.ListEvents(ctx, EventFilter{Source: req.Source, Type: req.Type, From: req.From, To: req.To}, false, maxReplayEvents+1)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) > maxReplayEvents {
+		return nil, fmt.Errorf("window holds more than %d events; narrow it", maxReplayEvents)
+	}
+
+	response := &models.ReplayEventsResponse{Events: len(events), Matches: []models.EventMatch{}}
+	for i := range events {
+		response.Matches = append(response.Matches, s.route(ctx, &events[i], rules, true)...)
+	}
+	return response, nil
+}
+
+// SaveRule creates a rule (id 0) or replaces an existing one
+func (s *EventBusService) SaveRule(ctx context.Context, id int64, req *models.EventRuleRequest) (*models.EventRule, error) {
+	if req.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if err := validateEventPattern(req.Pattern); err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+	if len(req.FunctionIDs) == 0 {
+		return nil, fmt.Errorf("function_ids must not be empty")
+	}
+	for _, functionID := range req.FunctionIDs {
+		if _, err := getAuthorizedFunction(ctx, s.db, functionID, models.AccessInvoke); err != nil {
+			return nil, err
+		}
+	}
+
+	taken, err := s.db.GetEventRuleByName(ctx, requestNamespace(ctx), req.Name)
+	if err != nil {
+		return nil, err
+	}
+	if taken != nil && taken.ID != id {
+		return nil, ErrEventRuleNameTaken
+	}
+
+	var before *models.EventRule
+	if id != 0 {
+		if before, err = s.db.GetEventRule(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+
+	rule := &models.EventRule{
+		ID:          id,
+		NamespaceID: requestNamespace(ctx),
+		Name:        req.Name,
+		Description: req.Description,
+		Pattern:     req.Pattern,
+		FunctionIDs: req.FunctionIDs,
+		Enabled:     req.Enabled == nil || *req.Enabled,
+	}
+	saved, err := s.db.SaveEventRule(ctx, rule)
+	if err != nil {
+		return nil, err
+	}
+	if !saved {
+		return nil, fmt.Errorf("event rule not found: %d", id)
+	}
+	after, err := s.GetRule(ctx, rule.ID)
+	if err != nil {
+		return nil, err
+	}
+	if before == nil {
+		audit(ctx, s.db, models.AuditCreate, models.AuditEventRule, after.ID, nil, after)
+	} else {
+		audit(ctx, s.db, models.AuditUpdate, models.AuditEventRule, after.ID, before, after)
+	}
+	return after, nil
+}
+
+// GetRule retrieves an event rule by ID
+func (s *EventBusService) GetRule(ctx context.Context, id int64) (*models.EventRule, error) {
+	rule, err := s.db.GetEventRule(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if rule == nil {
+		return nil, fmt.Errorf("event rule not found: %d", id)
+	}
+	return rule, nil
+}
+
+// ListRules returns all event rules
+func (s *EventBusService) ListRules(ctx context.Context) ([]models.EventRule, error) {
+	return s.db.ListEventRules(ctx, false)
+}
+
+// DeleteRule removes an event rule
+func (s *EventBusService) DeleteRule(ctx context.Context, id int64) error {
+	before, err := s.db.GetEventRule(ctx, id)
+	if err != nil {
+		return err
+	}
+	deleted, err := s.db.DeleteEventRule(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return fmt.Errorf("event rule not found: %d", id)
+	}
+	audit(ctx, s.db, models.AuditDelete, models.AuditEventRule, id, before, nil)
+	return nil
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// validateEventPattern checks that a rule pattern only uses known matchers.
+// See models.EventRule for the pattern syntax.
+func validateEventPattern(pattern map[string]interface{}) error {
+	if len(pattern) == 0 {
+		return fmt.Errorf("pattern must not be empty")
+	}
+	for key, p := range pattern {
+		switch p := p.(type) {
+		case map[string]interface{}:
+			if err := validateEventPattern(p); err != nil {
+				return fmt.Errorf("%s.%w", key, err)
+			}
+		case []interface{}:
+			if len(p) == 0 {
+				return fmt.Errorf("%s: value list must not be empty", key)
+			}
+			for _, alt := range p {
+				if m, ok := alt.(map[string]interface{}); ok {
+					if err := validateEventMatcher(m); err != nil {
+						return fmt.Errorf("%s: %w", key, err)
+					}
+				}
+			}
+		default:
+			return fmt.Errorf("%s: expected an object or a list of values", key)
+		}
+	}
+	return nil
+}
+
+func validateEventMatcher(m map[string]interface{}) error {
+	if len(m) != 1 {
+		return fmt.Errorf("a matcher must have exactly one key")
+	}
+	for op, arg := range m {
+		switch op {
+		case "prefix", "suffix":
+			if _, ok := arg.(string); !ok {
+				return fmt.Errorf("%s needs a string", op)
+			}
+		case "exists":
+			if _, ok := arg.(bool); !ok {
+				return fmt.Errorf("exists needs a boolean")
+			}
+		case "anything-but":
+		case "numeric":
+			conds, ok := arg.([]interface{})
+			if !ok || len(conds) == 0 || len(conds)%2 != 0 {
+				return fmt.Errorf("numeric needs operator/number pairs")
+			}
+			for i := 0; i < len(conds); i += 2 {
+				cmp, _ := conds[i].(string)
+				if _, ok := conds[i+1].(float64); !ok || !isNumericOperator(cmp) {
+					return fmt.Errorf("numeric needs operator/number pairs")
+				}
+			}
+		default:
+			return fmt.Errorf("unknown matcher %q", op)
+		}
+	}
+	return nil
+}
+
+func isNumericOperator(op string) bool {
+	switch op {
+	case "=", "<", "<=", ">", ">=":
+		return true
+	}
+	return false
+}
+
+// matchEventPattern reports whether an event (as decoded JSON) satisfies every field of a pattern
+func matchEventPattern(pattern, event map[string]interface{}) bool {
+	for key, p := range pattern {
+		value, present := event[key]
+		switch p := p.(type) {
+		case map[string]interface{}:
+			nested, ok := value.(map[string]interface{})
+			if !ok || !matchEventPattern(p, nested) {
+				return false
+			}
+		case []interface{}:
+			matched := false
+			for _, alt := range p {
+				if matchEventValue(alt, value, present) {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// matchEventValue matches one accepted value or matcher against an event field
+func matchEventValue(alt, value interface{}, present bool) bool {
+	m, isMatcher := alt.(map[string]interface{})
+	if isMatcher {
+		if exists, ok := m["exists"].(bool); ok {
+			return present == exists
+		}
+	}
+	if !present {
+		return false
+	}
+
+	if values, ok := value.([]interface{}); ok {
+		for _, v := range values {
+			if matchEventValue(alt, v, true) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if !isMatcher {
+		return reflect.DeepEqual(alt, value)
+	}
+	for op, arg := range m {
+		switch op {
+		case "prefix":
+			s, ok := value.(string)
+			return ok && strings.HasPrefix(s, arg.(string))
+		case "suffix":
+			s, ok := value.(string)
+			return ok && strings.HasSuffix(s, arg.(string))
+		case "anything-but":
+			excluded, ok := arg.([]interface{})
+			if !ok {
+				excluded = []interface{}{arg}
+			}
+			for _, x := range excluded {
+				if reflect.DeepEqual(x, value) {
+					return false
+				}
+			}
+			return true
+		case "numeric":
+			n, ok := value.(float64)
+			if !ok {
+				return false
+			}
+			conds := arg.([]interface{})
+			for i := 0; i < len(conds); i += 2 {
+				if !compareNumeric(n, conds[i].(string), conds[i+1].(float64)) {
+					return false
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
+
+func compareNumeric(n float64, op string, limit float64) bool {
+	switch op {
+	case "=":
+		return n == limit
+	case "<":
+		return n < limit
+	case "<=":
+		return n <= limit
+	case ">":
+		return n > limit
+	case ">=":
+		return n >= limit
+	}
+	return false
+}
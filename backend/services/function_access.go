@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"lambda-runner-server/middleware"
+	"lambda-runner-server/models"
+)
+
+// Public functions can be read and invoked by every key of their namespace.
+// Everything else, and all access to private functions, needs the owner, a
+// grant (see models.FunctionGrant) or an admin key. Background work carries
+// no key and has full access, as do functions created before ownership.
+
+// requestPrincipals returns the principals of the calling key, or nil when
+// the caller may access every function
+func requestPrincipals(ctx context.Context) []string {
+	key := middleware.APIKeyFromContext(ctx)
+	if key == nil || key.HasScope(models.ScopeAdmin) {
+		return nil
+	}
+	return key.Principals()
+}
+
+// functionAccess returns the access level the caller has to a function, or "" for none
+func functionAccess(ctx context.Context, db FunctionStore, fn *models.Function) (string, error) {
+	principals := requestPrincipals(ctx)
+	if principals == nil || fn.Owner == "" {
+		return models.AccessAdmin, nil
+	}
+	for _, p := range principals {
+		if p == fn.Owner {
+			return models.AccessAdmin, nil
+		}
+	}
+
+	levels, err := db.FunctionGrantLevels(ctx, fn.ID, principals)
+	if err != nil {
+		return "", err
+	}
+	access := ""
+	if fn.IsPublic {
+		access = models.AccessInvoke
+	}
+	for _, level := range levels {
+		access = models.MaxAccess(access, level)
+	}
+	return access, nil
+}
+
+// authorizeFunction fails with ErrFunctionAccessDenied unless the caller has
+// at least the required access to a function
+func authorizeFunction(ctx context.Context, db FunctionStore, fn *models.Function, required string) error {
+	access, err := functionAccess(ctx, db, fn)
+	if err != nil {
+		return err
+	}
+	if !models.AccessAllows(access, required) {
+		return fmt.Errorf("%w: %s access to function %d required", ErrFunctionAccessDenied, required, fn.ID)
+	}
+	return nil
+}
+
+// getAuthorizedFunction loads a function the caller has at least the required access to
+func getAuthorizedFunction(ctx context.Context, db FunctionStore, id int64, required string) (*models.Function, error) {
+	fn, err := db.GetFunction(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if fn == nil {
+		return nil, fmt.Errorf("function not found: %d", id)
+	}
+	if err := authorizeFunction(ctx, db, fn, required); err != nil {
+		return nil, err
+	}
+	return fn, nil
+}
+
+// ListGrants returns the principals a function is shared with
+func (s *FunctionService) ListGrants(ctx context.Context, functionID int64) ([]models.FunctionGrant, error) {
+	if _, err := getAuthorizedFunction(ctx, s.db, functionID, models.AccessAdmin); err != nil {
+		return nil, err
+	}
+	return s.db.ListFunctionGrants(ctx, functionID)
+}
+
+// GrantAccess shares a function with a key or user
+func (s *FunctionService) GrantAccess(ctx context.Context, functionID int64, req *models.GrantFunctionAccessRequest) (*models.FunctionGrant, error) {
+	if !models.ValidPrincipal(req.Principal) {
+		return nil, fmt.Errorf("principal must be key:<id> or user:<name>")
+	}
+	if !models.ValidAccess(req.Access) {
+		return nil, fmt.Errorf("access must be %q, %q or %q", models.AccessRead, models.AccessInvoke, models.AccessAdmin)
+	}
+	if _, err := getAuthorizedFunction(ctx, s.db, functionID, models.AccessAdmin); err != nil {
+		return nil, err
+	}
+	grant, err := s.db.SaveFunctionGrant(ctx, &models.FunctionGrant{
+		FunctionID: functionID,
+		Principal:  req.Principal,
+		Access:     req.Access,
+	})
+	if err != nil {
+		return nil, err
+	}
+	audit(ctx, s.db, models.AuditCreate, models.AuditFunctionGrant, grant.ID, nil, grant)
+	return grant, nil
+}
+
+// RevokeGrant stops sharing a function with a principal
+func (s *FunctionService) RevokeGrant(ctx context.Context, functionID, grantID int64) error {
+	if _, err := getAuthorizedFunction(ctx, s.db, functionID, models.AccessAdmin); err != nil {
+		return err
+	}
+	deleted, err := s.db.DeleteFunctionGrant(ctx, functionID, grantID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return fmt.Errorf("grant not found: %d", grantID)
+	}
+	audit(ctx, s.db, models.AuditDelete, models.AuditFunctionGrant, grantID, map[string]interface{}{"function_id": functionID}, nil)
+	return nil
+}
+
+// UpdateVisibility makes a function public or private
+func (s *FunctionService) UpdateVisibility(ctx context.Context, functionID int64, req *models.UpdateVisibilityRequest) (*models.Function, error) {
+	fn, err := getAuthorizedFunction(ctx, s.db, functionID, models.AccessAdmin)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.UpdateFunctionVisibility(ctx, functionID, req.IsPublic); err != nil {
+		return nil, err
+	}
+	before := *fn
+	fn.IsPublic = req.IsPublic
+	audit(ctx, s.db, models.AuditUpdate, models.AuditFunction, functionID, &before, fn)
+	return fn, nil
+}
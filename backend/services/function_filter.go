@@ -0,0 +1,126 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"lambda-runner-server/models"
+)
+
+// maxFunctionPageSize caps the limit of function listings
+const maxFunctionPageSize = 1000
+
+// maxFunctionTags caps the number of tags on a function
+const maxFunctionTags = 20
+
+// Tags are lowercase; labels like team:payments or tier=gold are tags too
+var tagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.:/=-]{0,62}$`)
+
+// FunctionFilter selects and orders functions; zero fields do not filter
+type FunctionFilter struct {
+	Query   string   // words that must all appear in the name or description
+	Runtime string   // exact runtime
+	Tags    []string // functions carrying every one of these tags
+	Sort    string   // one of models.FunctionSort*, created_at by default
+	Order   string   // asc or desc; names sort ascending and times descending by default
+	Limit   int      // 0 lists every function
+	Offset  int
+}
+
+// Validate checks the filter values and fills in the default order
+func (f *FunctionFilter) Validate() error {
+	switch f.Sort {
+	case "":
+		f.Sort = models.FunctionSortCreatedAt
+	case models.FunctionSortName, models.FunctionSortCreatedAt, models.FunctionSortUpdatedAt, models.FunctionSortLastInvokedAt:
+	default:
+		return fmt.Errorf("invalid sort: %s", f.Sort)
+	}
+	switch f.Order {
+	case "":
+		f.Order = "desc"
+		if f.Sort == models.FunctionSortName {
+			f.Order = "asc"
+		}
+	case "asc", "desc":
+	default:
+		return fmt.Errorf("invalid order: %s", f.Order)
+	}
+	if f.Limit < 0 || f.Offset < 0 {
+		return fmt.Errorf("limit and offset must not be negative")
+	}
+	if f.Limit > maxFunctionPageSize {
+		f.Limit = maxFunctionPageSize
+	}
+	tags, err := NormalizeTags(f.Tags)
+	if err != nil {
+		return err
+	}
+	f.Tags = tags
+	return nil
+}
+
+// orderBy returns the ORDER BY clause of the filter; ties are broken by ID so
+// that pages do not overlap. Functions never invoked sort last either way.
+func (f *FunctionFilter) orderBy() string {
+	order := "DESC"
+	if f.Order == "asc" {
+		order = "ASC"
+	}
+	switch f.Sort {
+	case models.FunctionSortLastInvokedAt:
+		return ` ORDER BY last_invoked_at ` + order + ` NULLS LAST, id ` + order
+	default:
+		return ` ORDER BY ` + f.Sort + ` ` + order + `, id ` + order
+	}
+}
+
+// searchTerms splits the query into lowercase words of letters and digits
+func (f *FunctionFilter) searchTerms() []string {
+	return strings.FieldsFunc(strings.ToLower(f.Query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// matches applies the filter to a function in memory, except for visibility.
+// Search terms match anywhere in the name or description, as with SQLite;
+// Postgres matches them against the start of words.
+func (f *FunctionFilter) matches(fn *models.Function) bool {
+	if f.Runtime != "" && fn.Runtime != f.Runtime {
+		return false
+	}
+	for _, tag := range f.Tags {
+		if !containsString(fn.Tags, tag) {
+			return false
+		}
+	}
+	text := strings.ToLower(fn.Name + " " + fn.Description)
+	for _, term := range f.searchTerms() {
+		if !strings.Contains(text, term) {
+			return false
+		}
+	}
+	return true
+}
+
+// NormalizeTags lowercases, validates, deduplicates and sorts tags
+func NormalizeTags(tags []string) ([]string, error) {
+	normalized := []string{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if !tagPattern.MatchString(tag) {
+			return nil, fmt.Errorf("invalid tag %q: use up to 63 lowercase letters, digits and _.:/=-", tag)
+		}
+		if !containsString(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	if len(normalized) > maxFunctionTags {
+		return nil, fmt.Errorf("a function can have at most %d tags", maxFunctionTags)
+	}
+	sort.Strings(normalized)
+	return normalized, nil
+}
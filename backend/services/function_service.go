@@ -2,44 +2,107 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"time"
 
+	"lambda-runner-server/middleware"
 	"lambda-runner-server/models"
 )
 
 type FunctionService struct {
-	db      *DBService
-	storage StorageService
-	redis   *RedisService
+	db          Store
+	storage     StorageService
+	queue       ExecutionQueue
+	limiter     *ConcurrencyLimiter
+	rateLimiter *RateLimitService
 }
 
-func NewFunctionService(db *DBService, storage StorageService, redis *RedisService) *FunctionService {
+func NewFunctionService(db Store, storage StorageService, queue ExecutionQueue) *FunctionService {
 	return &FunctionService{
 		db:      db,
 		storage: storage,
-		redis:   redis,
+		queue:   queue,
 	}
 }
 
+// UseConcurrencyLimiter enables per-function concurrency limits on invoke
+func (s *FunctionService) UseConcurrencyLimiter(limiter *ConcurrencyLimiter) {
+	s.limiter = limiter
+}
+
+// UseRateLimiter enables rate limits and quotas on invoke
+func (s *FunctionService) UseRateLimiter(rateLimiter *RateLimitService) {
+	s.rateLimiter = rateLimiter
+}
+
 // CreateFunction creates a new function with code stored in storage
 func (s *FunctionService) CreateFunction(ctx context.Context, req *models.CreateFunctionRequest) (*models.Function, error) {
+	settings := &models.UpdateConcurrencyRequest{
+		MaxConcurrency:      req.MaxConcurrency,
+		ReservedConcurrency: req.ReservedConcurrency,
+		OverflowPolicy:      req.OverflowPolicy,
+	}
+	if err := validateConcurrencySettings(settings); err != nil {
+		return nil, err
+	}
+	tags, err := NormalizeTags(req.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	ns, err := s.db.GetNamespace(ctx, requestNamespace(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if ns == nil {
+		return nil, fmt.Errorf("namespace not found: %d", requestNamespace(ctx))
+	}
+	if ns.MaxFunctions > 0 {
+		count, _, err := s.db.CountNamespaceResources(ctx, ns.ID)
+		if err != nil {
+			return nil, err
+		}
+		if count >= ns.MaxFunctions {
+			return nil, ErrFunctionQuotaExceeded
+		}
+	}
+
 	fn := &models.Function{
-		Name:        req.Name,
-		Description: req.Description,
-		Runtime:     req.Runtime,
-		SampleEvent: req.SampleEvent,
-		Params:      req.Params,
+		NamespaceID:         ns.ID,
+		Name:                req.Name,
+		Description:         req.Description,
+		Runtime:             req.Runtime,
+		SampleEvent:         req.SampleEvent,
+		Params:              req.Params,
+		Tags:                tags,
+		MaxConcurrency:      settings.MaxConcurrency,
+		ReservedConcurrency: settings.ReservedConcurrency,
+		OverflowPolicy:      settings.OverflowPolicy,
+		CallbackURL:         req.CallbackURL,
+		CallbackSecret:      req.CallbackSecret,
+		IsPublic:            req.IsPublic == nil || *req.IsPublic,
+	}
+	if key := middleware.APIKeyFromContext(ctx); key != nil {
+		fn.Owner = key.Principal()
+	}
+	if s.limiter != nil {
+		if err := s.limiter.ValidateSettings(ctx, fn, settings); err != nil {
+			return nil, err
+		}
 	}
 
 	// Create function in DB first to get ID
 	fn.CodeS3Key = "temp" // Will be updated after we have the ID
+	fn.CreatedByKeyID = middleware.APIKeyID(ctx)
 	created, err := s.db.CreateFunction(ctx, fn)
 	if err != nil {
 		return nil, err
 	}
 
 	// Generate storage key and save code
-	codeKey := GenerateCodeKey(created.ID, created.Runtime)
+	codeKey := GenerateCodeKey(ns.Name, created.ID, created.Runtime)
 	if err := s.storage.SaveCode(ctx, codeKey, req.Code); err != nil {
 		return nil, err
 	}
@@ -51,10 +114,16 @@ func (s *FunctionService) CreateFunction(ctx context.Context, req *models.Create
 	created.CodeS3Key = codeKey
 	created.Code = req.Code
 
+	if s.limiter != nil && created.ReservedConcurrency > 0 {
+		s.limiter.invalidate()
+	}
+
+	audit(ctx, s.db, models.AuditCreate, models.AuditFunction, created.ID, nil, created)
 	return created, nil
 }
 
-// GetFunction retrieves a function by ID
+// GetFunction retrieves a function by ID. The code of private functions is
+// only returned to callers with read access.
 func (s *FunctionService) GetFunction(ctx context.Context, id int64) (*models.Function, error) {
 	fn, err := s.db.GetFunction(ctx, id)
 	if err != nil {
@@ -64,6 +133,15 @@ func (s *FunctionService) GetFunction(ctx context.Context, id int64) (*models.Fu
 		return nil, fmt.Errorf("function not found: %d", id)
 	}
 
+	access, err := functionAccess(ctx, s.db, fn)
+	if err != nil {
+		return nil, err
+	}
+	if !models.AccessAllows(access, models.AccessRead) {
+		fn.CodeS3Key = ""
+		return fn, nil
+	}
+
 	// Load code from storage
 	code, err := s.storage.GetCode(ctx, fn.CodeS3Key)
 	if err != nil {
@@ -74,55 +152,297 @@ func (s *FunctionService) GetFunction(ctx context.Context, id int64) (*models.Fu
 	return fn, nil
 }
 
-// ListFunctions returns all functions
-func (s *FunctionService) ListFunctions(ctx context.Context) ([]models.FunctionListItem, error) {
-	return s.db.ListFunctions(ctx)
+// getInvocableFunction loads a function the caller may invoke, with its code
+func (s *FunctionService) getInvocableFunction(ctx context.Context, id int64) (*models.Function, error) {
+	fn, err := getAuthorizedFunction(ctx, s.db, id, models.AccessInvoke)
+	if err != nil {
+		return nil, err
+	}
+	code, err := s.storage.GetCode(ctx, fn.CodeS3Key)
+	if err != nil {
+		return nil, err
+	}
+	fn.Code = code
+	return fn, nil
+}
+
+// ListFunctions returns a page of the functions matching the filter and the
+// number of all matching functions
+func (s *FunctionService) ListFunctions(ctx context.Context, filter FunctionFilter) ([]models.FunctionListItem, int, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, 0, err
+	}
+	return s.db.ListFunctions(ctx, filter)
+}
+
+// UpdateTags replaces the tags of a function
+func (s *FunctionService) UpdateTags(ctx context.Context, id int64, req *models.UpdateTagsRequest) (*models.Function, error) {
+	tags, err := NormalizeTags(req.Tags)
+	if err != nil {
+		return nil, err
+	}
+	fn, err := getAuthorizedFunction(ctx, s.db, id, models.AccessAdmin)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.UpdateFunctionTags(ctx, id, tags); err != nil {
+		return nil, err
+	}
+	before := *fn
+	fn.Tags = tags
+	audit(ctx, s.db, models.AuditUpdate, models.AuditFunction, id, &before, fn)
+	return fn, nil
+}
+
+// InvokeOptions carries per-invocation settings for InvokeFunction
+type InvokeOptions struct {
+	InvokedBy string
+	Priority  string // one of models.Priority*, defaults to normal
+
+	// Completion callback; when empty the function's default applies
+	CallbackURL    string
+	CallbackSecret string
+
+	// Replays link to the invocation they re-run, and may require the
+	// function to still run a given code version
+	ReplayOf    *int64
+	CodeVersion string
 }
 
 // InvokeFunction executes a function and returns invocation ID
-func (s *FunctionService) InvokeFunction(ctx context.Context, functionID int64, params map[string]interface{}, invokedBy string) (*models.Invocation, error) {
+func (s *FunctionService) InvokeFunction(ctx context.Context, functionID int64, params map[string]interface{}, opts InvokeOptions) (*models.Invocation, error) {
+	priority, err := NormalizePriority(opts.Priority, models.PriorityNormal)
+	if err != nil {
+		return nil, err
+	}
+	invokedBy := opts.InvokedBy
+
 	// Get function
-	fn, err := s.GetFunction(ctx, functionID)
+	fn, err := s.getInvocableFunction(ctx, functionID)
 	if err != nil {
 		return nil, err
 	}
+	version := codeVersion(fn.Code)
+	if opts.CodeVersion != "" && opts.CodeVersion != version {
+		return nil, ErrCodeVersionChanged
+	}
+
+	var admission *InvokeAdmission
+	if s.rateLimiter != nil {
+		if admission, err = s.rateLimiter.CheckInvoke(ctx, functionID, invokedBy); err != nil {
+			return nil, err
+		}
+	}
 
 	// Create invocation record
 	inv := &models.Invocation{
-		FunctionID: functionID,
-		InputEvent: params,
-		InvokedBy:  invokedBy,
-		Status:     models.StatusPending,
+		FunctionID:     functionID,
+		InputEvent:     params,
+		InvokedBy:      invokedBy,
+		Status:         models.StatusPending,
+		CallbackURL:    opts.CallbackURL,
+		CallbackSecret: opts.CallbackSecret,
+		APIKeyID:       middleware.APIKeyID(ctx),
+		CodeVersion:    version,
+		ReplayOf:       opts.ReplayOf,
 	}
 
 	created, err := s.db.CreateInvocation(ctx, inv)
 	if err != nil {
+		s.refundInvoke(ctx, admission)
 		return nil, err
 	}
 
-	// Push to Redis queue
+	// Push to the execution queue
 	execReq := &models.ExecutionRequest{
 		InvocationID: created.ID,
 		FunctionID:   functionID,
 		Code:         fn.Code,
 		Input:        params,
 		Runtime:      fn.Runtime,
+		Priority:     priority,
 	}
 
-	queueName := getQueueName(fn.Runtime)
-	if err := s.redis.PushExecutionRequest(ctx, queueName, execReq); err != nil {
+	if err := s.dispatch(ctx, fn, execReq); err != nil {
+		var throttleErr *ThrottleError
+		if errors.As(err, &throttleErr) {
+			s.db.SetInvocationStatus(ctx, created.ID, models.StatusThrottled, err.Error())
+		}
+		s.refundInvoke(ctx, admission)
 		return nil, err
 	}
 
 	return created, nil
 }
 
+// refundInvoke gives back the rate limit tokens and quota of a request that
+// was admitted but could not be started
+func (s *FunctionService) refundInvoke(ctx context.Context, admission *InvokeAdmission) {
+	if admission == nil {
+		return
+	}
+	if err := s.rateLimiter.Refund(ctx, admission); err != nil {
+		log.Printf("rate limit: failed to refund rejected invocation: %v", err)
+	}
+}
+
+// dispatch pushes an execution request to its runtime queue. When the function
+// is at its concurrency limit the request is either rejected or parked in the
+// function's overflow queue, depending on its overflow policy.
+func (s *FunctionService) dispatch(ctx context.Context, fn *models.Function, execReq *models.ExecutionRequest) error {
+	if s.limiter != nil {
+		acquired, err := s.limiter.Acquire(ctx, fn, execReq.InvocationID)
+		if err != nil {
+			return err
+		}
+		if !acquired {
+			if fn.OverflowPolicy == models.OverflowQueue {
+				return s.queue.PushOverflow(ctx, fn.ID, execReq)
+			}
+			return &ThrottleError{
+				Reason:     fmt.Sprintf("function %d is at its concurrency limit", fn.ID),
+				RetryAfter: time.Second,
+			}
+		}
+	}
+
+	queueName := executionQueueName(fn.Runtime, execReq.Priority)
+	if err := s.queue.PushExecutionRequest(ctx, queueName, execReq); err != nil {
+		if s.limiter != nil {
+			s.limiter.Release(ctx, fn, execReq.InvocationID)
+		}
+		return err
+	}
+	return nil
+}
+
+// drainOverflow moves requests from a function's overflow queue to its runtime
+// queue for as long as concurrency slots are available
+func (s *FunctionService) drainOverflow(ctx context.Context, fn *models.Function) error {
+	for {
+		execReq, err := s.queue.PopOverflow(ctx, fn.ID)
+		if err != nil || execReq == nil {
+			return err
+		}
+
+		acquired, err := s.limiter.Acquire(ctx, fn, execReq.InvocationID)
+		if err != nil || !acquired {
+			if requeueErr := s.queue.RequeueOverflow(ctx, fn.ID, execReq); requeueErr != nil {
+				return requeueErr
+			}
+			return err
+		}
+
+		if err := s.queue.PushExecutionRequest(ctx, executionQueueName(fn.Runtime, execReq.Priority), execReq); err != nil {
+			s.limiter.Release(ctx, fn, execReq.InvocationID)
+			s.queue.RequeueOverflow(ctx, fn.ID, execReq)
+			return err
+		}
+	}
+}
+
+// DrainOverflowQueues retries dispatching parked requests for every function
+// with a non-empty overflow queue (covers slots reclaimed by lease expiry)
+func (s *FunctionService) DrainOverflowQueues(ctx context.Context) {
+	if s.limiter == nil {
+		return
+	}
+
+	functionIDs, err := s.queue.ListOverflowFunctions(ctx)
+	if err != nil {
+		log.Printf("concurrency: failed to list overflow queues: %v", err)
+		return
+	}
+	for _, functionID := range functionIDs {
+		fn, err := s.db.GetFunction(ctx, functionID)
+		if err != nil || fn == nil {
+			continue
+		}
+		if err := s.drainOverflow(ctx, fn); err != nil {
+			log.Printf("concurrency: failed to drain overflow queue for function %d: %v", functionID, err)
+		}
+	}
+}
+
+// UpdateConcurrency changes the concurrency settings of a function
+func (s *FunctionService) UpdateConcurrency(ctx context.Context, id int64, req *models.UpdateConcurrencyRequest) (*models.Function, error) {
+	if err := validateConcurrencySettings(req); err != nil {
+		return nil, err
+	}
+
+	fn, err := getAuthorizedFunction(ctx, s.db, id, models.AccessAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.limiter != nil {
+		if err := s.limiter.ValidateSettings(ctx, fn, req); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.db.UpdateFunctionConcurrency(ctx, id, req.MaxConcurrency, req.ReservedConcurrency, req.OverflowPolicy); err != nil {
+		return nil, err
+	}
+	before := *fn
+	fn.MaxConcurrency = req.MaxConcurrency
+	fn.ReservedConcurrency = req.ReservedConcurrency
+	fn.OverflowPolicy = req.OverflowPolicy
+	audit(ctx, s.db, models.AuditUpdate, models.AuditFunction, id, &before, fn)
+
+	if s.limiter != nil {
+		s.limiter.invalidate()
+		// A raised limit may free room for parked requests
+		if err := s.drainOverflow(ctx, fn); err != nil {
+			log.Printf("concurrency: failed to drain overflow queue for function %d: %v", id, err)
+		}
+	}
+
+	return fn, nil
+}
+
+// GetConcurrencyStatus returns the concurrency settings and usage of a function
+func (s *FunctionService) GetConcurrencyStatus(ctx context.Context, id int64) (*models.ConcurrencyStatus, error) {
+	fn, err := getAuthorizedFunction(ctx, s.db, id, models.AccessRead)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.limiter == nil {
+		return &models.ConcurrencyStatus{
+			FunctionID:          fn.ID,
+			MaxConcurrency:      fn.MaxConcurrency,
+			ReservedConcurrency: fn.ReservedConcurrency,
+			OverflowPolicy:      fn.OverflowPolicy,
+		}, nil
+	}
+	return s.limiter.Status(ctx, fn)
+}
+
+// validateConcurrencySettings checks and normalizes concurrency settings
+func validateConcurrencySettings(req *models.UpdateConcurrencyRequest) error {
+	if req.MaxConcurrency < 0 || req.ReservedConcurrency < 0 {
+		return fmt.Errorf("concurrency settings must not be negative")
+	}
+	if req.MaxConcurrency > 0 && req.ReservedConcurrency > req.MaxConcurrency {
+		return fmt.Errorf("reserved_concurrency must not exceed max_concurrency")
+	}
+	switch req.OverflowPolicy {
+	case "":
+		req.OverflowPolicy = models.OverflowThrottle
+	case models.OverflowThrottle, models.OverflowQueue:
+	default:
+		return fmt.Errorf("overflow_policy must be %q or %q", models.OverflowThrottle, models.OverflowQueue)
+	}
+	return nil
+}
+
 // GetInvocation retrieves an invocation by ID
 func (s *FunctionService) GetInvocation(ctx context.Context, id int64) (*models.Invocation, error) {
 	return s.db.GetInvocation(ctx, id)
 }
 
-// GetInvocationResult polls Redis for result and updates DB
+// GetInvocationResult polls the queue for a result and updates DB
 func (s *FunctionService) GetInvocationResult(ctx context.Context, invocationID int64) (*models.Invocation, error) {
 	// First check if result is already in DB
 	inv, err := s.db.GetInvocation(ctx, invocationID)
@@ -132,31 +452,23 @@ func (s *FunctionService) GetInvocationResult(ctx context.Context, invocationID
 	if inv == nil {
 		return nil, fmt.Errorf("invocation not found: %d", invocationID)
 	}
+	if _, err := getAuthorizedFunction(ctx, s.db, inv.FunctionID, models.AccessRead); err != nil {
+		return nil, err
+	}
 
 	// If already completed, return from DB
 	if inv.Status != models.StatusPending {
 		return inv, nil
 	}
 
-	// Check Redis for result
-	result, err := s.redis.GetResult(ctx, invocationID)
+	// Check the queue for a result
+	result, err := s.queue.GetResult(ctx, invocationID)
 	if err != nil {
 		return nil, err
 	}
 
 	if result != nil {
-		// Update DB with result
-		status := result.Status
-		if status == "SUCCESS" {
-			status = models.StatusSuccess
-		} else if status == "ERROR" {
-			status = models.StatusFail
-		} else if status == "TIMEOUT" {
-			status = models.StatusTimeout
-		}
-
-		err = s.db.UpdateInvocationResult(ctx, invocationID, status, result.Output, result.ErrorMessage, result.DurationMs)
-		if err != nil {
+		if err := s.completeInvocation(ctx, inv, result); err != nil {
 			return nil, err
 		}
 
@@ -168,13 +480,305 @@ func (s *FunctionService) GetInvocationResult(ctx context.Context, invocationID
 	return inv, nil
 }
 
-// ListInvocations returns invocations for a function
-func (s *FunctionService) ListInvocations(ctx context.Context, functionID int64, limit int) ([]models.InvocationListItem, error) {
-	return s.db.ListInvocations(ctx, functionID, limit)
+// maxResultPollInterval caps the backoff between polls of WaitForResult
+const maxResultPollInterval = time.Second
+
+// WaitForResult polls an invocation until it leaves pending or the timeout
+// elapses. Polls start interval apart and back off exponentially up to
+// maxResultPollInterval, so slow invocations cost few queries. On timeout
+// the still-pending invocation is returned.
+func (s *FunctionService) WaitForResult(ctx context.Context, invocationID int64, timeout, interval time.Duration) (*models.Invocation, error) {
+	deadline := time.Now().Add(timeout)
+	var last *models.Invocation
+
+	for {
+		inv, err := s.GetInvocationResult(ctx, invocationID)
+		if err != nil {
+			log.Printf("failed to get result of invocation %d: %v", invocationID, err)
+		} else {
+			last = inv
+			if inv.Status != models.StatusPending {
+				return inv, nil
+			}
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			break
+		}
+		wait := interval
+		if wait > remaining {
+			wait = remaining
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		if interval *= 2; interval > maxResultPollInterval {
+			interval = maxResultPollInterval
+		}
+	}
+
+	if last == nil {
+		return nil, fmt.Errorf("invocation not found: %d", invocationID)
+	}
+	return last, nil
+}
+
+// completeInvocation persists a worker result and, if this call moved the
+// invocation out of pending, runs the completion side effects
+func (s *FunctionService) completeInvocation(ctx context.Context, inv *models.Invocation, result *models.ExecutionResult) error {
+	// Update DB with result
+	status := result.Status
+	if status == "SUCCESS" {
+		status = models.StatusSuccess
+	} else if status == "ERROR" {
+		status = models.StatusFail
+	} else if status == "TIMEOUT" {
+		status = models.StatusTimeout
+	} else if status == "CANCELLED" {
+		status = models.StatusCancelled
+	}
+
+	updated, err := s.db.UpdateInvocationResult(ctx, inv.ID, status, result.Output, result.ErrorMessage, result.DurationMs)
+	if err != nil {
+		return err
+	}
+	if updated {
+		inv.Status = status
+		inv.DurationMs = result.DurationMs
+		s.onInvocationFinished(ctx, inv)
+	}
+	return nil
+}
+
+// onInvocationFinished records usage, releases resources held by an
+// invocation that reached a final status and schedules its callback
+func (s *FunctionService) onInvocationFinished(ctx context.Context, inv *models.Invocation) {
+	s.enqueueCallback(ctx, inv.ID)
+
+	if s.rateLimiter != nil {
+		if err := s.rateLimiter.RecordCompute(ctx, inv.FunctionID, inv.InvokedBy, inv.DurationMs); err != nil {
+			log.Printf("quota: failed to record compute for invocation %d: %v", inv.ID, err)
+		}
+	}
+
+	if s.limiter != nil {
+		s.releaseSlot(ctx, inv)
+	}
+
+	if inv.BatchID != nil {
+		s.advanceBatch(ctx, inv.FunctionID, *inv.BatchID)
+	}
+}
+
+// enqueueCallback schedules delivery of the final result to the invocation's callback URL
+func (s *FunctionService) enqueueCallback(ctx context.Context, invocationID int64) {
+	if _, err := s.db.EnqueueCallback(ctx, invocationID); err != nil {
+		log.Printf("callbacks: failed to enqueue callback for invocation %d: %v", invocationID, err)
+	}
+}
+
+// UpdateCallback sets the default completion callback of a function
+func (s *FunctionService) UpdateCallback(ctx context.Context, id int64, req *models.UpdateCallbackRequest) (*models.Function, error) {
+	fn, err := getAuthorizedFunction(ctx, s.db, id, models.AccessAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.db.UpdateFunctionCallback(ctx, id, req.CallbackURL, req.CallbackSecret); err != nil {
+		return nil, err
+	}
+	before := *fn
+	fn.CallbackURL = req.CallbackURL
+	fn.CallbackSecret = req.CallbackSecret
+	audit(ctx, s.db, models.AuditUpdate, models.AuditFunction, id, &before, fn)
+	return fn, nil
+}
+
+// GetInvocationCallback returns the callback delivery log of an invocation
+func (s *FunctionService) GetInvocationCallback(ctx context.Context, functionID, invocationID int64) (*models.InvocationCallback, error) {
+	inv, err := s.db.GetInvocation(ctx, invocationID)
+	if err != nil {
+		return nil, err
+	}
+	if inv == nil || inv.FunctionID != functionID {
+		return nil, fmt.Errorf("invocation not found: %d", invocationID)
+	}
+	if _, err := getAuthorizedFunction(ctx, s.db, functionID, models.AccessRead); err != nil {
+		return nil, err
+	}
+
+	cb, err := s.db.GetInvocationCallback(ctx, invocationID)
+	if err != nil {
+		return nil, err
+	}
+	if cb == nil {
+		return nil, fmt.Errorf("no callback registered for invocation %d", invocationID)
+	}
+	return cb, nil
+}
+
+// releaseSlot frees the concurrency slot of an invocation and lets parked requests run
+func (s *FunctionService) releaseSlot(ctx context.Context, inv *models.Invocation) {
+	fn, err := s.db.GetFunction(ctx, inv.FunctionID)
+	if err != nil || fn == nil {
+		return
+	}
+	released, err := s.limiter.Release(ctx, fn, inv.ID)
+	if err != nil {
+		log.Printf("concurrency: failed to release slot for invocation %d: %v", inv.ID, err)
+		return
+	}
+	if released {
+		if err := s.drainOverflow(ctx, fn); err != nil {
+			log.Printf("concurrency: failed to drain overflow queue for function %d: %v", fn.ID, err)
+		}
+	}
+}
+
+// CollectResults persists worker results for the invocations pending since
+// the given time. It pages through all of them, pageSize at a time, so
+// invocations that stay pending (throttled, parked or still queued) cannot
+// crowd out newer ones that have finished.
+func (s *FunctionService) CollectResults(ctx context.Context, since time.Time, pageSize int) (int, error) {
+	if pageSize <= 0 {
+		pageSize = 200
+	}
+	collected := 0
+	after := InvocationCursor{InvokedAt: since}
+	for {
+		pending, err := s.db.ListPendingInvocations(ctx, after, pageSize)
+		if err != nil {
+			return collected, err
+		}
+
+		for i := range pending {
+			inv := &pending[i]
+			result, err := s.queue.GetResult(ctx, inv.ID)
+			if err != nil {
+				return collected, err
+			}
+			if result == nil {
+				continue
+			}
+			if err := s.completeInvocation(ctx, inv, result); err != nil {
+				return collected, err
+			}
+			collected++
+		}
+
+		if len(pending) < pageSize {
+			return collected, nil
+		}
+		last := pending[len(pending)-1]
+		after = InvocationCursor{InvokedAt: last.InvokedAt, ID: last.ID}
+	}
+}
+
+// CancelInvocation stops a pending invocation. A request still waiting in a
+// queue is removed from it; otherwise the worker running it is signalled.
+func (s *FunctionService) CancelInvocation(ctx context.Context, functionID, invocationID int64) (*models.Invocation, error) {
+	inv, err := s.db.GetInvocation(ctx, invocationID)
+	if err != nil {
+		return nil, err
+	}
+	if inv == nil || inv.FunctionID != functionID {
+		return nil, fmt.Errorf("invocation not found: %d", invocationID)
+	}
+
+	fn, err := getAuthorizedFunction(ctx, s.db, functionID, models.AccessInvoke)
+	if err != nil {
+		return nil, err
+	}
+
+	if inv.Status != models.StatusPending {
+		return nil, ErrInvocationFinished
+	}
+
+	cancelled, err := s.db.SetInvocationStatus(ctx, invocationID, models.StatusCancelled, "invocation cancelled")
+	if err != nil {
+		return nil, err
+	}
+	if !cancelled {
+		return nil, ErrInvocationFinished
+	}
+
+	if inv.BatchID != nil {
+		// An item still parked behind its batch's max parallelism holds
+		// nothing and frees no parallelism slot
+		parked, err := s.queue.RemoveQueuedRequest(ctx, []string{batchQueueKey(*inv.BatchID)}, invocationID)
+		if err != nil {
+			return nil, err
+		}
+		if parked {
+			s.enqueueCallback(ctx, invocationID)
+			return s.db.GetInvocation(ctx, invocationID)
+		}
+	}
+
+	removed, err := s.queue.RemoveQueuedRequest(ctx, cancelQueueKeys(fn), invocationID)
+	if err != nil {
+		return nil, err
+	}
+	if !removed {
+		// Already picked up by a worker (or about to be): tombstone it and
+		// tell the worker to kill the running process
+		if err := s.queue.SignalCancel(ctx, invocationID); err != nil {
+			return nil, err
+		}
+	}
+
+	inv.Status = models.StatusCancelled
+	s.onInvocationFinished(ctx, inv)
+
+	return s.db.GetInvocation(ctx, invocationID)
+}
+
+// cancelQueueKeys returns every queue a request of fn may wait in
+func cancelQueueKeys(fn *models.Function) []string {
+	return []string{
+		executionQueueName(fn.Runtime, models.PriorityHigh),
+		executionQueueName(fn.Runtime, models.PriorityNormal),
+		executionQueueName(fn.Runtime, models.PriorityLow),
+		overflowQueueKey(fn.ID),
+	}
+}
+
+// ListInvocations returns a page of the invocations matching the filter and
+// the cursor of the next page, nil on the last page. With a FunctionID the
+// caller needs read access to that function; otherwise the invocations of
+// every function the caller may see are searched.
+func (s *FunctionService) ListInvocations(ctx context.Context, filter InvocationFilter, limit int) ([]models.InvocationListItem, *InvocationCursor, error) {
+	if filter.FunctionID != 0 {
+		if _, err := getAuthorizedFunction(ctx, s.db, filter.FunctionID, models.AccessRead); err != nil {
+			return nil, nil, err
+		}
+	}
+	if limit <= 0 {
+		limit = 20
+	} else if limit > maxInvocationPageSize {
+		limit = maxInvocationPageSize
+	}
+
+	invocations, err := s.db.ListInvocations(ctx, filter, limit+1)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(invocations) <= limit {
+		return invocations, nil, nil
+	}
+	invocations = invocations[:limit]
+	return invocations, NewInvocationCursor(&invocations[limit-1]), nil
 }
 
 // DeleteFunction removes the function and its stored code
 func (s *FunctionService) DeleteFunction(ctx context.Context, id int64) (*models.Function, error) {
+	if _, err := getAuthorizedFunction(ctx, s.db, id, models.AccessAdmin); err != nil {
+		return nil, err
+	}
+
 	fn, err := s.db.DeleteFunction(ctx, id)
 	if err != nil {
 		return nil, err
@@ -182,6 +786,7 @@ func (s *FunctionService) DeleteFunction(ctx context.Context, id int64) (*models
 	if fn == nil {
 		return nil, fmt.Errorf("function not found: %d", id)
 	}
+	audit(ctx, s.db, models.AuditDelete, models.AuditFunction, id, fn, nil)
 
 	if fn.CodeS3Key != "" {
 		if err := s.storage.DeleteCode(ctx, fn.CodeS3Key); err != nil {
@@ -192,6 +797,30 @@ func (s *FunctionService) DeleteFunction(ctx context.Context, id int64) (*models
 	return fn, nil
 }
 
+// NormalizePriority validates a priority, returning def when it is empty
+func NormalizePriority(priority, def string) (string, error) {
+	switch priority {
+	case "":
+		return def, nil
+	case models.PriorityHigh, models.PriorityNormal, models.PriorityLow:
+		return priority, nil
+	default:
+		return "", fmt.Errorf("priority must be %q, %q or %q", models.PriorityHigh, models.PriorityNormal, models.PriorityLow)
+	}
+}
+
+// executionQueueName returns the queue of a runtime's priority lane. The
+// normal lane keeps the plain runtime queue name.
+func executionQueueName(runtime, priority string) string {
+	queueName := getQueueName(runtime)
+	switch priority {
+	case models.PriorityHigh, models.PriorityLow:
+		return queueName + ":" + priority
+	default:
+		return queueName
+	}
+}
+
 // getQueueName returns the Redis queue name based on runtime
 func getQueueName(runtime string) string {
 	queueMap := map[string]string{
@@ -0,0 +1,260 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"lambda-runner-server/middleware"
+	"lambda-runner-server/models"
+)
+
+// MaxBatchSize caps the number of items accepted by a single batch invoke
+const MaxBatchSize = 1000
+
+// InvokeBatch fans a function out over many inputs. All invocations are
+// created in one transaction and queued in one round trip; with max
+// parallelism set, the remaining items start as earlier ones finish.
+func (s *FunctionService) InvokeBatch(ctx context.Context, functionID int64, req *models.BatchInvokeRequest, opts InvokeOptions) (*models.BatchInvokeResponse, error) {
+	if len(req.Params) == 0 {
+		return nil, fmt.Errorf("params must contain at least one item")
+	}
+	if len(req.Params) > MaxBatchSize {
+		return nil, fmt.Errorf("batch must not contain more than %d items", MaxBatchSize)
+	}
+	if req.MaxParallelism < 0 {
+		return nil, fmt.Errorf("max_parallelism must not be negative")
+	}
+	priority, err := NormalizePriority(opts.Priority, models.PriorityNormal)
+	if err != nil {
+		return nil, err
+	}
+
+	fn, err := s.getInvocableFunction(ctx, functionID)
+	if err != nil {
+		return nil, err
+	}
+
+	var admission *InvokeAdmission
+	if s.rateLimiter != nil {
+		if admission, err = s.rateLimiter.CheckInvokeN(ctx, functionID, opts.InvokedBy, int64(len(req.Params))); err != nil {
+			return nil, err
+		}
+	}
+
+	version := codeVersion(fn.Code)
+	invocations := make([]*models.Invocation, len(req.Params))
+	for i, params := range req.Params {
+		invocations[i] = &models.Invocation{
+			FunctionID:     functionID,
+			InputEvent:     params,
+			InvokedBy:      opts.InvokedBy,
+			Status:         models.StatusPending,
+			CallbackURL:    opts.CallbackURL,
+			CallbackSecret: opts.CallbackSecret,
+			APIKeyID:       middleware.APIKeyID(ctx),
+			CodeVersion:    version,
+		}
+	}
+
+	batch, err := s.db.CreateBatch(ctx, &models.InvocationBatch{
+		FunctionID:     functionID,
+		Total:          len(invocations),
+		MaxParallelism: req.MaxParallelism,
+		Priority:       priority,
+		InvokedBy:      opts.InvokedBy,
+	}, invocations)
+	if err != nil {
+		s.refundInvoke(ctx, admission)
+		return nil, err
+	}
+
+	execReqs := make([]*models.ExecutionRequest, len(invocations))
+	ids := make([]int64, len(invocations))
+	for i, inv := range invocations {
+		ids[i] = inv.ID
+		execReqs[i] = &models.ExecutionRequest{
+			InvocationID: inv.ID,
+			FunctionID:   functionID,
+			Code:         fn.Code,
+			Input:        inv.InputEvent,
+			Runtime:      fn.Runtime,
+			Priority:     priority,
+		}
+	}
+
+	immediate := execReqs
+	if batch.MaxParallelism > 0 && batch.MaxParallelism < len(execReqs) {
+		immediate = execReqs[:batch.MaxParallelism]
+		// Park the rest before starting anything, so an early finisher always
+		// finds the next item
+		if err := s.queue.PushBatchPending(ctx, batch.ID, execReqs[batch.MaxParallelism:]); err != nil {
+			s.failBatchItems(ctx, execReqs, err)
+			s.refundInvoke(ctx, admission)
+			return nil, err
+		}
+	}
+
+	if unqueued, err := s.dispatchBatch(ctx, fn, immediate); err != nil {
+		// The parked items are dropped too: the batch is reported as failed,
+		// so nothing should start after it
+		notStarted := append(unqueued[:len(unqueued):len(unqueued)], execReqs[len(immediate):]...)
+		s.drainBatchPending(ctx, batch.ID)
+		s.failBatchItems(ctx, notStarted, err)
+		if len(notStarted) == len(execReqs) {
+			s.refundInvoke(ctx, admission)
+		} else {
+			s.refundInvoke(ctx, admission.Items(int64(len(notStarted))))
+		}
+		return nil, err
+	}
+
+	return &models.BatchInvokeResponse{
+		BatchID:       batch.ID,
+		FunctionID:    functionID,
+		Total:         batch.Total,
+		InvocationIDs: ids,
+		CreatedAt:     batch.CreatedAt,
+	}, nil
+}
+
+// batchFunction returns fn with its overflow policy forced to queue: batch
+// items are already accepted, so they wait for capacity instead of failing
+func batchFunction(fn *models.Function) *models.Function {
+	batchFn := *fn
+	batchFn.OverflowPolicy = models.OverflowQueue
+	return &batchFn
+}
+
+// dispatchBatch queues many requests of one function, taking a concurrency
+// slot for each and parking the ones over the limit in the overflow queue.
+// On failure it returns the requests that were not queued.
+func (s *FunctionService) dispatchBatch(ctx context.Context, fn *models.Function, reqs []*models.ExecutionRequest) ([]*models.ExecutionRequest, error) {
+	if len(reqs) == 0 {
+		return nil, nil
+	}
+	queueName := executionQueueName(fn.Runtime, reqs[0].Priority)
+
+	if s.limiter == nil {
+		if err := s.queue.PushExecutionRequests(ctx, queueName, reqs); err != nil {
+			return reqs, err
+		}
+		return nil, nil
+	}
+
+	var ready, overflow []*models.ExecutionRequest
+	for _, req := range reqs {
+		acquired, err := s.limiter.Acquire(ctx, fn, req.InvocationID)
+		if err != nil {
+			log.Printf("concurrency: failed to acquire slot for invocation %d: %v", req.InvocationID, err)
+		}
+		if acquired {
+			ready = append(ready, req)
+		} else {
+			overflow = append(overflow, req)
+		}
+	}
+
+	if err := s.queue.PushExecutionRequests(ctx, queueName, ready); err != nil {
+		for _, req := range ready {
+			s.limiter.Release(ctx, fn, req.InvocationID)
+		}
+		return reqs, err
+	}
+	if err := s.queue.PushOverflowRequests(ctx, fn.ID, overflow); err != nil {
+		return overflow, err
+	}
+	return nil, nil
+}
+
+// failBatchItems marks batch items that never started as failed and
+// schedules their callbacks
+func (s *FunctionService) failBatchItems(ctx context.Context, reqs []*models.ExecutionRequest, cause error) {
+	for _, req := range reqs {
+		failed, err := s.db.SetInvocationStatus(ctx, req.InvocationID, models.StatusFail, cause.Error())
+		if err != nil {
+			log.Printf("batch: failed to mark invocation %d as failed: %v", req.InvocationID, err)
+			continue
+		}
+		if failed {
+			s.enqueueCallback(ctx, req.InvocationID)
+		}
+	}
+}
+
+// drainBatchPending drops the parked items of a batch that failed to start
+func (s *FunctionService) drainBatchPending(ctx context.Context, batchID int64) {
+	for {
+		execReq, err := s.queue.PopBatchPending(ctx, batchID)
+		if err != nil {
+			log.Printf("batch: failed to drop parked items of batch %d: %v", batchID, err)
+			return
+		}
+		if execReq == nil {
+			return
+		}
+	}
+}
+
+// advanceBatch starts the next parked item of a batch after one finished.
+// An item that cannot be started is marked failed and the one after it is
+// tried instead, since nothing else would release the rest of the batch.
+func (s *FunctionService) advanceBatch(ctx context.Context, functionID, batchID int64) {
+	for {
+		execReq, err := s.queue.PopBatchPending(ctx, batchID)
+		if err != nil {
+			log.Printf("batch: failed to pop next item of batch %d: %v", batchID, err)
+			return
+		}
+		if execReq == nil {
+			return
+		}
+
+		fn, err := s.db.GetFunction(ctx, functionID)
+		if err == nil && fn == nil {
+			err = fmt.Errorf("function not found: %d", functionID)
+		}
+		if err == nil {
+			err = s.dispatch(ctx, batchFunction(fn), execReq)
+		}
+		if err == nil {
+			return
+		}
+		log.Printf("batch: failed to start invocation %d of batch %d: %v", execReq.InvocationID, batchID, err)
+		s.failBatchItems(ctx, []*models.ExecutionRequest{execReq}, err)
+	}
+}
+
+// GetBatch returns the aggregate progress and per-item results of a batch
+func (s *FunctionService) GetBatch(ctx context.Context, id int64) (*models.BatchStatus, error) {
+	batch, err := s.db.GetBatch(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if batch == nil {
+		return nil, fmt.Errorf("batch not found: %d", id)
+	}
+	if _, err := getAuthorizedFunction(ctx, s.db, batch.FunctionID, models.AccessRead); err != nil {
+		return nil, err
+	}
+
+	results, err := s.db.ListBatchInvocations(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &models.BatchStatus{
+		InvocationBatch: *batch,
+		Counts:          map[string]int{},
+		Results:         results,
+	}
+	for _, item := range results {
+		status.Counts[item.Status]++
+		if item.Status != models.StatusPending {
+			status.Completed++
+		}
+	}
+	status.Done = status.Completed == batch.Total
+
+	return status, nil
+}
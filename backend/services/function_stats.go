@@ -0,0 +1,136 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"lambda-runner-server/models"
+)
+
+// Limits of the statistics time series
+const (
+	minStatsBucket  = time.Minute
+	maxStatsBuckets = 1000
+)
+
+// InvocationStatsRow is one group of the invocations of a function in a
+// statistics query. Bucket is the index of the time bucket and Status the
+// status of the group, each nil when the group spans all of them. The
+// duration percentiles are only read from groups spanning all statuses.
+type InvocationStatsRow struct {
+	Bucket        *int64
+	Status        *string
+	Count         int64
+	P50, P90, P99 *float64
+}
+
+// finishedStatus reports whether invocations with the status ran to an end
+// and so have a duration
+func finishedStatus(status string) bool {
+	return status == models.StatusSuccess || status == models.StatusFail || status == models.StatusTimeout
+}
+
+// StatsRange is the time range of function statistics and the size of its buckets
+type StatsRange struct {
+	From, To time.Time
+	Bucket   time.Duration
+}
+
+// Validate checks the range and aligns From down to a multiple of the bucket
+// size, so that consecutive queries share buckets
+func (r *StatsRange) Validate() error {
+	if r.Bucket < minStatsBucket || r.Bucket%time.Second != 0 {
+		return fmt.Errorf("bucket must be whole seconds of at least %s", minStatsBucket)
+	}
+	r.From = r.From.UTC().Truncate(r.Bucket)
+	r.To = r.To.UTC()
+	if !r.From.Before(r.To) {
+		return fmt.Errorf("from must be before to")
+	}
+	if n := r.buckets(); n > maxStatsBuckets {
+		return fmt.Errorf("the range spans %d buckets, at most %d are allowed", n, maxStatsBuckets)
+	}
+	return nil
+}
+
+func (r *StatsRange) buckets() int64 {
+	return int64((r.To.Sub(r.From) + r.Bucket - 1) / r.Bucket)
+}
+
+// FunctionStats returns the invocation statistics of a function over a range
+func (s *FunctionService) FunctionStats(ctx context.Context, functionID int64, r StatsRange) (*models.FunctionStats, error) {
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	from, to, bucket, buckets := r.From, r.To, r.Bucket, r.buckets()
+
+	if _, err := getAuthorizedFunction(ctx, s.db, functionID, models.AccessRead); err != nil {
+		return nil, err
+	}
+	rows, err := s.db.InvocationStats(ctx, functionID, from, to, bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &models.FunctionStats{
+		FunctionID:    functionID,
+		From:          from,
+		To:            to,
+		BucketSeconds: int64(bucket / time.Second),
+		Summary:       models.InvocationStats{Counts: map[string]int64{}},
+		Buckets:       make([]models.StatsBucket, buckets),
+	}
+	for i := range stats.Buckets {
+		stats.Buckets[i] = models.StatsBucket{
+			Start:           from.Add(time.Duration(i) * bucket),
+			InvocationStats: models.InvocationStats{Counts: map[string]int64{}},
+		}
+	}
+	for _, row := range rows {
+		group := &stats.Summary
+		if row.Bucket != nil {
+			if *row.Bucket < 0 || *row.Bucket >= buckets {
+				continue
+			}
+			group = &stats.Buckets[*row.Bucket].InvocationStats
+		}
+		if row.Status != nil {
+			group.Counts[*row.Status] = row.Count
+			continue
+		}
+		group.Total = row.Count
+		group.P50Ms, group.P90Ms, group.P99Ms = row.P50, row.P90, row.P99
+	}
+	setErrorRate(&stats.Summary)
+	for i := range stats.Buckets {
+		setErrorRate(&stats.Buckets[i].InvocationStats)
+	}
+	return stats, nil
+}
+
+func setErrorRate(stats *models.InvocationStats) {
+	failed := stats.Counts[models.StatusFail] + stats.Counts[models.StatusTimeout]
+	if finished := failed + stats.Counts[models.StatusSuccess]; finished > 0 {
+		stats.ErrorRate = float64(failed) / float64(finished)
+	}
+}
+
+// percentile returns the p-th percentile of values with linear interpolation
+// between the closest ranks, like Postgres percentile_cont. It sorts values
+// and returns nil when there are none.
+func percentile(values []float64, p float64) *float64 {
+	if len(values) == 0 {
+		return nil
+	}
+	sort.Float64s(values)
+	pos := p * float64(len(values)-1)
+	lower := math.Floor(pos)
+	result := values[int(lower)]
+	if upper := math.Ceil(pos); upper != lower {
+		result += (values[int(upper)] - result) * (pos - lower)
+	}
+	return &result
+}
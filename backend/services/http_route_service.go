@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+
+	"lambda-runner-server/models"
+)
+
+const (
+	defaultRouteTimeoutSeconds = 30
+	maxRouteTimeoutSeconds     = 300
+)
+
+var routeSlugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// HTTPRouteService manages the public HTTP routes of functions
+type HTTPRouteService struct {
+	db *DBService
+}
+
+func NewHTTPRouteService(db *DBService) *HTTPRouteService {
+	return &HTTPRouteService{db: db}
+}
+
+// UpsertRoute creates or updates the HTTP route of a function. A token is
+// issued when token auth is first enabled or when rotation is requested.
+func (s *HTTPRouteService) UpsertRoute(ctx context.Context, functionID int64, req *models.UpsertHTTPRouteRequest) (*models.HTTPRoute, error) {
+	if !routeSlugPattern.MatchString(req.Slug) {
+		return nil, fmt.Errorf("slug must be 1-63 lowercase letters, digits or dashes")
+	}
+	switch req.AuthType {
+	case "":
+		req.AuthType = models.RouteAuthNone
+	case models.RouteAuthNone, models.RouteAuthToken:
+	default:
+		return nil, fmt.Errorf("auth_type must be %q or %q", models.RouteAuthNone, models.RouteAuthToken)
+	}
+	if req.TimeoutSeconds == 0 {
+		req.TimeoutSeconds = defaultRouteTimeoutSeconds
+	}
+	if req.TimeoutSeconds < 0 || req.TimeoutSeconds > maxRouteTimeoutSeconds {
+		return nil, fmt.Errorf("timeout_seconds must be between 1 and %d", maxRouteTimeoutSeconds)
+	}
+
+	if _, err := getAuthorizedFunction(ctx, s.db, functionID, models.AccessAdmin); err != nil {
+		return nil, err
+	}
+
+	taken, err := s.db.GetHTTPRouteBySlug(ctx, req.Slug)
+	if err != nil {
+		return nil, err
+	}
+	if taken != nil && taken.FunctionID != functionID {
+		return nil, ErrRouteSlugTaken
+	}
+
+	existing, err := s.db.GetHTTPRouteByFunction(ctx, functionID)
+	if err != nil {
+		return nil, err
+	}
+
+	route := &models.HTTPRoute{
+		FunctionID:     functionID,
+		Slug:           req.Slug,
+		AuthType:       req.AuthType,
+		TimeoutSeconds: req.TimeoutSeconds,
+	}
+
+	var token string
+	if req.AuthType == models.RouteAuthToken {
+		if existing != nil && existing.TokenHash != "" && !req.RotateToken {
+			route.TokenHash = existing.TokenHash
+		} else {
+			token, err = randomHex(24)
+			if err != nil {
+				return nil, err
+			}
+			route.TokenHash = hashRouteToken(token)
+		}
+	}
+
+	saved, err := s.db.UpsertHTTPRoute(ctx, route)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		audit(ctx, s.db, models.AuditCreate, models.AuditHTTPRoute, functionID, nil, saved)
+	} else {
+		audit(ctx, s.db, models.AuditUpdate, models.AuditHTTPRoute, functionID, existing, saved)
+	}
+	saved.Token = token
+	return saved, nil
+}
+
+// GetRoute returns the HTTP route of a function
+func (s *HTTPRouteService) GetRoute(ctx context.Context, functionID int64) (*models.HTTPRoute, error) {
+	if _, err := getAuthorizedFunction(ctx, s.db, functionID, models.AccessRead); err != nil {
+		return nil, err
+	}
+	route, err := s.db.GetHTTPRouteByFunction(ctx, functionID)
+	if err != nil {
+		return nil, err
+	}
+	if route == nil {
+		return nil, fmt.Errorf("no HTTP route for function %d", functionID)
+	}
+	return route, nil
+}
+
+// DeleteRoute removes the HTTP route of a function
+func (s *HTTPRouteService) DeleteRoute(ctx context.Context, functionID int64) error {
+	if _, err := getAuthorizedFunction(ctx, s.db, functionID, models.AccessAdmin); err != nil {
+		return err
+	}
+	deleted, err := s.db.DeleteHTTPRoute(ctx, functionID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return fmt.Errorf("no HTTP route for function %d", functionID)
+	}
+	audit(ctx, s.db, models.AuditDelete, models.AuditHTTPRoute, functionID, map[string]interface{}{"function_id": functionID}, nil)
+	return nil
+}
+
+// ResolveRoute returns the route serving a slug, or nil
+func (s *HTTPRouteService) ResolveRoute(ctx context.Context, slug string) (*models.HTTPRoute, error) {
+	return s.db.GetHTTPRouteBySlug(ctx, slug)
+}
+
+// Authorize reports whether a request bearing token may call the route
+func (s *HTTPRouteService) Authorize(route *models.HTTPRoute, token string) bool {
+	if route.AuthType == models.RouteAuthNone {
+		return true
+	}
+	if token == "" || route.TokenHash == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(hashRouteToken(token)), []byte(route.TokenHash)) == 1
+}
+
+func hashRouteToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"lambda-runner-server/models"
+)
+
+// maxInvocationPageSize caps the limit of invocation listings
+const maxInvocationPageSize = 1000
+
+// InvocationFilter selects invocations; zero fields do not filter.
+// Invocations are listed newest first, by invoked_at and then ID.
+type InvocationFilter struct {
+	FunctionID    int64 // 0 selects the invocations of every function the caller may see
+	Statuses      []string
+	InvokedBy     string
+	ReplayOf      int64     // replays of this invocation
+	From          time.Time // invoked at or after
+	To            time.Time // invoked before
+	MinDurationMs int
+	MaxDurationMs int
+	Input         map[string]interface{} // input_event contains this JSON, like Postgres @>
+	Output        map[string]interface{} // output_result contains this JSON
+	After         *InvocationCursor      // only invocations listed after this position
+}
+
+// InvocationCursor is the position of an invocation in listing order
+type InvocationCursor struct {
+	InvokedAt time.Time
+	ID        int64
+}
+
+// NewInvocationCursor returns the position of an invocation
+func NewInvocationCursor(inv *models.InvocationListItem) *InvocationCursor {
+	return &InvocationCursor{InvokedAt: inv.InvokedAt, ID: inv.ID}
+}
+
+// Before reports whether an invocation at invokedAt with the given ID is
+// listed before the cursor position
+func (c *InvocationCursor) Before(invokedAt time.Time, id int64) bool {
+	return invokedAt.After(c.InvokedAt) || invokedAt.Equal(c.InvokedAt) && id >= c.ID
+}
+
+// Encode returns the cursor as an opaque string for API clients
+func (c *InvocationCursor) Encode() string {
+	raw := c.InvokedAt.UTC().Format(time.RFC3339Nano) + "," + strconv.FormatInt(c.ID, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeInvocationCursor parses a cursor returned by Encode
+func DecodeInvocationCursor(s string) (*InvocationCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	invokedAt, id, ok := strings.Cut(string(raw), ",")
+	if !ok {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	var c InvocationCursor
+	if c.InvokedAt, err = time.Parse(time.RFC3339Nano, invokedAt); err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	if c.ID, err = strconv.ParseInt(id, 10, 64); err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	return &c, nil
+}
+
+// Validate checks the filter values
+func (f *InvocationFilter) Validate() error {
+	for _, status := range f.Statuses {
+		switch status {
+		case models.StatusSuccess, models.StatusFail, models.StatusTimeout, models.StatusPending,
+			models.StatusThrottled, models.StatusCancelled:
+		default:
+			return fmt.Errorf("invalid status: %s", status)
+		}
+	}
+	if f.MinDurationMs < 0 || f.MaxDurationMs < 0 {
+		return fmt.Errorf("durations must not be negative")
+	}
+	if f.MaxDurationMs > 0 && f.MinDurationMs > f.MaxDurationMs {
+		return fmt.Errorf("min_duration_ms must not exceed max_duration_ms")
+	}
+	if !f.From.IsZero() && !f.To.IsZero() && !f.From.Before(f.To) {
+		return fmt.Errorf("from must be before to")
+	}
+	return nil
+}
+
+// matches applies the filter to an invocation in memory, except for
+// FunctionID and visibility
+func (f *InvocationFilter) matches(inv *models.Invocation) bool {
+	if len(f.Statuses) > 0 && !containsString(f.Statuses, inv.Status) {
+		return false
+	}
+	if f.InvokedBy != "" && inv.InvokedBy != f.InvokedBy {
+		return false
+	}
+	if f.ReplayOf != 0 && (inv.ReplayOf == nil || *inv.ReplayOf != f.ReplayOf) {
+		return false
+	}
+	if !f.From.IsZero() && inv.InvokedAt.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !inv.InvokedAt.Before(f.To) {
+		return false
+	}
+	if f.MinDurationMs > 0 && inv.DurationMs < f.MinDurationMs {
+		return false
+	}
+	if f.MaxDurationMs > 0 && inv.DurationMs > f.MaxDurationMs {
+		return false
+	}
+	if f.Input != nil && !jsonContains(inv.InputEvent, f.Input) {
+		return false
+	}
+	if f.Output != nil && (inv.OutputResult == nil || !jsonContains(inv.OutputResult, f.Output)) {
+		return false
+	}
+	if f.After != nil && f.After.Before(inv.InvokedAt, inv.ID) {
+		return false
+	}
+	return true
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonContains reports whether the decoded JSON value doc contains pattern,
+// like the Postgres jsonb @> operator on objects: objects contain the keys of
+// the pattern with contained values, arrays contain every element of the
+// pattern array, and scalars must be equal.
+func jsonContains(doc, pattern interface{}) bool {
+	switch pattern := pattern.(type) {
+	case map[string]interface{}:
+		obj, ok := doc.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for key, value := range pattern {
+			v, ok := obj[key]
+			if !ok || !jsonContains(v, value) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		arr, ok := doc.([]interface{})
+		if !ok {
+			return false
+		}
+		for _, want := range pattern {
+			found := false
+			for _, have := range arr {
+				if jsonContains(have, want) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(doc, pattern)
+	}
+}
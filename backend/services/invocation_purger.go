@@ -0,0 +1,261 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"lambda-runner-server/models"
+)
+
+// InvocationArchivePrefix is the storage key prefix of invocation archives
+const InvocationArchivePrefix = "archive/invocations/"
+
+// InvocationPurger deletes invocations expired by the retention policies in
+// batches, archiving them to storage first when the policy asks for it.
+// Concurrent purgers on other instances skip the rows this one is deleting.
+// It also maintains the monthly partitions of function_invocations: upcoming
+// months are created ahead of time, and partitions whose invocations have all
+// expired are dropped whole.
+type InvocationPurger struct {
+	db              *DBService
+	storage         StorageService
+	interval        time.Duration
+	batchSize       int
+	maxBatches      int // per policy and run, so one policy cannot starve the others
+	partitionsAhead int // months of partitions created in advance
+	stopCh          chan struct{}
+	wg              sync.WaitGroup
+
+	mu     sync.Mutex
+	status models.PurgeStatus
+}
+
+func NewInvocationPurger(db *DBService, storage StorageService) *InvocationPurger {
+	return &InvocationPurger{
+		db:              db,
+		storage:         storage,
+		interval:        10 * time.Minute,
+		batchSize:       1000,
+		maxBatches:      100,
+		partitionsAhead: 3,
+		stopCh:          make(chan struct{}),
+	}
+}
+
+func (p *InvocationPurger) Start() {
+	// Partitions must exist before invocations are inserted into them
+	if err := p.createPartitions(context.Background()); err != nil {
+		log.Printf("retention: failed to create partitions: %v", err)
+	}
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.purge()
+			case <-p.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+func (p *InvocationPurger) Stop() {
+	close(p.stopCh)
+	p.wg.Wait()
+}
+
+// Status returns the results of the purges run by this instance
+func (p *InvocationPurger) Status() models.PurgeStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.status
+}
+
+func (p *InvocationPurger) purge() {
+	ctx := context.Background()
+	p.mu.Lock()
+	p.status.Running = true
+	p.mu.Unlock()
+
+	var deleted, archived int64
+	var dropped []string
+	var lastErr error
+	if err := p.createPartitions(ctx); err != nil {
+		log.Printf("retention: failed to create partitions: %v", err)
+		lastErr = err
+	}
+	policies, err := p.db.ListRetentionPolicies(ctx)
+	if err != nil {
+		log.Printf("retention: failed to load policies: %v", err)
+		lastErr = err
+	} else if dropped, err = p.dropExpiredPartitions(ctx, policies); err != nil {
+		log.Printf("retention: failed to drop partitions: %v", err)
+		lastErr = err
+	}
+	for i := range policies {
+		n, err := p.purgePolicy(ctx, &policies[i])
+		deleted += n
+		if policies[i].Archive {
+			archived += n
+		}
+		if err != nil {
+			log.Printf("retention: purge of %s failed: %v", retentionPolicyName(&policies[i]), err)
+			lastErr = err
+		}
+	}
+	if deleted > 0 {
+		log.Printf("retention: deleted %d expired invocations (%d archived)", deleted, archived)
+	}
+
+	now := time.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.status.Running = false
+	p.status.LastRunAt = &now
+	p.status.LastDeleted = deleted
+	p.status.LastArchived = archived
+	p.status.LastDropped = dropped
+	p.status.TotalDeleted += deleted
+	p.status.TotalArchived += archived
+	p.status.LastError = ""
+	if lastErr != nil {
+		p.status.LastError = lastErr.Error()
+	}
+}
+
+func (p *InvocationPurger) createPartitions(ctx context.Context) error {
+	created, err := p.db.MaintainInvocationPartitions(ctx, p.partitionsAhead)
+	for _, name := range created {
+		log.Printf("retention: created partition %s", name)
+	}
+	return err
+}
+
+// dropExpiredPartitions drops the partitions whose invocations have all
+// expired by age. When a policy archives, partitions are only dropped once the
+// batched purge has archived and deleted their rows.
+func (p *InvocationPurger) dropExpiredPartitions(ctx context.Context, policies []models.RetentionPolicy) ([]string, error) {
+	maxAgeDays, archive, ok := partitionRetention(policies)
+	if !ok {
+		return nil, nil
+	}
+	partitions, err := p.db.ListInvocationPartitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -maxAgeDays)
+	var dropped []string
+	for _, partition := range partitions {
+		if partition.EndsAt.After(cutoff) {
+			break
+		}
+		if archive {
+			empty, err := p.db.InvocationPartitionEmpty(ctx, partition.Name)
+			if err != nil {
+				return dropped, err
+			}
+			if !empty {
+				continue
+			}
+		}
+		if err := p.db.DropInvocationPartition(ctx, partition.Name); err != nil {
+			return dropped, err
+		}
+		log.Printf("retention: dropped partition %s", partition.Name)
+		dropped = append(dropped, partition.Name)
+	}
+	return dropped, nil
+}
+
+// partitionRetention returns the age in days after which every invocation has
+// expired, the longest MaxAgeDays of the policies, and whether any policy
+// archives. It reports false when some invocations never expire by age.
+func partitionRetention(policies []models.RetentionPolicy) (maxAgeDays int, archive bool, ok bool) {
+	for _, policy := range policies {
+		if policy.MaxAgeDays == 0 {
+			return 0, false, false
+		}
+		if policy.FunctionID == nil {
+			ok = true
+		}
+		if policy.MaxAgeDays > maxAgeDays {
+			maxAgeDays = policy.MaxAgeDays
+		}
+		archive = archive || policy.Archive
+	}
+	// Without a global policy, functions without their own keep everything
+	return maxAgeDays, archive, ok
+}
+
+// purgePolicy deletes the invocations a policy expires, batch by batch, until
+// none are left or the batch limit of the run is reached
+func (p *InvocationPurger) purgePolicy(ctx context.Context, policy *models.RetentionPolicy) (int64, error) {
+	var archive func(rows []json.RawMessage) error
+	if policy.Archive {
+		archive = func(rows []json.RawMessage) error {
+			return p.archive(ctx, rows)
+		}
+	}
+
+	var deleted int64
+	for batch := 0; batch < p.maxBatches; batch++ {
+		n, err := p.db.PurgeExpiredInvocations(ctx, policy, p.batchSize, archive)
+		deleted += int64(n)
+		if err != nil {
+			return deleted, err
+		}
+		if n < p.batchSize {
+			break
+		}
+	}
+	return deleted, nil
+}
+
+// archive stores invocation rows as gzip-compressed NDJSON under
+// archive/invocations/<yyyy>/<mm>/<dd>/<first id>-<last id>.ndjson.gz
+func (p *InvocationPurger) archive(ctx context.Context, rows []json.RawMessage) error {
+	var first, last struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.Unmarshal(rows[0], &first); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(rows[len(rows)-1], &last); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	for _, row := range rows {
+		gz.Write(row)
+		gz.Write([]byte{'\n'})
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("%s%s/%d-%d.ndjson.gz", InvocationArchivePrefix, time.Now().UTC().Format("2006/01/02"), first.ID, last.ID)
+	if err := p.storage.SaveCode(ctx, key, buf.String()); err != nil {
+		return fmt.Errorf("failed to archive invocations to %s: %w", key, err)
+	}
+	return nil
+}
+
+func retentionPolicyName(policy *models.RetentionPolicy) string {
+	if policy.FunctionID == nil {
+		return "global policy"
+	}
+	return fmt.Sprintf("policy of function %d", *policy.FunctionID)
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"lambda-runner-server/models"
+)
+
+// codeVersion identifies the code an invocation runs
+func codeVersion(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// ReplayInvocation invokes a function again with the input of one of its
+// invocations, after applying the request's patch to a copy of that input.
+// The new invocation records the original in ReplayOf. With PinCodeVersion
+// set, the replay fails unless the function still runs the original code.
+func (s *FunctionService) ReplayInvocation(ctx context.Context, functionID, invocationID int64, req *models.ReplayInvocationRequest, invokedBy string) (*models.Invocation, error) {
+	priority, err := NormalizePriority(req.Priority, models.PriorityHigh)
+	if err != nil {
+		return nil, err
+	}
+
+	// Authorize before the patch sees the original input: the outcome of
+	// test operations would otherwise reveal it
+	if _, err := getAuthorizedFunction(ctx, s.db, functionID, models.AccessInvoke); err != nil {
+		return nil, err
+	}
+
+	original, err := s.db.GetInvocation(ctx, invocationID)
+	if err != nil {
+		return nil, err
+	}
+	if original == nil || original.FunctionID != functionID {
+		return nil, fmt.Errorf("invocation not found: %d", invocationID)
+	}
+
+	input, err := applyJSONPatch(original.InputEvent, req.Patch)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := InvokeOptions{
+		InvokedBy: invokedBy,
+		Priority:  priority,
+		ReplayOf:  &original.ID,
+	}
+	if req.PinCodeVersion {
+		// Invocations from before code versions were recorded cannot be pinned
+		if original.CodeVersion == "" {
+			return nil, fmt.Errorf("%w: invocation %d has no recorded code version", ErrCodeVersionChanged, invocationID)
+		}
+		opts.CodeVersion = original.CodeVersion
+	}
+	return s.InvokeFunction(ctx, functionID, input, opts)
+}
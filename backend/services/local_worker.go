@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"lambda-runner-server/models"
+)
+
+// LocalHandler runs an execution request in process and returns its output
+type LocalHandler func(ctx context.Context, req *models.ExecutionRequest) (map[string]interface{}, error)
+
+// EchoHandler returns the input of a request as its output
+func EchoHandler(ctx context.Context, req *models.ExecutionRequest) (map[string]interface{}, error) {
+	return req.Input, nil
+}
+
+// LocalWorker stands in for the runtime workers when a MemoryQueue is used:
+// it takes requests of every runtime from the queue, runs them through a
+// LocalHandler instead of a container and stores the results the way the
+// workers do.
+type LocalWorker struct {
+	queue    *MemoryQueue
+	handler  LocalHandler
+	interval time.Duration
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+}
+
+func NewLocalWorker(queue *MemoryQueue, handler LocalHandler) *LocalWorker {
+	return &LocalWorker{
+		queue:    queue,
+		handler:  handler,
+		interval: 50 * time.Millisecond,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+func (w *LocalWorker) Start() {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				w.Drain(context.Background())
+			case <-w.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+func (w *LocalWorker) Stop() {
+	close(w.stopCh)
+	w.wg.Wait()
+}
+
+// Drain runs queued requests one by one until the queue is empty and
+// returns how many ran
+func (w *LocalWorker) Drain(ctx context.Context) int {
+	n := 0
+	for {
+		req := w.queue.PopExecutionRequest()
+		if req == nil {
+			return n
+		}
+		w.queue.SetResult(w.run(ctx, req))
+		n++
+	}
+}
+
+// run executes one request; statuses match those reported by the workers
+func (w *LocalWorker) run(ctx context.Context, req *models.ExecutionRequest) *models.ExecutionResult {
+	result := &models.ExecutionResult{InvocationID: req.InvocationID}
+	if w.queue.Cancelled(req.InvocationID) {
+		result.Status = "CANCELLED"
+		result.ErrorMessage = "invocation cancelled"
+		return result
+	}
+
+	start := time.Now()
+	output, err := w.handler(ctx, req)
+	result.DurationMs = int(time.Since(start).Milliseconds())
+	if err != nil {
+		result.Status = "ERROR"
+		result.ErrorMessage = err.Error()
+		return result
+	}
+	result.Status = "SUCCESS"
+	result.Output = output
+	return result
+}
@@ -0,0 +1,240 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"lambda-runner-server/models"
+)
+
+// MemoryQueue is an in-process ExecutionQueue for tests and local
+// development. Lists keep the Redis semantics: requests are taken oldest
+// first, and a requeued overflow request is taken next. LocalWorker consumes
+// its runtime queues in place of the runtime workers.
+type MemoryQueue struct {
+	mu        sync.Mutex
+	lists     map[string][]*models.ExecutionRequest
+	overflow  map[int64]bool // functions with parked overflow requests
+	results   map[int64]*models.ExecutionResult
+	cancelled map[int64]bool
+}
+
+func NewMemoryQueue() *MemoryQueue {
+	return &MemoryQueue{
+		lists:     map[string][]*models.ExecutionRequest{},
+		overflow:  map[int64]bool{},
+		results:   map[int64]*models.ExecutionResult{},
+		cancelled: map[int64]bool{},
+	}
+}
+
+// push appends requests to the tail of a list; callers hold mu
+func (q *MemoryQueue) push(key string, reqs ...*models.ExecutionRequest) {
+	for _, req := range reqs {
+		c := *req
+		q.lists[key] = append(q.lists[key], &c)
+	}
+}
+
+// pop takes the oldest request of a list, or nil; callers hold mu
+func (q *MemoryQueue) pop(key string) *models.ExecutionRequest {
+	list := q.lists[key]
+	if len(list) == 0 {
+		return nil
+	}
+	req := list[0]
+	if len(list) == 1 {
+		delete(q.lists, key)
+	} else {
+		q.lists[key] = list[1:]
+	}
+	return req
+}
+
+// PushExecutionRequest appends an execution request to a runtime queue
+func (q *MemoryQueue) PushExecutionRequest(ctx context.Context, queueKey string, req *models.ExecutionRequest) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.push(queueKey, req)
+	return nil
+}
+
+// PushExecutionRequests appends many execution requests to a runtime queue in order
+func (q *MemoryQueue) PushExecutionRequests(ctx context.Context, queueKey string, reqs []*models.ExecutionRequest) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.push(queueKey, reqs...)
+	return nil
+}
+
+// GetResult returns the result of an invocation, or nil while it runs
+func (q *MemoryQueue) GetResult(ctx context.Context, invocationID int64) (*models.ExecutionResult, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	result := q.results[invocationID]
+	if result == nil {
+		return nil, nil
+	}
+	c := *result
+	return &c, nil
+}
+
+// RemoveQueuedRequest removes a not-yet-started request from the first of the
+// given queues holding it and reports whether it was found
+func (q *MemoryQueue) RemoveQueuedRequest(ctx context.Context, queueKeys []string, invocationID int64) (bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, key := range queueKeys {
+		list := q.lists[key]
+		for i, req := range list {
+			if req.InvocationID == invocationID {
+				q.lists[key] = append(list[:i:i], list[i+1:]...)
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// SignalCancel tombstones an invocation; LocalWorker reports it cancelled
+func (q *MemoryQueue) SignalCancel(ctx context.Context, invocationID int64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.cancelled[invocationID] = true
+	return nil
+}
+
+// PushOverflow appends an execution request to a function's overflow queue
+func (q *MemoryQueue) PushOverflow(ctx context.Context, functionID int64, req *models.ExecutionRequest) error {
+	return q.PushOverflowRequests(ctx, functionID, []*models.ExecutionRequest{req})
+}
+
+// PushOverflowRequests appends many requests to a function's overflow queue
+func (q *MemoryQueue) PushOverflowRequests(ctx context.Context, functionID int64, reqs []*models.ExecutionRequest) error {
+	if len(reqs) == 0 {
+		return nil
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.push(overflowQueueKey(functionID), reqs...)
+	q.overflow[functionID] = true
+	return nil
+}
+
+// PopOverflow takes the oldest request from a function's overflow queue
+func (q *MemoryQueue) PopOverflow(ctx context.Context, functionID int64) (*models.ExecutionRequest, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	req := q.pop(overflowQueueKey(functionID))
+	if req == nil {
+		delete(q.overflow, functionID)
+	}
+	return req, nil
+}
+
+// RequeueOverflow puts a request back at the head of a function's overflow queue
+func (q *MemoryQueue) RequeueOverflow(ctx context.Context, functionID int64, req *models.ExecutionRequest) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	key := overflowQueueKey(functionID)
+	c := *req
+	q.lists[key] = append([]*models.ExecutionRequest{&c}, q.lists[key]...)
+	q.overflow[functionID] = true
+	return nil
+}
+
+// ListOverflowFunctions returns the IDs of functions that have queued overflow requests
+func (q *MemoryQueue) ListOverflowFunctions(ctx context.Context) ([]int64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	ids := make([]int64, 0, len(q.overflow))
+	for id := range q.overflow {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+// PushBatchPending parks batch items beyond the batch's max parallelism
+func (q *MemoryQueue) PushBatchPending(ctx context.Context, batchID int64, reqs []*models.ExecutionRequest) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.push(batchQueueKey(batchID), reqs...)
+	return nil
+}
+
+// PopBatchPending takes the next parked item of a batch, or nil when none is left
+func (q *MemoryQueue) PopBatchPending(ctx context.Context, batchID int64) (*models.ExecutionRequest, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.pop(batchQueueKey(batchID)), nil
+}
+
+// PopExecutionRequest takes the next request of any runtime queue, draining
+// high priority lanes before normal and low ones. It returns nil when all
+// runtime queues are empty.
+func (q *MemoryQueue) PopExecutionRequest() *models.ExecutionRequest {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var keys []string
+	for key := range q.lists {
+		if strings.HasPrefix(key, "execution_queue:") {
+			keys = append(keys, key)
+		}
+	}
+	lane := func(key string) int {
+		switch {
+		case strings.HasSuffix(key, ":"+models.PriorityHigh):
+			return 0
+		case strings.HasSuffix(key, ":"+models.PriorityLow):
+			return 2
+		default:
+			return 1
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if lane(keys[i]) != lane(keys[j]) {
+			return lane(keys[i]) < lane(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+
+	for _, key := range keys {
+		if req := q.pop(key); req != nil {
+			return req
+		}
+	}
+	return nil
+}
+
+// SetResult stores the result of an invocation, as a worker does
+func (q *MemoryQueue) SetResult(result *models.ExecutionResult) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	c := *result
+	q.results[result.InvocationID] = &c
+}
+
+// Cancelled reports whether cancellation of an invocation was signalled
+func (q *MemoryQueue) Cancelled(invocationID int64) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.cancelled[invocationID]
+}
@@ -0,0 +1,787 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"lambda-runner-server/middleware"
+	"lambda-runner-server/models"
+)
+
+// MemoryStore is an in-process Store for tests and local development. It
+// follows the semantics of DBService, including namespace confinement and
+// function visibility, but keeps no data across restarts. It starts with the
+// "default" namespace (ID 1).
+type MemoryStore struct {
+	mu sync.Mutex
+
+	lastID      int64
+	namespaces  map[int64]*models.Namespace
+	functions   map[int64]*models.Function
+	grants      map[int64]*models.FunctionGrant
+	invocations map[int64]*models.Invocation
+	batches     map[int64]*models.InvocationBatch
+	callbacks   map[int64]*models.InvocationCallback // by invocation ID
+	schedules   map[int64]*models.FunctionSchedule
+	auditEvents []models.AuditEvent
+}
+
+func NewMemoryStore() *MemoryStore {
+	now := time.Now().UTC()
+	return &MemoryStore{
+		lastID: 1,
+		namespaces: map[int64]*models.Namespace{
+			1: {ID: 1, Name: "default", Description: "Default namespace", CreatedAt: now, UpdatedAt: now},
+		},
+		functions:   map[int64]*models.Function{},
+		grants:      map[int64]*models.FunctionGrant{},
+		invocations: map[int64]*models.Invocation{},
+		batches:     map[int64]*models.InvocationBatch{},
+		callbacks:   map[int64]*models.InvocationCallback{},
+		schedules:   map[int64]*models.FunctionSchedule{},
+	}
+}
+
+// nextID hands out IDs from one sequence for all records; callers hold mu
+func (m *MemoryStore) nextID() int64 {
+	m.lastID++
+	return m.lastID
+}
+
+// function returns a function visible in the request's namespace; callers hold mu
+func (m *MemoryStore) function(ctx context.Context, id int64) *models.Function {
+	fn := m.functions[id]
+	if fn == nil {
+		return nil
+	}
+	if ns := requestNamespace(ctx); ns != 0 && fn.NamespaceID != ns {
+		return nil
+	}
+	return fn
+}
+
+// invocation returns an invocation of a function in the request's namespace; callers hold mu
+func (m *MemoryStore) invocation(ctx context.Context, id int64) *models.Invocation {
+	inv := m.invocations[id]
+	if inv == nil || m.function(ctx, inv.FunctionID) == nil {
+		return nil
+	}
+	return inv
+}
+
+func copyFunction(fn *models.Function) *models.Function {
+	c := *fn
+	c.Params = append([]models.FunctionParam(nil), fn.Params...)
+	c.Tags = append([]string{}, fn.Tags...)
+	return &c
+}
+
+func invocationListItem(inv *models.Invocation) models.InvocationListItem {
+	return models.InvocationListItem{
+		ID:           inv.ID,
+		FunctionID:   inv.FunctionID,
+		InvokedAt:    inv.InvokedAt,
+		InputEvent:   inv.InputEvent,
+		Status:       inv.Status,
+		OutputResult: inv.OutputResult,
+		ErrorMessage: inv.ErrorMessage,
+		DurationMs:   inv.DurationMs,
+		BatchID:      inv.BatchID,
+		ReplayOf:     inv.ReplayOf,
+	}
+}
+
+// CreateFunction stores a new function and its params
+func (m *MemoryStore) CreateFunction(ctx context.Context, fn *models.Function) (*models.Function, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().UTC()
+	fn.ID = m.nextID()
+	fn.CreatedAt = now
+	fn.UpdatedAt = now
+	fn.UpdatedByKeyID = fn.CreatedByKeyID
+	for i := range fn.Params {
+		fn.Params[i].ID = m.nextID()
+		fn.Params[i].FunctionID = fn.ID
+	}
+
+	stored := copyFunction(fn)
+	stored.Code = ""
+	m.functions[fn.ID] = stored
+	return fn, nil
+}
+
+// GetFunction returns a function by ID, or nil
+func (m *MemoryStore) GetFunction(ctx context.Context, id int64) (*models.Function, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fn := m.function(ctx, id)
+	if fn == nil {
+		return nil, nil
+	}
+	return copyFunction(fn), nil
+}
+
+// ListFunctions returns a page of the functions matching the filter that
+// the caller may see, and the number of all of them
+func (m *MemoryStore) ListFunctions(ctx context.Context, filter FunctionFilter) ([]models.FunctionListItem, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	principals := requestPrincipals(ctx)
+	since := time.Now().Add(-24 * time.Hour)
+	var functions []models.FunctionListItem
+	for _, fn := range m.functions {
+		if m.function(ctx, fn.ID) == nil || !m.visible(fn, principals) || !filter.matches(fn) {
+			continue
+		}
+		item := models.FunctionListItem{
+			ID:          fn.ID,
+			NamespaceID: fn.NamespaceID,
+			Name:        fn.Name,
+			Description: fn.Description,
+			Runtime:     fn.Runtime,
+			Tags:        append([]string{}, fn.Tags...),
+			IsPublic:    fn.IsPublic,
+			Owner:       fn.Owner,
+			CreatedAt:   fn.CreatedAt,
+			UpdatedAt:   fn.UpdatedAt,
+		}
+		var last *models.Invocation
+		for _, inv := range m.invocations {
+			if inv.FunctionID != fn.ID {
+				continue
+			}
+			if !inv.InvokedAt.Before(since) {
+				item.Invocations24h++
+			}
+			if last == nil || inv.InvokedAt.After(last.InvokedAt) || inv.InvokedAt.Equal(last.InvokedAt) && inv.ID > last.ID {
+				last = inv
+			}
+		}
+		if last != nil {
+			invokedAt := last.InvokedAt
+			item.LastInvokedAt = &invokedAt
+			item.LastInvocationStatus = last.Status
+		}
+		functions = append(functions, item)
+	}
+
+	sort.Slice(functions, func(i, j int) bool {
+		a, b := &functions[i], &functions[j]
+		if filter.Sort == models.FunctionSortLastInvokedAt && (a.LastInvokedAt == nil) != (b.LastInvokedAt == nil) {
+			return a.LastInvokedAt != nil
+		}
+		var cmp int
+		switch filter.Sort {
+		case models.FunctionSortName:
+			cmp = strings.Compare(a.Name, b.Name)
+		case models.FunctionSortUpdatedAt:
+			cmp = a.UpdatedAt.Compare(b.UpdatedAt)
+		case models.FunctionSortLastInvokedAt:
+			if a.LastInvokedAt != nil {
+				cmp = a.LastInvokedAt.Compare(*b.LastInvokedAt)
+			}
+		default:
+			cmp = a.CreatedAt.Compare(b.CreatedAt)
+		}
+		if cmp == 0 {
+			cmp = int(a.ID - b.ID)
+		}
+		if filter.Order == "asc" {
+			return cmp < 0
+		}
+		return cmp > 0
+	})
+
+	total := len(functions)
+	if filter.Offset >= len(functions) {
+		return nil, total, nil
+	}
+	functions = functions[filter.Offset:]
+	if filter.Limit > 0 && len(functions) > filter.Limit {
+		functions = functions[:filter.Limit]
+	}
+	return functions, total, nil
+}
+
+// visible mirrors functionVisible; callers hold mu
+func (m *MemoryStore) visible(fn *models.Function, principals []string) bool {
+	if principals == nil || fn.IsPublic || fn.Owner == "" {
+		return true
+	}
+	for _, p := range principals {
+		if p == fn.Owner {
+			return true
+		}
+		for _, g := range m.grants {
+			if g.FunctionID == fn.ID && g.Principal == p {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// updateFunction applies change to a function of the request's namespace
+func (m *MemoryStore) updateFunction(ctx context.Context, id int64, change func(fn *models.Function)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fn := m.function(ctx, id)
+	if fn == nil {
+		return nil
+	}
+	change(fn)
+	if keyID := middleware.APIKeyID(ctx); keyID != nil {
+		fn.UpdatedByKeyID = keyID
+	}
+	fn.UpdatedAt = time.Now().UTC()
+	return nil
+}
+
+// UpdateCodeKey updates the storage key of a function's code
+func (m *MemoryStore) UpdateCodeKey(ctx context.Context, id int64, codeKey string) error {
+	return m.updateFunction(ctx, id, func(fn *models.Function) {
+		fn.CodeS3Key = codeKey
+	})
+}
+
+// UpdateFunctionConcurrency updates the concurrency settings for a function
+func (m *MemoryStore) UpdateFunctionConcurrency(ctx context.Context, id int64, maxConcurrency, reservedConcurrency int, overflowPolicy string) error {
+	return m.updateFunction(ctx, id, func(fn *models.Function) {
+		fn.MaxConcurrency = maxConcurrency
+		fn.ReservedConcurrency = reservedConcurrency
+		fn.OverflowPolicy = overflowPolicy
+	})
+}
+
+// UpdateFunctionCallback sets the default completion callback of a function
+func (m *MemoryStore) UpdateFunctionCallback(ctx context.Context, id int64, callbackURL, callbackSecret string) error {
+	return m.updateFunction(ctx, id, func(fn *models.Function) {
+		fn.CallbackURL = callbackURL
+		fn.CallbackSecret = callbackSecret
+	})
+}
+
+// UpdateFunctionVisibility makes a function public or private
+func (m *MemoryStore) UpdateFunctionVisibility(ctx context.Context, id int64, isPublic bool) error {
+	return m.updateFunction(ctx, id, func(fn *models.Function) {
+		fn.IsPublic = isPublic
+	})
+}
+
+// UpdateFunctionTags replaces the tags of a function
+func (m *MemoryStore) UpdateFunctionTags(ctx context.Context, id int64, tags []string) error {
+	return m.updateFunction(ctx, id, func(fn *models.Function) {
+		fn.Tags = append([]string{}, tags...)
+	})
+}
+
+// DeleteFunction removes a function with everything that belongs to it and
+// returns it, or nil when it did not exist
+func (m *MemoryStore) DeleteFunction(ctx context.Context, id int64) (*models.Function, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fn := m.function(ctx, id)
+	if fn == nil {
+		return nil, nil
+	}
+	delete(m.functions, id)
+	for gid, g := range m.grants {
+		if g.FunctionID == id {
+			delete(m.grants, gid)
+		}
+	}
+	for iid, inv := range m.invocations {
+		if inv.FunctionID == id {
+			delete(m.invocations, iid)
+			delete(m.callbacks, iid)
+		}
+	}
+	for bid, b := range m.batches {
+		if b.FunctionID == id {
+			delete(m.batches, bid)
+		}
+	}
+	for sid, sched := range m.schedules {
+		if sched.FunctionID == id {
+			delete(m.schedules, sid)
+		}
+	}
+	return fn, nil
+}
+
+// SaveFunctionGrant creates a grant or changes the access of an existing one
+func (m *MemoryStore) SaveFunctionGrant(ctx context.Context, g *models.FunctionGrant) (*models.FunctionGrant, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.grants {
+		if existing.FunctionID == g.FunctionID && existing.Principal == g.Principal {
+			existing.Access = g.Access
+			existing.GrantedByKeyID = middleware.APIKeyID(ctx)
+			saved := *existing
+			return &saved, nil
+		}
+	}
+	saved := *g
+	saved.ID = m.nextID()
+	saved.GrantedByKeyID = middleware.APIKeyID(ctx)
+	saved.CreatedAt = time.Now().UTC()
+	m.grants[saved.ID] = &saved
+	result := saved
+	return &result, nil
+}
+
+// ListFunctionGrants returns the grants of a function
+func (m *MemoryStore) ListFunctionGrants(ctx context.Context, functionID int64) ([]models.FunctionGrant, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	grants := []models.FunctionGrant{}
+	if m.function(ctx, functionID) == nil {
+		return grants, nil
+	}
+	for _, g := range m.grants {
+		if g.FunctionID == functionID {
+			grants = append(grants, *g)
+		}
+	}
+	sort.Slice(grants, func(i, j int) bool { return grants[i].ID < grants[j].ID })
+	return grants, nil
+}
+
+// FunctionGrantLevels returns the access levels a function is granted to any of the principals
+func (m *MemoryStore) FunctionGrantLevels(ctx context.Context, functionID int64, principals []string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var levels []string
+	for _, g := range m.grants {
+		if g.FunctionID != functionID {
+			continue
+		}
+		for _, p := range principals {
+			if g.Principal == p {
+				levels = append(levels, g.Access)
+			}
+		}
+	}
+	return levels, nil
+}
+
+// DeleteFunctionGrant removes a grant of a function
+func (m *MemoryStore) DeleteFunctionGrant(ctx context.Context, functionID, grantID int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g := m.grants[grantID]
+	if g == nil || g.FunctionID != functionID || m.function(ctx, functionID) == nil {
+		return false, nil
+	}
+	delete(m.grants, grantID)
+	return true, nil
+}
+
+// GetNamespace returns a namespace by ID, or nil
+func (m *MemoryStore) GetNamespace(ctx context.Context, id int64) (*models.Namespace, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ns := m.namespaces[id]
+	if ns == nil {
+		return nil, nil
+	}
+	result := *ns
+	return &result, nil
+}
+
+// CountNamespaceResources counts the functions of a namespace; the memory
+// store holds no workflows
+func (m *MemoryStore) CountNamespaceResources(ctx context.Context, id int64) (functions, workflows int, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, fn := range m.functions {
+		if fn.NamespaceID == id {
+			functions++
+		}
+	}
+	return functions, 0, nil
+}
+
+// insertInvocation stores a new invocation; callers hold mu
+func (m *MemoryStore) insertInvocation(inv *models.Invocation) {
+	now := time.Now().UTC()
+	inv.ID = m.nextID()
+	inv.InvokedAt = now
+	inv.CreatedAt = now
+	stored := *inv
+	m.invocations[inv.ID] = &stored
+}
+
+// CreateInvocation stores a new invocation
+func (m *MemoryStore) CreateInvocation(ctx context.Context, inv *models.Invocation) (*models.Invocation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.insertInvocation(inv)
+	return inv, nil
+}
+
+// GetInvocation returns an invocation by ID, or nil
+func (m *MemoryStore) GetInvocation(ctx context.Context, id int64) (*models.Invocation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	inv := m.invocation(ctx, id)
+	if inv == nil {
+		return nil, nil
+	}
+	result := *inv
+	return &result, nil
+}
+
+// UpdateInvocationResult stores the result of a pending invocation and
+// reports whether it was still pending
+func (m *MemoryStore) UpdateInvocationResult(ctx context.Context, id int64, status string, outputResult map[string]interface{}, errorMessage string, durationMs int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	inv := m.invocations[id]
+	if inv == nil || inv.Status != models.StatusPending {
+		return false, nil
+	}
+	inv.Status = status
+	inv.OutputResult = outputResult
+	inv.ErrorMessage = errorMessage
+	inv.DurationMs = durationMs
+	return true, nil
+}
+
+// SetInvocationStatus sets the status of a pending invocation without a
+// result and reports whether it was still pending
+func (m *MemoryStore) SetInvocationStatus(ctx context.Context, id int64, status, errorMessage string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	inv := m.invocation(ctx, id)
+	if inv == nil || inv.Status != models.StatusPending {
+		return false, nil
+	}
+	inv.Status = status
+	inv.ErrorMessage = errorMessage
+	return true, nil
+}
+
+// ListInvocations returns the latest invocations matching the filter
+func (m *MemoryStore) ListInvocations(ctx context.Context, filter InvocationFilter, limit int) ([]models.InvocationListItem, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	principals := requestPrincipals(ctx)
+	var invocations []models.InvocationListItem
+	for _, inv := range m.invocations {
+		if filter.FunctionID != 0 && inv.FunctionID != filter.FunctionID || !filter.matches(inv) {
+			continue
+		}
+		fn := m.function(ctx, inv.FunctionID)
+		if fn == nil || filter.FunctionID == 0 && !m.visible(fn, principals) {
+			continue
+		}
+		invocations = append(invocations, invocationListItem(inv))
+	}
+	sort.Slice(invocations, func(i, j int) bool {
+		if !invocations[i].InvokedAt.Equal(invocations[j].InvokedAt) {
+			return invocations[i].InvokedAt.After(invocations[j].InvokedAt)
+		}
+		return invocations[i].ID > invocations[j].ID
+	})
+	if len(invocations) > limit {
+		invocations = invocations[:limit]
+	}
+	return invocations, nil
+}
+
+// InvocationStats groups the invocations of a function like DBService.InvocationStats
+func (m *MemoryStore) InvocationStats(ctx context.Context, functionID int64, from, to time.Time, bucket time.Duration) ([]InvocationStatsRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	type key struct {
+		bucket int64 // -1 for all buckets
+		status string
+	}
+	counts := map[key]int64{{-1, ""}: 0} // the overall group exists even without invocations
+	durations := map[key][]float64{}
+	if m.function(ctx, functionID) != nil {
+		for _, inv := range m.invocations {
+			if inv.FunctionID != functionID || inv.InvokedAt.Before(from) || !inv.InvokedAt.Before(to) {
+				continue
+			}
+			b := int64(inv.InvokedAt.Sub(from) / bucket)
+			for _, k := range []key{{b, inv.Status}, {b, ""}, {-1, inv.Status}, {-1, ""}} {
+				counts[k]++
+				if k.status == "" && finishedStatus(inv.Status) {
+					durations[k] = append(durations[k], float64(inv.DurationMs))
+				}
+			}
+		}
+	}
+
+	var rows []InvocationStatsRow
+	for k, count := range counts {
+		row := InvocationStatsRow{Count: count}
+		if k.bucket >= 0 {
+			b := k.bucket
+			row.Bucket = &b
+		}
+		if k.status != "" {
+			status := k.status
+			row.Status = &status
+		} else {
+			row.P50 = percentile(durations[k], 0.5)
+			row.P90 = percentile(durations[k], 0.9)
+			row.P99 = percentile(durations[k], 0.99)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// ListPendingInvocations returns the oldest pending invocations after a
+// position in (invoked_at, id) order
+func (m *MemoryStore) ListPendingInvocations(ctx context.Context, after InvocationCursor, limit int) ([]models.Invocation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var invocations []models.Invocation
+	for _, inv := range m.invocations {
+		if inv.Status != models.StatusPending {
+			continue
+		}
+		if inv.InvokedAt.After(after.InvokedAt) || inv.InvokedAt.Equal(after.InvokedAt) && inv.ID > after.ID {
+			invocations = append(invocations, *inv)
+		}
+	}
+	sort.Slice(invocations, func(i, j int) bool {
+		if !invocations[i].InvokedAt.Equal(invocations[j].InvokedAt) {
+			return invocations[i].InvokedAt.Before(invocations[j].InvokedAt)
+		}
+		return invocations[i].ID < invocations[j].ID
+	})
+	if len(invocations) > limit {
+		invocations = invocations[:limit]
+	}
+	return invocations, nil
+}
+
+// CreateBatch stores a batch and its invocations
+func (m *MemoryStore) CreateBatch(ctx context.Context, batch *models.InvocationBatch, invocations []*models.Invocation) (*models.InvocationBatch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	batch.ID = m.nextID()
+	batch.CreatedAt = time.Now().UTC()
+	stored := *batch
+	m.batches[batch.ID] = &stored
+
+	for _, inv := range invocations {
+		batchID := batch.ID
+		inv.BatchID = &batchID
+		m.insertInvocation(inv)
+	}
+	return batch, nil
+}
+
+// GetBatch returns a batch by ID, or nil
+func (m *MemoryStore) GetBatch(ctx context.Context, id int64) (*models.InvocationBatch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	batch := m.batches[id]
+	if batch == nil || m.function(ctx, batch.FunctionID) == nil {
+		return nil, nil
+	}
+	result := *batch
+	return &result, nil
+}
+
+// ListBatchInvocations returns the invocations of a batch in submission order
+func (m *MemoryStore) ListBatchInvocations(ctx context.Context, batchID int64) ([]models.InvocationListItem, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	invocations := []models.InvocationListItem{}
+	for _, inv := range m.invocations {
+		if inv.BatchID != nil && *inv.BatchID == batchID && m.function(ctx, inv.FunctionID) != nil {
+			invocations = append(invocations, invocationListItem(inv))
+		}
+	}
+	sort.Slice(invocations, func(i, j int) bool { return invocations[i].ID < invocations[j].ID })
+	return invocations, nil
+}
+
+// EnqueueCallback registers the callback delivery of a finished invocation
+// when it or its function has a callback URL. Deliveries are recorded but
+// never sent.
+func (m *MemoryStore) EnqueueCallback(ctx context.Context, invocationID int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	inv := m.invocations[invocationID]
+	if inv == nil || m.callbacks[invocationID] != nil {
+		return false, nil
+	}
+	url, secret := inv.CallbackURL, inv.CallbackSecret
+	if url == "" {
+		fn := m.functions[inv.FunctionID]
+		if fn == nil || fn.CallbackURL == "" {
+			return false, nil
+		}
+		url, secret = fn.CallbackURL, fn.CallbackSecret
+	}
+
+	now := time.Now().UTC()
+	m.callbacks[invocationID] = &models.InvocationCallback{
+		ID:            m.nextID(),
+		InvocationID:  invocationID,
+		URL:           url,
+		Secret:        secret,
+		Status:        models.CallbackPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+	return true, nil
+}
+
+// GetInvocationCallback returns the callback delivery of an invocation, or nil
+func (m *MemoryStore) GetInvocationCallback(ctx context.Context, invocationID int64) (*models.InvocationCallback, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cb := m.callbacks[invocationID]
+	if cb == nil || m.invocation(ctx, invocationID) == nil {
+		return nil, nil
+	}
+	result := *cb
+	result.Log = []models.CallbackAttempt{}
+	return &result, nil
+}
+
+// CreateSchedule stores a new scheduled execution
+func (m *MemoryStore) CreateSchedule(ctx context.Context, sched *models.FunctionSchedule) (*models.FunctionSchedule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().UTC()
+	created := *sched
+	created.ID = m.nextID()
+	created.CreatedAt = now
+	created.UpdatedAt = now
+	stored := created
+	m.schedules[created.ID] = &stored
+	return &created, nil
+}
+
+// ListSchedules returns the schedules of a function, latest first
+func (m *MemoryStore) ListSchedules(ctx context.Context, functionID int64) ([]models.FunctionSchedule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	schedules := []models.FunctionSchedule{}
+	if m.function(ctx, functionID) == nil {
+		return schedules, nil
+	}
+	for _, sched := range m.schedules {
+		if sched.FunctionID == functionID {
+			schedules = append(schedules, *sched)
+		}
+	}
+	sort.Slice(schedules, func(i, j int) bool { return schedules[i].ScheduledAt.After(schedules[j].ScheduledAt) })
+	return schedules, nil
+}
+
+// DeleteSchedule removes a schedule and reports whether it existed
+func (m *MemoryStore) DeleteSchedule(ctx context.Context, functionID, scheduleID int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sched := m.schedules[scheduleID]
+	if sched == nil || sched.FunctionID != functionID || m.function(ctx, functionID) == nil {
+		return false, nil
+	}
+	delete(m.schedules, scheduleID)
+	return true, nil
+}
+
+// ClaimDueSchedules marks up to limit due schedules executed and returns them
+func (m *MemoryStore) ClaimDueSchedules(ctx context.Context, limit int) ([]models.FunctionSchedule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().UTC()
+	var due []*models.FunctionSchedule
+	for _, sched := range m.schedules {
+		if !sched.Executed && !sched.ScheduledAt.After(now) {
+			due = append(due, sched)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].ScheduledAt.Before(due[j].ScheduledAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	schedules := make([]models.FunctionSchedule, 0, len(due))
+	for _, sched := range due {
+		schedules = append(schedules, *sched)
+		executedAt := now
+		sched.Executed = true
+		sched.ExecutedAt = &executedAt
+		sched.UpdatedAt = now
+	}
+	return schedules, nil
+}
+
+// MarkScheduleExecuted records the execution result of a schedule
+func (m *MemoryStore) MarkScheduleExecuted(ctx context.Context, scheduleID int64, status, errMsg string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if sched := m.schedules[scheduleID]; sched != nil {
+		sched.Status = status
+		sched.ErrorMessage = errMsg
+		sched.UpdatedAt = time.Now().UTC()
+	}
+	return nil
+}
+
+// InsertAuditEvent appends an event to the audit log
+func (m *MemoryStore) InsertAuditEvent(ctx context.Context, e *models.AuditEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e.ID = m.nextID()
+	e.CreatedAt = time.Now().UTC()
+	m.auditEvents = append(m.auditEvents, *e)
+	return nil
+}
+
+// AuditEvents returns the recorded audit events in insertion order
+func (m *MemoryStore) AuditEvents() []models.AuditEvent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]models.AuditEvent(nil), m.auditEvents...)
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"lambda-runner-server/middleware"
+	"lambda-runner-server/models"
+)
+
+var namespaceNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// NamespaceService manages namespaces, the projects that own functions,
+// workflows, events and API keys
+type NamespaceService struct {
+	db *DBService
+}
+
+func NewNamespaceService(db *DBService) *NamespaceService {
+	return &NamespaceService{db: db}
+}
+
+func validateNamespaceQuotas(maxFunctions int) error {
+	if maxFunctions < 0 {
+		return fmt.Errorf("max_functions must not be negative")
+	}
+	return nil
+}
+
+// CreateNamespace creates an empty namespace
+func (s *NamespaceService) CreateNamespace(ctx context.Context, req *models.CreateNamespaceRequest) (*models.Namespace, error) {
+	if !namespaceNamePattern.MatchString(req.Name) {
+		return nil, fmt.Errorf("name must be 1-63 lowercase letters, digits or dashes, starting with a letter or digit")
+	}
+	if err := validateNamespaceQuotas(req.MaxFunctions); err != nil {
+		return nil, err
+	}
+
+	taken, err := s.db.GetNamespaceByName(ctx, req.Name)
+	if err != nil {
+		return nil, err
+	}
+	if taken != nil {
+		return nil, ErrNamespaceNameTaken
+	}
+
+	ns, err := s.db.CreateNamespace(ctx, &models.Namespace{
+		Name:         req.Name,
+		Description:  req.Description,
+		MaxFunctions: req.MaxFunctions,
+	})
+	if err != nil {
+		return nil, err
+	}
+	audit(ctx, s.db, models.AuditCreate, models.AuditNamespace, ns.Name, nil, ns)
+	return ns, nil
+}
+
+// ResolveNamespace implements middleware.NamespaceResolver
+func (s *NamespaceService) ResolveNamespace(ctx context.Context, name string) (*models.Namespace, error) {
+	return s.db.GetNamespaceByName(ctx, name)
+}
+
+// ListNamespaces returns every namespace to admin keys and only its own
+// namespace to any other key
+func (s *NamespaceService) ListNamespaces(ctx context.Context) ([]models.Namespace, error) {
+	namespaces, err := s.db.ListNamespaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key := middleware.APIKeyFromContext(ctx)
+	if key == nil || key.HasScope(models.ScopeAdmin) {
+		return namespaces, nil
+	}
+
+	visible := []models.Namespace{}
+	for _, ns := range namespaces {
+		if ns.ID == key.NamespaceID {
+			visible = append(visible, ns)
+		}
+	}
+	return visible, nil
+}
+
+// GetNamespace returns the namespace the request is confined to
+func (s *NamespaceService) GetNamespace(ctx context.Context) (*models.Namespace, error) {
+	id := requestNamespace(ctx)
+	ns, err := s.db.GetNamespace(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if ns == nil {
+		return nil, fmt.Errorf("namespace not found: %d", id)
+	}
+	return ns, nil
+}
+
+// UpdateNamespace changes the description and quotas of the request's namespace
+func (s *NamespaceService) UpdateNamespace(ctx context.Context, req *models.UpdateNamespaceRequest) (*models.Namespace, error) {
+	if err := validateNamespaceQuotas(req.MaxFunctions); err != nil {
+		return nil, err
+	}
+	before, err := s.GetNamespace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	id := before.ID
+	ns, err := s.db.UpdateNamespace(ctx, &models.Namespace{
+		ID:           id,
+		Description:  req.Description,
+		MaxFunctions: req.MaxFunctions,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if ns == nil {
+		return nil, fmt.Errorf("namespace not found: %d", id)
+	}
+	audit(ctx, s.db, models.AuditUpdate, models.AuditNamespace, ns.Name, before, ns)
+	return ns, nil
+}
+
+// DeleteNamespace removes the request's namespace with its API keys, events
+// and event rules. Functions and workflows must be deleted first, and the
+// default namespace cannot be deleted.
+func (s *NamespaceService) DeleteNamespace(ctx context.Context) error {
+	ns, err := s.GetNamespace(ctx)
+	if err != nil {
+		return err
+	}
+	if ns.Name == models.DefaultNamespace {
+		return fmt.Errorf("the %s namespace cannot be deleted", models.DefaultNamespace)
+	}
+
+	functions, workflows, err := s.db.CountNamespaceResources(ctx, ns.ID)
+	if err != nil {
+		return err
+	}
+	if functions > 0 || workflows > 0 {
+		return ErrNamespaceNotEmpty
+	}
+
+	deleted, err := s.db.DeleteNamespace(ctx, ns.ID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return fmt.Errorf("namespace not found: %s", ns.Name)
+	}
+	audit(ctx, s.db, models.AuditDelete, models.AuditNamespace, ns.Name, ns, nil)
+	return nil
+}
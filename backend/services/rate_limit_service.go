@@ -0,0 +1,420 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"lambda-runner-server/models"
+)
+
+const rateLimitCacheTTL = 10 * time.Second
+
+// RateLimitService enforces token-bucket rate limits and invocation/compute
+// quotas per function, per caller and per namespace. Limits live in Postgres,
+// counters in Redis.
+type RateLimitService struct {
+	db    *DBService
+	redis *RedisService
+
+	mu         sync.Mutex
+	limits     map[string]models.RateLimit
+	loadedAt   time.Time
+	namespaces map[int64]string // function ID -> namespace name; functions never move
+}
+
+func NewRateLimitService(db *DBService, redis *RedisService) *RateLimitService {
+	return &RateLimitService{
+		db:    db,
+		redis: redis,
+	}
+}
+
+// rateLimitSubject identifies who a limit is checked against
+type rateLimitSubject struct {
+	scope   string
+	subject string
+}
+
+func (s rateLimitSubject) String() string {
+	return fmt.Sprintf("%s %s", s.scope, s.subject)
+}
+
+// quotaPeriod is a calendar window quotas are counted in (UTC)
+type quotaPeriod struct {
+	name string
+	id   string
+	end  time.Time
+}
+
+func quotaPeriods(now time.Time) (daily, monthly quotaPeriod) {
+	now = now.UTC()
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	daily = quotaPeriod{name: "daily", id: now.Format("20060102"), end: dayStart.AddDate(0, 0, 1)}
+	monthly = quotaPeriod{name: "monthly", id: now.Format("200601"), end: monthStart.AddDate(0, 1, 0)}
+	return daily, monthly
+}
+
+func quotaKey(sub rateLimitSubject, metric string, period quotaPeriod) string {
+	return fmt.Sprintf("%s%s:%s:%s:%s", QuotaKeyPrefix, sub.scope, sub.subject, metric, period.id)
+}
+
+func quotaCounter(sub rateLimitSubject, metric string, period quotaPeriod, limit int64, now time.Time) QuotaCounter {
+	return QuotaCounter{
+		Key:   quotaKey(sub, metric, period),
+		Limit: limit,
+		// Keep counters a day past the period so usage can still be inspected
+		TTL: period.end.Sub(now) + 24*time.Hour,
+	}
+}
+
+// limitFor returns the limit that applies to a subject: its own entry, or the
+// scope default
+func (s *RateLimitService) limitFor(ctx context.Context, sub rateLimitSubject) (*models.RateLimit, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.limits == nil || time.Since(s.loadedAt) > rateLimitCacheTTL {
+		list, err := s.db.ListRateLimits(ctx)
+		if err != nil {
+			return nil, err
+		}
+		s.limits = make(map[string]models.RateLimit, len(list))
+		for _, limit := range list {
+			s.limits[limit.Scope+"/"+limit.Subject] = limit
+		}
+		s.loadedAt = time.Now()
+	}
+
+	if limit, ok := s.limits[sub.scope+"/"+sub.subject]; ok {
+		return &limit, nil
+	}
+	if limit, ok := s.limits[sub.scope+"/"+models.RateLimitDefaultSubject]; ok {
+		return &limit, nil
+	}
+	return nil, nil
+}
+
+// subjects returns everything an invocation of a function by a caller counts against
+func (s *RateLimitService) subjects(ctx context.Context, functionID int64, caller string) ([]rateLimitSubject, error) {
+	subjects := []rateLimitSubject{
+		{scope: models.RateLimitScopeFunction, subject: strconv.FormatInt(functionID, 10)},
+		{scope: models.RateLimitScopeCaller, subject: caller},
+	}
+
+	s.mu.Lock()
+	namespace, ok := s.namespaces[functionID]
+	s.mu.Unlock()
+	if !ok {
+		var err error
+		if namespace, err = s.db.FunctionNamespace(ctx, functionID); err != nil {
+			return nil, err
+		}
+		if namespace != "" {
+			s.mu.Lock()
+			if s.namespaces == nil {
+				s.namespaces = make(map[int64]string)
+			}
+			s.namespaces[functionID] = namespace
+			s.mu.Unlock()
+		}
+	}
+	if namespace != "" {
+		subjects = append(subjects, rateLimitSubject{scope: models.RateLimitScopeNamespace, subject: namespace})
+	}
+	return subjects, nil
+}
+
+func (s *RateLimitService) invalidate() {
+	s.mu.Lock()
+	s.limits = nil
+	s.mu.Unlock()
+}
+
+// InvokeAdmission records what an admitted request consumed, so it can be
+// refunded when the request is rejected further on
+type InvokeAdmission struct {
+	buckets  []TokenBucket
+	counters []QuotaCounter
+	n        int64
+}
+
+// CheckInvoke admits one invocation of a function by a caller, consuming rate
+// limit tokens and invocation quota. It returns a *ThrottleError when any
+// applicable limit is exhausted.
+func (s *RateLimitService) CheckInvoke(ctx context.Context, functionID int64, caller string) (*InvokeAdmission, error) {
+	return s.CheckInvokeN(ctx, functionID, caller, 1)
+}
+
+// CheckInvokeN admits a request creating n invocations at once (a batch). The
+// request takes a single rate limit token but n units of invocation quota.
+func (s *RateLimitService) CheckInvokeN(ctx context.Context, functionID int64, caller string, n int64) (*InvokeAdmission, error) {
+	now := time.Now()
+	daily, monthly := quotaPeriods(now)
+
+	subjects, err := s.subjects(ctx, functionID, caller)
+	if err != nil {
+		return nil, err
+	}
+
+	var buckets []TokenBucket
+	var bucketOwners []rateLimitSubject
+	var counters []QuotaCounter
+	var counterLabels []string
+	var counterEnds []time.Time
+
+	for _, sub := range subjects {
+		limit, err := s.limitFor(ctx, sub)
+		if err != nil {
+			return nil, err
+		}
+		if limit == nil {
+			continue
+		}
+
+		// Compute quotas are consumed on completion, so only check them here
+		if err := s.checkCompute(ctx, sub, limit.DailyComputeSeconds, daily); err != nil {
+			return nil, err
+		}
+		if err := s.checkCompute(ctx, sub, limit.MonthlyComputeSeconds, monthly); err != nil {
+			return nil, err
+		}
+
+		if limit.RequestsPerSecond > 0 {
+			burst := limit.Burst
+			if burst <= 0 {
+				burst = int(math.Max(1, math.Ceil(limit.RequestsPerSecond)))
+			}
+			buckets = append(buckets, TokenBucket{
+				Key:   fmt.Sprintf("%s%s:%s", RateLimitKeyPrefix, sub.scope, sub.subject),
+				Rate:  limit.RequestsPerSecond,
+				Burst: burst,
+			})
+			bucketOwners = append(bucketOwners, sub)
+		}
+		if limit.DailyInvocations > 0 {
+			counters = append(counters, quotaCounter(sub, "invocations", daily, limit.DailyInvocations, now))
+			counterLabels = append(counterLabels, fmt.Sprintf("daily invocation quota exceeded for %s", sub))
+			counterEnds = append(counterEnds, daily.end)
+		}
+		if limit.MonthlyInvocations > 0 {
+			counters = append(counters, quotaCounter(sub, "invocations", monthly, limit.MonthlyInvocations, now))
+			counterLabels = append(counterLabels, fmt.Sprintf("monthly invocation quota exceeded for %s", sub))
+			counterEnds = append(counterEnds, monthly.end)
+		}
+	}
+
+	wait, err := s.redis.TakeTokens(ctx, buckets)
+	if err != nil {
+		return nil, err
+	}
+	if wait > 0 {
+		owners := make([]string, len(bucketOwners))
+		for i, sub := range bucketOwners {
+			owners[i] = sub.String()
+		}
+		return nil, &ThrottleError{
+			Reason:     fmt.Sprintf("rate limit exceeded for %s", strings.Join(owners, ", ")),
+			RetryAfter: wait,
+		}
+	}
+
+	exhausted, err := s.redis.IncrementQuotas(ctx, counters, n)
+	if err == nil && exhausted >= 0 {
+		err = &ThrottleError{
+			Reason:     counterLabels[exhausted],
+			RetryAfter: time.Until(counterEnds[exhausted]),
+		}
+	}
+	if err != nil {
+		// The request is rejected, so it keeps no tokens
+		s.Refund(ctx, &InvokeAdmission{buckets: buckets})
+		return nil, err
+	}
+
+	return &InvokeAdmission{buckets: buckets, counters: counters, n: n}, nil
+}
+
+// Items returns an admission covering the quota of n of the admitted items,
+// for refunding the part of a batch that never started. The request's rate
+// limit tokens are not part of it.
+func (a *InvokeAdmission) Items(n int64) *InvokeAdmission {
+	if a == nil {
+		return nil
+	}
+	return &InvokeAdmission{counters: a.counters, n: n}
+}
+
+// Refund gives back the rate limit tokens and invocation quota of an admitted
+// request that was rejected afterwards, e.g. at the concurrency limit
+func (s *RateLimitService) Refund(ctx context.Context, a *InvokeAdmission) error {
+	if a == nil {
+		return nil
+	}
+	if err := s.redis.ReturnTokens(ctx, a.buckets); err != nil {
+		return err
+	}
+	if a.n > 0 {
+		return s.redis.AddToCounters(ctx, a.counters, -a.n)
+	}
+	return nil
+}
+
+// checkCompute rejects the invocation when the compute quota of a period is used up
+func (s *RateLimitService) checkCompute(ctx context.Context, sub rateLimitSubject, limitSeconds int64, period quotaPeriod) error {
+	if limitSeconds <= 0 {
+		return nil
+	}
+	used, err := s.redis.GetCounters(ctx, quotaKey(sub, "compute_ms", period))
+	if err != nil {
+		return err
+	}
+	if used[0] >= limitSeconds*1000 {
+		return &ThrottleError{
+			Reason:     fmt.Sprintf("%s compute quota exceeded for %s", period.name, sub),
+			RetryAfter: time.Until(period.end),
+		}
+	}
+	return nil
+}
+
+// RecordCompute adds the duration of a finished invocation to compute usage
+func (s *RateLimitService) RecordCompute(ctx context.Context, functionID int64, caller string, durationMs int) error {
+	if durationMs <= 0 {
+		return nil
+	}
+
+	now := time.Now()
+	daily, monthly := quotaPeriods(now)
+	subjects, err := s.subjects(ctx, functionID, caller)
+	if err != nil {
+		return err
+	}
+
+	var counters []QuotaCounter
+	for _, sub := range subjects {
+		counters = append(counters,
+			quotaCounter(sub, "compute_ms", daily, 0, now),
+			quotaCounter(sub, "compute_ms", monthly, 0, now),
+		)
+	}
+	return s.redis.AddToCounters(ctx, counters, int64(durationMs))
+}
+
+// GetUsage returns the quota consumption of a subject in the current periods
+func (s *RateLimitService) GetUsage(ctx context.Context, scope, subject string) (*models.QuotaUsage, error) {
+	if err := validateRateLimitScope(scope); err != nil {
+		return nil, err
+	}
+
+	daily, monthly := quotaPeriods(time.Now())
+	sub := rateLimitSubject{scope: scope, subject: subject}
+	counts, err := s.redis.GetCounters(ctx,
+		quotaKey(sub, "invocations", daily),
+		quotaKey(sub, "invocations", monthly),
+		quotaKey(sub, "compute_ms", daily),
+		quotaKey(sub, "compute_ms", monthly),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.QuotaUsage{
+		Scope:                 scope,
+		Subject:               subject,
+		DailyInvocations:      counts[0],
+		MonthlyInvocations:    counts[1],
+		DailyComputeSeconds:   float64(counts[2]) / 1000,
+		MonthlyComputeSeconds: float64(counts[3]) / 1000,
+	}, nil
+}
+
+// ListRateLimits returns all configured limits
+func (s *RateLimitService) ListRateLimits(ctx context.Context) ([]models.RateLimit, error) {
+	return s.db.ListRateLimits(ctx)
+}
+
+// UpsertRateLimit creates or replaces the limit of a scope/subject pair
+func (s *RateLimitService) UpsertRateLimit(ctx context.Context, req *models.UpsertRateLimitRequest) (*models.RateLimit, error) {
+	if err := validateRateLimitScope(req.Scope); err != nil {
+		return nil, err
+	}
+	if req.Subject == "" {
+		return nil, fmt.Errorf("subject is required")
+	}
+	if req.Scope == models.RateLimitScopeFunction && req.Subject != models.RateLimitDefaultSubject {
+		if _, err := strconv.ParseInt(req.Subject, 10, 64); err != nil {
+			return nil, fmt.Errorf("subject must be a function ID or %q", models.RateLimitDefaultSubject)
+		}
+	}
+	if req.RequestsPerSecond < 0 || req.Burst < 0 || req.DailyInvocations < 0 || req.MonthlyInvocations < 0 ||
+		req.DailyComputeSeconds < 0 || req.MonthlyComputeSeconds < 0 {
+		return nil, fmt.Errorf("limits must not be negative")
+	}
+
+	limits, err := s.db.ListRateLimits(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var before *models.RateLimit
+	for i := range limits {
+		if limits[i].Scope == req.Scope && limits[i].Subject == req.Subject {
+			before = &limits[i]
+		}
+	}
+
+	limit, err := s.db.UpsertRateLimit(ctx, &models.RateLimit{
+		Scope:                 req.Scope,
+		Subject:               req.Subject,
+		RequestsPerSecond:     req.RequestsPerSecond,
+		Burst:                 req.Burst,
+		DailyInvocations:      req.DailyInvocations,
+		MonthlyInvocations:    req.MonthlyInvocations,
+		DailyComputeSeconds:   req.DailyComputeSeconds,
+		MonthlyComputeSeconds: req.MonthlyComputeSeconds,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if before == nil {
+		audit(ctx, s.db, models.AuditCreate, models.AuditRateLimit, limit.ID, nil, limit)
+	} else {
+		audit(ctx, s.db, models.AuditUpdate, models.AuditRateLimit, limit.ID, before, limit)
+	}
+
+	s.invalidate()
+	return limit, nil
+}
+
+// DeleteRateLimit removes a limit
+func (s *RateLimitService) DeleteRateLimit(ctx context.Context, id int64) error {
+	limit, err := s.db.GetRateLimit(ctx, id)
+	if err != nil {
+		return err
+	}
+	if limit == nil {
+		return fmt.Errorf("rate limit not found: %d", id)
+	}
+
+	if err := s.db.DeleteRateLimit(ctx, id); err != nil {
+		return err
+	}
+	audit(ctx, s.db, models.AuditDelete, models.AuditRateLimit, id, limit, nil)
+
+	s.invalidate()
+	return nil
+}
+
+func validateRateLimitScope(scope string) error {
+	switch scope {
+	case models.RateLimitScopeFunction, models.RateLimitScopeCaller, models.RateLimitScopeNamespace:
+		return nil
+	default:
+		return fmt.Errorf("scope must be %q, %q or %q", models.RateLimitScopeFunction, models.RateLimitScopeCaller, models.RateLimitScopeNamespace)
+	}
+}
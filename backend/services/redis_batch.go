@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/redis/go-redis/v9"
+	"lambda-runner-server/models"
+)
+
+// BatchQueueKeyPrefix holds batch items waiting for a free parallelism slot
+const BatchQueueKeyPrefix = "batch_queue:"
+
+func batchQueueKey(batchID int64) string {
+	return fmt.Sprintf("%s%d", BatchQueueKeyPrefix, batchID)
+}
+
+func marshalExecutionRequests(reqs []*models.ExecutionRequest) ([]interface{}, error) {
+	values := make([]interface{}, len(reqs))
+	for i, req := range reqs {
+		jsonData, err := json.Marshal(req)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = string(jsonData)
+	}
+	return values, nil
+}
+
+// PushExecutionRequests pushes many execution requests in a single round trip.
+// Requests are appended in order, so workers (RPOP) take them first-in first-out.
+func (r *RedisService) PushExecutionRequests(ctx context.Context, queueKey string, reqs []*models.ExecutionRequest) error {
+	if len(reqs) == 0 {
+		return nil
+	}
+
+	var err error
+	xray.Capture(ctx, "Redis.LPush", func(ctx1 context.Context) error {
+		values, marshalErr := marshalExecutionRequests(reqs)
+		if marshalErr != nil {
+			err = marshalErr
+			return marshalErr
+		}
+		err = r.client.LPush(ctx, queueKey, values...).Err()
+
+		// Add metadata to subsegment
+		if seg := xray.GetSegment(ctx1); seg != nil {
+			seg.AddMetadata("redis.queue_key", queueKey)
+			seg.AddMetadata("redis.operation", "LPUSH")
+			seg.AddMetadata("redis.count", len(reqs))
+		}
+
+		return err
+	})
+	return err
+}
+
+// PushOverflowRequests appends many requests to a function's overflow queue
+func (r *RedisService) PushOverflowRequests(ctx context.Context, functionID int64, reqs []*models.ExecutionRequest) error {
+	if len(reqs) == 0 {
+		return nil
+	}
+
+	values, err := marshalExecutionRequests(reqs)
+	if err != nil {
+		return err
+	}
+
+	pipe := r.client.TxPipeline()
+	pipe.LPush(ctx, overflowQueueKey(functionID), values...)
+	pipe.SAdd(ctx, OverflowFunctionsKey, functionID)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// PushBatchPending parks batch items beyond the batch's max parallelism
+func (r *RedisService) PushBatchPending(ctx context.Context, batchID int64, reqs []*models.ExecutionRequest) error {
+	if len(reqs) == 0 {
+		return nil
+	}
+
+	values, err := marshalExecutionRequests(reqs)
+	if err != nil {
+		return err
+	}
+	return r.client.LPush(ctx, batchQueueKey(batchID), values...).Err()
+}
+
+// PopBatchPending takes the next parked item of a batch, or nil when none is left
+func (r *RedisService) PopBatchPending(ctx context.Context, batchID int64) (*models.ExecutionRequest, error) {
+	jsonData, err := r.client.RPop(ctx, batchQueueKey(batchID)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var req models.ExecutionRequest
+	if err := json.Unmarshal([]byte(jsonData), &req); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
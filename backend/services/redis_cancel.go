@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/redis/go-redis/v9"
+)
+
+// CancelKeyPrefix is used both for the cancel tombstone key and the pub/sub
+// channel a worker listens on while running an invocation
+const CancelKeyPrefix = "cancel:"
+
+// removeQueuedScript removes the request of an invocation from the first list
+// that holds it.
+//
+// KEYS = candidate lists, ARGV[1] = invocation ID
+var removeQueuedScript = redis.NewScript(`
+local target = tonumber(ARGV[1])
+for _, key in ipairs(KEYS) do
+	local items = redis.call('LRANGE', key, 0, -1)
+	for _, item in ipairs(items) do
+		local ok, req = pcall(cjson.decode, item)
+		if ok and tonumber(req['invocationId']) == target then
+			redis.call('LREM', key, 1, item)
+			return 1
+		end
+	end
+end
+return 0
+`)
+
+func cancelKey(invocationID int64) string {
+	return fmt.Sprintf("%s%d", CancelKeyPrefix, invocationID)
+}
+
+// RemoveQueuedRequest removes a not-yet-started request from the given queues.
+// It reports whether the request was found.
+func (r *RedisService) RemoveQueuedRequest(ctx context.Context, queueKeys []string, invocationID int64) (bool, error) {
+	var removed bool
+	var finalErr error
+
+	xray.Capture(ctx, "Redis.RemoveQueuedRequest", func(ctx1 context.Context) error {
+		res, err := removeQueuedScript.Run(ctx, r.client, queueKeys, invocationID).Int()
+		if err != nil {
+			finalErr = err
+			return err
+		}
+		removed = res == 1
+
+		// Add metadata to subsegment
+		if seg := xray.GetSegment(ctx1); seg != nil {
+			seg.AddMetadata("redis.operation", "EVALSHA")
+			seg.AddMetadata("redis.invocation_id", invocationID)
+			seg.AddMetadata("redis.removed", removed)
+		}
+
+		return nil
+	})
+
+	return removed, finalErr
+}
+
+// SignalCancel tombstones an invocation and notifies the worker running it
+func (r *RedisService) SignalCancel(ctx context.Context, invocationID int64) error {
+	key := cancelKey(invocationID)
+
+	pipe := r.client.TxPipeline()
+	pipe.Set(ctx, key, "1", ResultTTL)
+	pipe.Publish(ctx, key, "cancel")
+	_, err := pipe.Exec(ctx)
+	return err
+}
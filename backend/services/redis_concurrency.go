@@ -0,0 +1,187 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/redis/go-redis/v9"
+	"lambda-runner-server/models"
+)
+
+const (
+	FunctionSlotsKeyPrefix = "concurrency:function:"
+	RuntimeSlotsKeyPrefix  = "concurrency:runtime:"
+	OverflowQueueKeyPrefix = "overflow_queue:function:"
+	OverflowFunctionsKey   = "overflow_queue:functions"
+
+	// SlotLeaseTTL bounds how long a slot is held when no result ever arrives
+	// (e.g. a worker crashed), so leaked slots are eventually reclaimed.
+	SlotLeaseTTL = ResultTTL
+)
+
+// acquireSlotScript atomically takes a concurrency slot for an invocation.
+//
+// KEYS[1] = function slot set, KEYS[2] = shared runtime pool set
+// ARGV[1] = member (invocation ID), ARGV[2] = now (ms), ARGV[3] = expire before (ms),
+// ARGV[4] = function limit (0 = unlimited), ARGV[5] = reserved slots,
+// ARGV[6] = shared pool limit (-1 = unlimited)
+//
+// Slots below the function's reservation never touch the shared pool; slots
+// above it are borrowed from the runtime's unreserved capacity.
+var acquireSlotScript = redis.NewScript(`
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', ARGV[3])
+redis.call('ZREMRANGEBYSCORE', KEYS[2], '-inf', ARGV[3])
+if redis.call('ZSCORE', KEYS[1], ARGV[1]) then
+	return 1
+end
+local fnLimit = tonumber(ARGV[4])
+local reserved = tonumber(ARGV[5])
+local poolLimit = tonumber(ARGV[6])
+local inFlight = redis.call('ZCARD', KEYS[1])
+if fnLimit > 0 and inFlight >= fnLimit then
+	return 0
+end
+if inFlight >= reserved and poolLimit >= 0 then
+	if redis.call('ZCARD', KEYS[2]) >= poolLimit then
+		return 0
+	end
+	redis.call('ZADD', KEYS[2], ARGV[2], ARGV[1])
+end
+redis.call('ZADD', KEYS[1], ARGV[2], ARGV[1])
+return 1
+`)
+
+func functionSlotsKey(functionID int64) string {
+	return fmt.Sprintf("%s%d", FunctionSlotsKeyPrefix, functionID)
+}
+
+func runtimeSlotsKey(pool string) string {
+	return RuntimeSlotsKeyPrefix + pool
+}
+
+func overflowQueueKey(functionID int64) string {
+	return fmt.Sprintf("%s%d", OverflowQueueKeyPrefix, functionID)
+}
+
+// AcquireSlot tries to take a concurrency slot for an invocation
+func (r *RedisService) AcquireSlot(ctx context.Context, functionID int64, pool string, invocationID int64, fnLimit, reserved, poolLimit int) (bool, error) {
+	var acquired bool
+	var finalErr error
+
+	xray.Capture(ctx, "Redis.AcquireSlot", func(ctx1 context.Context) error {
+		now := time.Now()
+		res, err := acquireSlotScript.Run(ctx, r.client,
+			[]string{functionSlotsKey(functionID), runtimeSlotsKey(pool)},
+			invocationID, now.UnixMilli(), now.Add(-SlotLeaseTTL).UnixMilli(), fnLimit, reserved, poolLimit,
+		).Int()
+		if err != nil {
+			finalErr = err
+			return err
+		}
+		acquired = res == 1
+
+		// Add metadata to subsegment
+		if seg := xray.GetSegment(ctx1); seg != nil {
+			seg.AddMetadata("redis.operation", "EVALSHA")
+			seg.AddMetadata("redis.function_id", functionID)
+			seg.AddMetadata("redis.acquired", acquired)
+		}
+
+		return nil
+	})
+
+	return acquired, finalErr
+}
+
+// ReleaseSlot frees the slot held by an invocation. It reports whether a slot was held.
+func (r *RedisService) ReleaseSlot(ctx context.Context, functionID int64, pool string, invocationID int64) (bool, error) {
+	member := strconv.FormatInt(invocationID, 10)
+
+	pipe := r.client.TxPipeline()
+	fnRem := pipe.ZRem(ctx, functionSlotsKey(functionID), member)
+	pipe.ZRem(ctx, runtimeSlotsKey(pool), member)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+
+	return fnRem.Val() > 0, nil
+}
+
+// CountSlots returns the number of live slots held by a function
+func (r *RedisService) CountSlots(ctx context.Context, functionID int64) (int64, error) {
+	expireBefore := strconv.FormatInt(time.Now().Add(-SlotLeaseTTL).UnixMilli(), 10)
+	return r.client.ZCount(ctx, functionSlotsKey(functionID), "("+expireBefore, "+inf").Result()
+}
+
+// PushOverflow appends an execution request to a function's overflow queue
+func (r *RedisService) PushOverflow(ctx context.Context, functionID int64, req *models.ExecutionRequest) error {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	pipe := r.client.TxPipeline()
+	pipe.LPush(ctx, overflowQueueKey(functionID), string(jsonData))
+	pipe.SAdd(ctx, OverflowFunctionsKey, functionID)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// PopOverflow takes the oldest request from a function's overflow queue
+func (r *RedisService) PopOverflow(ctx context.Context, functionID int64) (*models.ExecutionRequest, error) {
+	jsonData, err := r.client.RPop(ctx, overflowQueueKey(functionID)).Result()
+	if err == redis.Nil {
+		r.client.SRem(ctx, OverflowFunctionsKey, functionID)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var req models.ExecutionRequest
+	if err := json.Unmarshal([]byte(jsonData), &req); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// RequeueOverflow puts a request back at the head of a function's overflow queue
+func (r *RedisService) RequeueOverflow(ctx context.Context, functionID int64, req *models.ExecutionRequest) error {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	pipe := r.client.TxPipeline()
+	pipe.RPush(ctx, overflowQueueKey(functionID), string(jsonData))
+	pipe.SAdd(ctx, OverflowFunctionsKey, functionID)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// OverflowLength returns the number of requests waiting in a function's overflow queue
+func (r *RedisService) OverflowLength(ctx context.Context, functionID int64) (int64, error) {
+	return r.client.LLen(ctx, overflowQueueKey(functionID)).Result()
+}
+
+// ListOverflowFunctions returns the IDs of functions that have queued overflow requests
+func (r *RedisService) ListOverflowFunctions(ctx context.Context) ([]int64, error) {
+	members, err := r.client.SMembers(ctx, OverflowFunctionsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, 0, len(members))
+	for _, m := range members {
+		id, err := strconv.ParseInt(m, 10, 64)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
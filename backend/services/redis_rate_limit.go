@@ -0,0 +1,207 @@
+package services
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	RateLimitKeyPrefix = "ratelimit:"
+	QuotaKeyPrefix     = "quota:"
+)
+
+// TokenBucket describes one bucket checked by takeTokensScript
+type TokenBucket struct {
+	Key   string
+	Rate  float64 // tokens refilled per second
+	Burst int     // bucket size
+}
+
+// QuotaCounter describes one counter checked by incrementQuotasScript
+type QuotaCounter struct {
+	Key   string
+	Limit int64
+	TTL   time.Duration
+}
+
+// takeTokensScript takes one token from every bucket, or from none of them.
+//
+// KEYS = bucket keys
+// ARGV[1] = now (ms), then per bucket: rate, burst
+//
+// Returns 0 when the tokens were taken, otherwise the wait in ms until every
+// bucket holds a token again.
+var takeTokensScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local tokens = {}
+local wait = 0
+for i, key in ipairs(KEYS) do
+	local rate = tonumber(ARGV[i * 2])
+	local burst = tonumber(ARGV[i * 2 + 1])
+	local state = redis.call('HMGET', key, 'tokens', 'ts')
+	local t = tonumber(state[1]) or burst
+	local ts = tonumber(state[2]) or now
+	t = math.min(burst, t + math.max(0, now - ts) / 1000 * rate)
+	tokens[i] = t
+	if t < 1 then
+		wait = math.max(wait, math.ceil((1 - t) / rate * 1000))
+	end
+end
+if wait > 0 then
+	return wait
+end
+for i, key in ipairs(KEYS) do
+	local rate = tonumber(ARGV[i * 2])
+	local burst = tonumber(ARGV[i * 2 + 1])
+	redis.call('HSET', key, 'tokens', tokens[i] - 1, 'ts', now)
+	redis.call('PEXPIRE', key, math.ceil(burst / rate * 1000) + 1000)
+end
+return 0
+`)
+
+// incrementQuotasScript increments every counter by n, or none of them.
+//
+// KEYS = counter keys
+// ARGV[1] = n, then per counter: limit, ttl (seconds)
+//
+// Returns 0 when incremented, otherwise the 1-based index of the exhausted counter.
+var incrementQuotasScript = redis.NewScript(`
+local n = tonumber(ARGV[1])
+for i, key in ipairs(KEYS) do
+	local limit = tonumber(ARGV[i * 2])
+	local used = tonumber(redis.call('GET', key) or '0')
+	if used + n > limit then
+		return i
+	end
+end
+for i, key in ipairs(KEYS) do
+	redis.call('INCRBY', key, n)
+	redis.call('EXPIRE', key, tonumber(ARGV[i * 2 + 1]))
+end
+return 0
+`)
+
+// returnTokensScript puts one token back into every bucket that still
+// exists, up to its burst.
+//
+// KEYS = bucket keys
+// ARGV = burst per bucket
+var returnTokensScript = redis.NewScript(`
+for i, key in ipairs(KEYS) do
+	local burst = tonumber(ARGV[i])
+	local t = tonumber(redis.call('HGET', key, 'tokens'))
+	if t then
+		redis.call('HSET', key, 'tokens', math.min(burst, t + 1))
+	end
+end
+return 0
+`)
+
+// TakeTokens takes a token from each bucket. It returns the wait until a
+// retry can succeed, or zero when the tokens were taken.
+func (r *RedisService) TakeTokens(ctx context.Context, buckets []TokenBucket) (time.Duration, error) {
+	if len(buckets) == 0 {
+		return 0, nil
+	}
+
+	var wait time.Duration
+	var finalErr error
+
+	xray.Capture(ctx, "Redis.TakeTokens", func(ctx1 context.Context) error {
+		keys := make([]string, len(buckets))
+		args := []interface{}{time.Now().UnixMilli()}
+		for i, b := range buckets {
+			keys[i] = b.Key
+			args = append(args, b.Rate, b.Burst)
+		}
+
+		waitMs, err := takeTokensScript.Run(ctx, r.client, keys, args...).Int64()
+		if err != nil {
+			finalErr = err
+			return err
+		}
+		wait = time.Duration(waitMs) * time.Millisecond
+
+		// Add metadata to subsegment
+		if seg := xray.GetSegment(ctx1); seg != nil {
+			seg.AddMetadata("redis.operation", "EVALSHA")
+			seg.AddMetadata("redis.buckets", len(buckets))
+		}
+
+		return nil
+	})
+
+	return wait, finalErr
+}
+
+// ReturnTokens gives back a token taken from each bucket
+func (r *RedisService) ReturnTokens(ctx context.Context, buckets []TokenBucket) error {
+	if len(buckets) == 0 {
+		return nil
+	}
+
+	keys := make([]string, len(buckets))
+	args := make([]interface{}, len(buckets))
+	for i, b := range buckets {
+		keys[i] = b.Key
+		args[i] = b.Burst
+	}
+	return returnTokensScript.Run(ctx, r.client, keys, args...).Err()
+}
+
+// IncrementQuotas increments each counter by n unless one would be exceeded.
+// It returns the index of the exhausted counter, or -1 when all were incremented.
+func (r *RedisService) IncrementQuotas(ctx context.Context, counters []QuotaCounter, n int64) (int, error) {
+	if len(counters) == 0 {
+		return -1, nil
+	}
+
+	keys := make([]string, len(counters))
+	args := make([]interface{}, 0, len(counters)*2+1)
+	args = append(args, n)
+	for i, c := range counters {
+		keys[i] = c.Key
+		args = append(args, c.Limit, int64(c.TTL.Seconds()))
+	}
+
+	res, err := incrementQuotasScript.Run(ctx, r.client, keys, args...).Int()
+	if err != nil {
+		return -1, err
+	}
+	return res - 1, nil
+}
+
+// AddToCounters increments each counter by n and refreshes its expiry
+func (r *RedisService) AddToCounters(ctx context.Context, counters []QuotaCounter, n int64) error {
+	if len(counters) == 0 {
+		return nil
+	}
+
+	pipe := r.client.Pipeline()
+	for _, c := range counters {
+		pipe.IncrBy(ctx, c.Key, n)
+		pipe.Expire(ctx, c.Key, c.TTL)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// GetCounters returns the current values of the given counter keys (missing keys are 0)
+func (r *RedisService) GetCounters(ctx context.Context, keys ...string) ([]int64, error) {
+	values, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make([]int64, len(values))
+	for i, v := range values {
+		if str, ok := v.(string); ok {
+			counts[i], _ = strconv.ParseInt(str, 10, 64)
+		}
+	}
+	return counts, nil
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// TriggerStreamKeyPrefix holds the internal stream pub/sub triggers are bridged into
+	TriggerStreamKeyPrefix = "trigger_stream:"
+	// TriggerLockKeyPrefix guards the single subscriber of a pub/sub trigger
+	TriggerLockKeyPrefix = "trigger_lock:"
+	// TriggerConsumeLockKeyPrefix guards the single consumer reading a trigger's stream
+	TriggerConsumeLockKeyPrefix = "trigger_consume_lock:"
+
+	triggerStreamMaxLen = 10000
+)
+
+// renewLockScript extends a lock only while it is still held by the owner
+var renewLockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// releaseLockScript deletes a lock only while it is still held by the owner
+var releaseLockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+func triggerStreamKey(triggerID int64) string {
+	return fmt.Sprintf("%s%d", TriggerStreamKeyPrefix, triggerID)
+}
+
+func triggerLockKey(triggerID int64) string {
+	return fmt.Sprintf("%s%d", TriggerLockKeyPrefix, triggerID)
+}
+
+func triggerConsumeLockKey(triggerID int64) string {
+	return fmt.Sprintf("%s%d", TriggerConsumeLockKeyPrefix, triggerID)
+}
+
+// EnsureConsumerGroup creates a consumer group (and the stream) if missing.
+// New groups start after the current end of the stream.
+func (r *RedisService) EnsureConsumerGroup(ctx context.Context, stream, group string) error {
+	err := r.client.XGroupCreateMkStream(ctx, stream, group, "$").Err()
+	if err != nil && strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil
+	}
+	return err
+}
+
+// ReadGroup reads up to count entries for a consumer. start "0" re-reads the
+// consumer's pending (delivered but unacknowledged) entries without blocking;
+// ">" waits up to block for new entries.
+func (r *RedisService) ReadGroup(ctx context.Context, stream, group, consumer, start string, count int64, block time.Duration) ([]redis.XMessage, error) {
+	if start != ">" {
+		block = -1
+	}
+	streams, err := r.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    group,
+		Consumer: consumer,
+		Streams:  []string{stream, start},
+		Count:    count,
+		Block:    block,
+	}).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(streams) == 0 {
+		return nil, nil
+	}
+	return streams[0].Messages, nil
+}
+
+// ClaimIdle takes over up to count entries of a group that have been pending
+// with any consumer for at least minIdle, oldest first
+func (r *RedisService) ClaimIdle(ctx context.Context, stream, group, consumer string, minIdle time.Duration, count int64) ([]redis.XMessage, error) {
+	start := "0-0"
+	for {
+		msgs, next, err := r.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   stream,
+			Group:    group,
+			Consumer: consumer,
+			MinIdle:  minIdle,
+			Start:    start,
+			Count:    count,
+		}).Result()
+		if err != nil || len(msgs) > 0 || next == "0-0" {
+			return msgs, err
+		}
+		start = next
+	}
+}
+
+// PendingElsewhere returns the number of entries of a group pending with
+// consumers other than the given one
+func (r *RedisService) PendingElsewhere(ctx context.Context, stream, group, consumer string) (int64, error) {
+	pending, err := r.client.XPending(ctx, stream, group).Result()
+	if err != nil {
+		return 0, err
+	}
+	return pending.Count - pending.Consumers[consumer], nil
+}
+
+// AckMessages acknowledges processed entries of a consumer group
+func (r *RedisService) AckMessages(ctx context.Context, stream, group string, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.client.XAck(ctx, stream, group, ids...).Err()
+}
+
+// AppendTriggerMessage adds a pub/sub message to a trigger's internal stream
+func (r *RedisService) AppendTriggerMessage(ctx context.Context, triggerID int64, channel, payload string) error {
+	return r.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: triggerStreamKey(triggerID),
+		MaxLen: triggerStreamMaxLen,
+		Approx: true,
+		Values: map[string]interface{}{"channel": channel, "payload": payload},
+	}).Err()
+}
+
+// Subscribe subscribes to a pub/sub channel
+func (r *RedisService) Subscribe(ctx context.Context, channel string) *redis.PubSub {
+	return r.client.Subscribe(ctx, channel)
+}
+
+// AcquireLock takes a lock key for owner unless another owner holds it
+func (r *RedisService) AcquireLock(ctx context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	return r.client.SetNX(ctx, key, owner, ttl).Result()
+}
+
+// RenewLock extends a lock held by owner. It reports false when the lock was lost.
+func (r *RedisService) RenewLock(ctx context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	res, err := renewLockScript.Run(ctx, r.client, []string{key}, owner, ttl.Milliseconds()).Int()
+	return res == 1, err
+}
+
+// ReleaseLock frees a lock held by owner
+func (r *RedisService) ReleaseLock(ctx context.Context, key, owner string) error {
+	return releaseLockScript.Run(ctx, r.client, []string{key}, owner).Err()
+}
@@ -0,0 +1,453 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"lambda-runner-server/models"
+)
+
+const (
+	redisTriggerLockTTL   = 15 * time.Second
+	redisTriggerRetryWait = time.Second
+
+	// A consumer stops reading once it fails to renew the consume lock, so
+	// entries pending with another consumer for longer than this are abandoned
+	redisTriggerClaimIdle     = 2 * redisTriggerLockTTL
+	redisTriggerClaimInterval = 30 * time.Second
+)
+
+// RedisTriggerRunner consumes Redis streams with consumer groups and invokes
+// the subscribed functions. Each enabled trigger gets its own worker; workers
+// are started and stopped as triggers are created and deleted. Of all
+// instances, only the one holding a trigger's consume lock reads its stream,
+// so entries are handed to the function in stream order.
+//
+// Entries are acknowledged only after their invocation is recorded, and the
+// last handed-over entry is checkpointed in Postgres per trigger and consumer
+// group. Entries left pending by a consumer that went away, e.g. one renamed
+// by a redeploy, are claimed once idle and checked against the checkpoint
+// like the reader's own backlog: those up to it are only acknowledged, the
+// rest are invoked. New entries are read only while no other consumer holds
+// pending ones, so the checkpoint never passes an entry not yet handed over.
+type RedisTriggerRunner struct {
+	db        *DBService
+	redis     *RedisService
+	functions *FunctionService
+	consumer  string
+	interval  time.Duration
+
+	mu        sync.Mutex
+	workers   map[int64]*redisTriggerWorker
+	workersWg sync.WaitGroup
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+type redisTriggerWorker struct {
+	trigger models.RedisTrigger
+	cancel  context.CancelFunc
+}
+
+// NewRedisTriggerRunner creates a runner. consumer names this backend instance
+// within consumer groups and must be unique among the running instances.
+func NewRedisTriggerRunner(db *DBService, redis *RedisService, functions *FunctionService, consumer string) *RedisTriggerRunner {
+	return &RedisTriggerRunner{
+		db:        db,
+		redis:     redis,
+		functions: functions,
+		consumer:  consumer,
+		interval:  5 * time.Second,
+		workers:   make(map[int64]*redisTriggerWorker),
+		stopCh:    make(chan struct{}),
+	}
+}
+
+func (r *RedisTriggerRunner) Start() {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.syncWorkers()
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.syncWorkers()
+			case <-r.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+func (r *RedisTriggerRunner) Stop() {
+	close(r.stopCh)
+	// Wait for the sync loop first so no worker is started after cancelling
+	r.wg.Wait()
+
+	r.mu.Lock()
+	for id, w := range r.workers {
+		w.cancel()
+		delete(r.workers, id)
+	}
+	r.mu.Unlock()
+	r.workersWg.Wait()
+}
+
+// syncWorkers starts workers for new triggers and stops those of deleted or changed ones
+func (r *RedisTriggerRunner) syncWorkers() {
+	triggers, err := r.db.ListEnabledRedisTriggers(context.Background())
+	if err != nil {
+		log.Printf("redis triggers: failed to list triggers: %v", err)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	active := make(map[int64]bool, len(triggers))
+	for _, t := range triggers {
+		active[t.ID] = true
+		if w, ok := r.workers[t.ID]; ok {
+			if w.trigger.UpdatedAt.Equal(t.UpdatedAt) {
+				continue
+			}
+			w.cancel()
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		r.workers[t.ID] = &redisTriggerWorker{trigger: t, cancel: cancel}
+		r.workersWg.Add(1)
+		go func(t models.RedisTrigger) {
+			defer r.workersWg.Done()
+			r.consume(ctx, t)
+		}(t)
+	}
+
+	for id, w := range r.workers {
+		if !active[id] {
+			w.cancel()
+			delete(r.workers, id)
+		}
+	}
+}
+
+// consume reads a trigger's stream until ctx is cancelled
+func (r *RedisTriggerRunner) consume(ctx context.Context, t models.RedisTrigger) {
+	stream := t.SourceKey
+	if t.SourceType == models.RedisSourcePubSub {
+		stream = triggerStreamKey(t.ID)
+		r.workersWg.Add(1)
+		go func() {
+			defer r.workersWg.Done()
+			r.bridge(ctx, t)
+		}()
+	}
+
+	for {
+		err := r.redis.EnsureConsumerGroup(ctx, stream, t.ConsumerGroup)
+		if err == nil {
+			break
+		}
+		log.Printf("redis triggers: trigger %d: failed to create consumer group: %v", t.ID, err)
+		if !sleepCtx(ctx, redisTriggerRetryWait) {
+			return
+		}
+	}
+
+	lockKey := triggerConsumeLockKey(t.ID)
+	for ctx.Err() == nil {
+		acquired, err := r.redis.AcquireLock(ctx, lockKey, r.consumer, redisTriggerLockTTL)
+		if err != nil || !acquired {
+			sleepCtx(ctx, redisTriggerLockTTL/3)
+			continue
+		}
+
+		lockCtx, cancel := context.WithCancel(ctx)
+		held := make(chan struct{})
+		go func() {
+			defer close(held)
+			r.holdLock(lockCtx, cancel, lockKey)
+		}()
+		r.consumeLocked(lockCtx, t, stream)
+		cancel()
+		<-held
+		r.redis.ReleaseLock(context.Background(), lockKey, r.consumer)
+	}
+}
+
+// holdLock renews a lock until ctx is done and cancels ctx once the lock is lost
+func (r *RedisTriggerRunner) holdLock(ctx context.Context, cancel context.CancelFunc, lockKey string) {
+	renew := time.NewTicker(redisTriggerLockTTL / 3)
+	defer renew.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-renew.C:
+			held, err := r.redis.RenewLock(ctx, lockKey, r.consumer, redisTriggerLockTTL)
+			if err != nil || !held {
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+// consumeLocked reads a trigger's stream until ctx is cancelled, which
+// happens when the consume lock is lost
+func (r *RedisTriggerRunner) consumeLocked(ctx context.Context, t models.RedisTrigger, stream string) {
+	checkpoint, err := r.db.GetRedisTriggerCheckpoint(ctx, t.ID, t.ConsumerGroup)
+	if err != nil {
+		log.Printf("redis triggers: trigger %d: failed to load checkpoint: %v", t.ID, err)
+	}
+
+	// Start with entries delivered to this consumer before a restart, then
+	// take over those of consumers that went away
+	backlog, claiming := true, true
+	var claimed time.Time
+	for ctx.Err() == nil {
+		if !claiming && time.Since(claimed) >= redisTriggerClaimInterval {
+			claiming = true
+		}
+
+		var msgs []redis.XMessage
+		switch {
+		case backlog:
+			msgs, err = r.redis.ReadGroup(ctx, stream, t.ConsumerGroup, r.consumer, "0", int64(t.BatchSize), 0)
+		case claiming:
+			msgs, err = r.redis.ClaimIdle(ctx, stream, t.ConsumerGroup, r.consumer, redisTriggerClaimIdle, int64(t.BatchSize))
+		default:
+			msgs, err = r.readBatch(ctx, stream, t)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("redis triggers: trigger %d: read failed: %v", t.ID, err)
+			if strings.HasPrefix(err.Error(), "NOGROUP") {
+				r.redis.EnsureConsumerGroup(ctx, stream, t.ConsumerGroup)
+			}
+			sleepCtx(ctx, redisTriggerRetryWait)
+			continue
+		}
+		if len(msgs) == 0 {
+			switch {
+			case backlog:
+				backlog = false
+			case claiming:
+				// Entries still pending elsewhere are older than any new one,
+				// so reading resumes only once all of them were claimed
+				others, err := r.redis.PendingElsewhere(ctx, stream, t.ConsumerGroup, r.consumer)
+				if err != nil || others > 0 {
+					if err != nil {
+						log.Printf("redis triggers: trigger %d: failed to check pending entries: %v", t.ID, err)
+					}
+					sleepCtx(ctx, redisTriggerRetryWait)
+					continue
+				}
+				claiming = false
+				claimed = time.Now()
+			}
+			continue
+		}
+
+		// Entries up to the checkpoint were already handed to the function
+		var fresh []redis.XMessage
+		var stale []string
+		for _, m := range msgs {
+			if m.Values == nil || (checkpoint != "" && compareStreamIDs(m.ID, checkpoint) <= 0) {
+				stale = append(stale, m.ID)
+			} else {
+				fresh = append(fresh, m)
+			}
+		}
+		if err := r.redis.AckMessages(ctx, stream, t.ConsumerGroup, stale...); err != nil {
+			log.Printf("redis triggers: trigger %d: ack failed: %v", t.ID, err)
+		}
+		if len(fresh) == 0 {
+			continue
+		}
+
+		if err := r.invoke(ctx, t, fresh); err != nil {
+			log.Printf("redis triggers: trigger %d: invoke failed: %v", t.ID, err)
+			// Claimed entries now belong to this consumer and are retried with its backlog
+			backlog = true
+			wait := redisTriggerRetryWait
+			var throttleErr *ThrottleError
+			if errors.As(err, &throttleErr) && throttleErr.RetryAfter > wait {
+				wait = throttleErr.RetryAfter
+			}
+			sleepCtx(ctx, wait)
+			continue
+		}
+
+		last := fresh[len(fresh)-1].ID
+		if err := r.db.SaveRedisTriggerCheckpoint(ctx, t.ID, t.ConsumerGroup, last); err != nil {
+			log.Printf("redis triggers: trigger %d: failed to save checkpoint: %v", t.ID, err)
+		}
+		checkpoint = last
+
+		ids := make([]string, len(fresh))
+		for i, m := range fresh {
+			ids[i] = m.ID
+		}
+		if err := r.redis.AckMessages(ctx, stream, t.ConsumerGroup, ids...); err != nil {
+			log.Printf("redis triggers: trigger %d: ack failed: %v", t.ID, err)
+			backlog = true
+		}
+	}
+}
+
+// readBatch reads new entries until the batch is full or its window elapses
+func (r *RedisTriggerRunner) readBatch(ctx context.Context, stream string, t models.RedisTrigger) ([]redis.XMessage, error) {
+	window := time.Duration(t.BatchWindowMs) * time.Millisecond
+	msgs, err := r.redis.ReadGroup(ctx, stream, t.ConsumerGroup, r.consumer, ">", int64(t.BatchSize), window)
+	if err != nil || len(msgs) == 0 || len(msgs) >= t.BatchSize {
+		return msgs, err
+	}
+
+	deadline := time.Now().Add(window)
+	for len(msgs) < t.BatchSize {
+		remaining := time.Until(deadline)
+		if remaining < time.Millisecond {
+			break
+		}
+		more, err := r.redis.ReadGroup(ctx, stream, t.ConsumerGroup, r.consumer, ">", int64(t.BatchSize-len(msgs)), remaining)
+		if err != nil {
+			// Already delivered entries stay pending and are picked up as backlog
+			return msgs, nil
+		}
+		if len(more) == 0 {
+			break
+		}
+		msgs = append(msgs, more...)
+	}
+	return msgs, nil
+}
+
+// invoke enqueues one invocation for a batch of entries
+func (r *RedisTriggerRunner) invoke(ctx context.Context, t models.RedisTrigger, msgs []redis.XMessage) error {
+	event := &models.RedisTriggerEvent{
+		TriggerID:  t.ID,
+		SourceType: t.SourceType,
+		SourceKey:  t.SourceKey,
+		Messages:   make([]models.RedisMessage, len(msgs)),
+	}
+	for i, m := range msgs {
+		msg := models.RedisMessage{ID: m.ID}
+		if t.SourceType == models.RedisSourcePubSub {
+			msg.Payload = fmt.Sprint(m.Values["payload"])
+		} else {
+			msg.Fields = make(map[string]string, len(m.Values))
+			for k, v := range m.Values {
+				msg.Fields[k] = fmt.Sprint(v)
+			}
+		}
+		event.Messages[i] = msg
+	}
+
+	params, err := EventParams(event)
+	if err != nil {
+		return err
+	}
+	_, err = r.functions.InvokeFunction(ctx, t.FunctionID, params, InvokeOptions{
+		InvokedBy: fmt.Sprintf("redis:%d", t.ID),
+	})
+	return err
+}
+
+// bridge copies messages of a pub/sub channel into the trigger's internal
+// stream. Only the instance holding the trigger lock subscribes, so each
+// message is copied once.
+func (r *RedisTriggerRunner) bridge(ctx context.Context, t models.RedisTrigger) {
+	lockKey := triggerLockKey(t.ID)
+	for ctx.Err() == nil {
+		acquired, err := r.redis.AcquireLock(ctx, lockKey, r.consumer, redisTriggerLockTTL)
+		if err != nil || !acquired {
+			sleepCtx(ctx, redisTriggerLockTTL/3)
+			continue
+		}
+		r.subscribe(ctx, t, lockKey)
+		r.redis.ReleaseLock(context.Background(), lockKey, r.consumer)
+	}
+}
+
+// subscribe forwards channel messages while the trigger lock is held
+func (r *RedisTriggerRunner) subscribe(ctx context.Context, t models.RedisTrigger, lockKey string) {
+	pubsub := r.redis.Subscribe(ctx, t.SourceKey)
+	defer pubsub.Close()
+
+	if _, err := pubsub.Receive(ctx); err != nil {
+		log.Printf("redis triggers: trigger %d: subscribe failed: %v", t.ID, err)
+		sleepCtx(ctx, redisTriggerRetryWait)
+		return
+	}
+
+	renew := time.NewTicker(redisTriggerLockTTL / 3)
+	defer renew.Stop()
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-renew.C:
+			held, err := r.redis.RenewLock(ctx, lockKey, r.consumer, redisTriggerLockTTL)
+			if err != nil || !held {
+				return
+			}
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			if err := r.redis.AppendTriggerMessage(ctx, t.ID, msg.Channel, msg.Payload); err != nil {
+				log.Printf("redis triggers: trigger %d: failed to store message: %v", t.ID, err)
+			}
+		}
+	}
+}
+
+// compareStreamIDs orders stream entry IDs ("<ms>-<seq>")
+func compareStreamIDs(a, b string) int {
+	aMs, aSeq := parseStreamID(a)
+	bMs, bSeq := parseStreamID(b)
+	switch {
+	case aMs != bMs:
+		if aMs < bMs {
+			return -1
+		}
+		return 1
+	case aSeq != bSeq:
+		if aSeq < bSeq {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+func parseStreamID(id string) (uint64, uint64) {
+	msPart, seqPart, _ := strings.Cut(id, "-")
+	ms, _ := strconv.ParseUint(msPart, 10, 64)
+	seq, _ := strconv.ParseUint(seqPart, 10, 64)
+	return ms, seq
+}
+
+// sleepCtx waits for d unless ctx is cancelled first; it reports whether the full wait elapsed
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"lambda-runner-server/models"
+)
+
+const (
+	maxRedisTriggerBatchSize   = 100
+	maxRedisTriggerBatchWindow = 60000
+)
+
+// RedisTriggerService manages Redis stream and pub/sub triggers
+type RedisTriggerService struct {
+	db *DBService
+}
+
+func NewRedisTriggerService(db *DBService) *RedisTriggerService {
+	return &RedisTriggerService{db: db}
+}
+
+// CreateTrigger subscribes a function to a Redis stream or channel
+func (s *RedisTriggerService) CreateTrigger(ctx context.Context, functionID int64, req *models.CreateRedisTriggerRequest) (*models.RedisTrigger, error) {
+	switch req.SourceType {
+	case models.RedisSourceStream, models.RedisSourcePubSub:
+	default:
+		return nil, fmt.Errorf("source_type must be %q or %q", models.RedisSourceStream, models.RedisSourcePubSub)
+	}
+	if req.SourceKey == "" {
+		return nil, fmt.Errorf("source_key is required")
+	}
+	if req.BatchSize == 0 {
+		req.BatchSize = 1
+	}
+	if req.BatchSize < 1 || req.BatchSize > maxRedisTriggerBatchSize {
+		return nil, fmt.Errorf("batch_size must be between 1 and %d", maxRedisTriggerBatchSize)
+	}
+	if req.BatchWindowMs == 0 {
+		req.BatchWindowMs = 1000
+	}
+	if req.BatchWindowMs < 0 || req.BatchWindowMs > maxRedisTriggerBatchWindow {
+		return nil, fmt.Errorf("batch_window_ms must be between 1 and %d", maxRedisTriggerBatchWindow)
+	}
+
+	if _, err := getAuthorizedFunction(ctx, s.db, functionID, models.AccessAdmin); err != nil {
+		return nil, err
+	}
+
+	created, err := s.db.CreateRedisTrigger(ctx, &models.RedisTrigger{
+		FunctionID:    functionID,
+		SourceType:    req.SourceType,
+		SourceKey:     req.SourceKey,
+		ConsumerGroup: req.ConsumerGroup,
+		BatchSize:     req.BatchSize,
+		BatchWindowMs: req.BatchWindowMs,
+	})
+	if err != nil {
+		return nil, err
+	}
+	audit(ctx, s.db, models.AuditCreate, models.AuditRedisTrigger, created.ID, nil, created)
+	return created, nil
+}
+
+// ListTriggers returns the Redis triggers of a function
+func (s *RedisTriggerService) ListTriggers(ctx context.Context, functionID int64) ([]models.RedisTrigger, error) {
+	if _, err := getAuthorizedFunction(ctx, s.db, functionID, models.AccessRead); err != nil {
+		return nil, err
+	}
+	return s.db.ListRedisTriggers(ctx, functionID)
+}
+
+// DeleteTrigger removes a Redis trigger of a function
+func (s *RedisTriggerService) DeleteTrigger(ctx context.Context, functionID, triggerID int64) error {
+	if _, err := getAuthorizedFunction(ctx, s.db, functionID, models.AccessAdmin); err != nil {
+		return err
+	}
+	deleted, err := s.db.DeleteRedisTrigger(ctx, functionID, triggerID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return fmt.Errorf("redis trigger not found: %d", triggerID)
+	}
+	audit(ctx, s.db, models.AuditDelete, models.AuditRedisTrigger, triggerID, map[string]interface{}{"function_id": functionID}, nil)
+	return nil
+}
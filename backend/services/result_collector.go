@@ -0,0 +1,60 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// ResultCollector persists worker results in the background, so completion
+// side effects run even when nobody polls the invocation.
+type ResultCollector struct {
+	functionService *FunctionService
+	interval        time.Duration
+	window          time.Duration
+	pageSize        int
+	stopCh          chan struct{}
+	wg              sync.WaitGroup
+}
+
+func NewResultCollector(functionService *FunctionService) *ResultCollector {
+	return &ResultCollector{
+		functionService: functionService,
+		interval:        time.Second,
+		window:          time.Hour,
+		pageSize:        200,
+		stopCh:          make(chan struct{}),
+	}
+}
+
+func (c *ResultCollector) Start() {
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.collect()
+			case <-c.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+func (c *ResultCollector) Stop() {
+	close(c.stopCh)
+	c.wg.Wait()
+}
+
+func (c *ResultCollector) collect() {
+	ctx := context.Background()
+	since := time.Now().Add(-c.window)
+	if _, err := c.functionService.CollectResults(ctx, since, c.pageSize); err != nil {
+		log.Printf("collector: failed to collect results: %v", err)
+	}
+	c.functionService.DrainOverflowQueues(ctx)
+}
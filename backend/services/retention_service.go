@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"lambda-runner-server/models"
+)
+
+// RetentionService manages the retention policies of invocations and reports
+// on the storage they use. Expired invocations are deleted by the InvocationPurger.
+type RetentionService struct {
+	db     *DBService
+	purger *InvocationPurger
+}
+
+func NewRetentionService(db *DBService, purger *InvocationPurger) *RetentionService {
+	return &RetentionService{db: db, purger: purger}
+}
+
+// ListPolicies returns all retention policies
+func (s *RetentionService) ListPolicies(ctx context.Context) ([]models.RetentionPolicy, error) {
+	return s.db.ListRetentionPolicies(ctx)
+}
+
+// UpsertPolicy creates or replaces the global policy or the policy of a function
+func (s *RetentionService) UpsertPolicy(ctx context.Context, req *models.UpsertRetentionPolicyRequest) (*models.RetentionPolicy, error) {
+	if req.MaxAgeDays < 0 || req.MaxCount < 0 {
+		return nil, fmt.Errorf("max_age_days and max_count must not be negative")
+	}
+	if req.FunctionID != nil {
+		namespace, err := s.db.FunctionNamespace(ctx, *req.FunctionID)
+		if err != nil {
+			return nil, err
+		}
+		if namespace == "" {
+			return nil, fmt.Errorf("function not found: %d", *req.FunctionID)
+		}
+	}
+
+	policies, err := s.db.ListRetentionPolicies(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var before *models.RetentionPolicy
+	for i := range policies {
+		if sameFunction(policies[i].FunctionID, req.FunctionID) {
+			before = &policies[i]
+		}
+	}
+
+	policy, err := s.db.UpsertRetentionPolicy(ctx, &models.RetentionPolicy{
+		FunctionID: req.FunctionID,
+		MaxAgeDays: req.MaxAgeDays,
+		MaxCount:   req.MaxCount,
+		Archive:    req.Archive,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if before == nil {
+		audit(ctx, s.db, models.AuditCreate, models.AuditRetentionPolicy, policy.ID, nil, policy)
+	} else {
+		audit(ctx, s.db, models.AuditUpdate, models.AuditRetentionPolicy, policy.ID, before, policy)
+	}
+	return policy, nil
+}
+
+// DeletePolicy removes a retention policy; invocations it covered fall back
+// to the global policy, or are kept when it was the global policy
+func (s *RetentionService) DeletePolicy(ctx context.Context, id int64) error {
+	policy, err := s.db.GetRetentionPolicy(ctx, id)
+	if err != nil {
+		return err
+	}
+	if policy == nil {
+		return fmt.Errorf("retention policy not found: %d", id)
+	}
+
+	if err := s.db.DeleteRetentionPolicy(ctx, id); err != nil {
+		return err
+	}
+	audit(ctx, s.db, models.AuditDelete, models.AuditRetentionPolicy, id, policy, nil)
+	return nil
+}
+
+// Status reports table sizes, the retention policies, the invocation partitions
+// and the purges of this instance
+func (s *RetentionService) Status(ctx context.Context) (*models.RetentionStatus, error) {
+	tables, err := s.db.ListTableSizes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	policies, err := s.db.ListRetentionPolicies(ctx)
+	if err != nil {
+		return nil, err
+	}
+	oldest, err := s.db.OldestInvocationTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	partitions, err := s.db.ListInvocationPartitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.RetentionStatus{
+		Tables:             tables,
+		Policies:           policies,
+		Partitions:         partitions,
+		OldestInvocationAt: oldest,
+		Purger:             s.purger.Status(),
+	}, nil
+}
+
+func sameFunction(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
@@ -68,36 +68,25 @@ func (r *ScheduleRunner) executeSchedule(ctx context.Context, sched models.Funct
 	if payload == nil {
 		payload = map[string]interface{}{}
 	}
-	invokedBy := fmt.Sprintf("schedule:%d", sched.ID)
-	inv, err := r.functionService.InvokeFunction(ctx, sched.FunctionID, payload, invokedBy)
+	inv, err := r.functionService.InvokeFunction(ctx, sched.FunctionID, payload, InvokeOptions{
+		InvokedBy: fmt.Sprintf("schedule:%d", sched.ID),
+		Priority:  sched.Priority,
+	})
 	if err != nil {
 		r.scheduleService.MarkExecuted(ctx, sched.ID, models.StatusFail, err.Error())
 		return
 	}
 
-	// Poll for result (max 60 seconds)
-	maxRetries := 120 // 120 * 0.5s = 60s
-	for i := 0; i < maxRetries; i++ {
-		time.Sleep(500 * time.Millisecond)
-
-		result, err := r.functionService.GetInvocationResult(ctx, inv.ID)
-		if err != nil {
-			log.Printf("scheduler: failed to get invocation result: %v", err)
-			continue
-		}
-
-		if result.Status != models.StatusPending {
-			// Execution completed
-			status := result.Status
-			errMsg := ""
-			if result.Status == models.StatusFail || result.Status == models.StatusTimeout {
-				errMsg = result.ErrorMessage
-			}
-			r.scheduleService.MarkExecuted(ctx, sched.ID, status, errMsg)
-			return
-		}
+	// Wait for result (max 60 seconds)
+	result, err := r.functionService.WaitForResult(ctx, inv.ID, 60*time.Second, 500*time.Millisecond)
+	if err != nil || result.Status == models.StatusPending {
+		r.scheduleService.MarkExecuted(ctx, sched.ID, models.StatusTimeout, "execution timed out after 60 seconds")
+		return
 	}
 
-	// Timeout
-	r.scheduleService.MarkExecuted(ctx, sched.ID, models.StatusTimeout, "execution timed out after 60 seconds")
+	errMsg := ""
+	if result.Status == models.StatusFail || result.Status == models.StatusTimeout {
+		errMsg = result.ErrorMessage
+	}
+	r.scheduleService.MarkExecuted(ctx, sched.ID, result.Status, errMsg)
 }
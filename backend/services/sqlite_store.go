@@ -0,0 +1,495 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+
+	"lambda-runner-server/middleware"
+	"lambda-runner-server/models"
+)
+
+// Timestamps are stored as UTC text with a fixed width, so they compare
+// correctly as strings; sqliteNow is the SQL expression of the current time
+const (
+	sqliteTimeLayout = "2006-01-02 15:04:05.000"
+	sqliteNow        = `strftime('%Y-%m-%d %H:%M:%f', 'now')`
+)
+
+func sqliteTime(t time.Time) string {
+	return t.UTC().Format(sqliteTimeLayout)
+}
+
+func sqliteNullTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return sqliteTime(*t)
+}
+
+// sqliteDriver is the sqlite3 driver with the functions the queries need
+const sqliteDriver = "sqlite3_softgate"
+
+func init() {
+	sql.Register(sqliteDriver, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			if err := conn.RegisterFunc("json_contains", sqliteJSONContains, true); err != nil {
+				return err
+			}
+			return conn.RegisterAggregator("percentile", func() *percentileAggregator { return &percentileAggregator{} }, true)
+		},
+	})
+}
+
+// sqliteJSONContains implements json_contains(doc, pattern), the Postgres
+// jsonb @> operator for JSON text
+func sqliteJSONContains(doc, pattern string) (bool, error) {
+	var d, p interface{}
+	if err := json.Unmarshal([]byte(doc), &d); err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal([]byte(pattern), &p); err != nil {
+		return false, err
+	}
+	return jsonContains(d, p), nil
+}
+
+// percentileAggregator is the percentile(value, p) aggregate of the SQLite
+// driver; NULL values are skipped
+type percentileAggregator struct {
+	values []float64
+	p      float64
+}
+
+func (a *percentileAggregator) Step(value interface{}, p float64) {
+	a.p = p
+	switch v := value.(type) {
+	case int64:
+		a.values = append(a.values, float64(v))
+	case float64:
+		a.values = append(a.values, v)
+	}
+}
+
+func (a *percentileAggregator) Done() interface{} {
+	if result := percentile(a.values, a.p); result != nil {
+		return *result
+	}
+	return nil
+}
+
+// SQLiteStore implements the stores on a SQLite file for single-node
+// deployments (DB_DRIVER=sqlite). The schema lives in migrations/sqlite.
+// SQLite allows a single writer at a time, which also serializes the claim
+// of due schedules and callbacks that Postgres does with SKIP LOCKED.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// OpenSQLiteStore opens or creates the database file at path. Transactions
+// take the write lock when they begin, so they wait for each other instead
+// of failing when they upgrade from a read.
+func OpenSQLiteStore(path string) (*SQLiteStore, error) {
+	dsn := fmt.Sprintf("file:%s?_foreign_keys=on&_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate", path)
+	db, err := sql.Open(sqliteDriver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLiteStore{db: db}, nil
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// sqliteInNamespace is inNamespace for SQLite parameters (?n)
+func sqliteInNamespace(column string, n int) string {
+	return fmt.Sprintf(`(?%[1]d = 0 OR %[2]s = ?%[1]d)`, n, column)
+}
+
+// sqliteFunctionInNamespace is functionInNamespace for SQLite parameters (?n)
+func sqliteFunctionInNamespace(column string, n int) string {
+	return fmt.Sprintf(`(?%[1]d = 0 OR %[2]s IN (SELECT id FROM functions WHERE namespace_id = ?%[1]d))`, n, column)
+}
+
+// sqliteFunctionVisible is functionVisible for SQLite; parameter ?n holds
+// the principals as a JSON array (see sqlitePrincipals)
+func sqliteFunctionVisible(n int) string {
+	return fmt.Sprintf(`(?%[1]d IS NULL OR is_public OR owner IS NULL OR owner IN (SELECT value FROM json_each(?%[1]d))
+		OR id IN (SELECT function_id FROM function_grants WHERE principal IN (SELECT value FROM json_each(?%[1]d))))`, n)
+}
+
+// sqlitePrincipals encodes the request's principals for sqliteFunctionVisible
+func sqlitePrincipals(ctx context.Context) interface{} {
+	principals := requestPrincipals(ctx)
+	if principals == nil {
+		return nil
+	}
+	data, _ := json.Marshal(principals)
+	return string(data)
+}
+
+// CreateFunction inserts a new function and its params
+func (s *SQLiteStore) CreateFunction(ctx context.Context, fn *models.Function) (*models.Function, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	sampleEventJSON, _ := json.Marshal(fn.SampleEvent)
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO functions (name, description, runtime, code_s3_key, sample_event, is_public, max_concurrency, reserved_concurrency, overflow_policy,
+			callback_url, callback_secret, created_by_key_id, updated_by_key_id, namespace_id, owner, tags)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, NULLIF(?10, ''), NULLIF(?11, ''), ?12, ?12, ?13, NULLIF(?14, ''), ?15)
+		RETURNING id, created_at, updated_at
+	`, fn.Name, fn.Description, fn.Runtime, fn.CodeS3Key, string(sampleEventJSON), fn.IsPublic, fn.MaxConcurrency, fn.ReservedConcurrency, fn.OverflowPolicy,
+		fn.CallbackURL, fn.CallbackSecret, fn.CreatedByKeyID, fn.NamespaceID, fn.Owner, sqliteTags(fn.Tags)).Scan(&fn.ID, &fn.CreatedAt, &fn.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	fn.UpdatedByKeyID = fn.CreatedByKeyID
+
+	for i := range fn.Params {
+		param := &fn.Params[i]
+		defaultValueJSON, _ := json.Marshal(param.DefaultValue)
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO function_params (function_id, param_key, param_type, is_required, description, default_value)
+			VALUES (?1, ?2, ?3, ?4, ?5, ?6)
+			RETURNING id
+		`, fn.ID, param.ParamKey, param.ParamType, param.IsRequired, param.Description, string(defaultValueJSON)).Scan(&param.ID)
+		if err != nil {
+			return nil, err
+		}
+		param.FunctionID = fn.ID
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return fn, nil
+}
+
+// GetFunction retrieves a function by ID with its params
+func (s *SQLiteStore) GetFunction(ctx context.Context, id int64) (*models.Function, error) {
+	fn := &models.Function{}
+	var sampleEventJSON, tagsJSON []byte
+	var createdByKeyID, updatedByKeyID sql.NullInt64
+
+	err := s.db.QueryRowContext(ctx, `
+		SELECT id, name, description, runtime, code_s3_key, sample_event, is_public, created_at, updated_at,
+			max_concurrency, reserved_concurrency, overflow_policy, COALESCE(callback_url, ''), COALESCE(callback_secret, ''),
+			created_by_key_id, updated_by_key_id, namespace_id, COALESCE(owner, ''), tags
+		FROM functions WHERE id = ?1 AND `+sqliteInNamespace("namespace_id", 2)+`
+	`, id, requestNamespace(ctx)).Scan(&fn.ID, &fn.Name, &fn.Description, &fn.Runtime, &fn.CodeS3Key, &sampleEventJSON, &fn.IsPublic, &fn.CreatedAt, &fn.UpdatedAt,
+		&fn.MaxConcurrency, &fn.ReservedConcurrency, &fn.OverflowPolicy, &fn.CallbackURL, &fn.CallbackSecret,
+		&createdByKeyID, &updatedByKeyID, &fn.NamespaceID, &fn.Owner, &tagsJSON)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if sampleEventJSON != nil {
+		json.Unmarshal(sampleEventJSON, &fn.SampleEvent)
+	}
+	if err := json.Unmarshal(tagsJSON, &fn.Tags); err != nil {
+		return nil, err
+	}
+	if createdByKeyID.Valid {
+		fn.CreatedByKeyID = &createdByKeyID.Int64
+	}
+	if updatedByKeyID.Valid {
+		fn.UpdatedByKeyID = &updatedByKeyID.Int64
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, function_id, param_key, param_type, is_required, description, default_value
+		FROM function_params WHERE function_id = ?1
+		ORDER BY id
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var param models.FunctionParam
+		var defaultValueJSON []byte
+		var desc sql.NullString
+		if err := rows.Scan(&param.ID, &param.FunctionID, &param.ParamKey, &param.ParamType, &param.IsRequired, &desc, &defaultValueJSON); err != nil {
+			return nil, err
+		}
+		if desc.Valid {
+			param.Description = desc.String
+		}
+		if defaultValueJSON != nil {
+			json.Unmarshal(defaultValueJSON, &param.DefaultValue)
+		}
+		fn.Params = append(fn.Params, param)
+	}
+
+	return fn, rows.Err()
+}
+
+// sqliteWhere returns the conditions of the filter for SQLite, including
+// namespace and visibility
+func (f FunctionFilter) sqliteWhere(ctx context.Context) (string, []interface{}) {
+	conditions := []string{sqliteInNamespace("namespace_id", 1), sqliteFunctionVisible(2)}
+	args := []interface{}{requestNamespace(ctx), sqlitePrincipals(ctx)}
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(cond, len(args)))
+	}
+	if f.Runtime != "" {
+		add("runtime = ?%d", f.Runtime)
+	}
+	if len(f.Tags) > 0 {
+		add("NOT EXISTS (SELECT value FROM json_each(?%d) EXCEPT SELECT value FROM json_each(tags))", sqliteTags(f.Tags))
+	}
+	for _, term := range f.searchTerms() {
+		add("(lower(name) LIKE ?%[1]d OR lower(description) LIKE ?%[1]d)", "%"+term+"%")
+	}
+	return ` WHERE ` + strings.Join(conditions, " AND "), args
+}
+
+// ListFunctions returns a page of the functions (without code) matching the
+// filter that the caller may see, and the number of all of them
+func (s *SQLiteStore) ListFunctions(ctx context.Context, filter FunctionFilter) ([]models.FunctionListItem, int, error) {
+	where, args := filter.sqliteWhere(ctx)
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, `SELECT count(*) FROM functions`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	limit := filter.Limit
+	if limit == 0 {
+		limit = -1
+	}
+	since := sqliteTime(time.Now().Add(-24 * time.Hour))
+	args = append(args, since, limit, filter.Offset)
+	n := len(args)
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT id, namespace_id, name, description, runtime, tags, is_public, COALESCE(owner, ''), created_at, updated_at,
+			(SELECT invoked_at FROM function_invocations WHERE function_id = functions.id ORDER BY invoked_at DESC, id DESC LIMIT 1) AS last_invoked_at,
+			(SELECT status FROM function_invocations WHERE function_id = functions.id ORDER BY invoked_at DESC, id DESC LIMIT 1),
+			(SELECT count(*) FROM function_invocations WHERE function_id = functions.id AND invoked_at >= ?%d)
+		FROM functions%s%s
+		LIMIT ?%d OFFSET ?%d
+	`, n-2, where, filter.orderBy(), n-1, n), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var functions []models.FunctionListItem
+	for rows.Next() {
+		var fn models.FunctionListItem
+		var tagsJSON []byte
+		var lastInvokedAt sql.NullTime
+		var lastStatus sql.NullString
+		if err := rows.Scan(&fn.ID, &fn.NamespaceID, &fn.Name, &fn.Description, &fn.Runtime, &tagsJSON, &fn.IsPublic, &fn.Owner, &fn.CreatedAt, &fn.UpdatedAt,
+			&lastInvokedAt, &lastStatus, &fn.Invocations24h); err != nil {
+			return nil, 0, err
+		}
+		if err := json.Unmarshal(tagsJSON, &fn.Tags); err != nil {
+			return nil, 0, err
+		}
+		if lastInvokedAt.Valid {
+			fn.LastInvokedAt = &lastInvokedAt.Time
+		}
+		fn.LastInvocationStatus = lastStatus.String
+		functions = append(functions, fn)
+	}
+
+	return functions, total, rows.Err()
+}
+
+// sqliteTags encodes tags as the JSON array stored in functions.tags
+func sqliteTags(tags []string) string {
+	if tags == nil {
+		tags = []string{}
+	}
+	data, _ := json.Marshal(tags)
+	return string(data)
+}
+
+// updateFunction applies set to a function in the request's namespace and
+// records the calling API key as updated_by_key_id; args fill ?3 onwards
+func (s *SQLiteStore) updateFunction(ctx context.Context, id int64, set string, args ...interface{}) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE functions SET `+set+`, updated_by_key_id = COALESCE(?2, updated_by_key_id), updated_at = `+sqliteNow+`
+		WHERE id = ?1 AND `+sqliteInNamespace("namespace_id", len(args)+3)+`
+	`, append(append([]interface{}{id, middleware.APIKeyID(ctx)}, args...), requestNamespace(ctx))...)
+	return err
+}
+
+// UpdateCodeKey updates the code_s3_key for a function
+func (s *SQLiteStore) UpdateCodeKey(ctx context.Context, id int64, codeKey string) error {
+	return s.updateFunction(ctx, id, `code_s3_key = ?3`, codeKey)
+}
+
+// UpdateFunctionConcurrency updates the concurrency settings for a function
+func (s *SQLiteStore) UpdateFunctionConcurrency(ctx context.Context, id int64, maxConcurrency, reservedConcurrency int, overflowPolicy string) error {
+	return s.updateFunction(ctx, id, `max_concurrency = ?3, reserved_concurrency = ?4, overflow_policy = ?5`,
+		maxConcurrency, reservedConcurrency, overflowPolicy)
+}
+
+// UpdateFunctionCallback sets the default completion callback of a function
+func (s *SQLiteStore) UpdateFunctionCallback(ctx context.Context, id int64, callbackURL, callbackSecret string) error {
+	return s.updateFunction(ctx, id, `callback_url = NULLIF(?3, ''), callback_secret = NULLIF(?4, '')`, callbackURL, callbackSecret)
+}
+
+// UpdateFunctionTags replaces the tags of a function
+func (s *SQLiteStore) UpdateFunctionTags(ctx context.Context, id int64, tags []string) error {
+	return s.updateFunction(ctx, id, `tags = ?3`, sqliteTags(tags))
+}
+
+// UpdateFunctionVisibility makes a function public or private
+func (s *SQLiteStore) UpdateFunctionVisibility(ctx context.Context, id int64, isPublic bool) error {
+	return s.updateFunction(ctx, id, `is_public = ?3`, isPublic)
+}
+
+// DeleteFunction removes a function record (cascades to params/invocations)
+func (s *SQLiteStore) DeleteFunction(ctx context.Context, id int64) (*models.Function, error) {
+	fn, err := s.GetFunction(ctx, id)
+	if err != nil || fn == nil {
+		return nil, err
+	}
+
+	_, err = s.db.ExecContext(ctx, `DELETE FROM functions WHERE id = ?1 AND `+sqliteInNamespace("namespace_id", 2), id, requestNamespace(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return fn, nil
+}
+
+// SaveFunctionGrant shares a function with a principal, replacing the
+// access level of an existing grant to the same principal
+func (s *SQLiteStore) SaveFunctionGrant(ctx context.Context, g *models.FunctionGrant) (*models.FunctionGrant, error) {
+	return scanFunctionGrant(s.db.QueryRowContext(ctx, `
+		INSERT INTO function_grants (function_id, principal, access, granted_by_key_id)
+		VALUES (?1, ?2, ?3, ?4)
+		ON CONFLICT (function_id, principal) DO UPDATE
+		SET access = excluded.access, granted_by_key_id = excluded.granted_by_key_id
+		RETURNING `+functionGrantColumns,
+		g.FunctionID, g.Principal, g.Access, middleware.APIKeyID(ctx)))
+}
+
+// ListFunctionGrants returns the grants of a function
+func (s *SQLiteStore) ListFunctionGrants(ctx context.Context, functionID int64) ([]models.FunctionGrant, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+functionGrantColumns+` FROM function_grants
+		WHERE function_id = ?1 AND `+sqliteFunctionInNamespace("function_id", 2)+`
+		ORDER BY id
+	`, functionID, requestNamespace(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	grants := []models.FunctionGrant{}
+	for rows.Next() {
+		g, err := scanFunctionGrant(rows)
+		if err != nil {
+			return nil, err
+		}
+		grants = append(grants, *g)
+	}
+	return grants, rows.Err()
+}
+
+// FunctionGrantLevels returns the access levels a function is granted to any of the principals
+func (s *SQLiteStore) FunctionGrantLevels(ctx context.Context, functionID int64, principals []string) ([]string, error) {
+	principalsJSON, _ := json.Marshal(principals)
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT access FROM function_grants
+		WHERE function_id = ?1 AND principal IN (SELECT value FROM json_each(?2))
+	`, functionID, string(principalsJSON))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var levels []string
+	for rows.Next() {
+		var level string
+		if err := rows.Scan(&level); err != nil {
+			return nil, err
+		}
+		levels = append(levels, level)
+	}
+	return levels, rows.Err()
+}
+
+// DeleteFunctionGrant removes a grant of a function
+func (s *SQLiteStore) DeleteFunctionGrant(ctx context.Context, functionID, grantID int64) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `
+		DELETE FROM function_grants WHERE id = ?1 AND function_id = ?2 AND `+sqliteFunctionInNamespace("function_id", 3)+`
+	`, grantID, functionID, requestNamespace(ctx))
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// GetNamespace returns a namespace by ID, or nil
+func (s *SQLiteStore) GetNamespace(ctx context.Context, id int64) (*models.Namespace, error) {
+	return scanNamespace(s.db.QueryRowContext(ctx, `SELECT `+namespaceColumns+` FROM namespaces WHERE id = ?1`, id))
+}
+
+// GetNamespaceByName returns a namespace by its unique name, or nil
+func (s *SQLiteStore) GetNamespaceByName(ctx context.Context, name string) (*models.Namespace, error) {
+	return scanNamespace(s.db.QueryRowContext(ctx, `SELECT `+namespaceColumns+` FROM namespaces WHERE name = ?1`, name))
+}
+
+// CountNamespaceResources returns the number of functions and workflows in a
+// namespace. Workflows are not available on SQLite, so they count as 0.
+func (s *SQLiteStore) CountNamespaceResources(ctx context.Context, id int64) (functions, workflows int, err error) {
+	err = s.db.QueryRowContext(ctx, `SELECT count(*) FROM functions WHERE namespace_id = ?1`, id).Scan(&functions)
+	return functions, 0, err
+}
+
+// InsertAuditEvent appends an event to the audit log
+func (s *SQLiteStore) InsertAuditEvent(ctx context.Context, e *models.AuditEvent) error {
+	var before, after, request interface{}
+	if e.Before != nil {
+		data, err := json.Marshal(e.Before)
+		if err != nil {
+			return err
+		}
+		before = string(data)
+	}
+	if e.After != nil {
+		data, err := json.Marshal(e.After)
+		if err != nil {
+			return err
+		}
+		after = string(data)
+	}
+	if e.Request != nil {
+		data, err := json.Marshal(e.Request)
+		if err != nil {
+			return err
+		}
+		request = string(data)
+	}
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO audit_events (namespace_id, actor, api_key_id, action, resource_type, resource_id, before_state, after_state, request)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9)
+	`, e.NamespaceID, e.Actor, e.APIKeyID, e.Action, e.ResourceType, e.ResourceID, before, after, request)
+	return err
+}
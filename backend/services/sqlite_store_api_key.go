@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"lambda-runner-server/models"
+)
+
+// Scopes are stored as a JSON array
+const sqliteAPIKeyColumns = `id, name, prefix, COALESCE(user_name, ''), namespace_id, (SELECT name FROM namespaces n WHERE n.id = api_keys.namespace_id),
+	scopes, expires_at, last_used_at, revoked_at, created_by_key_id, created_at`
+
+func scanSQLiteAPIKey(scanner interface{ Scan(...interface{}) error }) (*models.APIKey, error) {
+	var k models.APIKey
+	var scopesJSON []byte
+	var expiresAt, lastUsedAt, revokedAt sql.NullTime
+	var createdByKeyID sql.NullInt64
+	err := scanner.Scan(&k.ID, &k.Name, &k.Prefix, &k.User, &k.NamespaceID, &k.Namespace, &scopesJSON, &expiresAt, &lastUsedAt, &revokedAt, &createdByKeyID, &k.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(scopesJSON, &k.Scopes); err != nil {
+		return nil, err
+	}
+	if expiresAt.Valid {
+		k.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		k.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		k.RevokedAt = &revokedAt.Time
+	}
+	if createdByKeyID.Valid {
+		k.CreatedByKeyID = &createdByKeyID.Int64
+	}
+	return &k, nil
+}
+
+func sqliteScopes(scopes []string) string {
+	if scopes == nil {
+		scopes = []string{}
+	}
+	data, _ := json.Marshal(scopes)
+	return string(data)
+}
+
+// CreateAPIKey inserts an API key identified by the hash of its secret
+func (s *SQLiteStore) CreateAPIKey(ctx context.Context, k *models.APIKey, keyHash string) (*models.APIKey, error) {
+	return scanSQLiteAPIKey(s.db.QueryRowContext(ctx, `
+		INSERT INTO api_keys (name, prefix, user_name, namespace_id, key_hash, scopes, expires_at, created_by_key_id)
+		VALUES (?1, ?2, NULLIF(?3, ''), ?4, ?5, ?6, ?7, ?8)
+		RETURNING `+sqliteAPIKeyColumns,
+		k.Name, k.Prefix, k.User, k.NamespaceID, keyHash, sqliteScopes(k.Scopes), sqliteNullTime(k.ExpiresAt), k.CreatedByKeyID))
+}
+
+// EnsureAPIKey inserts an API key into the default namespace unless one with
+// the same hash exists. It reports whether the key was inserted.
+func (s *SQLiteStore) EnsureAPIKey(ctx context.Context, k *models.APIKey, keyHash string) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `
+		INSERT INTO api_keys (name, prefix, namespace_id, key_hash, scopes)
+		SELECT ?1, ?2, id, ?3, ?4 FROM namespaces WHERE name = ?5
+		ON CONFLICT (key_hash) DO NOTHING
+	`, k.Name, k.Prefix, keyHash, sqliteScopes(k.Scopes), models.DefaultNamespace)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// GetAPIKeyByHash returns the API key with the given hash, or nil
+func (s *SQLiteStore) GetAPIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	return scanSQLiteAPIKey(s.db.QueryRowContext(ctx, `SELECT `+sqliteAPIKeyColumns+` FROM api_keys WHERE key_hash = ?1`, keyHash))
+}
+
+// ListAPIKeys returns all API keys, including revoked and expired ones
+func (s *SQLiteStore) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+sqliteAPIKeyColumns+` FROM api_keys ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		k, err := scanSQLiteAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *k)
+	}
+	return keys, rows.Err()
+}
+
+// CountAPIKeys returns the number of API keys that are neither revoked nor expired
+func (s *SQLiteStore) CountAPIKeys(ctx context.Context) (int, error) {
+	var n int
+	err := s.db.QueryRowContext(ctx, `
+		SELECT count(*) FROM api_keys
+		WHERE revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?1)
+	`, sqliteTime(time.Now())).Scan(&n)
+	return n, err
+}
+
+// RevokeAPIKey marks an API key as revoked; revoking twice keeps the first time.
+// It returns nil when the key does not exist.
+func (s *SQLiteStore) RevokeAPIKey(ctx context.Context, id int64) (*models.APIKey, error) {
+	return scanSQLiteAPIKey(s.db.QueryRowContext(ctx, `
+		UPDATE api_keys SET revoked_at = COALESCE(revoked_at, `+sqliteNow+`)
+		WHERE id = ?1
+		RETURNING `+sqliteAPIKeyColumns, id))
+}
+
+// TouchAPIKey records the use of an API key, at most once a minute
+func (s *SQLiteStore) TouchAPIKey(ctx context.Context, id int64) error {
+	now := time.Now()
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE api_keys SET last_used_at = ?2
+		WHERE id = ?1 AND (last_used_at IS NULL OR last_used_at < ?3)
+	`, id, sqliteTime(now), sqliteTime(now.Add(-time.Minute)))
+	return err
+}
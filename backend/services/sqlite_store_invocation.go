@@ -0,0 +1,483 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"lambda-runner-server/models"
+)
+
+const sqliteInvocationListColumns = `id, function_id, invoked_at, input_event, status, output_result, error_message, duration_ms, replay_of`
+
+func scanSQLiteInvocationListItem(scanner interface{ Scan(...interface{}) error }) (*models.InvocationListItem, error) {
+	var inv models.InvocationListItem
+	var inputEventJSON, outputResultJSON []byte
+	var errorMessage sql.NullString
+	var durationMs sql.NullInt32
+	var replayOf sql.NullInt64
+	err := scanner.Scan(&inv.ID, &inv.FunctionID, &inv.InvokedAt, &inputEventJSON, &inv.Status, &outputResultJSON, &errorMessage, &durationMs, &replayOf)
+	if err != nil {
+		return nil, err
+	}
+	if inputEventJSON != nil {
+		json.Unmarshal(inputEventJSON, &inv.InputEvent)
+	}
+	if outputResultJSON != nil {
+		json.Unmarshal(outputResultJSON, &inv.OutputResult)
+	}
+	if errorMessage.Valid {
+		inv.ErrorMessage = errorMessage.String
+	}
+	if durationMs.Valid {
+		inv.DurationMs = int(durationMs.Int32)
+	}
+	if replayOf.Valid {
+		inv.ReplayOf = &replayOf.Int64
+	}
+	return &inv, nil
+}
+
+// CreateInvocation creates a new invocation record
+func (s *SQLiteStore) CreateInvocation(ctx context.Context, inv *models.Invocation) (*models.Invocation, error) {
+	inputEventJSON, _ := json.Marshal(inv.InputEvent)
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO function_invocations (function_id, invoked_by, input_event, status, callback_url, callback_secret, api_key_id, code_version, replay_of)
+		VALUES (?1, ?2, ?3, ?4, NULLIF(?5, ''), NULLIF(?6, ''), ?7, NULLIF(?8, ''), ?9)
+		RETURNING id, invoked_at, created_at
+	`, inv.FunctionID, inv.InvokedBy, string(inputEventJSON), inv.Status, inv.CallbackURL, inv.CallbackSecret, inv.APIKeyID, inv.CodeVersion, inv.ReplayOf).
+		Scan(&inv.ID, &inv.InvokedAt, &inv.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+// GetInvocation retrieves an invocation by ID
+func (s *SQLiteStore) GetInvocation(ctx context.Context, id int64) (*models.Invocation, error) {
+	inv := &models.Invocation{}
+	var inputEventJSON, outputResultJSON []byte
+	var errorMessage, invokedBy, containerID sql.NullString
+	var durationMs sql.NullInt32
+	var batchID, apiKeyID, replayOf sql.NullInt64
+	var codeVersion sql.NullString
+
+	err := s.db.QueryRowContext(ctx, `
+		SELECT id, function_id, invoked_at, invoked_by, input_event, status, output_result, error_message, duration_ms, container_id, batch_id, api_key_id, created_at, code_version, replay_of
+		FROM function_invocations WHERE id = ?1 AND `+sqliteFunctionInNamespace("function_id", 2)+`
+	`, id, requestNamespace(ctx)).Scan(&inv.ID, &inv.FunctionID, &inv.InvokedAt, &invokedBy, &inputEventJSON, &inv.Status, &outputResultJSON, &errorMessage, &durationMs, &containerID, &batchID, &apiKeyID, &inv.CreatedAt, &codeVersion, &replayOf)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if inputEventJSON != nil {
+		json.Unmarshal(inputEventJSON, &inv.InputEvent)
+	}
+	if outputResultJSON != nil {
+		json.Unmarshal(outputResultJSON, &inv.OutputResult)
+	}
+	inv.ErrorMessage = errorMessage.String
+	inv.InvokedBy = invokedBy.String
+	inv.ContainerID = containerID.String
+	inv.DurationMs = int(durationMs.Int32)
+	inv.CodeVersion = codeVersion.String
+	if batchID.Valid {
+		inv.BatchID = &batchID.Int64
+	}
+	if apiKeyID.Valid {
+		inv.APIKeyID = &apiKeyID.Int64
+	}
+	if replayOf.Valid {
+		inv.ReplayOf = &replayOf.Int64
+	}
+
+	return inv, nil
+}
+
+// UpdateInvocationResult updates a pending invocation with execution result.
+// It reports whether the row was still pending.
+func (s *SQLiteStore) UpdateInvocationResult(ctx context.Context, id int64, status string, outputResult map[string]interface{}, errorMessage string, durationMs int) (bool, error) {
+	outputJSON, _ := json.Marshal(outputResult)
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE function_invocations
+		SET status = ?2, output_result = ?3, error_message = ?4, duration_ms = ?5
+		WHERE id = ?1 AND status = 'pending'
+	`, id, status, string(outputJSON), errorMessage, durationMs)
+	if err != nil {
+		return false, err
+	}
+	affected, _ := res.RowsAffected()
+	return affected > 0, nil
+}
+
+// SetInvocationStatus sets the status of a pending invocation without a result.
+// It reports whether the invocation was still pending.
+func (s *SQLiteStore) SetInvocationStatus(ctx context.Context, id int64, status, errorMessage string) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE function_invocations
+		SET status = ?2, error_message = ?3
+		WHERE id = ?1 AND status = 'pending' AND `+sqliteFunctionInNamespace("function_id", 4)+`
+	`, id, status, errorMessage, requestNamespace(ctx))
+	if err != nil {
+		return false, err
+	}
+	affected, _ := res.RowsAffected()
+	return affected > 0, nil
+}
+
+// sqliteWhere is InvocationFilter.where for SQLite; JSON containment uses the
+// json_contains function registered by the sqlite driver
+func (f InvocationFilter) sqliteWhere(ctx context.Context) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(cond, len(args)))
+	}
+	args = append(args, requestNamespace(ctx))
+	conditions = append(conditions, sqliteFunctionInNamespace("function_id", 1))
+	if f.FunctionID != 0 {
+		add("function_id = ?%d", f.FunctionID)
+	} else {
+		args = append(args, sqlitePrincipals(ctx))
+		conditions = append(conditions, "function_id IN (SELECT id FROM functions WHERE "+sqliteFunctionVisible(len(args))+")")
+	}
+	if len(f.Statuses) > 0 {
+		statuses, _ := json.Marshal(f.Statuses)
+		add("status IN (SELECT value FROM json_each(?%d))", string(statuses))
+	}
+	if f.InvokedBy != "" {
+		add("invoked_by = ?%d", f.InvokedBy)
+	}
+	if f.ReplayOf != 0 {
+		add("replay_of = ?%d", f.ReplayOf)
+	}
+	if !f.From.IsZero() {
+		add("invoked_at >= ?%d", sqliteTime(f.From))
+	}
+	if !f.To.IsZero() {
+		add("invoked_at < ?%d", sqliteTime(f.To))
+	}
+	if f.MinDurationMs > 0 {
+		add("duration_ms >= ?%d", f.MinDurationMs)
+	}
+	if f.MaxDurationMs > 0 {
+		add("duration_ms <= ?%d", f.MaxDurationMs)
+	}
+	if f.Input != nil {
+		input, _ := json.Marshal(f.Input)
+		add("json_contains(input_event, ?%d)", string(input))
+	}
+	if f.Output != nil {
+		output, _ := json.Marshal(f.Output)
+		add("output_result IS NOT NULL AND json_contains(output_result, ?%d)", string(output))
+	}
+	if f.After != nil {
+		args = append(args, sqliteTime(f.After.InvokedAt), f.After.ID)
+		conditions = append(conditions, fmt.Sprintf(`(invoked_at, id) < (?%d, ?%d)`, len(args)-1, len(args)))
+	}
+	return ` WHERE ` + strings.Join(conditions, " AND "), args
+}
+
+// ListInvocations returns the latest invocations matching the filter
+func (s *SQLiteStore) ListInvocations(ctx context.Context, filter InvocationFilter, limit int) ([]models.InvocationListItem, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+
+	where, args := filter.sqliteWhere(ctx)
+	args = append(args, limit)
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+sqliteInvocationListColumns+`
+		FROM function_invocations`+where+`
+		ORDER BY invoked_at DESC, id DESC
+		`+fmt.Sprintf(`LIMIT ?%d`, len(args)), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var invocations []models.InvocationListItem
+	for rows.Next() {
+		inv, err := scanSQLiteInvocationListItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		invocations = append(invocations, *inv)
+	}
+	return invocations, rows.Err()
+}
+
+// InvocationStats is DBService.InvocationStats for SQLite, which lacks
+// grouping sets and percentile_cont: the groupings are unioned and the
+// percentiles come from the percentile aggregate of the driver
+func (s *SQLiteStore) InvocationStats(ctx context.Context, functionID int64, from, to time.Time, bucket time.Duration) ([]InvocationStatsRow, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		WITH i AS (
+			SELECT (CAST(strftime('%s', invoked_at) AS INTEGER) - ?2) / ?4 AS bucket, status,
+				CASE WHEN status IN ('success', 'fail', 'timeout') THEN duration_ms END AS duration_ms
+			FROM function_invocations
+			WHERE function_id = ?1 AND invoked_at >= ?5 AND invoked_at < ?6 AND `+sqliteFunctionInNamespace("function_id", 3)+`
+		)
+		SELECT bucket, status, count(*), NULL, NULL, NULL FROM i GROUP BY bucket, status
+		UNION ALL
+		SELECT bucket, NULL, count(*), percentile(duration_ms, 0.5), percentile(duration_ms, 0.9), percentile(duration_ms, 0.99)
+		FROM i GROUP BY bucket
+		UNION ALL
+		SELECT NULL, status, count(*), NULL, NULL, NULL FROM i GROUP BY status
+		UNION ALL
+		SELECT NULL, NULL, count(*), percentile(duration_ms, 0.5), percentile(duration_ms, 0.9), percentile(duration_ms, 0.99)
+		FROM i
+	`, functionID, from.Unix(), requestNamespace(ctx), int64(bucket/time.Second), sqliteTime(from), sqliteTime(to))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanInvocationStatsRows(rows)
+}
+
+// ListPendingInvocations returns the oldest pending invocations after a
+// position in (invoked_at, id) order
+func (s *SQLiteStore) ListPendingInvocations(ctx context.Context, after InvocationCursor, limit int) ([]models.Invocation, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, function_id, invoked_at, COALESCE(invoked_by, ''), batch_id
+		FROM function_invocations
+		WHERE status = 'pending' AND (invoked_at, id) > (?1, ?2)
+		ORDER BY invoked_at, id
+		LIMIT ?3
+	`, sqliteTime(after.InvokedAt), after.ID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var invocations []models.Invocation
+	for rows.Next() {
+		var inv models.Invocation
+		var batchID sql.NullInt64
+		if err := rows.Scan(&inv.ID, &inv.FunctionID, &inv.InvokedAt, &inv.InvokedBy, &batchID); err != nil {
+			return nil, err
+		}
+		if batchID.Valid {
+			inv.BatchID = &batchID.Int64
+		}
+		inv.Status = models.StatusPending
+		invocations = append(invocations, inv)
+	}
+	return invocations, rows.Err()
+}
+
+// CreateBatch inserts a batch and all of its invocations in one transaction
+func (s *SQLiteStore) CreateBatch(ctx context.Context, batch *models.InvocationBatch, invocations []*models.Invocation) (*models.InvocationBatch, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO invocation_batches (function_id, total, max_parallelism, priority, invoked_by)
+		VALUES (?1, ?2, ?3, ?4, ?5)
+		RETURNING id, created_at
+	`, batch.FunctionID, batch.Total, batch.MaxParallelism, batch.Priority, batch.InvokedBy).Scan(&batch.ID, &batch.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO function_invocations (function_id, invoked_by, input_event, status, batch_id, callback_url, callback_secret, api_key_id, code_version)
+		VALUES (?1, ?2, ?3, ?4, ?5, NULLIF(?6, ''), NULLIF(?7, ''), ?8, NULLIF(?9, ''))
+		RETURNING id, invoked_at, created_at
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	for _, inv := range invocations {
+		inputEventJSON, _ := json.Marshal(inv.InputEvent)
+		err := stmt.QueryRowContext(ctx, inv.FunctionID, inv.InvokedBy, string(inputEventJSON), inv.Status, batch.ID, inv.CallbackURL, inv.CallbackSecret, inv.APIKeyID, inv.CodeVersion).
+			Scan(&inv.ID, &inv.InvokedAt, &inv.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		inv.BatchID = &batch.ID
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// GetBatch retrieves a batch by ID
+func (s *SQLiteStore) GetBatch(ctx context.Context, id int64) (*models.InvocationBatch, error) {
+	batch := &models.InvocationBatch{}
+	var invokedBy sql.NullString
+
+	err := s.db.QueryRowContext(ctx, `
+		SELECT id, function_id, total, max_parallelism, priority, invoked_by, created_at
+		FROM invocation_batches WHERE id = ?1 AND `+sqliteFunctionInNamespace("function_id", 2)+`
+	`, id, requestNamespace(ctx)).Scan(&batch.ID, &batch.FunctionID, &batch.Total, &batch.MaxParallelism, &batch.Priority, &invokedBy, &batch.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	batch.InvokedBy = invokedBy.String
+	return batch, nil
+}
+
+// ListBatchInvocations returns the invocations of a batch in submission order
+func (s *SQLiteStore) ListBatchInvocations(ctx context.Context, batchID int64) ([]models.InvocationListItem, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+sqliteInvocationListColumns+`
+		FROM function_invocations
+		WHERE batch_id = ?1 AND `+sqliteFunctionInNamespace("function_id", 2)+`
+		ORDER BY id
+	`, batchID, requestNamespace(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invocations := []models.InvocationListItem{}
+	for rows.Next() {
+		inv, err := scanSQLiteInvocationListItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		inv.BatchID = &batchID
+		invocations = append(invocations, *inv)
+	}
+	return invocations, rows.Err()
+}
+
+// EnqueueCallback schedules delivery of an invocation's result when the
+// invocation or its function has a callback URL. It reports whether a
+// delivery was scheduled.
+func (s *SQLiteStore) EnqueueCallback(ctx context.Context, invocationID int64) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `
+		INSERT INTO invocation_callbacks (invocation_id, url, secret)
+		SELECT i.id, COALESCE(i.callback_url, f.callback_url),
+			CASE WHEN i.callback_url IS NOT NULL THEN i.callback_secret ELSE f.callback_secret END
+		FROM function_invocations i
+		JOIN functions f ON f.id = i.function_id
+		WHERE i.id = ?1 AND COALESCE(i.callback_url, f.callback_url) IS NOT NULL
+		ON CONFLICT (invocation_id) DO NOTHING
+	`, invocationID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// ClaimDueCallbacks leases pending deliveries that are due by pushing their
+// next_attempt_at forward. The update is a single write, so it cannot race
+// with another claim.
+func (s *SQLiteStore) ClaimDueCallbacks(ctx context.Context, limit int, lease time.Duration) ([]models.InvocationCallback, error) {
+	now := time.Now()
+	rows, err := s.db.QueryContext(ctx, `
+		UPDATE invocation_callbacks
+		SET next_attempt_at = ?3
+		WHERE id IN (
+			SELECT id FROM invocation_callbacks
+			WHERE status = 'pending' AND next_attempt_at <= ?2
+			ORDER BY next_attempt_at
+			LIMIT ?1
+		)
+		RETURNING `+callbackColumns,
+		limit, sqliteTime(now), sqliteTime(now.Add(lease)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var callbacks []models.InvocationCallback
+	for rows.Next() {
+		cb, err := scanCallback(rows)
+		if err != nil {
+			return nil, err
+		}
+		callbacks = append(callbacks, *cb)
+	}
+	return callbacks, rows.Err()
+}
+
+// RecordCallbackAttempt logs a delivery attempt and moves the delivery to its
+// next state: delivered, failed, or pending again at nextAttemptAt
+func (s *SQLiteStore) RecordCallbackAttempt(ctx context.Context, attempt *models.CallbackAttempt, status string, nextAttemptAt time.Time) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO invocation_callback_attempts (callback_id, attempt, status_code, error, duration_ms)
+		VALUES (?1, ?2, NULLIF(?3, 0), NULLIF(?4, ''), ?5)
+	`, attempt.CallbackID, attempt.Attempt, attempt.StatusCode, attempt.Error, attempt.DurationMs)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE invocation_callbacks
+		SET status = ?2, attempts = ?3, next_attempt_at = ?4,
+			last_status_code = NULLIF(?5, 0), last_error = NULLIF(?6, ''),
+			delivered_at = CASE WHEN ?7 THEN `+sqliteNow+` ELSE delivered_at END
+		WHERE id = ?1
+	`, attempt.CallbackID, status, attempt.Attempt, sqliteTime(nextAttemptAt), attempt.StatusCode, attempt.Error, status == models.CallbackDelivered)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetInvocationCallback returns the callback delivery of an invocation with
+// its attempt log, or nil when the invocation has no callback
+func (s *SQLiteStore) GetInvocationCallback(ctx context.Context, invocationID int64) (*models.InvocationCallback, error) {
+	cb, err := scanCallback(s.db.QueryRowContext(ctx, `
+		SELECT `+callbackColumns+`
+		FROM invocation_callbacks
+		WHERE invocation_id = ?1
+			AND invocation_id IN (SELECT id FROM function_invocations WHERE `+sqliteFunctionInNamespace("function_id", 2)+`)
+	`, invocationID, requestNamespace(ctx)))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, callback_id, attempt, COALESCE(status_code, 0), COALESCE(error, ''), duration_ms, attempted_at
+		FROM invocation_callback_attempts
+		WHERE callback_id = ?1
+		ORDER BY attempt
+	`, cb.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cb.Log = []models.CallbackAttempt{}
+	for rows.Next() {
+		var a models.CallbackAttempt
+		if err := rows.Scan(&a.ID, &a.CallbackID, &a.Attempt, &a.StatusCode, &a.Error, &a.DurationMs, &a.AttemptedAt); err != nil {
+			return nil, err
+		}
+		cb.Log = append(cb.Log, a)
+	}
+	return cb, rows.Err()
+}
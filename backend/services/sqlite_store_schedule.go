@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"lambda-runner-server/models"
+)
+
+const sqliteScheduleColumns = `id, function_id, scheduled_at, payload, priority, executed, executed_at, status, error_message,
+	created_by_key_id, created_at, updated_at`
+
+func scanSQLiteSchedule(scanner interface{ Scan(...interface{}) error }) (*models.FunctionSchedule, error) {
+	var sched models.FunctionSchedule
+	var payloadJSON []byte
+	var executedAt sql.NullTime
+	var status, errorMsg sql.NullString
+	var createdByKeyID sql.NullInt64
+	err := scanner.Scan(&sched.ID, &sched.FunctionID, &sched.ScheduledAt, &payloadJSON, &sched.Priority, &sched.Executed, &executedAt,
+		&status, &errorMsg, &createdByKeyID, &sched.CreatedAt, &sched.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if payloadJSON != nil {
+		json.Unmarshal(payloadJSON, &sched.Payload)
+	}
+	if executedAt.Valid {
+		sched.ExecutedAt = &executedAt.Time
+	}
+	sched.Status = status.String
+	sched.ErrorMessage = errorMsg.String
+	if createdByKeyID.Valid {
+		sched.CreatedByKeyID = &createdByKeyID.Int64
+	}
+	return &sched, nil
+}
+
+// CreateSchedule inserts a new scheduled execution
+func (s *SQLiteStore) CreateSchedule(ctx context.Context, sched *models.FunctionSchedule) (*models.FunctionSchedule, error) {
+	payloadJSON, _ := json.Marshal(sched.Payload)
+	return scanSQLiteSchedule(s.db.QueryRowContext(ctx, `
+		INSERT INTO function_schedules (function_id, scheduled_at, payload, priority, executed, created_by_key_id)
+		VALUES (?1, ?2, ?3, ?4, FALSE, ?5)
+		RETURNING `+sqliteScheduleColumns,
+		sched.FunctionID, sqliteTime(sched.ScheduledAt), string(payloadJSON), sched.Priority, sched.CreatedByKeyID))
+}
+
+// ListSchedules returns schedules for a function
+func (s *SQLiteStore) ListSchedules(ctx context.Context, functionID int64) ([]models.FunctionSchedule, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+sqliteScheduleColumns+`
+		FROM function_schedules
+		WHERE function_id = ?1 AND `+sqliteFunctionInNamespace("function_id", 2)+`
+		ORDER BY scheduled_at DESC
+	`, functionID, requestNamespace(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schedules := []models.FunctionSchedule{}
+	for rows.Next() {
+		sched, err := scanSQLiteSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, *sched)
+	}
+	return schedules, rows.Err()
+}
+
+// DeleteSchedule removes a schedule and reports whether it existed
+func (s *SQLiteStore) DeleteSchedule(ctx context.Context, functionID, scheduleID int64) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `
+		DELETE FROM function_schedules WHERE id = ?1 AND function_id = ?2 AND `+sqliteFunctionInNamespace("function_id", 3)+`
+	`, scheduleID, functionID, requestNamespace(ctx))
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// MarkScheduleExecuted updates the schedule execution result
+func (s *SQLiteStore) MarkScheduleExecuted(ctx context.Context, scheduleID int64, status, errMsg string) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE function_schedules
+		SET status = ?2, error_message = ?3, updated_at = `+sqliteNow+`
+		WHERE id = ?1
+	`, scheduleID, status, errMsg)
+	return err
+}
+
+// ClaimDueSchedules marks up to limit due schedules executed and returns
+// them. SQLite has no row locks to skip; instead selecting and marking the
+// schedules is a single UPDATE, which holds the database write lock, so a
+// schedule is claimed exactly once.
+func (s *SQLiteStore) ClaimDueSchedules(ctx context.Context, limit int) ([]models.FunctionSchedule, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		UPDATE function_schedules
+		SET executed = TRUE, executed_at = `+sqliteNow+`, updated_at = `+sqliteNow+`
+		WHERE id IN (
+			SELECT id FROM function_schedules
+			WHERE executed = FALSE AND scheduled_at <= ?1
+			ORDER BY scheduled_at
+			LIMIT ?2
+		)
+		RETURNING `+sqliteScheduleColumns,
+		sqliteTime(time.Now()), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schedules []models.FunctionSchedule
+	for rows.Next() {
+		sched, err := scanSQLiteSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, *sched)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// RETURNING yields rows in no particular order
+	sort.Slice(schedules, func(i, j int) bool { return schedules[i].ScheduledAt.Before(schedules[j].ScheduledAt) })
+	return schedules, nil
+}
@@ -126,8 +126,8 @@ func NewStorageService(storageType, pathOrBucket string) (StorageService, error)
 	}
 }
 
-// GenerateCodeKey generates a unique key for storing function code
-func GenerateCodeKey(functionID int64, runtime string) string {
+// GenerateCodeKey generates a unique key for storing function code under its namespace
+func GenerateCodeKey(namespace string, functionID int64, runtime string) string {
 	extMap := map[string]string{
 		// Interpreted
 		"python3.11":  ".py",
@@ -154,5 +154,5 @@ func GenerateCodeKey(functionID int64, runtime string) string {
 	if e, exists := extMap[runtime]; exists {
 		ext = e
 	}
-	return fmt.Sprintf("code/functions/func_%d%s", functionID, ext)
+	return fmt.Sprintf("code/%s/functions/func_%d%s", namespace, functionID, ext)
 }
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"lambda-runner-server/middleware"
+	"lambda-runner-server/models"
+)
+
+// StorageTriggerService manages storage event triggers
+type StorageTriggerService struct {
+	db *DBService
+
+	// Code storage, watched by triggers that name no location of their own
+	codeStorageType string
+	codeLocation    string
+
+	// Directories local triggers may watch; without any, only the code storage can be watched
+	localRoots []string
+
+	// Buckets S3 triggers may watch besides the code storage
+	buckets map[string]bool
+}
+
+func NewStorageTriggerService(db *DBService, codeStorageType, codeLocation string, localRoots, buckets []string) *StorageTriggerService {
+	s := &StorageTriggerService{db: db, codeStorageType: codeStorageType, codeLocation: codeLocation, buckets: map[string]bool{}}
+	for _, bucket := range buckets {
+		if bucket = strings.TrimSpace(bucket); bucket != "" {
+			s.buckets[bucket] = true
+		}
+	}
+	for _, root := range localRoots {
+		if root == "" {
+			continue
+		}
+		resolved, err := resolveLocalPath(root)
+		if err != nil {
+			continue // a root that does not exist holds nothing to watch
+		}
+		s.localRoots = append(s.localRoots, resolved)
+	}
+	return s
+}
+
+// resolveLocalPath returns the absolute path of an existing file or
+// directory with all symlinks resolved
+func resolveLocalPath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(abs)
+}
+
+// localLocation resolves the directory of a local trigger, which must lie
+// within one of the configured roots
+func (s *StorageTriggerService) localLocation(location string) (string, error) {
+	resolved, err := resolveLocalPath(location)
+	if err != nil {
+		return "", fmt.Errorf("location %q is not an accessible directory", location)
+	}
+	for _, root := range s.localRoots {
+		rel, err := filepath.Rel(root, resolved)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return resolved, nil
+		}
+	}
+	if len(s.localRoots) == 0 {
+		return "", fmt.Errorf("local storage triggers can only watch the code storage on this server")
+	}
+	return "", fmt.Errorf("location %q is outside the directories local storage triggers may watch", location)
+}
+
+// s3Location checks that an S3 trigger's bucket is one triggers may watch
+func (s *StorageTriggerService) s3Location(bucket string) error {
+	if s.buckets[bucket] {
+		return nil
+	}
+	if len(s.buckets) == 0 {
+		return fmt.Errorf("S3 storage triggers can only watch the code storage on this server")
+	}
+	return fmt.Errorf("bucket %q is not one S3 storage triggers may watch", bucket)
+}
+
+// CreateTrigger subscribes a function to object changes under a bucket or directory
+func (s *StorageTriggerService) CreateTrigger(ctx context.Context, functionID int64, req *models.CreateStorageTriggerRequest) (*models.StorageTrigger, error) {
+	defaultStorage := req.StorageType == "" && req.Location == ""
+	codeStorage := defaultStorage ||
+		(s.codeStorageType == "s3" && req.StorageType == "s3" && req.Location == s.codeLocation)
+	if codeStorage {
+		// The code storage holds every namespace; only the caller's own is
+		// watched, however the trigger names it
+		ns := middleware.NamespaceFromContext(ctx)
+		if ns == nil {
+			if defaultStorage {
+				return nil, fmt.Errorf("storage_type and location are required")
+			}
+			return nil, fmt.Errorf("the code storage can only be watched from a namespace")
+		}
+		req.StorageType = s.codeStorageType
+		req.Location = s.codeLocation
+		nsPrefix := "code/" + ns.Name + "/"
+		if !strings.HasPrefix(req.Prefix, nsPrefix) {
+			req.Prefix = nsPrefix + req.Prefix
+		}
+	}
+	switch req.StorageType {
+	case "local":
+		if req.Location == "" {
+			return nil, fmt.Errorf("location (directory) is required")
+		}
+		var location string
+		var err error
+		if codeStorage {
+			location, err = filepath.Abs(req.Location)
+		} else {
+			location, err = s.localLocation(req.Location)
+		}
+		if err != nil {
+			return nil, err
+		}
+		req.Location = location
+	case "s3":
+		if req.Location == "" {
+			return nil, fmt.Errorf("location (bucket) is required")
+		}
+		if !codeStorage {
+			if err := s.s3Location(req.Location); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("storage_type must be %q or %q", "local", "s3")
+	}
+	switch req.EventTypes {
+	case "":
+		req.EventTypes = models.StorageEventsCreated
+	case models.StorageEventsCreated, models.StorageEventsRemoved, models.StorageEventsAll:
+	default:
+		return nil, fmt.Errorf("event_types must be %q, %q or %q",
+			models.StorageEventsCreated, models.StorageEventsRemoved, models.StorageEventsAll)
+	}
+
+	if _, err := getAuthorizedFunction(ctx, s.db, functionID, models.AccessAdmin); err != nil {
+		return nil, err
+	}
+
+	created, err := s.db.CreateStorageTrigger(ctx, &models.StorageTrigger{
+		FunctionID:  functionID,
+		StorageType: req.StorageType,
+		Location:    req.Location,
+		Prefix:      req.Prefix,
+		Suffix:      req.Suffix,
+		EventTypes:  req.EventTypes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	audit(ctx, s.db, models.AuditCreate, models.AuditStorageTrigger, created.ID, nil, created)
+	return created, nil
+}
+
+// ListTriggers returns the storage triggers of a function
+func (s *StorageTriggerService) ListTriggers(ctx context.Context, functionID int64) ([]models.StorageTrigger, error) {
+	if _, err := getAuthorizedFunction(ctx, s.db, functionID, models.AccessRead); err != nil {
+		return nil, err
+	}
+	return s.db.ListStorageTriggers(ctx, functionID)
+}
+
+// DeleteTrigger removes a storage trigger of a function
+func (s *StorageTriggerService) DeleteTrigger(ctx context.Context, functionID, triggerID int64) error {
+	if _, err := getAuthorizedFunction(ctx, s.db, functionID, models.AccessAdmin); err != nil {
+		return err
+	}
+	deleted, err := s.db.DeleteStorageTrigger(ctx, functionID, triggerID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return fmt.Errorf("storage trigger not found: %d", triggerID)
+	}
+	audit(ctx, s.db, models.AuditDelete, models.AuditStorageTrigger, triggerID, map[string]interface{}{"function_id": functionID}, nil)
+	return nil
+}
@@ -0,0 +1,588 @@
+package services
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-xray-sdk-go/instrumentation/awsv2"
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/fsnotify/fsnotify"
+
+	"lambda-runner-server/models"
+)
+
+const (
+	// StorageTriggerLockKeyPrefix guards the single watcher of a storage trigger
+	StorageTriggerLockKeyPrefix = "storage_trigger_lock:"
+
+	storageTriggerLockTTL = 15 * time.Second
+	// Local writes are reported once the file has been quiet this long
+	storageWriteSettle = 500 * time.Millisecond
+)
+
+func storageTriggerLockKey(triggerID int64) string {
+	return fmt.Sprintf("%s%d", StorageTriggerLockKeyPrefix, triggerID)
+}
+
+// storageObject is an object seen in a watched bucket or directory
+type storageObject struct {
+	Key          string
+	Size         int64
+	ETag         string
+	LastModified time.Time
+}
+
+// StorageWatcher invokes functions for object changes in watched buckets and
+// directories. Local directories are watched with fsnotify; S3 buckets (or an
+// S3-compatible endpoint) are polled with ListObjectsV2.
+//
+// Each trigger is watched by one instance at a time (Redis lock). Delivered
+// changes advance the trigger's watermark, and on (re)start objects modified
+// after it are delivered first, so changes made while nobody watched are not
+// lost. Delivery is at-least-once: objects modified at the watermark itself
+// may be delivered again.
+type StorageWatcher struct {
+	db           *DBService
+	redis        *RedisService
+	functions    *FunctionService
+	owner        string
+	s3Endpoint   string
+	interval     time.Duration
+	pollInterval time.Duration
+
+	mu        sync.Mutex
+	s3Client  *s3.Client
+	workers   map[int64]*storageTriggerWorker
+	workersWg sync.WaitGroup
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+type storageTriggerWorker struct {
+	trigger models.StorageTrigger
+	cancel  context.CancelFunc
+}
+
+// NewStorageWatcher creates a watcher. owner identifies this backend instance
+// in trigger locks; s3Endpoint optionally points S3 polling at an
+// S3-compatible server (path-style addressing).
+func NewStorageWatcher(db *DBService, redis *RedisService, functions *FunctionService, owner, s3Endpoint string) *StorageWatcher {
+	return &StorageWatcher{
+		db:           db,
+		redis:        redis,
+		functions:    functions,
+		owner:        owner,
+		s3Endpoint:   s3Endpoint,
+		interval:     5 * time.Second,
+		pollInterval: 10 * time.Second,
+		workers:      make(map[int64]*storageTriggerWorker),
+		stopCh:       make(chan struct{}),
+	}
+}
+
+func (w *StorageWatcher) Start() {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		w.syncWorkers()
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				w.syncWorkers()
+			case <-w.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+func (w *StorageWatcher) Stop() {
+	close(w.stopCh)
+	// Wait for the sync loop first so no worker is started after cancelling
+	w.wg.Wait()
+
+	w.mu.Lock()
+	for id, worker := range w.workers {
+		worker.cancel()
+		delete(w.workers, id)
+	}
+	w.mu.Unlock()
+	w.workersWg.Wait()
+}
+
+// syncWorkers starts workers for new triggers and stops those of deleted or changed ones
+func (w *StorageWatcher) syncWorkers() {
+	triggers, err := w.db.ListEnabledStorageTriggers(context.Background())
+	if err != nil {
+		log.Printf("storage triggers: failed to list triggers: %v", err)
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	active := make(map[int64]bool, len(triggers))
+	for _, t := range triggers {
+		active[t.ID] = true
+		if worker, ok := w.workers[t.ID]; ok {
+			if worker.trigger.UpdatedAt.Equal(t.UpdatedAt) {
+				continue
+			}
+			worker.cancel()
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		w.workers[t.ID] = &storageTriggerWorker{trigger: t, cancel: cancel}
+		w.workersWg.Add(1)
+		go func(t models.StorageTrigger) {
+			defer w.workersWg.Done()
+			w.run(ctx, t)
+		}(t)
+	}
+
+	for id, worker := range w.workers {
+		if !active[id] {
+			worker.cancel()
+			delete(w.workers, id)
+		}
+	}
+}
+
+// run watches a trigger whenever this instance holds its lock, until ctx is cancelled
+func (w *StorageWatcher) run(ctx context.Context, t models.StorageTrigger) {
+	lockKey := storageTriggerLockKey(t.ID)
+	for ctx.Err() == nil {
+		acquired, err := w.redis.AcquireLock(ctx, lockKey, w.owner, storageTriggerLockTTL)
+		if err != nil || !acquired {
+			sleepCtx(ctx, storageTriggerLockTTL/3)
+			continue
+		}
+
+		lockCtx, cancel := context.WithCancel(ctx)
+		go func() {
+			renew := time.NewTicker(storageTriggerLockTTL / 3)
+			defer renew.Stop()
+			for {
+				select {
+				case <-lockCtx.Done():
+					return
+				case <-renew.C:
+					held, err := w.redis.RenewLock(lockCtx, lockKey, w.owner, storageTriggerLockTTL)
+					if err != nil || !held {
+						cancel()
+						return
+					}
+				}
+			}
+		}()
+
+		var watchErr error
+		if t.StorageType == "s3" {
+			watchErr = w.pollS3(lockCtx, &t)
+		} else {
+			watchErr = w.watchLocal(lockCtx, &t)
+		}
+		cancel()
+		w.redis.ReleaseLock(context.Background(), lockKey, w.owner)
+
+		if watchErr != nil && ctx.Err() == nil {
+			log.Printf("storage triggers: trigger %d: %v", t.ID, watchErr)
+			sleepCtx(ctx, w.pollInterval)
+		}
+	}
+}
+
+// watchLocal delivers changes under a local directory until ctx is cancelled
+func (w *StorageWatcher) watchLocal(ctx context.Context, t *models.StorageTrigger) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	// Watches go in before the catch-up scan so nothing slips in between
+	dirs := make(map[string]bool)
+	if err := addWatchTree(watcher, t.Location, dirs); err != nil {
+		return err
+	}
+
+	pending := make(map[string]time.Time)
+	if t.EventTypes != models.StorageEventsRemoved {
+		objects, err := scanLocalObjects(t, t.Location, t.LastEventAt)
+		if err != nil {
+			return err
+		}
+		for _, obj := range objects {
+			if !w.deliver(ctx, t, models.S3ObjectCreatedPut, obj) {
+				return nil
+			}
+		}
+	}
+
+	settle := time.NewTicker(storageWriteSettle / 2)
+	defer settle.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Printf("storage triggers: trigger %d: watch error: %v", t.ID, err)
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			switch {
+			case event.Has(fsnotify.Create) || event.Has(fsnotify.Write):
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					// Files created together with the directory have no events of their own
+					if event.Has(fsnotify.Create) {
+						addWatchTree(watcher, event.Name, dirs)
+						filepath.WalkDir(event.Name, func(path string, d fs.DirEntry, err error) error {
+							if err == nil && !d.IsDir() {
+								pending[path] = time.Now()
+							}
+							return nil
+						})
+					}
+					continue
+				}
+				pending[event.Name] = time.Now()
+
+			case event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename):
+				delete(pending, event.Name)
+				if dirs[event.Name] {
+					delete(dirs, event.Name)
+					continue
+				}
+				if t.EventTypes == models.StorageEventsCreated {
+					continue
+				}
+				key, ok := localObjectKey(t, event.Name)
+				if !ok {
+					continue
+				}
+				obj := storageObject{Key: key, LastModified: time.Now().UTC()}
+				if !w.deliver(ctx, t, models.S3ObjectRemovedDelete, obj) {
+					return nil
+				}
+			}
+
+		case <-settle.C:
+			if t.EventTypes == models.StorageEventsRemoved {
+				pending = make(map[string]time.Time)
+				continue
+			}
+			var ready []string
+			for path, at := range pending {
+				if time.Since(at) >= storageWriteSettle {
+					ready = append(ready, path)
+				}
+			}
+			sort.Strings(ready)
+			for _, path := range ready {
+				delete(pending, path)
+				obj, ok, err := statLocalObject(t, path)
+				if err != nil || !ok {
+					continue
+				}
+				if !w.deliver(ctx, t, models.S3ObjectCreatedPut, obj) {
+					return nil
+				}
+			}
+		}
+	}
+}
+
+// addWatchTree watches dir and all of its subdirectories
+func addWatchTree(watcher *fsnotify.Watcher, dir string, dirs map[string]bool) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if err := watcher.Add(path); err != nil {
+			return err
+		}
+		dirs[path] = true
+		return nil
+	})
+}
+
+// scanLocalObjects lists matching files under dir modified after since, oldest first
+func scanLocalObjects(t *models.StorageTrigger, dir string, since time.Time) ([]storageObject, error) {
+	var objects []storageObject
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil || !info.ModTime().After(since) {
+			return nil
+		}
+		obj, ok, err := statLocalObject(t, path)
+		if err == nil && ok {
+			objects = append(objects, obj)
+		}
+		return nil
+	})
+	sort.Slice(objects, func(i, j int) bool { return objects[i].LastModified.Before(objects[j].LastModified) })
+	return objects, err
+}
+
+// statLocalObject describes a regular file matching the trigger's filters
+func statLocalObject(t *models.StorageTrigger, path string) (storageObject, bool, error) {
+	key, ok := localObjectKey(t, path)
+	if !ok {
+		return storageObject{}, false, nil
+	}
+	// Symlinks are skipped, so they cannot lead outside the watched directory
+	info, err := os.Lstat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return storageObject{}, false, err
+	}
+
+	// Same ETag S3 reports for objects uploaded in one part
+	f, err := os.Open(path)
+	if err != nil {
+		return storageObject{}, false, err
+	}
+	defer f.Close()
+	h := md5.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return storageObject{}, false, err
+	}
+
+	return storageObject{
+		Key:          key,
+		Size:         info.Size(),
+		ETag:         hex.EncodeToString(h.Sum(nil)),
+		LastModified: info.ModTime().UTC(),
+	}, true, nil
+}
+
+// localObjectKey maps a path under the trigger's directory to an object key
+func localObjectKey(t *models.StorageTrigger, path string) (string, bool) {
+	rel, err := filepath.Rel(t.Location, path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return "", false
+	}
+	key := filepath.ToSlash(rel)
+	return key, matchesStorageTrigger(t, key)
+}
+
+func matchesStorageTrigger(t *models.StorageTrigger, key string) bool {
+	return strings.HasPrefix(key, t.Prefix) && strings.HasSuffix(key, t.Suffix)
+}
+
+// pollS3 delivers changes in a bucket until ctx is cancelled. Objects are
+// diffed against the previous listing; the first listing only delivers
+// objects modified since the watermark.
+func (w *StorageWatcher) pollS3(ctx context.Context, t *models.StorageTrigger) error {
+	client, err := w.s3()
+	if err != nil {
+		return err
+	}
+
+	var known map[string]storageObject
+	for {
+		objects, err := w.listS3Objects(ctx, client, t)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		var created []storageObject
+		for key, obj := range objects {
+			if known == nil {
+				// At-least-once: the watermark second may hold undelivered objects
+				if !obj.LastModified.Before(t.LastEventAt.Truncate(time.Second)) {
+					created = append(created, obj)
+				}
+			} else if prev, ok := known[key]; !ok || prev.ETag != obj.ETag || !prev.LastModified.Equal(obj.LastModified) {
+				created = append(created, obj)
+			}
+		}
+		sort.Slice(created, func(i, j int) bool {
+			if created[i].LastModified.Equal(created[j].LastModified) {
+				return created[i].Key < created[j].Key
+			}
+			return created[i].LastModified.Before(created[j].LastModified)
+		})
+
+		if t.EventTypes != models.StorageEventsRemoved {
+			for _, obj := range created {
+				if !w.deliver(ctx, t, models.S3ObjectCreatedPut, obj) {
+					return nil
+				}
+			}
+		}
+		if known != nil && t.EventTypes != models.StorageEventsCreated {
+			now := time.Now().UTC()
+			for key := range known {
+				if _, ok := objects[key]; ok {
+					continue
+				}
+				if !w.deliver(ctx, t, models.S3ObjectRemovedDelete, storageObject{Key: key, LastModified: now}) {
+					return nil
+				}
+			}
+		}
+		known = objects
+
+		if !sleepCtx(ctx, w.pollInterval) {
+			return nil
+		}
+	}
+}
+
+// listS3Objects lists the matching objects of the trigger's bucket
+func (w *StorageWatcher) listS3Objects(ctx context.Context, client *s3.Client, t *models.StorageTrigger) (map[string]storageObject, error) {
+	ctx, seg := xray.BeginSegment(ctx, "storage-trigger-poll")
+	seg.AddMetadata("trigger_id", t.ID)
+	seg.AddMetadata("bucket", t.Location)
+
+	objects := make(map[string]storageObject)
+	input := &s3.ListObjectsV2Input{Bucket: aws.String(t.Location)}
+	if t.Prefix != "" {
+		input.Prefix = aws.String(t.Prefix)
+	}
+	paginator := s3.NewListObjectsV2Paginator(client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			seg.Close(err)
+			return nil, err
+		}
+		for _, o := range page.Contents {
+			key := aws.ToString(o.Key)
+			if !matchesStorageTrigger(t, key) {
+				continue
+			}
+			objects[key] = storageObject{
+				Key:          key,
+				Size:         aws.ToInt64(o.Size),
+				ETag:         strings.Trim(aws.ToString(o.ETag), `"`),
+				LastModified: aws.ToTime(o.LastModified).UTC(),
+			}
+		}
+	}
+	seg.Close(nil)
+	return objects, nil
+}
+
+// s3 returns the S3 client, creating it on first use
+func (w *StorageWatcher) s3() (*s3.Client, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.s3Client != nil {
+		return w.s3Client, nil
+	}
+
+	cfg, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	awsv2.AWSV2Instrumentor(&cfg.APIOptions)
+
+	w.s3Client = s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if w.s3Endpoint != "" {
+			o.BaseEndpoint = aws.String(w.s3Endpoint)
+			o.UsePathStyle = true
+		}
+	})
+	return w.s3Client, nil
+}
+
+// deliver invokes the trigger's function for one object change, retrying
+// until it is enqueued. It reports false when ctx was cancelled first.
+func (w *StorageWatcher) deliver(ctx context.Context, t *models.StorageTrigger, eventName string, obj storageObject) bool {
+	params, err := EventParams(newS3Event(t, eventName, obj))
+	if err != nil {
+		log.Printf("storage triggers: trigger %d: failed to build event: %v", t.ID, err)
+		return true
+	}
+
+	for {
+		_, err := w.functions.InvokeFunction(ctx, t.FunctionID, params, InvokeOptions{
+			InvokedBy: fmt.Sprintf("storage:%d", t.ID),
+		})
+		if err == nil {
+			break
+		}
+		if ctx.Err() != nil {
+			return false
+		}
+		log.Printf("storage triggers: trigger %d: invoke failed for %s: %v", t.ID, obj.Key, err)
+		wait := redisTriggerRetryWait
+		var throttleErr *ThrottleError
+		if errors.As(err, &throttleErr) && throttleErr.RetryAfter > wait {
+			wait = throttleErr.RetryAfter
+		}
+		if !sleepCtx(ctx, wait) {
+			return false
+		}
+	}
+
+	if eventName == models.S3ObjectCreatedPut && obj.LastModified.After(t.LastEventAt) {
+		if err := w.db.AdvanceStorageTriggerWatermark(ctx, t.ID, obj.LastModified); err != nil {
+			log.Printf("storage triggers: trigger %d: failed to save watermark: %v", t.ID, err)
+		}
+		t.LastEventAt = obj.LastModified
+	}
+	return true
+}
+
+// newS3Event builds the S3 notification for one object change
+func newS3Event(t *models.StorageTrigger, eventName string, obj storageObject) *models.S3Event {
+	source, arn := "softgate:local", ""
+	if t.StorageType == "s3" {
+		source, arn = "aws:s3", "arn:aws:s3:::"+t.Location
+	}
+
+	return &models.S3Event{Records: []models.S3EventRecord{{
+		EventVersion: "2.1",
+		EventSource:  source,
+		AWSRegion:    os.Getenv("AWS_REGION"),
+		EventTime:    time.Now().UTC(),
+		EventName:    eventName,
+		S3: models.S3Entity{
+			SchemaVersion:   "1.0",
+			ConfigurationID: fmt.Sprintf("storage-trigger-%d", t.ID),
+			Bucket:          models.S3Bucket{Name: t.Location, ARN: arn},
+			Object: models.S3Object{
+				Key:          obj.Key,
+				Size:         obj.Size,
+				ETag:         obj.ETag,
+				LastModified: obj.LastModified,
+				Sequencer:    fmt.Sprintf("%016X", obj.LastModified.UnixNano()),
+			},
+		},
+	}}}
+}
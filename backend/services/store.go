@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"lambda-runner-server/models"
+)
+
+// The function engine (FunctionService, ScheduleService) works against the
+// interfaces below. DBService implements the stores on Postgres, SQLiteStore
+// on a SQLite file for single-node deployments, and RedisService implements
+// ExecutionQueue; MemoryStore and MemoryQueue keep everything in process for
+// tests and local development.
+
+// Database drivers selectable with DB_DRIVER
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// FunctionStore persists functions, their access grants and the namespace
+// quotas checked when functions are created
+type FunctionStore interface {
+	CreateFunction(ctx context.Context, fn *models.Function) (*models.Function, error)
+	GetFunction(ctx context.Context, id int64) (*models.Function, error)
+	ListFunctions(ctx context.Context, filter FunctionFilter) ([]models.FunctionListItem, int, error)
+	UpdateCodeKey(ctx context.Context, id int64, codeKey string) error
+	UpdateFunctionConcurrency(ctx context.Context, id int64, maxConcurrency, reservedConcurrency int, overflowPolicy string) error
+	UpdateFunctionCallback(ctx context.Context, id int64, callbackURL, callbackSecret string) error
+	UpdateFunctionVisibility(ctx context.Context, id int64, isPublic bool) error
+	UpdateFunctionTags(ctx context.Context, id int64, tags []string) error
+	DeleteFunction(ctx context.Context, id int64) (*models.Function, error)
+
+	SaveFunctionGrant(ctx context.Context, g *models.FunctionGrant) (*models.FunctionGrant, error)
+	ListFunctionGrants(ctx context.Context, functionID int64) ([]models.FunctionGrant, error)
+	FunctionGrantLevels(ctx context.Context, functionID int64, principals []string) ([]string, error)
+	DeleteFunctionGrant(ctx context.Context, functionID, grantID int64) (bool, error)
+
+	GetNamespace(ctx context.Context, id int64) (*models.Namespace, error)
+	CountNamespaceResources(ctx context.Context, id int64) (functions, workflows int, err error)
+}
+
+// InvocationStore persists invocations, batches and callback deliveries
+type InvocationStore interface {
+	CreateInvocation(ctx context.Context, inv *models.Invocation) (*models.Invocation, error)
+	GetInvocation(ctx context.Context, id int64) (*models.Invocation, error)
+	UpdateInvocationResult(ctx context.Context, id int64, status string, outputResult map[string]interface{}, errorMessage string, durationMs int) (bool, error)
+	SetInvocationStatus(ctx context.Context, id int64, status, errorMessage string) (bool, error)
+	ListInvocations(ctx context.Context, filter InvocationFilter, limit int) ([]models.InvocationListItem, error)
+	ListPendingInvocations(ctx context.Context, after InvocationCursor, limit int) ([]models.Invocation, error)
+	InvocationStats(ctx context.Context, functionID int64, from, to time.Time, bucket time.Duration) ([]InvocationStatsRow, error)
+
+	CreateBatch(ctx context.Context, batch *models.InvocationBatch, invocations []*models.Invocation) (*models.InvocationBatch, error)
+	GetBatch(ctx context.Context, id int64) (*models.InvocationBatch, error)
+	ListBatchInvocations(ctx context.Context, batchID int64) ([]models.InvocationListItem, error)
+
+	EnqueueCallback(ctx context.Context, invocationID int64) (bool, error)
+	GetInvocationCallback(ctx context.Context, invocationID int64) (*models.InvocationCallback, error)
+}
+
+// ScheduleStore persists one-time scheduled executions
+type ScheduleStore interface {
+	CreateSchedule(ctx context.Context, sched *models.FunctionSchedule) (*models.FunctionSchedule, error)
+	ListSchedules(ctx context.Context, functionID int64) ([]models.FunctionSchedule, error)
+	DeleteSchedule(ctx context.Context, functionID, scheduleID int64) (bool, error)
+	ClaimDueSchedules(ctx context.Context, limit int) ([]models.FunctionSchedule, error)
+	MarkScheduleExecuted(ctx context.Context, scheduleID int64, status, errMsg string) error
+}
+
+// AuditStore records audit events (see audit)
+type AuditStore interface {
+	InsertAuditEvent(ctx context.Context, e *models.AuditEvent) error
+}
+
+// CallbackStore leases and records callback deliveries for the CallbackDispatcher
+type CallbackStore interface {
+	ClaimDueCallbacks(ctx context.Context, limit int, lease time.Duration) ([]models.InvocationCallback, error)
+	RecordCallbackAttempt(ctx context.Context, attempt *models.CallbackAttempt, status string, nextAttemptAt time.Time) error
+	GetInvocation(ctx context.Context, id int64) (*models.Invocation, error)
+}
+
+// APIKeyStore persists API keys for the APIKeyService
+type APIKeyStore interface {
+	CreateAPIKey(ctx context.Context, k *models.APIKey, keyHash string) (*models.APIKey, error)
+	EnsureAPIKey(ctx context.Context, k *models.APIKey, keyHash string) (bool, error)
+	GetAPIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error)
+	ListAPIKeys(ctx context.Context) ([]models.APIKey, error)
+	CountAPIKeys(ctx context.Context) (int, error)
+	RevokeAPIKey(ctx context.Context, id int64) (*models.APIKey, error)
+	TouchAPIKey(ctx context.Context, id int64) error
+	GetNamespaceByName(ctx context.Context, name string) (*models.Namespace, error)
+	AuditStore
+}
+
+// Store is everything the function engine persists
+type Store interface {
+	FunctionStore
+	InvocationStore
+	ScheduleStore
+	AuditStore
+}
+
+// ExecutionQueue hands execution requests to the runtime workers and
+// returns their results. Besides the runtime queues it holds the per-function
+// overflow queues and the items of batches waiting for parallelism.
+type ExecutionQueue interface {
+	PushExecutionRequest(ctx context.Context, queueKey string, req *models.ExecutionRequest) error
+	PushExecutionRequests(ctx context.Context, queueKey string, reqs []*models.ExecutionRequest) error
+	GetResult(ctx context.Context, invocationID int64) (*models.ExecutionResult, error)
+	RemoveQueuedRequest(ctx context.Context, queueKeys []string, invocationID int64) (bool, error)
+	SignalCancel(ctx context.Context, invocationID int64) error
+
+	PushOverflow(ctx context.Context, functionID int64, req *models.ExecutionRequest) error
+	PushOverflowRequests(ctx context.Context, functionID int64, reqs []*models.ExecutionRequest) error
+	PopOverflow(ctx context.Context, functionID int64) (*models.ExecutionRequest, error)
+	RequeueOverflow(ctx context.Context, functionID int64, req *models.ExecutionRequest) error
+	ListOverflowFunctions(ctx context.Context) ([]int64, error)
+
+	PushBatchPending(ctx context.Context, batchID int64, reqs []*models.ExecutionRequest) error
+	PopBatchPending(ctx context.Context, batchID int64) (*models.ExecutionRequest, error)
+}
+
+var (
+	_ Store          = (*DBService)(nil)
+	_ Store          = (*MemoryStore)(nil)
+	_ Store          = (*SQLiteStore)(nil)
+	_ CallbackStore  = (*DBService)(nil)
+	_ CallbackStore  = (*SQLiteStore)(nil)
+	_ APIKeyStore    = (*DBService)(nil)
+	_ APIKeyStore    = (*SQLiteStore)(nil)
+	_ ExecutionQueue = (*RedisService)(nil)
+	_ ExecutionQueue = (*MemoryQueue)(nil)
+)
@@ -0,0 +1,276 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"strings"
+	"unicode/utf8"
+
+	"lambda-runner-server/models"
+)
+
+// WebhookPathPrefix is where webhook triggers receive deliveries
+const WebhookPathPrefix = "/hooks/"
+
+var webhookAlgorithms = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// WebhookService manages webhook triggers and turns verified deliveries into invocations
+type WebhookService struct {
+	db        *DBService
+	functions *FunctionService
+}
+
+func NewWebhookService(db *DBService, functions *FunctionService) *WebhookService {
+	return &WebhookService{
+		db:        db,
+		functions: functions,
+	}
+}
+
+// CreateTrigger registers a webhook trigger for a function. The returned
+// trigger carries its secret; later reads do not.
+func (s *WebhookService) CreateTrigger(ctx context.Context, functionID int64, req *models.CreateWebhookTriggerRequest) (*models.WebhookTrigger, error) {
+	t := &models.WebhookTrigger{
+		FunctionID:         functionID,
+		Name:               req.Name,
+		Secret:             req.Secret,
+		SignatureHeader:    req.SignatureHeader,
+		SignatureAlgorithm: strings.ToLower(req.SignatureAlgorithm),
+		SignaturePrefix:    req.SignaturePrefix,
+		SignatureEncoding:  strings.ToLower(req.SignatureEncoding),
+		DeliveryIDHeader:   req.DeliveryIDHeader,
+	}
+
+	if t.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if t.SignatureAlgorithm == "" {
+		t.SignatureAlgorithm = "sha256"
+	}
+	if _, ok := webhookAlgorithms[t.SignatureAlgorithm]; !ok {
+		return nil, fmt.Errorf("signature_algorithm must be sha1, sha256 or sha512")
+	}
+	if t.SignatureHeader == "" {
+		t.SignatureHeader = "X-Hub-Signature-256"
+		if t.SignatureAlgorithm == "sha1" {
+			t.SignatureHeader = "X-Hub-Signature"
+		}
+		if t.DeliveryIDHeader == "" {
+			t.DeliveryIDHeader = "X-GitHub-Delivery"
+		}
+		if t.SignaturePrefix == "" {
+			t.SignaturePrefix = t.SignatureAlgorithm + "="
+		}
+	}
+	switch t.SignatureEncoding {
+	case "":
+		t.SignatureEncoding = "hex"
+	case "hex", "base64":
+	default:
+		return nil, fmt.Errorf("signature_encoding must be hex or base64")
+	}
+
+	_, err := getAuthorizedFunction(ctx, s.db, functionID, models.AccessAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.Token, err = randomHex(16); err != nil {
+		return nil, err
+	}
+	if t.Secret == "" {
+		if t.Secret, err = randomHex(32); err != nil {
+			return nil, err
+		}
+	}
+
+	created, err := s.db.CreateWebhookTrigger(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	audit(ctx, s.db, models.AuditCreate, models.AuditWebhook, created.ID, nil, created) // before Path, which holds the token
+	created.Path = WebhookPathPrefix + created.Token
+	return created, nil
+}
+
+// ListTriggers returns the webhook triggers of a function (without secrets)
+func (s *WebhookService) ListTriggers(ctx context.Context, functionID int64) ([]models.WebhookTrigger, error) {
+	if _, err := getAuthorizedFunction(ctx, s.db, functionID, models.AccessRead); err != nil {
+		return nil, err
+	}
+	triggers, err := s.db.ListWebhookTriggers(ctx, functionID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range triggers {
+		triggers[i].Secret = ""
+		triggers[i].Path = WebhookPathPrefix + triggers[i].Token
+	}
+	return triggers, nil
+}
+
+// DeleteTrigger removes a webhook trigger of a function
+func (s *WebhookService) DeleteTrigger(ctx context.Context, functionID, triggerID int64) error {
+	if _, err := getAuthorizedFunction(ctx, s.db, functionID, models.AccessAdmin); err != nil {
+		return err
+	}
+	deleted, err := s.db.DeleteWebhookTrigger(ctx, functionID, triggerID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return fmt.Errorf("webhook trigger not found: %d", triggerID)
+	}
+	audit(ctx, s.db, models.AuditDelete, models.AuditWebhook, triggerID, map[string]interface{}{"function_id": functionID}, nil)
+	return nil
+}
+
+// ListDeliveries returns the delivery history of a trigger
+func (s *WebhookService) ListDeliveries(ctx context.Context, functionID, triggerID int64, limit int) ([]models.WebhookDelivery, error) {
+	if _, err := getAuthorizedFunction(ctx, s.db, functionID, models.AccessRead); err != nil {
+		return nil, err
+	}
+	t, err := s.db.GetWebhookTrigger(ctx, triggerID)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil || t.FunctionID != functionID {
+		return nil, fmt.Errorf("webhook trigger not found: %d", triggerID)
+	}
+	return s.db.ListWebhookDeliveries(ctx, triggerID, limit)
+}
+
+// Receive verifies a delivery and enqueues an invocation for it. Headers must
+// be keyed by lowercase name. A delivery whose ID was already accepted is not
+// invoked again; the earlier delivery is returned with duplicate set.
+func (s *WebhookService) Receive(ctx context.Context, token string, headers map[string]string, body []byte) (*models.WebhookDelivery, bool, error) {
+	t, err := s.db.GetWebhookTriggerByToken(ctx, token)
+	if err != nil {
+		return nil, false, err
+	}
+	if t == nil || !t.Enabled {
+		return nil, false, ErrWebhookNotFound
+	}
+
+	deliveryID := ""
+	if t.DeliveryIDHeader != "" {
+		deliveryID = headers[strings.ToLower(t.DeliveryIDHeader)]
+	}
+
+	if !verifyWebhookSignature(t, headers[strings.ToLower(t.SignatureHeader)], body) {
+		s.db.InsertWebhookDelivery(ctx, &models.WebhookDelivery{
+			TriggerID:  t.ID,
+			DeliveryID: deliveryID,
+			Status:     models.DeliveryRejected,
+			Error:      ErrInvalidSignature.Error(),
+		})
+		return nil, false, ErrInvalidSignature
+	}
+
+	var delivery *models.WebhookDelivery
+	if deliveryID != "" {
+		var duplicate bool
+		delivery, duplicate, err = s.db.ClaimWebhookDelivery(ctx, t.ID, deliveryID)
+		if err != nil {
+			return nil, false, err
+		}
+		if duplicate {
+			return delivery, true, nil
+		}
+	} else {
+		delivery, err = s.db.InsertWebhookDelivery(ctx, &models.WebhookDelivery{
+			TriggerID: t.ID,
+			Status:    models.DeliveryAccepted,
+		})
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	event := &models.WebhookEvent{
+		TriggerID:  t.ID,
+		DeliveryID: deliveryID,
+		Headers:    headers,
+	}
+	if utf8.Valid(body) {
+		event.Body = string(body)
+	} else {
+		event.Body = base64.StdEncoding.EncodeToString(body)
+		event.IsBase64Encoded = true
+	}
+	params, err := EventParams(event)
+	if err != nil {
+		return nil, false, err
+	}
+
+	inv, err := s.functions.InvokeFunction(ctx, t.FunctionID, params, InvokeOptions{
+		InvokedBy: fmt.Sprintf("webhook:%d", t.ID),
+	})
+	if err != nil {
+		// Failed deliveries drop out of dedupe so the sender's retry is accepted
+		s.db.UpdateWebhookDelivery(ctx, delivery.ID, models.DeliveryFailed, nil, err.Error())
+		return nil, false, err
+	}
+
+	if err := s.db.UpdateWebhookDelivery(ctx, delivery.ID, models.DeliveryAccepted, &inv.ID, ""); err != nil {
+		return nil, false, err
+	}
+	delivery.InvocationID = &inv.ID
+	return delivery, false, nil
+}
+
+// verifyWebhookSignature checks the signature header against the HMAC of the raw body
+func verifyWebhookSignature(t *models.WebhookTrigger, signature string, body []byte) bool {
+	newHash, ok := webhookAlgorithms[t.SignatureAlgorithm]
+	if !ok || signature == "" || !strings.HasPrefix(signature, t.SignaturePrefix) {
+		return false
+	}
+
+	mac := hmac.New(newHash, []byte(t.Secret))
+	mac.Write(body)
+	expected := mac.Sum(nil)
+
+	encoded := strings.TrimPrefix(signature, t.SignaturePrefix)
+	var got []byte
+	var err error
+	if t.SignatureEncoding == "base64" {
+		got, err = base64.StdEncoding.DecodeString(encoded)
+	} else {
+		got, err = hex.DecodeString(encoded)
+	}
+	return err == nil && hmac.Equal(got, expected)
+}
+
+// EventParams converts a typed trigger event into the generic params map functions receive
+func EventParams(event interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	var params map[string]interface{}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return nil, err
+	}
+	return params, nil
+}
+
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,401 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"lambda-runner-server/models"
+)
+
+const (
+	workflowPollInterval = time.Second
+	// Parallel steps are woken by their branches; this is only a safety net
+	workflowParallelRecheck = time.Minute
+)
+
+// WorkflowRunner drives workflow executions. All state lives in the
+// workflow_steps table: the runner claims due steps, advances each one
+// (invoking, polling, evaluating) and records the transition, so executions
+// continue after a backend restart. A crash between enqueueing a task's
+// invocation and recording it can invoke that task twice.
+type WorkflowRunner struct {
+	db        *DBService
+	functions *FunctionService
+	interval  time.Duration
+	lease     time.Duration
+	batchSize int
+	stopCh    chan struct{}
+	wg        sync.WaitGroup
+}
+
+func NewWorkflowRunner(db *DBService, functions *FunctionService) *WorkflowRunner {
+	return &WorkflowRunner{
+		db:        db,
+		functions: functions,
+		interval:  500 * time.Millisecond,
+		lease:     time.Minute,
+		batchSize: 50,
+		stopCh:    make(chan struct{}),
+	}
+}
+
+func (r *WorkflowRunner) Start() {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.runDue()
+			case <-r.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+func (r *WorkflowRunner) Stop() {
+	close(r.stopCh)
+	r.wg.Wait()
+}
+
+func (r *WorkflowRunner) runDue() {
+	ctx := context.Background()
+	steps, err := r.db.ClaimDueWorkflowSteps(ctx, r.batchSize, r.lease)
+	if err != nil {
+		log.Printf("workflows: failed to claim steps: %v", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, st := range steps {
+		wg.Add(1)
+		go func(st models.WorkflowStep) {
+			defer wg.Done()
+			if err := r.advance(ctx, &st); err != nil {
+				// The lease expires and the step is retried
+				log.Printf("workflows: execution %d step %d (%s): %v", st.ExecutionID, st.ID, st.StateName, err)
+			}
+		}(st)
+	}
+	wg.Wait()
+}
+
+// advance moves one claimed step forward
+func (r *WorkflowRunner) advance(ctx context.Context, st *models.WorkflowStep) error {
+	execution, err := r.db.GetWorkflowExecution(ctx, st.ExecutionID)
+	if err != nil {
+		return err
+	}
+	if execution == nil {
+		return nil
+	}
+	states := flattenWorkflowStates(&execution.Definition)
+	state, ok := states[st.StateName]
+	if !ok {
+		return r.fail(ctx, st, "invalid_definition", fmt.Sprintf("state %q not found", st.StateName))
+	}
+
+	switch state.Type {
+	case models.StateTask:
+		return r.advanceTask(ctx, st, state, states)
+	case models.StateParallel:
+		return r.advanceParallel(ctx, st, state, states)
+	case models.StateChoice:
+		next := state.Default
+		for _, rule := range state.Choices {
+			if matchChoice(&rule, st.Input) {
+				next = rule.Next
+				break
+			}
+		}
+		if next == "" {
+			return r.fail(ctx, st, "no_choice_matched", "no choice rule matched and no default is set")
+		}
+		return r.complete(ctx, st, st.Input, next, states)
+	case models.StatePass:
+		output := st.Input
+		if state.Result != nil {
+			output = applyWorkflowResult(st.Input, state.ResultPath, state.Result)
+		}
+		return r.complete(ctx, st, output, state.Next, states)
+	case models.StateWait:
+		return r.complete(ctx, st, st.Input, state.Next, states)
+	case models.StateSucceed:
+		return r.complete(ctx, st, st.Input, "", states)
+	case models.StateFail:
+		return r.fail(ctx, st, state.Error, state.Cause)
+	}
+	return r.fail(ctx, st, "invalid_definition", fmt.Sprintf("unknown state type %q", state.Type))
+}
+
+// advanceTask invokes the task's function, or checks on the invocation it started
+func (r *WorkflowRunner) advanceTask(ctx context.Context, st *models.WorkflowStep, state models.WorkflowState, states map[string]models.WorkflowState) error {
+	if st.InvocationID == nil {
+		inv, err := r.functions.InvokeFunction(ctx, state.FunctionID, st.Input, InvokeOptions{
+			InvokedBy: fmt.Sprintf("workflow:%d", st.ExecutionID),
+		})
+		var throttleErr *ThrottleError
+		if errors.As(err, &throttleErr) {
+			// Throttling is not a failed attempt; try again once allowed
+			st.NextRunAt = time.Now().Add(throttleErr.RetryAfter)
+			return r.db.RescheduleWorkflowStep(ctx, st)
+		}
+		st.Attempt++
+		if err != nil {
+			return r.handleError(ctx, st, state, states, models.WorkflowErrorInvoke, err.Error())
+		}
+		st.InvocationID = &inv.ID
+		st.NextRunAt = time.Now().Add(workflowPollInterval)
+		return r.db.RescheduleWorkflowStep(ctx, st)
+	}
+
+	inv, err := r.functions.GetInvocationResult(ctx, *st.InvocationID)
+	if err != nil {
+		return err
+	}
+	switch inv.Status {
+	case models.StatusPending:
+		st.NextRunAt = time.Now().Add(workflowPollInterval)
+		return r.db.RescheduleWorkflowStep(ctx, st)
+	case models.StatusSuccess:
+		output := inv.OutputResult
+		if output == nil {
+			output = map[string]interface{}{}
+		}
+		return r.complete(ctx, st, applyWorkflowResult(st.Input, state.ResultPath, output), state.Next, states)
+	}
+	return r.handleError(ctx, st, state, states, inv.Status, inv.ErrorMessage)
+}
+
+// advanceParallel starts the branches of a parallel step, or collects their
+// results once all of them finished
+func (r *WorkflowRunner) advanceParallel(ctx context.Context, st *models.WorkflowStep, state models.WorkflowState, states map[string]models.WorkflowState) error {
+	if st.Attempt == 0 {
+		children := make([]*models.WorkflowStep, len(state.Branches))
+		for i, branch := range state.Branches {
+			children[i] = newWorkflowStep(branch.StartAt, branch.States[branch.StartAt], st.Input, &st.ID, i)
+			children[i].ExecutionID = st.ExecutionID
+		}
+		st.NextRunAt = time.Now().Add(workflowParallelRecheck)
+		return r.db.StartWorkflowBranches(ctx, st, children)
+	}
+
+	finished, err := r.db.ListTerminalChildSteps(ctx, st.ID)
+	if err != nil {
+		return err
+	}
+	outputs := make([]interface{}, len(state.Branches))
+	finishedBranches := make([]bool, len(state.Branches))
+	done := 0
+	for _, child := range finished {
+		if child.Status == models.StepFailed {
+			cause := child.Error
+			if child.Cause != "" {
+				cause += ": " + child.Cause
+			}
+			return r.handleError(ctx, st, state, states, models.WorkflowErrorBranch,
+				fmt.Sprintf("branch %d failed in state %q: %s", child.BranchIndex, child.StateName, cause))
+		}
+		if child.Status == models.StepSucceeded && child.BranchIndex < len(outputs) && !finishedBranches[child.BranchIndex] {
+			outputs[child.BranchIndex] = child.Output
+			finishedBranches[child.BranchIndex] = true
+			done++
+		}
+	}
+	if done < len(state.Branches) {
+		st.NextRunAt = time.Now().Add(workflowParallelRecheck)
+		return r.db.RescheduleWorkflowStep(ctx, st)
+	}
+
+	// Branch outputs are a list, so they are stored under result_path or "branches"
+	resultPath := state.ResultPath
+	if resultPath == "" {
+		resultPath = "branches"
+	}
+	return r.complete(ctx, st, applyWorkflowResult(st.Input, resultPath, outputs), state.Next, states)
+}
+
+// handleError retries a failed task, moves to a catch state, or fails the
+// step and with it the execution or branch
+func (r *WorkflowRunner) handleError(ctx context.Context, st *models.WorkflowStep, state models.WorkflowState, states map[string]models.WorkflowState, errorName, cause string) error {
+	for _, rule := range state.Retry {
+		if !matchWorkflowError(rule.Errors, errorName) {
+			continue
+		}
+		if st.Attempt > rule.MaxAttempts {
+			break
+		}
+		st.InvocationID = nil
+		st.NextRunAt = time.Now().Add(workflowRetryDelay(&rule, st.Attempt))
+		return r.db.RescheduleWorkflowStep(ctx, st)
+	}
+
+	for _, rule := range state.Catch {
+		if !matchWorkflowError(rule.Errors, errorName) {
+			continue
+		}
+		resultPath := rule.ResultPath
+		if resultPath == "" {
+			resultPath = "error"
+		}
+		output := applyWorkflowResult(st.Input, resultPath, map[string]interface{}{
+			"error": errorName,
+			"cause": cause,
+		})
+
+		st.Status = models.StepFailed
+		st.Error = errorName
+		st.Cause = cause
+		st.Output = output
+		next := newWorkflowStep(rule.Next, states[rule.Next], output, st.ParentStepID, st.BranchIndex)
+		next.ExecutionID = st.ExecutionID
+		_, err := r.db.FinishWorkflowStep(ctx, &workflowTransition{Step: st, Next: next})
+		return err
+	}
+
+	return r.fail(ctx, st, errorName, cause)
+}
+
+// complete finishes a step successfully and enters the next state, or ends
+// the branch or execution when there is none
+func (r *WorkflowRunner) complete(ctx context.Context, st *models.WorkflowStep, output map[string]interface{}, next string, states map[string]models.WorkflowState) error {
+	st.Status = models.StepSucceeded
+	st.Output = output
+	t := &workflowTransition{Step: st}
+	if next != "" {
+		t.Next = newWorkflowStep(next, states[next], output, st.ParentStepID, st.BranchIndex)
+		t.Next.ExecutionID = st.ExecutionID
+	} else {
+		st.Terminal = true
+		t.ExecutionStatus = models.ExecutionSucceeded
+		t.ExecutionOutput = output
+	}
+	_, err := r.db.FinishWorkflowStep(ctx, t)
+	return err
+}
+
+// fail finishes a step with an error, failing its branch or execution
+func (r *WorkflowRunner) fail(ctx context.Context, st *models.WorkflowStep, errorName, cause string) error {
+	st.Status = models.StepFailed
+	st.Error = errorName
+	st.Cause = cause
+	st.Terminal = true
+	_, err := r.db.FinishWorkflowStep(ctx, &workflowTransition{Step: st, ExecutionStatus: models.ExecutionFailed})
+	return err
+}
+
+// flattenWorkflowStates indexes the states of a definition and all of its
+// branches by their (unique) name
+func flattenWorkflowStates(def *models.WorkflowDefinition) map[string]models.WorkflowState {
+	states := make(map[string]models.WorkflowState)
+	var walk func(def *models.WorkflowDefinition)
+	walk = func(def *models.WorkflowDefinition) {
+		for name, state := range def.States {
+			states[name] = state
+			for i := range state.Branches {
+				walk(&state.Branches[i])
+			}
+		}
+	}
+	walk(def)
+	return states
+}
+
+// applyWorkflowResult stores a result in a copy of the data under path, or
+// replaces the data with it when path is empty
+func applyWorkflowResult(data map[string]interface{}, path string, result interface{}) map[string]interface{} {
+	if path == "" {
+		if m, ok := result.(map[string]interface{}); ok {
+			return m
+		}
+		path = "result"
+	}
+	output := make(map[string]interface{}, len(data)+1)
+	for k, v := range data {
+		output[k] = v
+	}
+	output[strings.TrimPrefix(path, "$.")] = result
+	return output
+}
+
+func matchWorkflowError(names []string, errorName string) bool {
+	for _, name := range names {
+		if name == models.WorkflowErrorAll || name == errorName {
+			return true
+		}
+	}
+	return false
+}
+
+// workflowRetryDelay is the wait before retry number attempt (1-based)
+func workflowRetryDelay(rule *models.WorkflowRetryRule, attempt int) time.Duration {
+	interval := rule.IntervalSeconds
+	if interval == 0 {
+		interval = 1
+	}
+	rate := rule.BackoffRate
+	if rate == 0 {
+		rate = 2
+	}
+	seconds := float64(interval) * math.Pow(rate, float64(attempt-1))
+	return time.Duration(math.Min(seconds, 3600) * float64(time.Second))
+}
+
+// lookupWorkflowPath resolves a dot path ("$.a.b" or "a.b") in the data
+func lookupWorkflowPath(data map[string]interface{}, path string) (interface{}, bool) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	var current interface{} = data
+	for _, part := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		current, ok = m[part]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func matchChoice(rule *models.WorkflowChoiceRule, data map[string]interface{}) bool {
+	value, present := lookupWorkflowPath(data, rule.Variable)
+	if rule.IsPresent != nil {
+		return present == *rule.IsPresent
+	}
+	if !present {
+		return false
+	}
+
+	switch {
+	case rule.StringEquals != nil:
+		s, ok := value.(string)
+		return ok && s == *rule.StringEquals
+	case rule.BooleanEquals != nil:
+		b, ok := value.(bool)
+		return ok && b == *rule.BooleanEquals
+	}
+
+	n, ok := value.(float64)
+	if !ok {
+		return false
+	}
+	switch {
+	case rule.NumericEquals != nil:
+		return n == *rule.NumericEquals
+	case rule.NumericGreaterThan != nil:
+		return n > *rule.NumericGreaterThan
+	case rule.NumericLessThan != nil:
+		return n < *rule.NumericLessThan
+	}
+	return false
+}
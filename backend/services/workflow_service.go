@@ -0,0 +1,327 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"lambda-runner-server/models"
+)
+
+// WorkflowService manages workflow definitions and starts their executions.
+// Executions are driven by the WorkflowRunner.
+type WorkflowService struct {
+	db        *DBService
+	functions *FunctionService
+}
+
+func NewWorkflowService(db *DBService, functions *FunctionService) *WorkflowService {
+	return &WorkflowService{db: db, functions: functions}
+}
+
+// CreateWorkflow validates and stores a workflow definition
+func (s *WorkflowService) CreateWorkflow(ctx context.Context, req *models.CreateWorkflowRequest) (*models.Workflow, error) {
+	if err := s.validateRequest(ctx, 0, req); err != nil {
+		return nil, err
+	}
+	w, err := s.db.CreateWorkflow(ctx, &models.Workflow{
+		NamespaceID: requestNamespace(ctx),
+		Name:        req.Name,
+		Description: req.Description,
+		Definition:  req.Definition,
+	})
+	if err != nil {
+		return nil, err
+	}
+	audit(ctx, s.db, models.AuditCreate, models.AuditWorkflow, w.ID, nil, w)
+	return w, nil
+}
+
+// UpdateWorkflow replaces a workflow definition. Running executions keep the
+// definition they started with.
+func (s *WorkflowService) UpdateWorkflow(ctx context.Context, id int64, req *models.CreateWorkflowRequest) (*models.Workflow, error) {
+	if err := s.validateRequest(ctx, id, req); err != nil {
+		return nil, err
+	}
+	before, err := s.db.GetWorkflow(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	w, err := s.db.UpdateWorkflow(ctx, &models.Workflow{
+		ID:          id,
+		Name:        req.Name,
+		Description: req.Description,
+		Definition:  req.Definition,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if w == nil {
+		return nil, fmt.Errorf("workflow not found: %d", id)
+	}
+	audit(ctx, s.db, models.AuditUpdate, models.AuditWorkflow, id, before, w)
+	return w, nil
+}
+
+func (s *WorkflowService) validateRequest(ctx context.Context, id int64, req *models.CreateWorkflowRequest) error {
+	if req.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	taken, err := s.db.GetWorkflowByName(ctx, requestNamespace(ctx), req.Name)
+	if err != nil {
+		return err
+	}
+	if taken != nil && taken.ID != id {
+		return ErrWorkflowNameTaken
+	}
+
+	functionIDs := make(map[int64]bool)
+	if err := validateWorkflowDefinition(&req.Definition, make(map[string]bool), functionIDs); err != nil {
+		return err
+	}
+	return s.authorizeTasks(ctx, functionIDs)
+}
+
+// authorizeTasks fails unless the caller may invoke every task function.
+// Executions run in the background with full access, so both saving and
+// starting a workflow check the functions it invokes.
+func (s *WorkflowService) authorizeTasks(ctx context.Context, functionIDs map[int64]bool) error {
+	for functionID := range functionIDs {
+		if _, err := getAuthorizedFunction(ctx, s.db, functionID, models.AccessInvoke); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// workflowFunctionIDs collects the task functions of a definition and its branches
+func workflowFunctionIDs(def *models.WorkflowDefinition, functionIDs map[int64]bool) {
+	for _, state := range def.States {
+		switch state.Type {
+		case models.StateTask:
+			functionIDs[state.FunctionID] = true
+		case models.StateParallel:
+			for i := range state.Branches {
+				workflowFunctionIDs(&state.Branches[i], functionIDs)
+			}
+		}
+	}
+}
+
+// validateWorkflowDefinition checks a definition and its branches. names
+// collects state names, which must be unique across the whole workflow.
+func validateWorkflowDefinition(def *models.WorkflowDefinition, names map[string]bool, functionIDs map[int64]bool) error {
+	if len(def.States) == 0 {
+		return fmt.Errorf("states must not be empty")
+	}
+	if _, ok := def.States[def.StartAt]; !ok {
+		return fmt.Errorf("start_at %q is not a state", def.StartAt)
+	}
+
+	inScope := func(name string) bool {
+		_, ok := def.States[name]
+		return ok
+	}
+
+	for name, state := range def.States {
+		if names[name] {
+			return fmt.Errorf("state name %q is used more than once", name)
+		}
+		names[name] = true
+
+		switch state.Type {
+		case models.StateTask, models.StateParallel, models.StatePass, models.StateWait:
+			if (state.Next == "") == !state.End {
+				return fmt.Errorf("state %q: exactly one of next and end must be set", name)
+			}
+			if state.Next != "" && !inScope(state.Next) {
+				return fmt.Errorf("state %q: next %q is not a state of the same branch", name, state.Next)
+			}
+		case models.StateChoice, models.StateSucceed, models.StateFail:
+			if state.Next != "" || state.End {
+				return fmt.Errorf("state %q: %s states take no next or end", name, state.Type)
+			}
+		default:
+			return fmt.Errorf("state %q: unknown type %q", name, state.Type)
+		}
+
+		if state.Type != models.StateTask && len(state.Retry) > 0 {
+			return fmt.Errorf("state %q: retry is only supported on task states", name)
+		}
+		if state.Type != models.StateTask && state.Type != models.StateParallel && len(state.Catch) > 0 {
+			return fmt.Errorf("state %q: catch is only supported on task and parallel states", name)
+		}
+		for _, rule := range state.Retry {
+			if len(rule.Errors) == 0 || rule.MaxAttempts < 1 || rule.IntervalSeconds < 0 || (rule.BackoffRate != 0 && rule.BackoffRate < 1) {
+				return fmt.Errorf("state %q: retry rules need errors, max_attempts >= 1, interval_seconds >= 0 and backoff_rate >= 1", name)
+			}
+		}
+		for _, rule := range state.Catch {
+			if len(rule.Errors) == 0 || !inScope(rule.Next) {
+				return fmt.Errorf("state %q: catch rules need errors and a next state of the same branch", name)
+			}
+		}
+
+		switch state.Type {
+		case models.StateTask:
+			if state.FunctionID <= 0 {
+				return fmt.Errorf("state %q: function_id is required", name)
+			}
+			functionIDs[state.FunctionID] = true
+		case models.StateParallel:
+			if len(state.Branches) == 0 {
+				return fmt.Errorf("state %q: branches must not be empty", name)
+			}
+			for i := range state.Branches {
+				if err := validateWorkflowDefinition(&state.Branches[i], names, functionIDs); err != nil {
+					return fmt.Errorf("state %q branch %d: %w", name, i, err)
+				}
+			}
+		case models.StateChoice:
+			if len(state.Choices) == 0 {
+				return fmt.Errorf("state %q: choices must not be empty", name)
+			}
+			for i, rule := range state.Choices {
+				if rule.Variable == "" || !inScope(rule.Next) || countChoiceComparisons(&rule) != 1 {
+					return fmt.Errorf("state %q choice %d: needs variable, a next state of the same branch and exactly one comparison", name, i)
+				}
+			}
+			if state.Default != "" && !inScope(state.Default) {
+				return fmt.Errorf("state %q: default %q is not a state of the same branch", name, state.Default)
+			}
+		case models.StateWait:
+			if state.Seconds < 0 {
+				return fmt.Errorf("state %q: seconds must not be negative", name)
+			}
+		}
+	}
+	return nil
+}
+
+func countChoiceComparisons(rule *models.WorkflowChoiceRule) int {
+	n := 0
+	for _, set := range []bool{
+		rule.StringEquals != nil,
+		rule.NumericEquals != nil,
+		rule.NumericGreaterThan != nil,
+		rule.NumericLessThan != nil,
+		rule.BooleanEquals != nil,
+		rule.IsPresent != nil,
+	} {
+		if set {
+			n++
+		}
+	}
+	return n
+}
+
+// GetWorkflow retrieves a workflow by ID
+func (s *WorkflowService) GetWorkflow(ctx context.Context, id int64) (*models.Workflow, error) {
+	w, err := s.db.GetWorkflow(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if w == nil {
+		return nil, fmt.Errorf("workflow not found: %d", id)
+	}
+	return w, nil
+}
+
+// ListWorkflows returns all workflows
+func (s *WorkflowService) ListWorkflows(ctx context.Context) ([]models.Workflow, error) {
+	return s.db.ListWorkflows(ctx)
+}
+
+// DeleteWorkflow removes a workflow and its executions
+func (s *WorkflowService) DeleteWorkflow(ctx context.Context, id int64) error {
+	before, err := s.db.GetWorkflow(ctx, id)
+	if err != nil {
+		return err
+	}
+	deleted, err := s.db.DeleteWorkflow(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return fmt.Errorf("workflow not found: %d", id)
+	}
+	audit(ctx, s.db, models.AuditDelete, models.AuditWorkflow, id, before, nil)
+	return nil
+}
+
+// StartExecution starts a workflow with the given input
+func (s *WorkflowService) StartExecution(ctx context.Context, workflowID int64, input map[string]interface{}) (*models.WorkflowExecution, error) {
+	w, err := s.GetWorkflow(ctx, workflowID)
+	if err != nil {
+		return nil, err
+	}
+	if input == nil {
+		input = map[string]interface{}{}
+	}
+
+	// The workflow may have been saved by someone with more access
+	functionIDs := make(map[int64]bool)
+	workflowFunctionIDs(&w.Definition, functionIDs)
+	if err := s.authorizeTasks(ctx, functionIDs); err != nil {
+		return nil, err
+	}
+
+	first := newWorkflowStep(w.Definition.StartAt, w.Definition.States[w.Definition.StartAt], input, nil, 0)
+	return s.db.StartWorkflowExecution(ctx, &models.WorkflowExecution{
+		WorkflowID: w.ID,
+		Definition: w.Definition,
+		Input:      input,
+	}, first)
+}
+
+// ListExecutions returns the most recent executions of a workflow
+func (s *WorkflowService) ListExecutions(ctx context.Context, workflowID int64, limit int) ([]models.WorkflowExecution, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	return s.db.ListWorkflowExecutions(ctx, workflowID, limit)
+}
+
+// GetExecution returns an execution with its step history
+func (s *WorkflowService) GetExecution(ctx context.Context, id int64) (*models.WorkflowExecutionDetail, error) {
+	e, err := s.db.GetWorkflowExecution(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		return nil, fmt.Errorf("workflow execution not found: %d", id)
+	}
+	steps, err := s.db.ListWorkflowSteps(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &models.WorkflowExecutionDetail{WorkflowExecution: *e, Steps: steps}, nil
+}
+
+// CancelExecution stops a running execution. Invocations already enqueued
+// by its tasks still run to completion.
+func (s *WorkflowService) CancelExecution(ctx context.Context, id int64) (*models.WorkflowExecutionDetail, error) {
+	if _, err := s.db.CancelWorkflowExecution(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.GetExecution(ctx, id)
+}
+
+// newWorkflowStep builds the step entering a state. Wait states start out
+// waiting until their delay elapses; all others are due immediately.
+func newWorkflowStep(name string, state models.WorkflowState, input map[string]interface{}, parentStepID *int64, branchIndex int) *models.WorkflowStep {
+	st := &models.WorkflowStep{
+		ParentStepID: parentStepID,
+		BranchIndex:  branchIndex,
+		StateName:    name,
+		StateType:    state.Type,
+		Status:       models.StepRunning,
+		Input:        input,
+		NextRunAt:    time.Now(),
+	}
+	if state.Type == models.StateWait {
+		st.Status = models.StepWaiting
+		st.NextRunAt = st.NextRunAt.Add(time.Duration(state.Seconds) * time.Second)
+	}
+	return st
+}
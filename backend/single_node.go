@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"lambda-runner-server/handlers"
+	customMiddleware "lambda-runner-server/middleware"
+	sqlitemigrations "lambda-runner-server/migrations/sqlite"
+	"lambda-runner-server/services"
+)
+
+// singleNodeConfig is the part of the configuration a single-node server uses
+type singleNodeConfig struct {
+	dbPath                string
+	serverPort            string
+	redisHost             string
+	redisPort             int
+	storageType           string
+	storageBucket         string
+	adminAPIKey           string
+	callbackSigningSecret string
+	corsAllowOrigins      string
+}
+
+// runSingleNode serves the API from a SQLite database (DB_DRIVER=sqlite).
+// It covers functions with their invocations, batches, grants, callbacks and
+// schedules, and API keys, all in the default namespace. Workflows, the event
+// bus, triggers, namespaces, rate limits, concurrency limits, invocation
+// retention and the audit log API need Postgres and are not served.
+func runSingleNode(cfg singleNodeConfig) {
+	store, err := services.OpenSQLiteStore(cfg.dbPath)
+	if err != nil {
+		log.Fatalf("Failed to open SQLite database: %v", err)
+	}
+	defer store.Close()
+
+	migrationService, err := services.NewSQLiteMigrationService(store, sqlitemigrations.FS)
+	if err != nil {
+		log.Fatalf("Failed to load database migrations: %v", err)
+	}
+
+	// "server migrate up|down [n]|status" manages the schema and exits
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(context.Background(), migrationService, os.Args[2:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+	applyMigrations(context.Background(), migrationService)
+
+	apiKeyService := services.NewAPIKeyService(store)
+	if err := apiKeyService.EnsureBootstrapKey(context.Background(), cfg.adminAPIKey); err != nil {
+		log.Fatalf("Failed to register admin API key: %v", err)
+	}
+
+	storageService, err := services.NewStorageService(cfg.storageType, cfg.storageBucket)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage service: %v", err)
+	}
+	log.Printf("Storage service initialized: %s (%s)", cfg.storageType, cfg.storageBucket)
+
+	redisService := services.NewRedisService(cfg.redisHost, cfg.redisPort)
+	functionService := services.NewFunctionService(store, storageService, redisService)
+	scheduleService := services.NewScheduleService(store)
+
+	resultCollector := services.NewResultCollector(functionService)
+	resultCollector.Start()
+	defer resultCollector.Stop()
+
+	callbackDispatcher := services.NewCallbackDispatcher(store, cfg.callbackSigningSecret)
+	callbackDispatcher.Start()
+	defer callbackDispatcher.Stop()
+
+	scheduleRunner := services.NewScheduleRunner(scheduleService, functionService)
+	scheduleRunner.Start()
+	defer scheduleRunner.Stop()
+
+	app := newApp(cfg.corsAllowOrigins)
+	api := app.Group("/api", customMiddleware.APIKeyAuth(apiKeyService))
+	registerFunctionRoutes(api, handlers.NewFunctionHandler(functionService), handlers.NewScheduleHandler(scheduleService))
+	registerAPIKeyRoutes(api, handlers.NewAPIKeyHandler(apiKeyService))
+
+	log.Printf("SoftGate Server starting on port %s (single node)", cfg.serverPort)
+	log.Printf("Database: sqlite %s", cfg.dbPath)
+	log.Printf("Redis: %s:%d", cfg.redisHost, cfg.redisPort)
+	log.Fatal(app.Listen(":" + cfg.serverPort))
+}
@@ -9,25 +9,48 @@ import {
 } from '../types';
 
 const API_BASE = '/api';
+const API_KEY_STORAGE = 'softgate_api_key';
+
+function apiKey(): string | null {
+  return localStorage.getItem(API_KEY_STORAGE) || import.meta.env.VITE_API_KEY || null;
+}
+
+// fetch with the API key header. On 401 the user is asked for a key once,
+// which is kept in localStorage for later requests.
+async function apiFetch(url: string, init: RequestInit = {}): Promise<Response> {
+  const send = (key: string | null) => {
+    const headers = new Headers(init.headers);
+    if (key) headers.set('X-API-Key', key);
+    return fetch(url, { ...init, headers });
+  };
+
+  const res = await send(apiKey());
+  if (res.status !== 401) return res;
+
+  const key = window.prompt('API key');
+  if (!key) return res;
+  localStorage.setItem(API_KEY_STORAGE, key);
+  return send(key);
+}
 
 export const api = {
   // List all functions
   async listFunctions(): Promise<FunctionListItem[]> {
-    const res = await fetch(`${API_BASE}/functions`);
+    const res = await apiFetch(`${API_BASE}/functions`);
     if (!res.ok) throw new Error('Failed to fetch functions');
     return res.json();
   },
 
   // Get function detail
   async getFunction(id: number): Promise<FunctionDetail> {
-    const res = await fetch(`${API_BASE}/functions/${id}`);
+    const res = await apiFetch(`${API_BASE}/functions/${id}`);
     if (!res.ok) throw new Error('Function not found');
     return res.json();
   },
 
   // Create a new function
   async createFunction(func: CreateFunctionRequest): Promise<FunctionDetail> {
-    const res = await fetch(`${API_BASE}/functions`, {
+    const res = await apiFetch(`${API_BASE}/functions`, {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify(func),
@@ -38,7 +61,7 @@ export const api = {
 
   // Invoke a function
   async invokeFunction(functionId: number, params: Record<string, unknown>): Promise<InvokeResponse> {
-    const res = await fetch(`${API_BASE}/functions/${functionId}/invoke`, {
+    const res = await apiFetch(`${API_BASE}/functions/${functionId}/invoke`, {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify({ params }),
@@ -49,21 +72,21 @@ export const api = {
 
   // Get invocation result (for polling)
   async getInvocationResult(functionId: number, invocationId: number): Promise<InvokeResponse> {
-    const res = await fetch(`${API_BASE}/functions/${functionId}/invocations/${invocationId}`);
+    const res = await apiFetch(`${API_BASE}/functions/${functionId}/invocations/${invocationId}`);
     if (!res.ok) throw new Error('Failed to fetch result');
     return res.json();
   },
 
   // List invocations for a function
   async listInvocations(functionId: number, limit: number = 20): Promise<InvocationListItem[]> {
-    const res = await fetch(`${API_BASE}/functions/${functionId}/invocations?limit=${limit}`);
+    const res = await apiFetch(`${API_BASE}/functions/${functionId}/invocations?limit=${limit}`);
     if (!res.ok) throw new Error('Failed to fetch invocations');
     return res.json();
   },
 
   // Delete a function
   async deleteFunction(id: number): Promise<void> {
-    const res = await fetch(`${API_BASE}/functions/${id}`, {
+    const res = await apiFetch(`${API_BASE}/functions/${id}`, {
       method: 'DELETE',
     });
     if (!res.ok) {
@@ -74,14 +97,14 @@ export const api = {
 
   // List schedules for a function
   async listSchedules(functionId: number): Promise<FunctionSchedule[]> {
-    const res = await fetch(`${API_BASE}/functions/${functionId}/schedules`);
+    const res = await apiFetch(`${API_BASE}/functions/${functionId}/schedules`);
     if (!res.ok) throw new Error('Failed to fetch schedules');
     return res.json();
   },
 
   // Create schedule
   async createSchedule(functionId: number, schedule: CreateScheduleRequest): Promise<FunctionSchedule> {
-    const res = await fetch(`${API_BASE}/functions/${functionId}/schedules`, {
+    const res = await apiFetch(`${API_BASE}/functions/${functionId}/schedules`, {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify(schedule),
@@ -95,7 +118,7 @@ export const api = {
 
   // Delete schedule
   async deleteSchedule(functionId: number, scheduleId: number): Promise<void> {
-    const res = await fetch(`${API_BASE}/functions/${functionId}/schedules/${scheduleId}`, {
+    const res = await apiFetch(`${API_BASE}/functions/${functionId}/schedules/${scheduleId}`, {
       method: 'DELETE',
     });
     if (!res.ok) {
@@ -1,5 +1,6 @@
 import os
 import redis
+import itertools
 import json
 import time
 import sandbox
@@ -12,11 +13,28 @@ r = redis.Redis(host=REDIS_HOST, port=REDIS_PORT, db=0)
 QUEUE_KEY = "execution_queue:c99"
 RESULT_KEY_PREFIX = "result:"
 
+# Priority lanes in priority order: high, normal (plain queue key), low
+LANE_KEYS = [QUEUE_KEY + ":high", QUEUE_KEY, QUEUE_KEY + ":low"]
+# Lanes are drained in weighted order (high 3 : normal 2 : low 1)
+LANE_CYCLE = [0, 0, 0, 1, 1, 2]
+
+
+def pop_job(turn):
+    """Take the next job, preferring the lane whose turn it is."""
+    preferred = LANE_CYCLE[turn % len(LANE_CYCLE)]
+    order = [preferred] + [i for i in range(len(LANE_KEYS)) if i != preferred]
+    for lane in order:
+        raw_data = r.rpop(LANE_KEYS[lane])
+        if raw_data is not None:
+            return LANE_KEYS[lane], raw_data
+    # All lanes empty: block until any lane has work (highest lane first)
+    return r.brpop(LANE_KEYS, timeout=5)
+
 def main():
     print(f"C99 Worker started. Connecting to Redis at {REDIS_HOST}:{REDIS_PORT}")
 
-    while True:
-        item = r.brpop(QUEUE_KEY, timeout=5)
+    for turn in itertools.count():
+        item = pop_job(turn)
 
         if item:
             _, raw_data = item
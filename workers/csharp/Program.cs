@@ -12,6 +12,27 @@ class Program
     private const string ResultKeyPrefix = "result:";
     private static readonly TimeSpan ResultTTL = TimeSpan.FromMinutes(10);
 
+    // Priority lanes in priority order: high, normal (plain queue key), low
+    private static readonly string[] LaneKeys = { $"{QueueKey}:high", QueueKey, $"{QueueKey}:low" };
+    // Lanes are drained in weighted order (high 3 : normal 2 : low 1)
+    private static readonly int[] LaneCycle = { 0, 0, 0, 1, 1, 2 };
+
+    // Take the next job, preferring the lane whose turn it is
+    private static RedisValue PopJob(IDatabase db, long turn)
+    {
+        var preferred = LaneCycle[turn % LaneCycle.Length];
+        var order = new[] { preferred }.Concat(Enumerable.Range(0, LaneKeys.Length).Where(lane => lane != preferred));
+        foreach (var lane in order)
+        {
+            var item = db.ListRightPop(LaneKeys[lane]);
+            if (!item.IsNullOrEmpty)
+            {
+                return item;
+            }
+        }
+        return RedisValue.Null;
+    }
+
     static void Main(string[] args)
     {
         var redisHost = Environment.GetEnvironmentVariable("REDIS_HOST") ?? "localhost";
@@ -24,12 +45,12 @@ class Program
 
         Console.WriteLine("Connected to Redis successfully");
 
-        while (true)
+        for (long turn = 0; ; turn++)
         {
             try
             {
-                // Block and wait for job from queue (timeout 5 seconds)
-                var result = db.ListRightPop(QueueKey);
+                // Take the next job from the lanes, sleeping while all are empty
+                var result = PopJob(db, turn);
 
                 if (result.IsNullOrEmpty)
                 {
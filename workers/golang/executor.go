@@ -46,8 +46,8 @@ func main() {
 `, userCode)
 }
 
-// RunCode executes Go code in a sandbox
-func RunCode(code string, inputData map[string]interface{}) (status, output, logs string) {
+// RunCode executes Go code in a sandbox. Closing cancel kills the running process.
+func RunCode(code string, inputData map[string]interface{}, cancel <-chan struct{}) (status, output, logs string) {
 	status = "SUCCESS"
 
 	// Create temporary work directory
@@ -109,6 +109,14 @@ func RunCode(code string, inputData map[string]interface{}) (status, output, log
 		}
 		status = "TIMEOUT"
 		output = fmt.Sprintf("Execution timed out after %v", ExecutionTimeout)
+	case <-cancel:
+		if cmd.Process != nil {
+			cmd.Process.Kill()
+		}
+		<-done
+		status = "CANCELLED"
+		output = "Execution cancelled"
+		logs = stderr.String()
 	}
 
 	return status, output, logs
@@ -7,6 +7,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -15,9 +16,74 @@ import (
 const (
 	QueueKey        = "execution_queue:golang"
 	ResultKeyPrefix = "result:"
+	CancelKeyPrefix = "cancel:"
 	ResultTTL       = 10 * time.Minute
 )
 
+// Priority lanes in priority order: high, normal (plain queue key), low
+var laneKeys = []string{QueueKey + ":high", QueueKey, QueueKey + ":low"}
+
+// laneCycle drains the lanes in weighted order (high 3 : normal 2 : low 1)
+var laneCycle = []int{0, 0, 0, 1, 1, 2}
+
+// watchCancel subscribes to the cancel channel of an invocation. The returned
+// channel is closed when the backend cancels it; stop ends the subscription.
+func watchCancel(ctx context.Context, rdb *redis.Client, invocationID int64) (cancelled <-chan struct{}, stop func()) {
+	key := CancelKeyPrefix + strconv.FormatInt(invocationID, 10)
+	ch := make(chan struct{})
+	pubsub := rdb.Subscribe(ctx, key)
+
+	var once sync.Once
+	fire := func() { once.Do(func() { close(ch) }) }
+
+	// Wait for the subscription, then re-check the tombstone so a cancel
+	// published just before subscribing is not missed
+	if _, err := pubsub.Receive(ctx); err != nil {
+		log.Printf("Error subscribing to %s: %v", key, err)
+	}
+	if n, err := rdb.Exists(ctx, key).Result(); err == nil && n > 0 {
+		fire()
+	}
+
+	go func() {
+		for range pubsub.Channel() {
+			fire()
+		}
+	}()
+
+	return ch, func() { pubsub.Close() }
+}
+
+// popJob takes the next job, preferring the lane whose turn it is and falling
+// back to the other lanes so no worker slot idles while work is queued
+func popJob(ctx context.Context, rdb *redis.Client, turn int) (string, error) {
+	preferred := laneCycle[turn%len(laneCycle)]
+	order := []int{preferred}
+	for i := range laneKeys {
+		if i != preferred {
+			order = append(order, i)
+		}
+	}
+
+	for _, lane := range order {
+		data, err := rdb.RPop(ctx, laneKeys[lane]).Result()
+		if err == nil {
+			return data, nil
+		}
+		if err != redis.Nil {
+			return "", err
+		}
+	}
+
+	// All lanes empty: block until any lane has work (highest lane first)
+	result, err := rdb.BRPop(ctx, 5*time.Second, laneKeys...).Result()
+	if err != nil {
+		return "", err
+	}
+	// result[0] is the queue key, result[1] is the data
+	return result[1], nil
+}
+
 type ExecutionRequest struct {
 	InvocationID int64                  `json:"invocationId"`
 	Code         string                 `json:"code"`
@@ -60,9 +126,9 @@ func main() {
 	}
 	log.Println("Connected to Redis successfully")
 
-	for {
-		// Block and wait for job from queue
-		result, err := rdb.BRPop(ctx, 5*time.Second, QueueKey).Result()
+	for turn := 0; ; turn++ {
+		// Take the next job, waiting if all lanes are empty
+		rawData, err := popJob(ctx, rdb, turn)
 		if err != nil {
 			if err == redis.Nil {
 				continue // Timeout, no job available
@@ -71,20 +137,26 @@ func main() {
 			continue
 		}
 
-		// result[0] is the queue key, result[1] is the data
-		rawData := result[1]
-
 		var req ExecutionRequest
 		if err := json.Unmarshal([]byte(rawData), &req); err != nil {
 			log.Printf("Error parsing request JSON: %v", err)
 			continue
 		}
 
+		// Skip jobs cancelled while they were queued
+		cancelKey := CancelKeyPrefix + strconv.FormatInt(req.InvocationID, 10)
+		if n, err := rdb.Exists(ctx, cancelKey).Result(); err == nil && n > 0 {
+			log.Printf("Skipping cancelled invocation: %d", req.InvocationID)
+			continue
+		}
+
 		log.Printf("Processing invocation: %d", req.InvocationID)
 
+		cancelled, stopWatch := watchCancel(ctx, rdb, req.InvocationID)
 		startTime := time.Now()
-		status, output, logs := RunCode(req.Code, req.Input)
+		status, output, logs := RunCode(req.Code, req.Input, cancelled)
 		duration := time.Since(startTime).Milliseconds()
+		stopWatch()
 
 		var outputParsed interface{}
 		errorMessage := ""
@@ -4,6 +4,7 @@ import com.google.gson.Gson;
 import redis.clients.jedis.Jedis;
 import redis.clients.jedis.params.SetParams;
 
+import java.util.ArrayList;
 import java.util.HashMap;
 import java.util.List;
 import java.util.Map;
@@ -16,6 +17,31 @@ public class Worker {
     private static final int RESULT_TTL = 600; // 10 minutes
     private static final Gson gson = new Gson();
 
+    // Priority lanes in priority order: high, normal (plain queue key), low
+    private static final String[] LANE_KEYS = {QUEUE_KEY + ":high", QUEUE_KEY, QUEUE_KEY + ":low"};
+    // Lanes are drained in weighted order (high 3 : normal 2 : low 1)
+    private static final int[] LANE_CYCLE = {0, 0, 0, 1, 1, 2};
+
+    // Take the next job, preferring the lane whose turn it is
+    private static List<String> popJob(Jedis jedis, long turn) {
+        int preferred = LANE_CYCLE[(int) (turn % LANE_CYCLE.length)];
+        List<Integer> order = new ArrayList<>();
+        order.add(preferred);
+        for (int lane = 0; lane < LANE_KEYS.length; lane++) {
+            if (lane != preferred) {
+                order.add(lane);
+            }
+        }
+        for (int lane : order) {
+            String rawData = jedis.rpop(LANE_KEYS[lane]);
+            if (rawData != null) {
+                return List.of(LANE_KEYS[lane], rawData);
+            }
+        }
+        // All lanes empty: block until any lane has work (highest lane first)
+        return jedis.brpop(5, LANE_KEYS);
+    }
+
     public static void main(String[] args) {
         System.out.println("Java 21 Worker started. Connecting to Redis at " + REDIS_HOST + ":" + REDIS_PORT);
 
@@ -23,10 +49,10 @@ public class Worker {
             jedis.ping(); // Test connection
             System.out.println("Connected to Redis successfully");
 
-            while (true) {
+            for (long turn = 0; ; turn++) {
                 try {
-                    // Blocking pop from queue (5 second timeout)
-                    List<String> result = jedis.brpop(5, QUEUE_KEY);
+                    // Take the next job, waiting if all lanes are empty (5 second timeout)
+                    List<String> result = popJob(jedis, turn);
 
                     if (result != null && result.size() == 2) {
                         String rawData = result.get(1);
@@ -8,6 +8,25 @@ const QUEUE_KEY = 'execution_queue:javascript';
 const RESULT_KEY_PREFIX = 'result:';
 const RESULT_TTL = 600; // 10 minutes
 
+// Priority lanes in priority order: high, normal (plain queue key), low
+const LANE_KEYS = [`${QUEUE_KEY}:high`, QUEUE_KEY, `${QUEUE_KEY}:low`];
+// Lanes are drained in weighted order (high 3 : normal 2 : low 1)
+const LANE_CYCLE = [0, 0, 0, 1, 1, 2];
+
+// Take the next job, preferring the lane whose turn it is
+async function popJob(client, turn) {
+    const preferred = LANE_CYCLE[turn % LANE_CYCLE.length];
+    const order = [preferred, ...LANE_KEYS.map((_, lane) => lane).filter((lane) => lane !== preferred)];
+    for (const lane of order) {
+        const element = await client.rPop(LANE_KEYS[lane]);
+        if (element !== null) {
+            return { key: LANE_KEYS[lane], element };
+        }
+    }
+    // All lanes empty: block until any lane has work (highest lane first)
+    return client.brPop(LANE_KEYS, 5);
+}
+
 async function main() {
     const client = redis.createClient({
         socket: {
@@ -21,10 +40,10 @@ async function main() {
     await client.connect();
     console.log(`JS Worker started. Connected to Redis at ${REDIS_HOST}:${REDIS_PORT}`);
 
-    while (true) {
+    for (let turn = 0; ; turn++) {
         try {
-            // Blocking pop from queue (5 second timeout)
-            const item = await client.brPop(QUEUE_KEY, 5);
+            // Take the next job, waiting if all lanes are empty (5 second timeout)
+            const item = await popJob(client, turn);
 
             if (item) {
                 const rawData = item.element;
@@ -10,6 +10,25 @@ const val QUEUE_KEY = "execution_queue:kotlin"
 const val RESULT_KEY_PREFIX = "result:"
 const val RESULT_TTL = 600L
 
+// Priority lanes in priority order: high, normal (plain queue key), low
+val LANE_KEYS = arrayOf("$QUEUE_KEY:high", QUEUE_KEY, "$QUEUE_KEY:low")
+// Lanes are drained in weighted order (high 3 : normal 2 : low 1)
+val LANE_CYCLE = intArrayOf(0, 0, 0, 1, 1, 2)
+
+// Take the next job, preferring the lane whose turn it is
+fun popJob(jedis: Jedis, turn: Long): List<String>? {
+    val preferred = LANE_CYCLE[(turn % LANE_CYCLE.size).toInt()]
+    val order = listOf(preferred) + LANE_KEYS.indices.filter { it != preferred }
+    for (lane in order) {
+        val rawData = jedis.rpop(LANE_KEYS[lane])
+        if (rawData != null) {
+            return listOf(LANE_KEYS[lane], rawData)
+        }
+    }
+    // All lanes empty: block until any lane has work (highest lane first)
+    return jedis.brpop(5, *LANE_KEYS)
+}
+
 fun main() {
     val redisHost = System.getenv("REDIS_HOST") ?: REDIS_HOST
     val redisPort = System.getenv("REDIS_PORT")?.toIntOrNull() ?: REDIS_PORT
@@ -22,10 +41,11 @@ fun main() {
         jedis.ping()
         println("Connected to Redis successfully")
 
+        var turn = 0L
         while (true) {
             try {
-                // Blocking pop from queue (5 second timeout)
-                val result = jedis.brpop(5, QUEUE_KEY)
+                // Take the next job, waiting if all lanes are empty (5 second timeout)
+                val result = popJob(jedis, turn++)
 
                 if (result != null && result.size == 2) {
                     val rawData = result[1]
@@ -10,11 +10,30 @@ redis = Redis.new(host: REDIS_HOST, port: REDIS_PORT)
 QUEUE_KEY = "execution_queue:ruby"
 RESULT_KEY_PREFIX = "result:"
 
+# Priority lanes in priority order: high, normal (plain queue key), low
+LANE_KEYS = ["#{QUEUE_KEY}:high", QUEUE_KEY, "#{QUEUE_KEY}:low"].freeze
+# Lanes are drained in weighted order (high 3 : normal 2 : low 1)
+LANE_CYCLE = [0, 0, 0, 1, 1, 2].freeze
+
+# Take the next job, preferring the lane whose turn it is
+def pop_job(redis, turn)
+  preferred = LANE_CYCLE[turn % LANE_CYCLE.size]
+  order = [preferred] + (0...LANE_KEYS.size).reject { |lane| lane == preferred }
+  order.each do |lane|
+    raw_data = redis.rpop(LANE_KEYS[lane])
+    return [LANE_KEYS[lane], raw_data] if raw_data
+  end
+  # All lanes empty: block until any lane has work (highest lane first)
+  redis.brpop(LANE_KEYS, timeout: 5)
+end
+
 puts "Ruby Worker started. Connecting to Redis at #{REDIS_HOST}:#{REDIS_PORT}"
 
+turn = 0
 loop do
   begin
-    result = redis.brpop(QUEUE_KEY, timeout: 5)
+    result = pop_job(redis, turn)
+    turn += 1
     next unless result
 
     _, raw_data = result
@@ -10,6 +10,32 @@ const QUEUE_KEY: &str = "execution_queue:rust";
 const RESULT_KEY_PREFIX: &str = "result:";
 const RESULT_TTL_SECONDS: u64 = 600;
 
+// Lanes are drained in weighted order (high 3 : normal 2 : low 1)
+const LANE_CYCLE: [usize; 6] = [0, 0, 0, 1, 1, 2];
+
+/// Priority lanes in priority order: high, normal (plain queue key), low
+fn lane_keys() -> Vec<String> {
+    vec![
+        format!("{}:high", QUEUE_KEY),
+        QUEUE_KEY.to_string(),
+        format!("{}:low", QUEUE_KEY),
+    ]
+}
+
+/// Take the next job, preferring the lane whose turn it is
+fn pop_job(con: &mut redis::Connection, lanes: &[String], turn: usize) -> Option<(String, String)> {
+    let preferred = LANE_CYCLE[turn % LANE_CYCLE.len()];
+    let order = std::iter::once(preferred).chain((0..lanes.len()).filter(|&lane| lane != preferred));
+    for lane in order {
+        let item: Option<String> = con.rpop(&lanes[lane], None).unwrap_or(None);
+        if let Some(raw_data) = item {
+            return Some((lanes[lane].clone(), raw_data));
+        }
+    }
+    // All lanes empty: block until any lane has work (highest lane first)
+    con.brpop(lanes, 5.0).unwrap_or(None)
+}
+
 #[derive(Debug, Deserialize)]
 struct ExecutionRequest {
     #[serde(rename = "invocationId")]
@@ -59,11 +85,10 @@ fn main() {
 
     eprintln!("Connected to Redis successfully");
 
-    loop {
-        // Block and wait for job from queue (timeout 5 seconds)
-        let result: Option<(String, String)> = con
-            .brpop(QUEUE_KEY, 5.0)
-            .unwrap_or(None);
+    let lanes = lane_keys();
+    for turn in 0.. {
+        // Take the next job, waiting if all lanes are empty (timeout 5 seconds)
+        let result = pop_job(&mut con, &lanes, turn);
 
         if let Some((_, raw_data)) = result {
             let req: ExecutionRequest = match serde_json::from_str(&raw_data) {
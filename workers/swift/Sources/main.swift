@@ -8,6 +8,25 @@ let QUEUE_KEY = "execution_queue:swift"
 let RESULT_KEY_PREFIX = "result:"
 let RESULT_TTL: Int64 = 600
 
+// Priority lanes in priority order: high, normal (plain queue key), low
+let LANE_KEYS = ["\(QUEUE_KEY):high", QUEUE_KEY, "\(QUEUE_KEY):low"]
+// Lanes are drained in weighted order (high 3 : normal 2 : low 1)
+let LANE_CYCLE = [0, 0, 0, 1, 1, 2]
+
+// Take the next job, preferring the lane whose turn it is
+func popJob(_ connection: RedisConnection, turn: Int) async throws -> (RedisKey, RESPValue)? {
+    let preferred = LANE_CYCLE[turn % LANE_CYCLE.count]
+    let order = [preferred] + LANE_KEYS.indices.filter { $0 != preferred }
+    for lane in order {
+        let rawData = try await connection.rpop(from: RedisKey(LANE_KEYS[lane])).get()
+        if !rawData.isNull {
+            return (RedisKey(LANE_KEYS[lane]), rawData)
+        }
+    }
+    // All lanes empty: block until any lane has work (highest lane first)
+    return try await connection.brpop(from: LANE_KEYS.map { RedisKey($0) }, timeout: .seconds(5)).get()
+}
+
 func main() async {
     print("Swift Worker started. Connecting to Redis at \(REDIS_HOST):\(REDIS_PORT)")
 
@@ -22,10 +41,12 @@ func main() async {
 
         print("Connected to Redis successfully")
 
+        var turn = 0
         while true {
             do {
-                // Blocking pop from queue (5 second timeout)
-                let result = try await connection.brpop(from: [RedisKey(QUEUE_KEY)], timeout: .seconds(5)).get()
+                // Take the next job, waiting if all lanes are empty (5 second timeout)
+                let result = try await popJob(connection, turn: turn)
+                turn += 1
 
                 if let (_, rawData) = result {
                     var invocationId: Int64?